@@ -1,6 +1,7 @@
 package contenthash
 
 import (
+	"archive/tar"
 	"context"
 	"fmt"
 	"io"
@@ -552,6 +553,43 @@ func TestSymlinksNoFollow(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestFileChecksum(t *testing.T) {
+	t.Parallel()
+	tmpdir := t.TempDir()
+
+	snapshotter, err := native.NewSnapshotter(filepath.Join(tmpdir, "snapshots"))
+	require.NoError(t, err)
+	cm, cleanup := setupCacheManager(t, tmpdir, "native", snapshotter)
+	t.Cleanup(cleanup)
+
+	ref := createRef(t, cm, []string{
+		"ADD foo file data0",
+	})
+	dgst, err := Checksum(t.Context(), ref, "foo", ChecksumOpts{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, dgstFileData0, dgst)
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "foo",
+		Mode:     0644,
+		Size:     5,
+		ModTime:  time.Now(),
+	}
+	dgst, err = FileChecksum(hdr, strings.NewReader("data0"))
+	require.NoError(t, err)
+	require.Equal(t, dgstFileData0, dgst)
+
+	hdr.Mode = 0755
+	dgst, err = FileChecksum(hdr, strings.NewReader("data0"))
+	require.NoError(t, err)
+	require.NotEqual(t, dgstFileData0, dgst)
+
+	hdr.Typeflag = tar.TypeDir
+	_, err = FileChecksum(hdr, strings.NewReader(""))
+	require.Error(t, err)
+}
+
 func TestChecksumBasicFile(t *testing.T) {
 	t.Parallel()
 	tmpdir := t.TempDir()
@@ -4,11 +4,13 @@ import (
 	"archive/tar"
 	"encoding/hex"
 	"hash"
+	"io"
 	"os"
-	"path/filepath"
-	"time"
+	"strings"
 
 	"github.com/moby/buildkit/util/cachedigest"
+	"github.com/moby/buildkit/util/tarsum"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	fstypes "github.com/tonistiigi/fsutil/types"
 )
@@ -41,26 +43,43 @@ func NewFileHash(path string, fi os.FileInfo) (hash.Hash, error) {
 	return NewFromStat(stat)
 }
 
-func NewFromStat(stat *fstypes.Stat) (hash.Hash, error) {
-	// Clear the socket and irregular bits since archive/tar.FileInfoHeader does not handle them
-	stat.Mode &^= uint32(os.ModeSocket | os.ModeIrregular)
-
-	fi := &statInfo{stat}
-	hdr, err := tar.FileInfoHeader(fi, stat.Linkname)
+// FileChecksum returns the checksum of a regular file read from a tar stream.
+// It is equal to the checksum returned by Checksum for the same file extracted
+// into a ref. Like Checksum, it ignores the modification time of the file.
+func FileChecksum(hdr *tar.Header, r io.Reader) (digest.Digest, error) {
+	if hdr.Typeflag != tar.TypeReg {
+		return "", errors.Errorf("invalid non-regular file %s", hdr.Name)
+	}
+	stat := &fstypes.Stat{
+		Path:    hdr.Name,
+		Mode:    uint32(hdr.FileInfo().Mode()),
+		Size:    hdr.Size,
+		ModTime: hdr.ModTime.UnixNano(),
+		Uid:     uint32(hdr.Uid),
+		Gid:     uint32(hdr.Gid),
+	}
+	for k, v := range hdr.PAXRecords {
+		if name, ok := strings.CutPrefix(k, "SCHILY.xattr."); ok {
+			if stat.Xattrs == nil {
+				stat.Xattrs = map[string][]byte{}
+			}
+			stat.Xattrs[name] = []byte(v)
+		}
+	}
+	h, err := NewFromStat(stat)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to checksum file %s", stat.Path)
+		return "", err
 	}
-	hdr.Name = "" // note: empty name is different from current has in docker build. Name is added on recursive directory scan instead
-	hdr.Devmajor = stat.Devmajor
-	hdr.Devminor = stat.Devminor
-	hdr.Uid = int(stat.Uid)
-	hdr.Gid = int(stat.Gid)
+	if _, err := io.Copy(h, r); err != nil {
+		return "", errors.Wrapf(err, "failed to copy file data for %s", hdr.Name)
+	}
+	return digest.NewDigest(digest.SHA256, h), nil
+}
 
-	if len(stat.Xattrs) > 0 {
-		hdr.PAXRecords = make(map[string]string, len(stat.Xattrs))
-		for k, v := range stat.Xattrs {
-			hdr.PAXRecords["SCHILY.xattr."+k] = string(v)
-		}
+func NewFromStat(stat *fstypes.Stat) (hash.Hash, error) {
+	hdr, err := tarsum.FileHeader(stat)
+	if err != nil {
+		return nil, err
 	}
 	// fmt.Printf("hdr: %#v\n", hdr)
 	h := cachedigest.NewHash(cachedigest.TypeFile)
@@ -93,31 +112,3 @@ func (tsh *tarsumHash) Sum(_ []byte) []byte {
 	b, _ := hex.DecodeString(tsh.Hash.Sum().Hex())
 	return b
 }
-
-type statInfo struct {
-	*fstypes.Stat
-}
-
-func (s *statInfo) Name() string {
-	return filepath.Base(s.Path)
-}
-
-func (s *statInfo) Size() int64 {
-	return s.Stat.Size
-}
-
-func (s *statInfo) Mode() os.FileMode {
-	return os.FileMode(s.Stat.Mode)
-}
-
-func (s *statInfo) ModTime() time.Time {
-	return time.Unix(s.Stat.ModTime/1e9, s.Stat.ModTime%1e9)
-}
-
-func (s *statInfo) IsDir() bool {
-	return s.Mode().IsDir()
-}
-
-func (s *statInfo) Sys() any {
-	return s.Stat
-}
@@ -0,0 +1,570 @@
+//go:build linux
+
+package cache
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/mount"
+	"github.com/containerd/continuity/fs"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/estargz"
+	"github.com/moby/buildkit/util/flightcontrol"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// lazyFiles fetches the payloads of the files of eStargz layers that were
+// extracted without them when the files are first opened. The files are
+// watched with fanotify open permission events, so an open of a file whose
+// payload wasn't fetched yet blocks until the payload was written to it. The
+// events are also generated for the opens of the files through the overlay
+// mounts that use the layer as a lower directory, without a FUSE filesystem.
+type lazyFiles struct {
+	fd int
+
+	mu     sync.Mutex
+	layers map[string]*lazyLayer // by snapshot ID
+	files  map[fileKey]*lazyFile
+	// writers are the threads that open a file to write its payload. Their
+	// own open events are allowed right away.
+	writers map[int]fileKey
+
+	fetchG flightcontrol.Group[struct{}]
+	// addMu serializes the watches, so a layer is only added once
+	addMu sync.Mutex
+}
+
+type fileKey struct {
+	dev, ino uint64
+}
+
+type lazyLayer struct {
+	id      string // snapshot ID
+	dir     string // directory of the snapshot
+	journal string // file listing the names of the fetched files
+	tr      *estargz.TOCReader
+	p       *lazyProvider
+	files   int // files whose payload wasn't fetched yet
+
+	// done is called if the payloads of all the files were fetched
+	done func() error
+}
+
+type lazyFile struct {
+	estargz.LazyFile
+	layer *lazyLayer
+	key   fileKey
+}
+
+var getLazyFiles = sync.OnceValues(newLazyFiles)
+
+func newLazyFiles() (*lazyFiles, error) {
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_CONTENT|unix.FAN_CLOEXEC|unix.FAN_REPORT_TID|unix.FAN_UNLIMITED_MARKS|unix.FAN_UNLIMITED_QUEUE, unix.O_RDONLY|unix.O_LARGEFILE|unix.O_CLOEXEC)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize fanotify")
+	}
+	lf := &lazyFiles{
+		fd:      fd,
+		layers:  map[string]*lazyLayer{},
+		files:   map[fileKey]*lazyFile{},
+		writers: map[int]fileKey{},
+	}
+	go lf.run()
+	return lf, nil
+}
+
+var lazyFilesProbe struct {
+	once sync.Once
+	err  error
+}
+
+// lazyFilesSupported returns true if layers can be extracted into the
+// snapshot directory dir without the payloads of their files. The kernel has
+// to generate the open permission events of the files in a lower directory
+// for their opens through an overlay mount, that is probed once next to dir.
+func lazyFilesSupported(dir string) bool {
+	if _, err := getLazyFiles(); err != nil {
+		return false
+	}
+	lazyFilesProbe.once.Do(func() {
+		lazyFilesProbe.err = probeOverlayOpenPerm(filepath.Dir(dir))
+		if lazyFilesProbe.err != nil {
+			bklog.L.WithError(lazyFilesProbe.err).Warn("lazily pulled layers are extracted with the payloads of all their files")
+		}
+	})
+	return lazyFilesProbe.err == nil
+}
+
+// probeOverlayOpenPerm opens a file through an overlay mount in a temporary
+// directory in dir and fails if no open permission event is generated for
+// the file in the lower directory.
+func probeOverlayOpenPerm(dir string) error {
+	tmp, err := os.MkdirTemp(dir, ".lazyfiles-probe-")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	lower, upper, work, merged := filepath.Join(tmp, "lower"), filepath.Join(tmp, "upper"), filepath.Join(tmp, "work"), filepath.Join(tmp, "merged")
+	for _, d := range []string{lower, upper, work, merged} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(lower, "file"), nil, 0600); err != nil {
+		return errors.WithStack(err)
+	}
+
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_CONTENT|unix.FAN_CLOEXEC|unix.FAN_NONBLOCK, unix.O_RDONLY|unix.O_LARGEFILE|unix.O_CLOEXEC)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize fanotify")
+	}
+	defer unix.Close(fd)
+	if err := unix.FanotifyMark(fd, unix.FAN_MARK_ADD, unix.FAN_OPEN_PERM, unix.AT_FDCWD, filepath.Join(lower, "file")); err != nil {
+		return errors.Wrap(err, "failed to watch probe file")
+	}
+
+	if err := mount.All([]mount.Mount{{
+		Type:    "overlay",
+		Source:  "overlay",
+		Options: []string{"lowerdir=" + lower, "upperdir=" + upper, "workdir=" + work},
+	}}, merged); err != nil {
+		return errors.Wrap(err, "failed to mount overlay")
+	}
+	defer mount.UnmountAll(merged, 0)
+
+	opened := make(chan error, 1)
+	go func() {
+		f, err := os.Open(filepath.Join(merged, "file"))
+		if err == nil {
+			f.Close()
+		}
+		opened <- err
+	}()
+
+	buf := make([]byte, 16*unix.FAN_EVENT_METADATA_LEN)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		n, err := unix.Poll([]unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}, 100)
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			return errors.WithStack(err)
+		}
+		if n > 0 {
+			n, err := unix.Read(fd, buf)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			for off := 0; off+unix.FAN_EVENT_METADATA_LEN <= n; {
+				ev := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[off]))
+				off += int(ev.Event_len)
+				if ev.Fd == unix.FAN_NOFD {
+					continue
+				}
+				var dt [8]byte
+				binary.NativeEndian.PutUint32(dt[:4], uint32(ev.Fd))
+				binary.NativeEndian.PutUint32(dt[4:], unix.FAN_ALLOW)
+				unix.Write(fd, dt[:])
+				unix.Close(int(ev.Fd))
+			}
+			return errors.WithStack(<-opened)
+		}
+		select {
+		case err := <-opened:
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			return errors.New("no open permission events for the files opened through overlay mounts")
+		default:
+		}
+	}
+	return errors.New("timed out waiting for the open permission event of the probe file")
+}
+
+func (sr *immutableRef) watchLazyFiles(ctx context.Context, dir string, desc ocispecs.Descriptor, p content.Provider) error {
+	lf, err := getLazyFiles()
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch the files of layer %s", desc.Digest)
+	}
+	journal := sr.lazyFilesJournal()
+	return lf.watch(ctx, sr.getSnapshotID(), dir, journal, desc, p, func() error {
+		if err := os.Remove(journal); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.WithStack(err)
+		}
+		sr.queueLazyFilesDir("")
+		return sr.commitMetadata()
+	})
+}
+
+func (cr *cacheRecord) forgetLazyFiles() {
+	if cr.getLazyFilesDir() == "" {
+		return
+	}
+	if lf, err := getLazyFiles(); err == nil {
+		lf.forget(cr.getSnapshotID())
+	}
+	os.Remove(cr.lazyFilesJournal())
+}
+
+// watch starts fetching the payloads of the lazy files of the layer desc
+// that was extracted into dir when the files are opened. The names of the
+// fetched files are appended to journal, files listed in it are skipped.
+// Files that were extracted empty are truncated to their size first. If the
+// layer is already watched, only its provider is replaced. done is called if
+// the payloads of all the files were already fetched.
+func (lf *lazyFiles) watch(ctx context.Context, id, dir, journal string, desc ocispecs.Descriptor, p content.Provider, done func() error) error {
+	lf.addMu.Lock()
+	defer lf.addMu.Unlock()
+
+	lf.mu.Lock()
+	l, ok := lf.layers[id]
+	lf.mu.Unlock()
+	if ok {
+		l.p.set(p)
+		return nil
+	}
+	if p == nil {
+		return NeedsRemoteProviderError([]digest.Digest{desc.Digest})
+	}
+
+	lp := &lazyProvider{p: p}
+	tr, err := estargz.OpenTOC(estargz.ConcurrentReaderAt(context.WithoutCancel(ctx), lp, desc), desc)
+	if err != nil {
+		return err
+	}
+	return lf.add(&lazyLayer{
+		id:      id,
+		dir:     dir,
+		journal: journal,
+		tr:      tr,
+		p:       lp,
+		done:    done,
+	})
+}
+
+func (lf *lazyFiles) add(l *lazyLayer) (rerr error) {
+	fetched, err := readJournal(l.journal)
+	if err != nil {
+		return err
+	}
+	files, err := l.tr.LazyFiles()
+	if err != nil {
+		return err
+	}
+
+	var watched []*lazyFile
+	defer func() {
+		if rerr != nil {
+			for _, f := range watched {
+				lf.unmark(filepath.Join(l.dir, f.Name))
+			}
+		}
+	}()
+	for _, f := range files {
+		if _, ok := fetched[f.Name]; ok {
+			continue
+		}
+		p, err := lazyFilePath(l.dir, f.Name)
+		if err != nil {
+			return err
+		}
+		var st unix.Stat_t
+		if err := unix.Lstat(p, &st); err != nil {
+			return errors.Wrapf(err, "failed to stat lazy file %s", f.Name)
+		}
+		if st.Mode&unix.S_IFMT != unix.S_IFREG {
+			return errors.Errorf("lazy file %s is not a regular file", f.Name)
+		}
+		if st.Size == 0 {
+			// extracted empty, the payload is written on the first open
+			if err := unix.Truncate(p, f.Size); err != nil {
+				return errors.Wrapf(err, "failed to truncate lazy file %s", f.Name)
+			}
+			if err := restoreLazyFile(p, f); err != nil {
+				return err
+			}
+		}
+		if err := unix.FanotifyMark(lf.fd, unix.FAN_MARK_ADD|unix.FAN_MARK_DONT_FOLLOW, unix.FAN_OPEN_PERM, unix.AT_FDCWD, p); err != nil {
+			return errors.Wrapf(err, "failed to watch lazy file %s", f.Name)
+		}
+		watched = append(watched, &lazyFile{
+			LazyFile: f,
+			layer:    l,
+			key:      fileKey{dev: uint64(st.Dev), ino: st.Ino},
+		})
+	}
+	if len(watched) == 0 {
+		return l.done()
+	}
+
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	l.files = len(watched)
+	lf.layers[l.id] = l
+	for _, f := range watched {
+		lf.files[f.key] = f
+	}
+	return nil
+}
+
+// forget stops watching the files of the layer, for example because its
+// snapshot is removed.
+func (lf *lazyFiles) forget(id string) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	l, ok := lf.layers[id]
+	if !ok {
+		return
+	}
+	delete(lf.layers, id)
+	for k, f := range lf.files {
+		if f.layer == l {
+			delete(lf.files, k)
+			lf.unmark(filepath.Join(l.dir, f.Name))
+		}
+	}
+}
+
+func (lf *lazyFiles) unmark(p string) {
+	if err := unix.FanotifyMark(lf.fd, unix.FAN_MARK_REMOVE|unix.FAN_MARK_DONT_FOLLOW, unix.FAN_OPEN_PERM, unix.AT_FDCWD, p); err != nil && !errors.Is(err, unix.ENOENT) {
+		bklog.L.WithError(err).Warnf("failed to stop watching lazy file %s", p)
+	}
+}
+
+func (lf *lazyFiles) run() {
+	buf := make([]byte, 64*unix.FAN_EVENT_METADATA_LEN)
+	for {
+		n, err := unix.Read(lf.fd, buf)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) || errors.Is(err, unix.EAGAIN) {
+				continue
+			}
+			bklog.L.WithError(err).Error("failed to read fanotify events, lazy files are not fetched")
+			return
+		}
+		for off := 0; off+unix.FAN_EVENT_METADATA_LEN <= n; {
+			ev := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[off]))
+			off += int(ev.Event_len)
+			if ev.Vers != unix.FANOTIFY_METADATA_VERSION || ev.Fd == unix.FAN_NOFD {
+				continue
+			}
+			lf.handle(int(ev.Fd), int(ev.Pid))
+		}
+	}
+}
+
+// handle answers the open permission event for fd. The open is allowed once
+// the payload of the file was written.
+func (lf *lazyFiles) handle(fd, tid int) {
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		lf.respond(fd, nil)
+		return
+	}
+	key := fileKey{dev: uint64(st.Dev), ino: st.Ino}
+
+	lf.mu.Lock()
+	f := lf.files[key]
+	if w, ok := lf.writers[tid]; ok && w == key {
+		f = nil
+	}
+	lf.mu.Unlock()
+	if f == nil {
+		lf.respond(fd, nil)
+		return
+	}
+	go func() {
+		lf.respond(fd, lf.fetch(f))
+	}()
+}
+
+func (lf *lazyFiles) respond(fd int, err error) {
+	resp := uint32(unix.FAN_ALLOW)
+	if err != nil {
+		bklog.L.WithError(err).Error("failed to fetch lazy file")
+		resp = unix.FAN_DENY
+	}
+	var dt [8]byte
+	binary.NativeEndian.PutUint32(dt[:4], uint32(fd))
+	binary.NativeEndian.PutUint32(dt[4:], resp)
+	if _, err := unix.Write(lf.fd, dt[:]); err != nil {
+		bklog.L.WithError(err).Error("failed to respond to fanotify event")
+	}
+	unix.Close(fd)
+}
+
+func (lf *lazyFiles) fetch(f *lazyFile) error {
+	_, err := lf.fetchG.Do(context.TODO(), strconv.FormatUint(f.key.dev, 10)+":"+strconv.FormatUint(f.key.ino, 10), func(ctx context.Context) (struct{}, error) {
+		lf.mu.Lock()
+		_, ok := lf.files[f.key]
+		lf.mu.Unlock()
+		if !ok {
+			return struct{}{}, nil
+		}
+		p := filepath.Join(f.layer.dir, f.Name)
+		if err := lf.write(p, f); err != nil {
+			return struct{}{}, errors.Wrapf(err, "failed to fetch %s of layer %s", f.Name, f.layer.id)
+		}
+		return struct{}{}, lf.fetched(p, f)
+	})
+	return err
+}
+
+// write writes the payload of the file to p. If that fails, the file is
+// emptied again, so it is fetched on the next open.
+func (lf *lazyFiles) write(p string, f *lazyFile) error {
+	w, err := lf.openWriter(p, f.key)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := f.layer.tr.WriteFile(w, f.Name); err != nil {
+		if err := w.Truncate(0); err == nil {
+			w.Truncate(f.Size)
+		}
+		return err
+	}
+	return restoreLazyFile(p, f.LazyFile)
+}
+
+// openWriter opens the file for writing its payload. The open generates a
+// permission event too, that is allowed for the thread of the caller.
+func (lf *lazyFiles) openWriter(p string, key fileKey) (*os.File, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	tid := unix.Gettid()
+	lf.mu.Lock()
+	lf.writers[tid] = key
+	lf.mu.Unlock()
+	defer func() {
+		lf.mu.Lock()
+		delete(lf.writers, tid)
+		lf.mu.Unlock()
+	}()
+	return os.OpenFile(p, os.O_WRONLY, 0)
+}
+
+// fetched stops watching the file after its payload was written. The layer
+// is forgotten once all its files were fetched, the next watch of the layer
+// finds them all in the journal and calls done.
+func (lf *lazyFiles) fetched(p string, f *lazyFile) error {
+	l := f.layer
+	lf.mu.Lock()
+	if lf.layers[l.id] != l {
+		// forgotten while the file was fetched
+		lf.mu.Unlock()
+		return nil
+	}
+	delete(lf.files, f.key)
+	l.files--
+	if l.files == 0 {
+		delete(lf.layers, l.id)
+	}
+	lf.mu.Unlock()
+
+	lf.unmark(p)
+	return appendJournal(l.journal, f.Name)
+}
+
+// lazyFilePath returns the path of the file name in dir. It fails if any of
+// the parent directories of the file is a symlink.
+func lazyFilePath(dir, name string) (string, error) {
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	parent, err := fs.RootPath(dir, filepath.Dir(filepath.FromSlash(name)))
+	if err != nil {
+		return "", err
+	}
+	if parent != filepath.Dir(p) {
+		return "", errors.Errorf("invalid path of lazy file %s", name)
+	}
+	return p, nil
+}
+
+// restoreLazyFile restores the metadata of the file that are changed by
+// writing to it.
+func restoreLazyFile(p string, f estargz.LazyFile) error {
+	for k, v := range f.Xattrs {
+		if err := unix.Lsetxattr(p, k, v, 0); err != nil {
+			return errors.Wrapf(err, "failed to set xattr %s of %s", k, f.Name)
+		}
+	}
+	ts, err := unix.TimeToTimespec(f.ModTime)
+	if err != nil {
+		return err
+	}
+	if err := unix.UtimesNanoAt(unix.AT_FDCWD, p, []unix.Timespec{ts, ts}, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		return errors.Wrapf(err, "failed to set mtime of %s", f.Name)
+	}
+	return nil
+}
+
+func readJournal(p string) (map[string]struct{}, error) {
+	names := map[string]struct{}{}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return names, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// a line cut short by a crash is skipped
+		if name, err := strconv.Unquote(s.Text()); err == nil {
+			names[name] = struct{}{}
+		}
+	}
+	return names, errors.WithStack(s.Err())
+}
+
+func appendJournal(p, name string) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := f.WriteString(strconv.Quote(name) + "\n"); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Close())
+}
+
+// lazyProvider passes reads to a provider that can be replaced, so the
+// payloads are fetched with the credentials of the latest session.
+type lazyProvider struct {
+	mu sync.Mutex
+	p  content.Provider
+}
+
+func (lp *lazyProvider) set(p content.Provider) {
+	if p == nil {
+		return
+	}
+	lp.mu.Lock()
+	lp.p = p
+	lp.mu.Unlock()
+}
+
+func (lp *lazyProvider) ReaderAt(ctx context.Context, desc ocispecs.Descriptor) (content.ReaderAt, error) {
+	lp.mu.Lock()
+	p := lp.p
+	lp.mu.Unlock()
+	return p.ReaderAt(ctx, desc)
+}
@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/mount"
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/moby/buildkit/util/testutil"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazyFiles(t *testing.T) {
+	lf, err := getLazyFiles()
+	if err != nil {
+		t.Skipf("lazy files not supported: %v", err)
+	}
+
+	files := map[string]string{
+		"prio":      "prio contents",
+		"dir/lazy":  "lazy contents",
+		"dir/lazy2": "lazy2 contents",
+	}
+	layer := tarLayer(t, files)
+	blob, err := estargz.Build(io.NewSectionReader(bytes.NewReader(layer), 0, int64(len(layer))), estargz.WithPrioritizedFiles([]string{"prio"}), estargz.WithCompression(testutil.EStargzCompression()))
+	require.NoError(t, err)
+	defer blob.Close()
+	dt, err := io.ReadAll(blob)
+	require.NoError(t, err)
+	desc := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(dt),
+		Size:      int64(len(dt)),
+		Annotations: map[string]string{
+			estargz.TOCJSONDigestAnnotation: blob.TOCDigest().String(),
+		},
+	}
+	p := &testBlobProvider{dt: dt}
+
+	_, dir, err := extractLazyPull(context.TODO(), p, desc, lazyFilesSupported, func(apply func([]mount.Mount) error) (string, error) {
+		dir := t.TempDir()
+		return dir, apply([]mount.Mount{{Type: "bind", Source: dir, Options: []string{"rbind"}}})
+	}, func([]mount.Mount) error {
+		return errors.New("unexpected full pull")
+	})
+	require.NoError(t, err)
+	if dir == "" {
+		t.Skipf("lazy files not supported: %v", lazyFilesProbe.err)
+	}
+
+	// the prioritized file is extracted, the other ones only have their size
+	// after they are watched
+	dt, err = os.ReadFile(filepath.Join(dir, "prio"))
+	require.NoError(t, err)
+	require.Equal(t, files["prio"], string(dt))
+	for _, name := range []string{"dir/lazy", "dir/lazy2"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Zero(t, fi.Size())
+	}
+
+	id := t.Name()
+	journal := filepath.Join(t.TempDir(), "journal")
+	done := 0
+	watch := func() error {
+		return lf.watch(context.TODO(), id, dir, journal, desc, p, func() error {
+			done++
+			return nil
+		})
+	}
+	t.Cleanup(func() { lf.forget(id) })
+
+	require.NoError(t, watch())
+	require.Zero(t, done)
+	for _, name := range []string{"dir/lazy", "dir/lazy2"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.Equal(t, int64(len(files[name])), fi.Size())
+	}
+
+	// watching again, for example after a restart, doesn't refetch
+	require.NoError(t, watch())
+	require.Zero(t, done)
+
+	// a file is fetched when it is opened through an overlay mount that uses
+	// the snapshot as a lower directory, like the root of an exec
+	merged := t.TempDir()
+	upper, work := t.TempDir(), t.TempDir()
+	require.NoError(t, mount.All([]mount.Mount{{
+		Type:    "overlay",
+		Source:  "overlay",
+		Options: []string{"lowerdir=" + dir, "upperdir=" + upper, "workdir=" + work},
+	}}, merged))
+	defer mount.UnmountAll(merged, 0)
+	dt, err = os.ReadFile(filepath.Join(merged, "dir/lazy"))
+	require.NoError(t, err)
+	require.Equal(t, files["dir/lazy"], string(dt))
+
+	fetched, err := readJournal(journal)
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{"dir/lazy": {}}, fetched)
+
+	// and when it is opened directly
+	dt, err = os.ReadFile(filepath.Join(dir, "dir/lazy2"))
+	require.NoError(t, err)
+	require.Equal(t, files["dir/lazy2"], string(dt))
+
+	fetched, err = readJournal(journal)
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{"dir/lazy": {}, "dir/lazy2": {}}, fetched)
+
+	// all files were fetched, the next watch finds them in the journal
+	require.NoError(t, watch())
+	require.Equal(t, 1, done)
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package cache
+
+import (
+	"context"
+
+	"github.com/containerd/containerd/v2/core/content"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+func lazyFilesSupported(string) bool {
+	return false
+}
+
+func (sr *immutableRef) watchLazyFiles(_ context.Context, _ string, desc ocispecs.Descriptor, _ content.Provider) error {
+	return errors.Errorf("fetching the files of layer %s on demand is unsupported", desc.Digest)
+}
+
+func (cr *cacheRecord) forgetLazyFiles() {}
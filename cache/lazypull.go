@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"context"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/diff/apply"
+	"github.com/containerd/containerd/v2/core/mount"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/estargz"
+	"github.com/moby/buildkit/util/overlay"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// lazyPullParallelism is the number of files of a layer that are fetched
+// concurrently when extracting it through its TOC.
+const lazyPullParallelism = 8
+
+// useLazyPull returns true if the layer can be extracted by ranged reads
+// through its eStargz TOC instead of pulling the full blob first.
+func (sr *immutableRef) useLazyPull(desc ocispecs.Descriptor, dh *DescHandler) bool {
+	return dh != nil && dh.LazyPull &&
+		sr.cm.Snapshotter.Name() == "overlayfs" &&
+		sr.GetLayerType() != "windows" &&
+		estargz.HasTOC(desc)
+}
+
+// extractLazyPull extracts the layer into a new snapshot on top of parentID
+// by reading its TOC directly from the remote, without storing the blob in
+// the content store. Only the payloads of the files that the layer lists for
+// prefetching are extracted, the other regular files are extracted empty and
+// the directory of the snapshot is returned. Their payloads are fetched by
+// ranged reads when they are first opened, see ensureLazyFiles. If the opens
+// of the files through overlay mounts can't be watched, all the payloads are
+// extracted by ranged reads instead. The blob stays lazy and is only pulled if it is needed later, for
+// example on export. If the layer can't be extracted this way, because it
+// isn't eStargz, the remote rejects ranged requests or a payload fails to be
+// fetched or verified, the partially extracted snapshot is removed and the
+// full blob is pulled and applied to a new snapshot.
+func (sr *immutableRef) extractLazyPull(ctx context.Context, desc ocispecs.Descriptor, dh *DescHandler, s session.Group, parentID string) (string, string, error) {
+	extract := func(apply func([]mount.Mount) error) (string, error) {
+		return sr.extract(ctx, parentID, apply)
+	}
+	return extractLazyPull(ctx, dh.Provider(s), desc, lazyFilesSupported, extract, func(mounts []mount.Mount) error {
+		if err := (lazyRefProvider{ref: sr, desc: desc, dh: dh, session: s}).Unlazy(ctx); err != nil {
+			return err
+		}
+		_, err := sr.cm.Applier.Apply(ctx, desc, mounts)
+		return err
+	})
+}
+
+// extractLazyPull calls extract to apply the layer to a new snapshot through
+// its TOC, and calls it again with fullPull if that fails. extract is
+// expected to remove the snapshot if the apply function fails. If the
+// directory of the snapshot can be determined and lazy returns true for it,
+// the payloads of the files that aren't prefetched are skipped and that
+// directory is returned.
+func extractLazyPull(ctx context.Context, p content.Provider, desc ocispecs.Descriptor, lazy func(dir string) bool, extract func(func([]mount.Mount) error) (string, error), fullPull func([]mount.Mount) error) (string, string, error) {
+	var dir string
+	key, err := extract(func(mounts []mount.Mount) error {
+		if d := snapshotDir(mounts); d != "" && lazy != nil && lazy(d) {
+			dir = d
+		}
+		return applyLazyPull(ctx, p, desc, mounts, dir != "")
+	})
+	if err == nil || ctx.Err() != nil {
+		return key, dir, err
+	}
+	bklog.G(ctx).WithError(err).Debugf("falling back to full pull of %s", desc.Digest)
+	key, err = extract(fullPull)
+	return key, "", err
+}
+
+func applyLazyPull(ctx context.Context, p content.Provider, desc ocispecs.Descriptor, mounts []mount.Mount, prefetch bool) error {
+	tr, err := estargz.OpenTOC(estargz.ConcurrentReaderAt(ctx, p, desc), desc)
+	if err != nil {
+		return err
+	}
+
+	var rc io.ReadCloser
+	if prefetch {
+		rc = tr.PrefetchTar(ctx, lazyPullParallelism)
+	} else {
+		rc = tr.Tar(ctx, lazyPullParallelism)
+	}
+	defer rc.Close()
+
+	_, err = apply.NewFileSystemApplier(&tarProvider{dgst: desc.Digest, rc: rc}).Apply(ctx, ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageLayer,
+		Digest:    desc.Digest,
+	}, mounts)
+	return err
+}
+
+// snapshotDir returns the directory that the files written to the mounts of
+// an active snapshot are stored in, or an empty string if it can't be
+// determined.
+func snapshotDir(mounts []mount.Mount) string {
+	if len(mounts) != 1 {
+		return ""
+	}
+	m := mounts[0]
+	if m.Type == "bind" {
+		return m.Source
+	}
+	if overlay.IsOverlayMountType(m) {
+		for _, o := range m.Options {
+			if dir, ok := strings.CutPrefix(o, "upperdir="); ok {
+				return dir
+			}
+		}
+	}
+	return ""
+}
+
+// ensureLazyFiles makes sure that the payloads of the files of the layers of
+// the ref that were extracted without them are fetched when they are opened.
+// The files are watched again with the provider of the current session, for
+// example after a restart.
+func (sr *immutableRef) ensureLazyFiles(ctx context.Context, s session.Group) error {
+	for _, r := range sr.layerChain() {
+		dir := r.getLazyFilesDir()
+		if dir == "" {
+			continue
+		}
+		desc, err := r.ociDesc(ctx, sr.descHandlers, true)
+		if err != nil {
+			return err
+		}
+		var p content.Provider
+		if dh := sr.descHandlers[desc.Digest]; dh != nil {
+			p = dh.Provider(s)
+		} else if _, err := sr.cm.ContentStore.Info(ctx, desc.Digest); err == nil {
+			p = sr.cm.ContentStore
+		}
+		if err := r.watchLazyFiles(ctx, dir, desc, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cr *cacheRecord) lazyFilesJournal() string {
+	return filepath.Join(cr.cm.root, "lazyfiles", cr.ID())
+}
+
+// tarProvider serves a single uncompressed tar stream of unknown size to the
+// applier, which only reads it sequentially.
+type tarProvider struct {
+	dgst digest.Digest
+	rc   io.ReadCloser
+}
+
+func (p *tarProvider) ReaderAt(ctx context.Context, desc ocispecs.Descriptor) (content.ReaderAt, error) {
+	if desc.Digest != p.dgst {
+		return nil, cerrdefs.ErrNotFound
+	}
+	return &streamReaderAt{rc: p.rc}, nil
+}
+
+type streamReaderAt struct {
+	rc     io.ReadCloser
+	offset int64
+}
+
+func (r *streamReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	if off != r.offset {
+		return 0, cerrdefs.ErrNotImplemented.WithMessage("non-sequential read of tar stream")
+	}
+	n, err := r.rc.Read(b)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *streamReaderAt) Size() int64 {
+	return math.MaxInt64
+}
+
+func (r *streamReaderAt) Close() error {
+	return r.rc.Close()
+}
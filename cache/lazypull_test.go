@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/mount"
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/moby/buildkit/util/testutil"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractLazyPull(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("unsupported GOOS: %s", runtime.GOOS)
+	}
+	t.Parallel()
+
+	files := map[string]string{
+		"foo":     "foo contents",
+		"dir/bar": "bar contents",
+	}
+	layer := tarLayer(t, files)
+
+	esgz := bytes.NewBuffer(nil)
+	w := estargz.NewWriterWithCompressor(esgz, testutil.EStargzCompression())
+	require.NoError(t, w.AppendTar(bytes.NewReader(layer)))
+	tocDgst, err := w.Close()
+	require.NoError(t, err)
+	esgzDesc := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(esgz.Bytes()),
+		Size:      int64(esgz.Len()),
+		Annotations: map[string]string{
+			estargz.TOCJSONDigestAnnotation: tocDgst.String(),
+		},
+	}
+
+	gz := bytes.NewBuffer(nil)
+	gw := gzip.NewWriter(gz)
+	_, err = gw.Write(layer)
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	gzDesc := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageLayerGzip,
+		Digest:    digest.FromBytes(gz.Bytes()),
+		Size:      int64(gz.Len()),
+		Annotations: map[string]string{
+			// the annotation is set but the blob is not eStargz
+			estargz.TOCJSONDigestAnnotation: tocDgst.String(),
+		},
+	}
+
+	// a payload that fails verification is only detected after the TOC was
+	// read and some files may have been extracted
+	esgzReader, err := estargz.Open(io.NewSectionReader(bytes.NewReader(esgz.Bytes()), 0, int64(esgz.Len())))
+	require.NoError(t, err)
+	entry, ok := esgzReader.Lookup("foo")
+	require.True(t, ok)
+	corrupted := bytes.Clone(esgz.Bytes())
+	corrupted[entry.Offset+1] ^= 0xff
+
+	for _, tc := range []struct {
+		name     string
+		provider *testBlobProvider
+		desc     ocispecs.Descriptor
+		fullPull bool
+	}{
+		{
+			name:     "estargz",
+			provider: &testBlobProvider{dt: esgz.Bytes()},
+			desc:     esgzDesc,
+		},
+		{
+			name:     "not estargz",
+			provider: &testBlobProvider{dt: gz.Bytes()},
+			desc:     gzDesc,
+			fullPull: true,
+		},
+		{
+			name:     "ranges rejected",
+			provider: &testBlobProvider{dt: esgz.Bytes(), noRanges: true},
+			desc:     esgzDesc,
+			fullPull: true,
+		},
+		{
+			name:     "corrupted payload",
+			provider: &testBlobProvider{dt: corrupted},
+			desc:     esgzDesc,
+			fullPull: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// each extraction gets a new snapshot that is removed on failure
+			var dirs []string
+			extract := func(apply func([]mount.Mount) error) (string, error) {
+				dir := t.TempDir()
+				if err := apply([]mount.Mount{{Type: "bind", Source: dir, Options: []string{"rbind"}}}); err != nil {
+					require.NoError(t, os.RemoveAll(dir))
+					return "", err
+				}
+				dirs = append(dirs, dir)
+				return dir, nil
+			}
+			fullPulled := false
+			dir, lazyDir, err := extractLazyPull(context.TODO(), tc.provider, tc.desc, nil, extract, func(mounts []mount.Mount) error {
+				fullPulled = true
+				return writeFiles(mounts[0].Source, files)
+			})
+			require.NoError(t, err)
+			require.Empty(t, lazyDir)
+			require.Equal(t, tc.fullPull, fullPulled)
+			require.Equal(t, []string{dir}, dirs)
+			for name, data := range files {
+				dt, err := os.ReadFile(filepath.Join(dir, name))
+				require.NoError(t, err)
+				require.Equal(t, data, string(dt))
+			}
+		})
+	}
+}
+
+func writeFiles(dir string, files map[string]string) error {
+	for name, data := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tarLayer(t *testing.T, files map[string]string) []byte {
+	buf := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for name, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))}))
+		_, err := tw.Write([]byte(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+// testBlobProvider serves a single blob from memory. With noRanges set it
+// behaves like a registry that rejects ranged requests.
+type testBlobProvider struct {
+	dt       []byte
+	noRanges bool
+}
+
+func (p *testBlobProvider) ReaderAt(ctx context.Context, desc ocispecs.Descriptor) (content.ReaderAt, error) {
+	return &testBlobReaderAt{Reader: bytes.NewReader(p.dt), noRanges: p.noRanges}, nil
+}
+
+type testBlobReaderAt struct {
+	*bytes.Reader
+	noRanges bool
+}
+
+func (r *testBlobReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	if r.noRanges && off > 0 {
+		return 0, errors.New("unexpected status code: range requests are not supported")
+	}
+	return r.Reader.ReadAt(b, off)
+}
+
+func (r *testBlobReaderAt) Close() error {
+	return nil
+}
@@ -839,9 +839,18 @@ func (cm *cacheManager) createMergeRef(ctx context.Context, parents parentRefs,
 }
 
 func (cm *cacheManager) Diff(ctx context.Context, lower, upper ImmutableRef, pg progress.Controller, opts ...RefOption) (ir ImmutableRef, rerr error) {
-	if lower == nil {
+	var computeDiff bool
+	for _, o := range opts {
+		if o == ComputeDiff {
+			computeDiff = true
+		}
+	}
+	if lower == nil && !computeDiff {
 		return nil, errors.New("lower ref for diff cannot be nil")
 	}
+	if upper == nil && computeDiff {
+		return nil, errors.New("upper ref for computed diff cannot be nil")
+	}
 
 	var dps diffParents
 	parents := parentRefs{diffParents: &dps}
@@ -880,9 +889,9 @@ func (cm *cacheManager) Diff(ctx context.Context, lower, upper ImmutableRef, pg
 	// Check to see if lower is an ancestor of upper. If so, define the diff as a merge
 	// of the layers separating the two. This can result in a different diff than just
 	// running the differ directly on lower and upper, but this is chosen as a default
-	// behavior in order to maximize layer re-use in the default case. We may add an
-	// option for controlling this behavior in the future if it's needed.
-	if dps.upper != nil {
+	// behavior in order to maximize layer re-use in the default case. ComputeDiff
+	// disables it.
+	if dps.upper != nil && !computeDiff {
 		lowerLayers := dps.lower.layerChain()
 		upperLayers := dps.upper.layerChain()
 		var lowerIsAncestor bool
@@ -941,8 +950,10 @@ func (cm *cacheManager) Diff(ctx context.Context, lower, upper ImmutableRef, pg
 
 func (cm *cacheManager) createDiffRef(ctx context.Context, parents parentRefs, dhs DescHandlers, pg progress.Controller, opts ...RefOption) (ir *immutableRef, rerr error) {
 	dps := parents.diffParents
-	if err := dps.lower.Finalize(ctx); err != nil {
-		return nil, errors.Wrapf(err, "failed to finalize lower parent during diff")
+	if dps.lower != nil {
+		if err := dps.lower.Finalize(ctx); err != nil {
+			return nil, errors.Wrapf(err, "failed to finalize lower parent during diff")
+		}
 	}
 	if dps.upper != nil {
 		if err := dps.upper.Finalize(ctx); err != nil {
@@ -1529,6 +1540,13 @@ type noUpdateLastUsed struct{}
 
 var NoUpdateLastUsed noUpdateLastUsed
 
+type computeDiff struct{}
+
+// ComputeDiff makes Diff always produce a single layer computed between lower
+// and upper, even if lower is an ancestor of upper. A nil lower is treated as
+// scratch, so all layers of upper are squashed into one.
+var ComputeDiff computeDiff
+
 func CachePolicyRetain(m *cacheMetadata) error {
 	return m.SetCachePolicyRetain()
 }
@@ -2304,6 +2304,45 @@ func TestDiffOp(t *testing.T) {
 	checkDiskUsage(ctx, t, cm, 0, 2)
 	require.NoError(t, cm.Prune(ctx, nil, client.PruneInfo{All: true}))
 	checkDiskUsage(ctx, t, cm, 0, 0)
+
+	// test computed diffs that are not split into single-layer diffs
+	newRef, err = cm.New(ctx, nil, nil)
+	require.NoError(t, err)
+	a, err = newRef.Commit(ctx)
+	require.NoError(t, err)
+	newRef, err = cm.New(ctx, a, nil)
+	require.NoError(t, err)
+	b, err = newRef.Commit(ctx)
+	require.NoError(t, err)
+	newRef, err = cm.New(ctx, b, nil)
+	require.NoError(t, err)
+	c, err = newRef.Commit(ctx)
+	require.NoError(t, err)
+
+	diff, err = cm.Diff(ctx, a, c, nil, ComputeDiff)
+	require.NoError(t, err)
+	chain := diff.LayerChain()
+	require.Len(t, chain, 1)
+	require.NoError(t, chain.Release(ctx))
+	checkDiskUsage(ctx, t, cm, 4, 0) // 3 base refs + 1 diff
+	squashed, err := cm.Diff(ctx, nil, c, nil, ComputeDiff)
+	require.NoError(t, err)
+	chain = squashed.LayerChain()
+	require.Len(t, chain, 1)
+	require.NoError(t, chain.Release(ctx))
+	checkDiskUsage(ctx, t, cm, 5, 0)
+	_, err = cm.Diff(ctx, nil, nil, nil, ComputeDiff)
+	require.Error(t, err)
+	require.NoError(t, a.Release(ctx))
+	require.NoError(t, b.Release(ctx))
+	require.NoError(t, c.Release(ctx))
+	_, err = squashed.Mount(ctx, true, nil)
+	require.NoError(t, err)
+	require.NoError(t, diff.Release(ctx))
+	require.NoError(t, squashed.Release(ctx))
+	checkDiskUsage(ctx, t, cm, 0, 5)
+	require.NoError(t, cm.Prune(ctx, nil, client.PruneInfo{All: true}))
+	checkDiskUsage(ctx, t, cm, 0, 0)
 }
 
 func TestLoadHalfFinalizedRef(t *testing.T) {
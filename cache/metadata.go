@@ -38,6 +38,7 @@ const keyImageRefs = "cache.imageRefs"
 const keyDeleted = "cache.deleted"
 const keyBlobSize = "cache.blobsize" // the packed blob size as specified in the oci descriptor
 const keyURLs = "cache.layer.urls"
+const keyLazyFilesDir = "cache.lazyfiles.dir" // the snapshot directory if files were extracted without their payloads
 
 // Indexes
 const blobchainIndex = "blobchainid:"
@@ -312,6 +313,14 @@ func (md *cacheMetadata) getBlobOnly() bool {
 	return md.getBool(keyBlobOnly)
 }
 
+func (md *cacheMetadata) queueLazyFilesDir(dir string) error {
+	return md.queueValue(keyLazyFilesDir, dir, "")
+}
+
+func (md *cacheMetadata) getLazyFilesDir() string {
+	return md.GetString(keyLazyFilesDir)
+}
+
 func (md *cacheMetadata) queueDeleted() error {
 	return md.queueValue(keyDeleted, true, "")
 }
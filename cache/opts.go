@@ -15,6 +15,7 @@ type DescHandler struct {
 	SnapshotLabels map[string]string
 	Annotations    map[string]string
 	Ref            string // string representation of desc origin, can be used as a sync key
+	LazyPull       bool   // fetch the files of layers with a TOC from Provider when they are opened
 }
 
 type DescHandlers map[digest.Digest]*DescHandler
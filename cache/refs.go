@@ -469,6 +469,7 @@ func (cr *cacheRecord) remove(ctx context.Context, removeSnapshot bool) (rerr er
 	}()
 	delete(cr.cm.records, cr.ID())
 	if removeSnapshot {
+		cr.forgetLazyFiles()
 		if err := cr.cm.LeaseManager.Delete(ctx, leases.Lease{
 			ID: cr.ID(),
 		}); err != nil && !cerrdefs.IsNotFound(err) {
@@ -976,6 +977,9 @@ func (sr *immutableRef) Mount(ctx context.Context, readonly bool, s session.Grou
 	if err := sr.Extract(ctx, s); err != nil {
 		return nil, err
 	}
+	if err := sr.ensureLazyFiles(ctx, s); err != nil {
+		return nil, err
+	}
 
 	sr.mu.Lock()
 	defer sr.mu.Unlock()
@@ -1360,15 +1364,18 @@ func (sr *immutableRef) unlazyLayer(ctx context.Context, dhs DescHandlers, pg pr
 	}
 	dh := dhs[desc.Digest]
 
-	eg.Go(func() error {
-		// unlazies if needed, otherwise a no-op
-		return lazyRefProvider{
-			ref:     sr,
-			desc:    desc,
-			dh:      dh,
-			session: s,
-		}.Unlazy(egctx)
-	})
+	lazyPull := sr.useLazyPull(desc, dh)
+	if !lazyPull {
+		eg.Go(func() error {
+			// unlazies if needed, otherwise a no-op
+			return lazyRefProvider{
+				ref:     sr,
+				desc:    desc,
+				dh:      dh,
+				session: s,
+			}.Unlazy(egctx)
+		})
+	}
 
 	if err := eg.Wait(); err != nil {
 		return err
@@ -1389,8 +1396,42 @@ func (sr *immutableRef) unlazyLayer(ctx context.Context, dhs DescHandlers, pg pr
 	))
 	defer sp.End()
 
+	var key, lazyFilesDir string
+	if lazyPull {
+		key, lazyFilesDir, err = sr.extractLazyPull(ctx, desc, dh, s, parentID)
+	} else {
+		key, err = sr.extract(ctx, parentID, func(mounts []mount.Mount) error {
+			_, err := sr.cm.Applier.Apply(ctx, desc, mounts)
+			return err
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := sr.cm.Snapshotter.Commit(ctx, sr.getSnapshotID(), key); err != nil {
+		if !errors.Is(err, cerrdefs.ErrAlreadyExists) {
+			return err
+		}
+	}
+	sr.queueBlobOnly(false)
+	sr.queueSize(sizeUnknown)
+	if lazyFilesDir != "" {
+		sr.queueLazyFilesDir(lazyFilesDir)
+	}
+	if err := sr.commitMetadata(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// extract prepares a new snapshot on top of parentID, applies the layer to
+// its mounts and returns the key of the snapshot. The snapshot is removed if
+// the layer can't be applied.
+func (sr *immutableRef) extract(ctx context.Context, parentID string, apply func([]mount.Mount) error) (_ string, rerr error) {
 	key := fmt.Sprintf("extract-%s %s", identity.NewID(), sr.getChainID())
 
+	var err error
 	if sr.cm.Snapshotter.Name() == "overlaybd" {
 		err = sr.cm.Snapshotter.Prepare(ctx, key, parentID,
 			snapshots.WithLabels(map[string]string{"containerd.io/snapshot.ref": string(sr.getChainID())}))
@@ -1398,37 +1439,32 @@ func (sr *immutableRef) unlazyLayer(ctx context.Context, dhs DescHandlers, pg pr
 		err = sr.cm.Snapshotter.Prepare(ctx, key, parentID)
 	}
 	if err != nil {
-		return err
+		return "", err
 	}
+	defer func() {
+		if rerr != nil {
+			if err := sr.cm.Snapshotter.Remove(context.WithoutCancel(ctx), key); err != nil {
+				bklog.G(ctx).WithError(err).Warnf("failed to remove snapshot %s", key)
+			}
+		}
+	}()
 
 	mountable, err := sr.cm.Snapshotter.Mounts(ctx, key)
 	if err != nil {
-		return err
+		return "", err
 	}
 	mounts, unmount, err := mountable.Mount()
 	if err != nil {
-		return err
+		return "", err
 	}
-	_, err = sr.cm.Applier.Apply(ctx, desc, mounts)
-	if err != nil {
+	if err := apply(mounts); err != nil {
 		unmount()
-		return err
+		return "", err
 	}
-
 	if err := unmount(); err != nil {
-		return err
-	}
-	if err := sr.cm.Snapshotter.Commit(ctx, sr.getSnapshotID(), key); err != nil {
-		if !errors.Is(err, cerrdefs.ErrAlreadyExists) {
-			return err
-		}
-	}
-	sr.queueBlobOnly(false)
-	sr.queueSize(sizeUnknown)
-	if err := sr.commitMetadata(); err != nil {
-		return err
+		return "", err
 	}
-	return nil
+	return key, nil
 }
 
 func (sr *immutableRef) Release(ctx context.Context) error {
@@ -1593,6 +1629,12 @@ func (sr *mutableRef) commit() (_ *immutableRef, rerr error) {
 }
 
 func (sr *mutableRef) Mount(ctx context.Context, readonly bool, s session.Group) (_ snapshot.Mountable, rerr error) {
+	if sr.layerParent != nil {
+		if err := sr.layerParent.ensureLazyFiles(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+
 	sr.mu.Lock()
 	defer sr.mu.Unlock()
 
@@ -7,11 +7,12 @@ import (
 )
 
 const (
-	ExporterImage  = "image"
-	ExporterLocal  = "local"
-	ExporterTar    = "tar"
-	ExporterOCI    = "oci"
-	ExporterDocker = "docker"
+	ExporterImage    = "image"
+	ExporterLocal    = "local"
+	ExporterTar      = "tar"
+	ExporterOCI      = "oci"
+	ExporterDocker   = "docker"
+	ExporterSquashFS = "squashfs"
 )
 
 type LocalExporterMode string
@@ -19,6 +20,7 @@ type LocalExporterMode string
 const (
 	LocalExporterModeCopy   LocalExporterMode = "copy"
 	LocalExporterModeDelete LocalExporterMode = "delete"
+	LocalExporterModeSync   LocalExporterMode = "sync"
 )
 
 func ParseLocalExporterMode(v string) (LocalExporterMode, error) {
@@ -27,6 +29,8 @@ func ParseLocalExporterMode(v string) (LocalExporterMode, error) {
 		return LocalExporterModeCopy, nil
 	case string(LocalExporterModeDelete):
 		return LocalExporterModeDelete, nil
+	case string(LocalExporterModeSync):
+		return LocalExporterModeSync, nil
 	default:
 		return "", errors.Errorf("invalid local exporter mode %q", v)
 	}
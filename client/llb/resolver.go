@@ -33,5 +33,16 @@ func WithImageChecksum(dgst digest.Digest) ImageOption {
 	})
 }
 
+// WithLazyPull makes layers that carry an eStargz TOC be extracted with ranged
+// reads from the registry when they are needed for a mount, instead of pulling
+// the full blob first. Only the files that the layer lists for prefetching are
+// extracted right away, the other files are fetched when they are first
+// opened. Layers without a TOC are pulled as usual.
+func WithLazyPull(v bool) ImageOption {
+	return imageOptionFunc(func(ii *ImageInfo) {
+		ii.lazyPull = v
+	})
+}
+
 // ImageMetaResolver can resolve image config metadata from a reference
 type ImageMetaResolver = sourceresolver.ImageMetaResolver
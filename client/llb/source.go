@@ -253,6 +253,11 @@ func Image(ref string, opts ...ImageOption) State {
 		addCap(&info.Constraints, pb.CapSourceImageChecksum)
 	}
 
+	if info.lazyPull {
+		attrs[pb.AttrImageLazyPull] = "true"
+		addCap(&info.Constraints, pb.CapSourceImageLazyPull)
+	}
+
 	src := NewSource("docker-image://"+ref, attrs, info.Constraints) // controversial
 	if err != nil {
 		src.err = err
@@ -357,6 +362,7 @@ type ImageInfo struct {
 	resolveMode   ResolveMode
 	layerLimit    *int
 	checksum      digest.Digest
+	lazyPull      bool
 	RecordType    string
 }
 
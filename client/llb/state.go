@@ -633,6 +633,16 @@ func WithCustomNamef(name string, a ...any) ConstraintsOpt {
 	return WithCustomName(fmt.Sprintf(name, a...))
 }
 
+// WithProvenanceMetadata adds metadata to the op that is recorded for its
+// build step when the provenance attestation includes the build definition.
+func WithProvenanceMetadata(m map[string]string) ConstraintsOpt {
+	desc := make(map[string]string, len(m))
+	for k, v := range m {
+		desc[pb.ProvenanceMetadataPrefix+k] = v
+	}
+	return WithDescription(desc)
+}
+
 // WithExportCache forces results for this vertex to be exported with the cache
 func WithExportCache() ConstraintsOpt {
 	return constraintsOptFunc(func(c *Constraints) {
@@ -161,7 +161,7 @@ func (c *Client) solve(ctx context.Context, def *llb.Definition, runGateway runG
 			switch ex.Type {
 			case ExporterLocal:
 				supportDir = true
-			case ExporterTar:
+			case ExporterTar, ExporterSquashFS:
 				supportFile = true
 			case ExporterOCI, ExporterDocker:
 				supportFile = ex.Output != nil
@@ -197,9 +197,12 @@ func (c *Client) solve(ctx context.Context, def *llb.Definition, runGateway runG
 							return nil, err
 						}
 					}
-					if mode == LocalExporterModeDelete {
+					switch mode {
+					case LocalExporterModeDelete:
 						syncTargets = append(syncTargets, filesync.WithFSSyncDirDelete(exID, ex.OutputDir))
-					} else {
+					case LocalExporterModeSync:
+						syncTargets = append(syncTargets, filesync.WithFSSyncDirSync(exID, ex.OutputDir))
+					default:
 						syncTargets = append(syncTargets, filesync.WithFSSyncDir(exID, ex.OutputDir))
 					}
 				} else {
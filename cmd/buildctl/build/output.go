@@ -78,9 +78,13 @@ func resolveExporterDest(exporter, dest string, attrs map[string]string) (filesy
 	switch exporter {
 	case client.ExporterLocal:
 		supportDir = true
-	case client.ExporterTar:
+	case client.ExporterTar, client.ExporterSquashFS:
 		supportFile = true
 	case client.ExporterOCI, client.ExporterDocker:
+		if attrs["dest-layout"] != "" {
+			// written to a layout directory on the daemon
+			break
+		}
 		tar, err := strconv.ParseBool(attrs["tar"])
 		if err != nil {
 			tar = true
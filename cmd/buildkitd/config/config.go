@@ -48,6 +48,11 @@ type Config struct {
 		Gateway    GatewayFrontendConfig    `toml:"gateway.v0"`
 	} `toml:"frontend"`
 
+	Exporters struct {
+		OCI    OCIExporterConfig `toml:"oci"`
+		Verify VerifyConfig      `toml:"verify"`
+	} `toml:"exporter"`
+
 	System *SystemConfig `toml:"system"`
 
 	// ProvenanceEnvDir is the directory where extra config is loaded
@@ -228,6 +233,26 @@ type DockerfileFrontendConfig struct {
 	Enabled *bool `toml:"enabled"`
 }
 
+type OCIExporterConfig struct {
+	// Layouts are the named OCI image layout directories on the daemon that
+	// the oci exporter can write to with the dest-layout option.
+	Layouts map[string]OCILayoutConfig `toml:"layout"`
+}
+
+type OCILayoutConfig struct {
+	Path string `toml:"path"`
+}
+
+// VerifyConfig configures the verifiers that check every build result before
+// it is exported. Builds can't change these options.
+type VerifyConfig struct {
+	MaxSize        string   `toml:"maxSize"`
+	ForbiddenPaths []string `toml:"forbiddenPaths"`
+	WorldWritable  bool     `toml:"worldWritable"`
+	Setuid         bool     `toml:"setuid"`
+	RequiredLabels []string `toml:"requiredLabels"`
+}
+
 type GatewayFrontendConfig struct {
 	Enabled             *bool    `toml:"enabled"`
 	AllowedRepositories []string `toml:"allowedRepositories"`
@@ -77,6 +77,14 @@ cert="cert.pem"
 nameservers=["1.1.1.1","8.8.8.8"]
 options=["edns0"]
 searchDomains=["example.com"]
+
+[exporter.oci.layout.shared]
+path="/var/lib/layouts/shared"
+
+[exporter.verify]
+maxSize="2GB"
+forbiddenPaths=["/root/.ssh", "*.pem"]
+setuid=true
 `
 
 	cfg, err := Load(bytes.NewBuffer([]byte(testConfig)))
@@ -150,4 +158,10 @@ searchDomains=["example.com"]
 	require.Equal(t, []string{"1.1.1.1", "8.8.8.8"}, cfg.DNS.Nameservers)
 	require.Equal(t, []string{"example.com"}, cfg.DNS.SearchDomains)
 	require.Equal(t, []string{"edns0"}, cfg.DNS.Options)
+
+	require.Equal(t, "/var/lib/layouts/shared", cfg.Exporters.OCI.Layouts["shared"].Path)
+	require.Equal(t, "2GB", cfg.Exporters.Verify.MaxSize)
+	require.Equal(t, []string{"/root/.ssh", "*.pem"}, cfg.Exporters.Verify.ForbiddenPaths)
+	require.True(t, cfg.Exporters.Verify.Setuid)
+	require.False(t, cfg.Exporters.Verify.WorldWritable)
 }
@@ -34,6 +34,7 @@ import (
 	"github.com/moby/buildkit/cmd/buildkitd/config"
 	"github.com/moby/buildkit/control"
 	"github.com/moby/buildkit/executor/oci"
+	"github.com/moby/buildkit/exporter/verifier"
 	"github.com/moby/buildkit/frontend"
 	dockerfile "github.com/moby/buildkit/frontend/dockerfile/builder"
 	dockerfileversion "github.com/moby/buildkit/frontend/dockerfile/version"
@@ -914,9 +915,10 @@ func newController(ctx context.Context, c *cli.Command, cfg *config.Config, mp m
 		return nil, err
 	}
 
+	policyVerifier := newVerifierProvider(cfg.Root)
 	var verifierProvider func() (*policy.Verifier, error)
 	if cfg.Cache.GHA != nil && cfg.Cache.GHA.Verify.Required {
-		verifierProvider = newVerifierProvider(cfg.Root)
+		verifierProvider = policyVerifier
 	}
 
 	remoteCacheExporterFuncs := map[string]remotecache.ResolveCacheExporterFunc{
@@ -944,6 +946,11 @@ func newController(ctx context.Context, c *cli.Command, cfg *config.Config, mp m
 		return nil, err
 	}
 
+	verifierOpts, err := getVerifierOpts(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	return control.NewController(control.Opt{
 		SessionManager:            sessionManager,
 		WorkerController:          wc,
@@ -963,6 +970,8 @@ func newController(ctx context.Context, c *cli.Command, cfg *config.Config, mp m
 		GarbageCollect:            w.GarbageCollect,
 		GracefulStop:              ctx.Done(),
 		ProvenanceEnv:             provenanceEnv,
+		PolicyVerifier:            policyVerifier,
+		Verifiers:                 verifierOpts,
 	})
 }
 
@@ -1058,6 +1067,44 @@ func getGCPolicy(cfg config.GCConfig, root string) []client.PruneInfo {
 	return out
 }
 
+func getOCILayouts(cfg *config.Config) (map[string]string, error) {
+	layouts := make(map[string]string, len(cfg.Exporters.OCI.Layouts))
+	for name, l := range cfg.Exporters.OCI.Layouts {
+		if l.Path == "" {
+			return nil, errors.Errorf("path is required for OCI layout %q", name)
+		}
+		if !filepath.IsAbs(l.Path) {
+			return nil, errors.Errorf("path %s for OCI layout %q must be absolute", l.Path, name)
+		}
+		layouts[name] = l.Path
+	}
+	return layouts, nil
+}
+
+func getVerifierOpts(cfg *config.Config) (map[string]string, error) {
+	vc := cfg.Exporters.Verify
+	opts := map[string]string{}
+	if vc.MaxSize != "" {
+		opts["max-size"] = vc.MaxSize
+	}
+	if len(vc.ForbiddenPaths) > 0 {
+		opts["forbidden-paths"] = verifier.JoinList(vc.ForbiddenPaths)
+	}
+	if vc.WorldWritable {
+		opts["world-writable"] = "true"
+	}
+	if vc.Setuid {
+		opts["setuid"] = "true"
+	}
+	if len(vc.RequiredLabels) > 0 {
+		opts["required-labels"] = verifier.JoinList(vc.RequiredLabels)
+	}
+	if _, err := verifier.New(opts); err != nil {
+		return nil, errors.Wrap(err, "invalid exporter verify config")
+	}
+	return opts, nil
+}
+
 func getBuildkitVersion() client.BuildkitVersion {
 	buildkitVersion := client.BuildkitVersion{
 		Package:  version.Package,
@@ -364,6 +364,10 @@ func containerdWorkerInitializer(c *cli.Command, common workerInitializerOpt) ([
 	opt.GCPolicy = getGCPolicy(cfg.GCConfig, common.config.Root)
 	opt.BuildkitVersion = getBuildkitVersion()
 	opt.RegistryHosts = resolverFunc(common.config)
+	opt.OCILayouts, err = getOCILayouts(common.config)
+	if err != nil {
+		return nil, err
+	}
 
 	if platformsStr := cfg.Platforms; len(platformsStr) != 0 {
 		platforms, err := parsePlatforms(platformsStr)
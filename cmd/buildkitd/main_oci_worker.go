@@ -336,6 +336,10 @@ func ociWorkerInitializer(c *cli.Command, common workerInitializerOpt) ([]worker
 	opt.GCPolicy = getGCPolicy(cfg.GCConfig, common.config.Root)
 	opt.BuildkitVersion = getBuildkitVersion()
 	opt.RegistryHosts = hosts
+	opt.OCILayouts, err = getOCILayouts(common.config)
+	if err != nil {
+		return nil, err
+	}
 
 	if platformsStr := cfg.Platforms; len(platformsStr) != 0 {
 		platforms, err := parsePlatforms(platformsStr)
@@ -39,3 +39,17 @@ func runApplyMainFlags(t *testing.T, args []string, cfg *config.Config) error {
 	}
 	return cmd.Run(t.Context(), append([]string{"buildkitd"}, args...))
 }
+
+func TestGetVerifierOpts(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Exporters.Verify = config.VerifyConfig{
+		ForbiddenPaths: []string{"/root/.ssh", "*.pem"},
+		RequiredLabels: []string{"org.opencontainers.image.vendor=Example, Inc.", "team"},
+	}
+	opts, err := getVerifierOpts(&cfg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"forbidden-paths": "/root/.ssh,*.pem",
+		"required-labels": `"org.opencontainers.image.vendor=Example, Inc.",team`,
+	}, opts)
+}
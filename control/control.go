@@ -26,6 +26,7 @@ import (
 	"github.com/moby/buildkit/exporter"
 	"github.com/moby/buildkit/exporter/containerimage/exptypes"
 	"github.com/moby/buildkit/exporter/util/epoch"
+	"github.com/moby/buildkit/exporter/verifier"
 	"github.com/moby/buildkit/frontend"
 	"github.com/moby/buildkit/frontend/attestations"
 	dockerfileversion "github.com/moby/buildkit/frontend/dockerfile/version"
@@ -85,6 +86,8 @@ type Opt struct {
 	GarbageCollect            func(context.Context) error
 	GracefulStop              <-chan struct{}
 	ProvenanceEnv             map[string]any
+	PolicyVerifier            llbsolver.PolicyVerifierProvider
+	Verifiers                 map[string]string
 }
 
 type Controller struct { // TODO: ControlService
@@ -128,6 +131,8 @@ func NewController(opt Opt) (*Controller, error) {
 		ProxyNetwork:     opt.ProxyNetwork,
 		ProvenanceEnv:    opt.ProvenanceEnv,
 		MeterProvider:    opt.MeterProvider,
+		PolicyVerifier:   opt.PolicyVerifier,
+		Verifiers:        opt.Verifiers,
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create solver")
@@ -449,18 +454,27 @@ func (c *Controller) Solve(ctx context.Context, req *controlapi.SolveRequest) (*
 	}
 
 	var expis []exporter.ExporterInstance
+	var verifierOpts map[string]string
 	for i, ex := range req.Exporters {
 		exp, err := w.Exporter(ex.Type, c.opt.SessionManager)
 		if err != nil {
 			return nil, err
 		}
+		opts, attrs := verifier.SplitAttrs(ex.Attrs)
+		if verifierOpts, err = verifier.MergeOpts(verifierOpts, opts); err != nil {
+			return nil, err
+		}
 		bklog.G(ctx).Debugf("resolve exporter %s with %v", ex.Type, ex.Attrs)
-		expi, err := exp.Resolve(ctx, i, ex.Attrs)
+		expi, err := exp.Resolve(ctx, i, attrs)
 		if err != nil {
 			return nil, err
 		}
 		expis = append(expis, expi)
 	}
+	// validate the verifier options before the build starts
+	if _, err := verifier.New(verifierOpts); err != nil {
+		return nil, err
+	}
 
 	rest, dupes, err := findDuplicateCacheOptions(req.Cache.Exports)
 	if err != nil {
@@ -582,6 +596,7 @@ func (c *Controller) Solve(ctx context.Context, req *controlapi.SolveRequest) (*
 		Exporters:             expis,
 		CacheExporters:        cacheExporters,
 		EnableSessionExporter: req.EnableSessionExporter,
+		VerifierOpts:          verifierOpts,
 	}, entitlementsFromPB(req.Entitlements), procs, req.Internal, req.SourcePolicy, req.SourcePolicySession, req.ProxyNetwork)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,339 @@
+package containerimage
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/docker/go-units"
+	"github.com/moby/buildkit/cache"
+	"github.com/moby/buildkit/cache/contenthash"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/snapshot"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/compression"
+	"github.com/moby/buildkit/util/contentutil"
+	"github.com/moby/buildkit/util/converter"
+	"github.com/moby/buildkit/util/progress"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/fsutil"
+	fstypes "github.com/tonistiigi/fsutil/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+// dedupeLayers removes the files from the layer blobs of ref that are identical
+// to the file in the layers below, so that the filesystem of the image does not
+// change. Files are compared by content and by all of their metadata, including
+// the modification time. The modification time of a file in the layers below
+// is read from the blob of the layer that last wrote it, so timestamps that
+// were rewritten for SOURCE_DATE_EPOCH are compared as they are in the image.
+// Layers of the base image are not changed. It returns the number of bytes
+// saved in the compressed layers.
+func (ic *ImageWriter) dedupeLayers(ctx context.Context, opts *ImageCommitOpts, ref cache.ImmutableRef, remote *solver.Remote, baseImg *dockerspec.DockerOCIImage, sg session.Group) (_ *solver.Remote, _ int64, rerr error) {
+	if !opts.DedupeFiles || ref == nil || len(remote.Descriptors) < 2 {
+		return remote, 0, nil
+	}
+	if ic.opt.CacheAccessor == nil {
+		return nil, 0, errors.New("deduplicating files is not supported by this worker")
+	}
+	layers := ref.LayerChain()
+	defer layers.Release(context.WithoutCancel(ctx))
+	if len(layers) != len(remote.Descriptors) {
+		return nil, 0, errors.Errorf("unexpected layer count %d for %d blobs", len(layers), len(remote.Descriptors))
+	}
+
+	pw, _, _ := progress.NewFromContext(ctx)
+	defer pw.Close()
+	id := "deduplicating files"
+	now := time.Now()
+	st := progress.Status{
+		Started: &now,
+		Action:  "saved",
+	}
+	pw.Write(id, st)
+
+	states := newLayerStates(ic.opt.CacheAccessor, layers)
+	defer states.Release(context.WithoutCancel(ctx))
+
+	base := baseImageLayers(remote, baseImg)
+	first := max(1, base)
+	lowers := make([]cache.ImmutableRef, len(layers))
+	for i := first; i < len(layers); i++ {
+		lower, err := states.state(ctx, i)
+		if err != nil {
+			return nil, 0, err
+		}
+		lowers[i] = lower
+	}
+
+	descs := make([]ocispecs.Descriptor, len(remote.Descriptors))
+	copy(descs, remote.Descriptors)
+	cs := contentutil.NewStoreWithProvider(ic.opt.ContentStore, remote.Provider)
+
+	// the entries of the layers that are not part of the base image
+	entries := make([]*layerEntries, len(descs))
+	eg, egCtx := errgroup.WithContext(ctx)
+	for i := base; i < len(descs); i++ {
+		eg.Go(func() error {
+			e, err := readLayerEntries(egCtx, cs, descs[i])
+			if err != nil {
+				return errors.Wrapf(err, "failed to read layer %d", i)
+			}
+			entries[i] = e
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, 0, err
+	}
+
+	saved := make([]int64, len(descs))
+	eg, egCtx = errgroup.WithContext(ctx)
+	for i := first; i < len(descs); i++ {
+		eg.Go(func() error {
+			lowerHeader := func(p string) (*tar.Header, bool) {
+				for j := i - 1; j >= base; j-- {
+					if hdr, ok := entries[j].headers[p]; ok {
+						return hdr, true
+					}
+				}
+				return nil, false
+			}
+			desc, err := dedupeLayer(egCtx, cs, descs[i], entries[i].files, lowers[i], lowerHeader, opts.RefCfg.Compression, sg)
+			if err != nil {
+				return errors.Wrapf(err, "failed to deduplicate files of layer %d", i)
+			}
+			if desc != nil {
+				if desc.Size < descs[i].Size {
+					saved[i] = descs[i].Size - desc.Size
+				}
+				descs[i] = *desc
+			}
+			return nil
+		})
+	}
+	err := eg.Wait()
+
+	var total int64
+	for _, s := range saved {
+		total += s
+	}
+	now = time.Now()
+	st.Current = int(total)
+	st.Completed = &now
+	pw.Write(id, st)
+	if err != nil {
+		return nil, 0, err
+	}
+	bklog.G(ctx).Debugf("deduplicating files saved %s", units.HumanSize(float64(total)))
+
+	return &solver.Remote{
+		Provider:    cs,
+		Descriptors: descs,
+	}, total, nil
+}
+
+// dedupeLayer returns a new blob for the layer without the regular files that
+// are identical in lower. lowerHeader returns the header of a file in the
+// layers below that are not part of the base image. It returns nil if no
+// files can be removed.
+func dedupeLayer(ctx context.Context, cs content.Store, desc ocispecs.Descriptor, files map[string]dedupeFile, lower cache.ImmutableRef, lowerHeader func(string) (*tar.Header, bool), comp compression.Config, sg session.Group) (*ocispecs.Descriptor, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	mountable, err := lower.Mount(ctx, true, sg)
+	if err != nil {
+		return nil, err
+	}
+	lm := snapshot.LocalMounter(mountable)
+	root, err := lm.Mount()
+	if err != nil {
+		return nil, err
+	}
+	defer lm.Unmount()
+
+	drop := map[string]struct{}{}
+	for p, f := range files {
+		if !isPlainPath(root, p) {
+			continue
+		}
+		st, err := fsutil.Stat(filepath.Join(root, filepath.FromSlash(p)))
+		if err != nil {
+			continue
+		}
+		if hdr, ok := lowerHeader(p); ok {
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			// the blob may have been rewritten with SOURCE_DATE_EPOCH
+			st.ModTime = hdr.ModTime.UnixNano()
+		}
+		if !sameMetadata(f.hdr, st) {
+			continue
+		}
+		lowerDgst, err := contenthash.Checksum(ctx, lower, p, contenthash.ChecksumOpts{}, sg)
+		if err != nil {
+			// the file does not exist in lower
+			continue
+		}
+		if lowerDgst == f.dgst {
+			drop[p] = struct{}{}
+		}
+	}
+	if len(drop) == 0 {
+		return nil, nil
+	}
+
+	convertFunc, err := converter.NewWithFilter(ctx, cs, desc, comp, func(hdr *tar.Header) bool {
+		_, ok := drop[cleanEntryName(hdr.Name)]
+		return !ok
+	})
+	if err != nil {
+		return nil, err
+	}
+	return convertFunc(ctx, cs, desc)
+}
+
+// dedupeFile is a regular file of a layer that may be removed.
+type dedupeFile struct {
+	hdr  *tar.Header
+	dgst digest.Digest
+}
+
+// layerEntries are the entries of a layer blob.
+type layerEntries struct {
+	// headers are the headers of all the entries, by path
+	headers map[string]*tar.Header
+	// files are the regular files that may be removed
+	files map[string]dedupeFile
+}
+
+// readLayerEntries reads the entries of the layer. Hardlinked files and files
+// below an opaque directory are never removed.
+func readLayerEntries(ctx context.Context, cs content.Store, desc ocispecs.Descriptor) (*layerEntries, error) {
+	ctype, err := compression.FromMediaType(desc.MediaType)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := ctype.Decompress(ctx, cs, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	e := &layerEntries{
+		headers: map[string]*tar.Header{},
+		files:   map[string]dedupeFile{},
+	}
+	linked := map[string]struct{}{}
+	opaque := map[string]struct{}{}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := cleanEntryName(hdr.Name)
+		e.headers[name] = hdr
+		switch {
+		case path.Base(name) == whiteoutOpaque:
+			opaque[path.Dir(name)] = struct{}{}
+		case hdr.Typeflag == tar.TypeLink:
+			linked[cleanEntryName(hdr.Linkname)] = struct{}{}
+		case hdr.Typeflag == tar.TypeReg:
+			dgst, err := contenthash.FileChecksum(hdr, tr)
+			if err != nil {
+				return nil, err
+			}
+			e.files[name] = dedupeFile{hdr: hdr, dgst: dgst}
+		}
+	}
+
+	for p := range e.files {
+		if _, ok := linked[p]; ok {
+			delete(e.files, p)
+			continue
+		}
+		for d := path.Dir(p); ; d = path.Dir(d) {
+			if _, ok := opaque[d]; ok {
+				delete(e.files, p)
+				break
+			}
+			if d == "/" {
+				break
+			}
+		}
+	}
+	return e, nil
+}
+
+// sameMetadata reports whether the file in the lower layers has the same
+// metadata as the tar header: mode, owner, modification time and xattrs.
+func sameMetadata(hdr *tar.Header, st *fstypes.Stat) bool {
+	const modeMask = os.ModeType | os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	if os.FileMode(st.Mode)&modeMask != hdr.FileInfo().Mode()&modeMask {
+		return false
+	}
+	if int(st.Uid) != hdr.Uid || int(st.Gid) != hdr.Gid {
+		return false
+	}
+	mtime := time.Unix(0, st.ModTime)
+	if hdr.ModTime.Nanosecond() == 0 {
+		// headers without PAX timestamps only store seconds
+		mtime = mtime.Truncate(time.Second)
+	}
+	if !mtime.Equal(hdr.ModTime) {
+		return false
+	}
+	xattrs := map[string]string{}
+	for k, v := range hdr.PAXRecords {
+		if name, ok := strings.CutPrefix(k, "SCHILY.xattr."); ok {
+			xattrs[name] = v
+		}
+	}
+	if len(xattrs) != len(st.Xattrs) {
+		return false
+	}
+	for k, v := range st.Xattrs {
+		if xv, ok := xattrs[k]; !ok || xv != string(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPlainPath reports whether all parent directories of p are directories in
+// the filesystem at root, so p resolves to the same file without following
+// symlinks.
+func isPlainPath(root, p string) bool {
+	for d := path.Dir(p); d != "/"; d = path.Dir(d) {
+		fi, err := os.Lstat(filepath.Join(root, filepath.FromSlash(d)))
+		if err != nil || !fi.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+func cleanEntryName(name string) string {
+	return path.Clean("/" + strings.TrimPrefix(name, "./"))
+}
@@ -0,0 +1,123 @@
+package containerimage
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/moby/buildkit/util/contentutil"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+func TestReadLayerEntries(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	buf := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "foo", Typeflag: tar.TypeReg, Mode: 0644, Size: 3},
+		{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "dir/bar", Typeflag: tar.TypeReg, Mode: 0644, Size: 3},
+		{Name: "dir/linked", Typeflag: tar.TypeReg, Mode: 0644, Size: 3},
+		{Name: "dir/link", Typeflag: tar.TypeLink, Linkname: "dir/linked"},
+		{Name: "opq/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "opq/.wh..wh..opq", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "opq/sub/baz", Typeflag: tar.TypeReg, Mode: 0644, Size: 3},
+	} {
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Size > 0 {
+			_, err := tw.Write([]byte("abc"))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+
+	cs := contentutil.NewBuffer()
+	desc := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageLayer,
+		Digest:    digest.FromBytes(buf.Bytes()),
+		Size:      int64(buf.Len()),
+	}
+	require.NoError(t, content.WriteBlob(ctx, cs, desc.Digest.String(), bytes.NewReader(buf.Bytes()), desc))
+
+	e, err := readLayerEntries(ctx, cs, desc)
+	require.NoError(t, err)
+	require.Len(t, e.headers, 8)
+	files := e.files
+	require.Len(t, files, 2)
+	require.Contains(t, files, "/foo")
+	require.Contains(t, files, "/dir/bar")
+	require.Equal(t, files["/foo"].dgst, files["/dir/bar"].dgst)
+}
+
+func TestSameMetadata(t *testing.T) {
+	t.Parallel()
+
+	mtime := time.Unix(1700000000, 0)
+	hdr := &tar.Header{
+		Name:     "foo",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Uid:      1000,
+		Gid:      1000,
+		ModTime:  mtime,
+		PAXRecords: map[string]string{
+			"SCHILY.xattr.user.foo": "bar",
+		},
+	}
+	stat := func() *fstypes.Stat {
+		return &fstypes.Stat{
+			Mode:    0644,
+			Uid:     1000,
+			Gid:     1000,
+			ModTime: mtime.Add(500 * time.Millisecond).UnixNano(),
+			Xattrs:  map[string][]byte{"user.foo": []byte("bar")},
+		}
+	}
+	// the header only stores seconds
+	require.True(t, sameMetadata(hdr, stat()))
+
+	for name, f := range map[string]func(*fstypes.Stat){
+		"mode":   func(st *fstypes.Stat) { st.Mode = 0755 },
+		"setuid": func(st *fstypes.Stat) { st.Mode |= uint32(os.ModeSetuid) },
+		"uid":    func(st *fstypes.Stat) { st.Uid = 0 },
+		"gid":    func(st *fstypes.Stat) { st.Gid = 0 },
+		"mtime":  func(st *fstypes.Stat) { st.ModTime = mtime.Add(time.Second).UnixNano() },
+		"xattr":  func(st *fstypes.Stat) { st.Xattrs["user.foo"] = []byte("baz") },
+		"extra":  func(st *fstypes.Stat) { st.Xattrs["user.bar"] = nil },
+		"none":   func(st *fstypes.Stat) { st.Xattrs = nil },
+	} {
+		st := stat()
+		f(st)
+		require.False(t, sameMetadata(hdr, st), name)
+	}
+
+	// sub-second timestamps are compared exactly
+	hdr.ModTime = mtime.Add(500 * time.Millisecond)
+	require.True(t, sameMetadata(hdr, stat()))
+	hdr.ModTime = mtime.Add(400 * time.Millisecond)
+	require.False(t, sameMetadata(hdr, stat()))
+}
+
+func TestIsPlainPath(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "a/b"), 0755))
+	require.NoError(t, os.Symlink("a", filepath.Join(root, "l")))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "f"), nil, 0644))
+
+	require.True(t, isPlainPath(root, "/foo"))
+	require.True(t, isPlainPath(root, "/a/b/foo"))
+	require.False(t, isPlainPath(root, "/l/b/foo"))
+	require.False(t, isPlainPath(root, "/f/foo"))
+	require.False(t, isPlainPath(root, "/missing/foo"))
+}
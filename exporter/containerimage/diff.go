@@ -0,0 +1,445 @@
+package containerimage
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/images"
+	cdcompression "github.com/containerd/containerd/v2/pkg/archive/compression"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/containerd/platforms"
+	"github.com/moby/buildkit/exporter"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/util/contentutil"
+	"github.com/moby/buildkit/util/resolver"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// diffImage compares the exported image with the reference image of the
+// diff-reference option. Nil is returned if the reference image does not
+// exist.
+func (e *imageExporterInstance) diffImage(ctx context.Context, src *exporter.Source, sessionID string, desc ocispecs.Descriptor) (*exptypes.ImageDiff, error) {
+	provider, _, err := e.imageProvider(ctx, src, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	r := resolver.DefaultPool.GetResolver(e.opt.RegistryHosts, e.diffReference, resolver.ScopeType{}, e.opt.SessionManager, session.NewGroup(sessionID))
+	name, refDesc, err := r.Resolve(ctx, e.diffReference)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to resolve %s", e.diffReference)
+	}
+	fetcher, err := r.Fetcher(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	refProvider := contentutil.FromFetcher(fetcher)
+
+	ps, err := exptypes.ParsePlatforms(src.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	lc := &layerCache{layers: map[digest.Digest]*layerFiles{}}
+	report := &exptypes.ImageDiff{
+		Reference:       e.diffReference,
+		ReferenceDigest: refDesc.Digest,
+	}
+	for _, p := range ps.Platforms {
+		matcher := platforms.OnlyStrict(p.Platform)
+		img, err := readComparedImage(ctx, provider, desc, matcher)
+		if err != nil {
+			return nil, err
+		}
+		refImg, err := readComparedImage(ctx, refProvider, refDesc, matcher)
+		if err != nil && !cerrdefs.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to read %s", e.diffReference)
+		}
+		pd, err := diffPlatform(ctx, lc, img, refImg)
+		if err != nil {
+			return nil, err
+		}
+		pd.Platform = platforms.FormatAll(p.Platform)
+		report.Platforms = append(report.Platforms, *pd)
+	}
+	return report, nil
+}
+
+type comparedImage struct {
+	provider content.Provider
+	layers   []ocispecs.Descriptor
+	diffIDs  []digest.Digest
+}
+
+func readComparedImage(ctx context.Context, provider content.Provider, desc ocispecs.Descriptor, matcher platforms.MatchComparer) (*comparedImage, error) {
+	mfst, err := images.Manifest(ctx, provider, desc, matcher)
+	if err != nil {
+		return nil, err
+	}
+	dt, err := content.ReadBlob(ctx, provider, mfst.Config)
+	if err != nil {
+		return nil, err
+	}
+	var img ocispecs.Image
+	if err := json.Unmarshal(dt, &img); err != nil {
+		return nil, errors.Wrap(err, "failed to parse image config")
+	}
+	if len(img.RootFS.DiffIDs) != len(mfst.Layers) {
+		return nil, errors.Errorf("invalid image %s: %d layers with %d diffIDs", desc.Digest, len(mfst.Layers), len(img.RootFS.DiffIDs))
+	}
+	return &comparedImage{
+		provider: provider,
+		layers:   mfst.Layers,
+		diffIDs:  img.RootFS.DiffIDs,
+	}, nil
+}
+
+func diffPlatform(ctx context.Context, lc *layerCache, img, refImg *comparedImage) (*exptypes.PlatformDiff, error) {
+	pd := &exptypes.PlatformDiff{}
+	refDiffIDs := map[digest.Digest]struct{}{}
+	if refImg == nil {
+		pd.NewPlatform = true
+	} else {
+		for _, d := range refImg.diffIDs {
+			refDiffIDs[d] = struct{}{}
+		}
+	}
+	diffIDs := map[digest.Digest]struct{}{}
+	for i, l := range img.layers {
+		diffIDs[img.diffIDs[i]] = struct{}{}
+		status := exptypes.LayerStatusNew
+		if _, ok := refDiffIDs[img.diffIDs[i]]; ok {
+			status = exptypes.LayerStatusReused
+		}
+		pd.Layers = append(pd.Layers, exptypes.LayerDiff{
+			Digest: l.Digest,
+			DiffID: img.diffIDs[i],
+			Size:   l.Size,
+			Status: status,
+		})
+	}
+
+	if refImg != nil {
+		for i, l := range refImg.layers {
+			if _, ok := diffIDs[refImg.diffIDs[i]]; !ok {
+				pd.RemovedLayers = append(pd.RemovedLayers, exptypes.LayerDiff{
+					Digest: l.Digest,
+					DiffID: refImg.diffIDs[i],
+					Size:   l.Size,
+				})
+			}
+		}
+	}
+
+	// The files of the layers both images share as a common base are the
+	// same in both, unless a later layer of either image changes them. Only
+	// the paths changed by the other layers are compared.
+	shared := 0
+	if refImg != nil {
+		for shared < len(img.diffIDs) && shared < len(refImg.diffIDs) && img.diffIDs[shared] == refImg.diffIDs[shared] {
+			shared++
+		}
+	}
+	if err := lc.read(ctx, img, shared); err != nil {
+		return nil, err
+	}
+	touched := &pathSet{paths: map[string]struct{}{}, dirs: map[string]struct{}{}}
+	lc.touch(touched, img, shared)
+	if refImg != nil {
+		if err := lc.read(ctx, refImg, shared); err != nil {
+			return nil, err
+		}
+		lc.touch(touched, refImg, shared)
+	}
+	base, err := lc.base(ctx, img, shared, touched)
+	if err != nil {
+		return nil, err
+	}
+
+	files := maps.Clone(base)
+	lc.apply(files, img, shared)
+	refFiles := map[string]*diffFile{}
+	if refImg != nil {
+		refFiles = maps.Clone(base)
+		lc.apply(refFiles, refImg, shared)
+	}
+	pd.Files = diffFiles(files, refFiles)
+	return pd, nil
+}
+
+func diffFiles(files, refFiles map[string]*diffFile) exptypes.FilesDiff {
+	var fd exptypes.FilesDiff
+	add := func(list *[]exptypes.FileDiff, f exptypes.FileDiff) {
+		if len(*list) >= exptypes.MaxDiffFiles {
+			fd.Truncated = true
+			return
+		}
+		*list = append(*list, f)
+	}
+	for _, p := range sortedPaths(files) {
+		f := files[p]
+		fd.SizeDelta += f.size
+		rf, ok := refFiles[p]
+		switch {
+		case !ok:
+			add(&fd.Added, exptypes.FileDiff{Path: p, Size: f.size, SizeDelta: f.size})
+		case *f != *rf:
+			add(&fd.Modified, exptypes.FileDiff{Path: p, Size: f.size, SizeDelta: f.size - rf.size})
+		}
+	}
+	for _, p := range sortedPaths(refFiles) {
+		rf := refFiles[p]
+		fd.SizeDelta -= rf.size
+		if _, ok := files[p]; !ok {
+			add(&fd.Removed, exptypes.FileDiff{Path: p, Size: rf.size, SizeDelta: -rf.size})
+		}
+	}
+	return fd
+}
+
+func sortedPaths(files map[string]*diffFile) []string {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// diffFile is a non-directory entry of an image filesystem.
+type diffFile struct {
+	typ      byte
+	mode     int64
+	uid, gid int
+	size     int64
+	linkname string
+	digest   digest.Digest
+}
+
+type layerFiles struct {
+	files     map[string]*diffFile
+	whiteouts []string // removed paths, including their children
+	opaque    []string // directories whose children from lower layers are removed
+}
+
+// pathSet is a set of paths of an image filesystem. The children of dirs are
+// part of the set.
+type pathSet struct {
+	paths map[string]struct{}
+	dirs  map[string]struct{}
+}
+
+func (s *pathSet) has(p string) bool {
+	if _, ok := s.paths[p]; ok {
+		return true
+	}
+	for d := p; d != "/"; {
+		d = path.Dir(d)
+		if _, ok := s.dirs[d]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// layerCache reads each layer once for all compared images. Layers are
+// identified by their diffID, as the same layer may be compressed differently
+// in the compared images. Only layers whose regular files have all been
+// hashed are cached.
+type layerCache struct {
+	mu     sync.Mutex
+	layers map[digest.Digest]*layerFiles
+}
+
+func (lc *layerCache) get(diffID digest.Digest) *layerFiles {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return lc.layers[diffID]
+}
+
+// read reads the layers of img from index start that are not cached yet.
+func (lc *layerCache) read(ctx context.Context, img *comparedImage, start int) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(4)
+	for i := start; i < len(img.layers); i++ {
+		l, diffID := img.layers[i], img.diffIDs[i]
+		if lc.get(diffID) != nil {
+			continue
+		}
+		eg.Go(func() error {
+			lf, err := readLayerFiles(ctx, img.provider, l, nil)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read layer %s", l.Digest)
+			}
+			lc.mu.Lock()
+			lc.layers[diffID] = lf
+			lc.mu.Unlock()
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+// touch adds the paths changed by the layers of img from index start to s.
+func (lc *layerCache) touch(s *pathSet, img *comparedImage, start int) {
+	for _, diffID := range img.diffIDs[start:] {
+		lf := lc.get(diffID)
+		for p := range lf.files {
+			s.paths[p] = struct{}{}
+		}
+		for _, p := range lf.whiteouts {
+			s.paths[p] = struct{}{}
+			s.dirs[p] = struct{}{}
+		}
+		for _, p := range lf.opaque {
+			s.dirs[p] = struct{}{}
+		}
+	}
+}
+
+// apply applies the cached layers of img from index start to files.
+func (lc *layerCache) apply(files map[string]*diffFile, img *comparedImage, start int) {
+	for _, diffID := range img.diffIDs[start:] {
+		applyLayerFiles(files, lc.get(diffID))
+	}
+}
+
+// base returns the files of the first n layers of img that are in touched.
+// Layers that are not cached are read without hashing the files that are not
+// in touched, and they are not cached.
+func (lc *layerCache) base(ctx context.Context, img *comparedImage, n int, touched *pathSet) (map[string]*diffFile, error) {
+	layers := make([]*layerFiles, n)
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(4)
+	for i := range n {
+		if layers[i] = lc.get(img.diffIDs[i]); layers[i] != nil {
+			continue
+		}
+		l := img.layers[i]
+		eg.Go(func() error {
+			lf, err := readLayerFiles(egCtx, img.provider, l, touched.has)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read layer %s", l.Digest)
+			}
+			layers[i] = lf
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	files := map[string]*diffFile{}
+	for _, lf := range layers {
+		applyLayerFiles(files, lf)
+	}
+	maps.DeleteFunc(files, func(p string, _ *diffFile) bool {
+		return !touched.has(p)
+	})
+	return files, nil
+}
+
+func applyLayerFiles(files map[string]*diffFile, lf *layerFiles) {
+	if len(lf.whiteouts) > 0 || len(lf.opaque) > 0 {
+		removed := map[string]struct{}{}
+		for _, p := range lf.whiteouts {
+			removed[p] = struct{}{}
+		}
+		opaque := map[string]struct{}{}
+		for _, p := range lf.opaque {
+			opaque[p] = struct{}{}
+		}
+		for p := range files {
+			if _, ok := removed[p]; ok {
+				delete(files, p)
+				continue
+			}
+			for d := path.Dir(p); ; d = path.Dir(d) {
+				_, ok1 := removed[d]
+				_, ok2 := opaque[d]
+				if ok1 || ok2 {
+					delete(files, p)
+					break
+				}
+				if d == "/" {
+					break
+				}
+			}
+		}
+	}
+	for p, f := range lf.files {
+		files[p] = f
+	}
+}
+
+// readLayerFiles reads the files of a layer. The regular files are hashed if
+// hash is nil or returns true for their path.
+func readLayerFiles(ctx context.Context, provider content.Provider, desc ocispecs.Descriptor, hash func(string) bool) (*layerFiles, error) {
+	ra, err := provider.ReaderAt(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer ra.Close()
+	rc, err := cdcompression.DecompressStream(content.NewReader(ra))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	lf := &layerFiles{files: map[string]*diffFile{}}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := cleanEntryName(hdr.Name)
+		base := path.Base(name)
+		switch {
+		case base == whiteoutOpaque:
+			lf.opaque = append(lf.opaque, path.Dir(name))
+			continue
+		case strings.HasPrefix(base, whiteoutPrefix):
+			lf.whiteouts = append(lf.whiteouts, path.Join(path.Dir(name), strings.TrimPrefix(base, whiteoutPrefix)))
+			continue
+		case hdr.Typeflag == tar.TypeDir:
+			continue
+		}
+		f := &diffFile{
+			typ:      hdr.Typeflag,
+			mode:     hdr.Mode,
+			uid:      hdr.Uid,
+			gid:      hdr.Gid,
+			size:     hdr.Size,
+			linkname: hdr.Linkname,
+		}
+		if hdr.Typeflag == tar.TypeLink {
+			f.linkname = cleanEntryName(hdr.Linkname)
+		}
+		if hdr.Typeflag == tar.TypeReg && (hash == nil || hash(name)) {
+			if f.digest, err = digest.SHA256.FromReader(tr); err != nil {
+				return nil, err
+			}
+		}
+		lf.files[name] = f
+	}
+	return lf, nil
+}
@@ -0,0 +1,166 @@
+package containerimage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/util/contentutil"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffPlatform(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	cs := contentutil.NewBuffer()
+	type entry struct {
+		name    string
+		content string
+		typ     byte
+		mode    int64
+	}
+	layer := func(compress bool, entries ...entry) (ocispecs.Descriptor, digest.Digest) {
+		buf := &bytes.Buffer{}
+		tw := tar.NewWriter(buf)
+		for _, e := range entries {
+			hdr := &tar.Header{Name: e.name, Typeflag: e.typ, Mode: e.mode, Size: int64(len(e.content))}
+			if hdr.Typeflag == 0 {
+				hdr.Typeflag = tar.TypeReg
+			}
+			if hdr.Mode == 0 {
+				hdr.Mode = 0644
+			}
+			require.NoError(t, tw.WriteHeader(hdr))
+			_, err := tw.Write([]byte(e.content))
+			require.NoError(t, err)
+		}
+		require.NoError(t, tw.Close())
+		diffID := digest.FromBytes(buf.Bytes())
+
+		dt := buf.Bytes()
+		mt := ocispecs.MediaTypeImageLayer
+		if compress {
+			gzbuf := &bytes.Buffer{}
+			gw := gzip.NewWriter(gzbuf)
+			_, err := gw.Write(dt)
+			require.NoError(t, err)
+			require.NoError(t, gw.Close())
+			dt = gzbuf.Bytes()
+			mt = ocispecs.MediaTypeImageLayerGzip
+		}
+		desc := ocispecs.Descriptor{MediaType: mt, Digest: digest.FromBytes(dt), Size: int64(len(dt))}
+		require.NoError(t, content.WriteBlob(ctx, cs, desc.Digest.String(), bytes.NewReader(dt), desc))
+		return desc, diffID
+	}
+	image := func(layers ...func() (ocispecs.Descriptor, digest.Digest)) *comparedImage {
+		img := &comparedImage{provider: cs}
+		for _, l := range layers {
+			desc, diffID := l()
+			img.layers = append(img.layers, desc)
+			img.diffIDs = append(img.diffIDs, diffID)
+		}
+		return img
+	}
+
+	base := func(compress bool) func() (ocispecs.Descriptor, digest.Digest) {
+		return func() (ocispecs.Descriptor, digest.Digest) {
+			return layer(compress,
+				entry{name: "etc/", typ: tar.TypeDir, mode: 0755},
+				entry{name: "etc/config", content: "v1"},
+				entry{name: "etc/unchanged", content: "same"},
+				entry{name: "lib/", typ: tar.TypeDir, mode: 0755},
+				entry{name: "lib/a", content: "aaaa"},
+				entry{name: "lib/sub/b", content: "bb"},
+				entry{name: "bin/tool", content: "tool", mode: 0755},
+			)
+		}
+	}
+	refImg := image(base(false), func() (ocispecs.Descriptor, digest.Digest) {
+		return layer(true, entry{name: "app/old", content: "old"})
+	})
+	img := image(base(true), func() (ocispecs.Descriptor, digest.Digest) {
+		return layer(true,
+			entry{name: "etc/config", content: "v2 longer"},
+			entry{name: "lib/.wh..wh..opq", typ: tar.TypeReg},
+			entry{name: "lib/a", content: "aaaa"},
+			entry{name: "bin/tool", content: "tool", mode: 0700},
+			entry{name: "app/new", content: "new"},
+		)
+	})
+
+	// the shared base layer is read once from the exported image, without
+	// hashing the files that the other layers don't change
+	refProvider := &readRecorder{Provider: cs}
+	refImg.provider = refProvider
+	lc := &layerCache{layers: map[digest.Digest]*layerFiles{}}
+	pd, err := diffPlatform(ctx, lc, img, refImg)
+	require.NoError(t, err)
+	require.False(t, pd.NewPlatform)
+	require.Equal(t, []digest.Digest{refImg.layers[1].Digest}, refProvider.read)
+	require.NotContains(t, lc.layers, img.diffIDs[0])
+
+	require.Len(t, pd.Layers, 2)
+	require.Equal(t, exptypes.LayerStatusReused, pd.Layers[0].Status)
+	require.Equal(t, exptypes.LayerStatusNew, pd.Layers[1].Status)
+	require.Len(t, pd.RemovedLayers, 1)
+	require.Equal(t, refImg.layers[1].Digest, pd.RemovedLayers[0].Digest)
+
+	require.Equal(t, []exptypes.FileDiff{{Path: "/app/new", Size: 3, SizeDelta: 3}}, pd.Files.Added)
+	require.Equal(t, []exptypes.FileDiff{
+		{Path: "/app/old", Size: 3, SizeDelta: -3},
+		{Path: "/lib/sub/b", Size: 2, SizeDelta: -2},
+	}, pd.Files.Removed)
+	require.Equal(t, []exptypes.FileDiff{
+		{Path: "/bin/tool", Size: 4},
+		{Path: "/etc/config", Size: 9, SizeDelta: 7},
+	}, pd.Files.Modified)
+	require.Equal(t, int64(3-3-2+7), pd.Files.SizeDelta)
+	require.False(t, pd.Files.Truncated)
+
+	// whiteouts remove files of the lower layers
+	img2 := image(base(true), func() (ocispecs.Descriptor, digest.Digest) {
+		return layer(true,
+			entry{name: "etc/.wh.config", typ: tar.TypeReg},
+			entry{name: "lib/.wh.sub", typ: tar.TypeReg},
+		)
+	})
+	pd, err = diffPlatform(ctx, lc, img2, nil)
+	require.NoError(t, err)
+	require.True(t, pd.NewPlatform)
+	require.Empty(t, pd.RemovedLayers)
+	require.Equal(t, exptypes.LayerStatusNew, pd.Layers[0].Status)
+	var added []string
+	for _, f := range pd.Files.Added {
+		added = append(added, f.Path)
+	}
+	require.Equal(t, []string{"/bin/tool", "/etc/unchanged", "/lib/a"}, added)
+
+	// files of the shared base layer changed only by the reference image are
+	// compared by content
+	refImg3 := image(base(false), func() (ocispecs.Descriptor, digest.Digest) {
+		return layer(true, entry{name: "etc/unchanged", content: "diff"})
+	})
+	img3 := image(base(true))
+	pd, err = diffPlatform(ctx, &layerCache{layers: map[digest.Digest]*layerFiles{}}, img3, refImg3)
+	require.NoError(t, err)
+	require.Equal(t, []exptypes.FileDiff{{Path: "/etc/unchanged", Size: 4}}, pd.Files.Modified)
+	require.Empty(t, pd.Files.Added)
+	require.Empty(t, pd.Files.Removed)
+}
+
+type readRecorder struct {
+	content.Provider
+	read []digest.Digest
+}
+
+func (r *readRecorder) ReaderAt(ctx context.Context, desc ocispecs.Descriptor) (content.ReaderAt, error) {
+	r.read = append(r.read, desc.Digest)
+	return r.Provider.ReaderAt(ctx, desc)
+}
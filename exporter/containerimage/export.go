@@ -20,6 +20,8 @@ import (
 	"github.com/containerd/containerd/v2/pkg/rootfs"
 	cerrdefs "github.com/containerd/errdefs"
 	"github.com/containerd/platforms"
+	"github.com/distribution/reference"
+	"github.com/docker/go-units"
 	"github.com/moby/buildkit/cache"
 	cacheconfig "github.com/moby/buildkit/cache/config"
 	"github.com/moby/buildkit/client"
@@ -28,6 +30,7 @@ import (
 	"github.com/moby/buildkit/session"
 	"github.com/moby/buildkit/snapshot"
 	"github.com/moby/buildkit/solver/llbsolver/compat"
+	"github.com/moby/buildkit/util/bklog"
 	"github.com/moby/buildkit/util/compression"
 	"github.com/moby/buildkit/util/contentutil"
 	"github.com/moby/buildkit/util/errutil"
@@ -110,6 +113,24 @@ func (e *imageExporter) Resolve(ctx context.Context, id int, opt map[string]stri
 				return nil, errors.Wrapf(err, "non-bool value specified for %s", k)
 			}
 			i.pushByDigest = b
+		case exptypes.OptKeyPushRetries:
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "non-int value specified for %s", k)
+			}
+			if n < 0 {
+				return nil, errors.Errorf("invalid value %d specified for %s, must not be negative", n, k)
+			}
+			i.pushOpt.Retries = &n
+		case exptypes.OptKeyPushChunkSize:
+			n, err := units.RAMInBytes(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid size specified for %s", k)
+			}
+			if n < 0 {
+				return nil, errors.Errorf("invalid value %d specified for %s, must not be negative", n, k)
+			}
+			i.pushOpt.ChunkSize = n
 		case exptypes.OptKeyInsecure:
 			if v == "" {
 				i.insecure = true
@@ -162,6 +183,21 @@ func (e *imageExporter) Resolve(ctx context.Context, id int, opt map[string]stri
 				return nil, errors.Wrapf(err, "non-bool value specified for %s", k)
 			}
 			i.danglingEmptyOnly = b
+		case exptypes.OptKeyDiffReference:
+			if v == "" {
+				continue
+			}
+			named, err := reference.ParseNormalizedNamed(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid %s", k)
+			}
+			i.diffReference = reference.TagNameOnly(named).String()
+		case exptypes.OptKeyTags:
+			tags, err := exptypes.ParseTagOptions(v)
+			if err != nil {
+				return nil, err
+			}
+			i.tags = tags
 		case exptypes.OptKeyNameCanonical:
 			if v == "" {
 				i.nameCanonical = true
@@ -190,6 +226,7 @@ type imageExporterInstance struct {
 	opts                 ImageCommitOpts
 	push                 bool
 	pushByDigest         bool
+	pushOpt              push.Opt
 	unpack               bool
 	store                bool
 	storeAllowIncomplete bool
@@ -197,6 +234,8 @@ type imageExporterInstance struct {
 	nameCanonical        bool
 	danglingPrefix       string
 	danglingEmptyOnly    bool
+	tags                 []exptypes.TagOptions
+	diffReference        string
 	meta                 map[string][]byte
 }
 
@@ -264,22 +303,27 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 	}
 
 	nameCanonical := e.nameCanonical
-	if e.danglingPrefix != "" && (!e.danglingEmptyOnly || e.opts.ImageName == "") {
+	if e.danglingPrefix != "" && (!e.danglingEmptyOnly || (e.opts.ImageName == "" && len(e.tags) == 0)) {
 		danglingImageName := e.danglingPrefix + "@" + desc.Digest.String()
 		if e.opts.ImageName != "" {
 			e.opts.ImageName += "," + danglingImageName
 		} else {
 			e.opts.ImageName = danglingImageName
-			nameCanonical = false
+			nameCanonical = nameCanonical && len(e.tags) > 0
 		}
 	}
 
+	tags, err := e.resolveTags(ctx, *desc, buildInfo.SessionID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	// Collect names for finalize callback to push
-	var namesToPush []string
+	var tagsToPush []*imageTag
 
-	if e.opts.ImageName != "" {
-		targetNames := strings.SplitSeq(e.opts.ImageName, ",")
-		for targetName := range targetNames {
+	if len(tags) > 0 {
+		for _, tag := range tags {
+			targetName := tag.Name
 			if e.opt.Images != nil && e.store {
 				tagDone := progress.OneOff(ctx, "naming to "+targetName)
 
@@ -293,14 +337,14 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 					imageClientCtx = epoch.WithSourceDateEpoch(imageClientCtx, e.opts.Epoch.Value)
 				}
 				img := images.Image{
-					Target: *desc,
+					Target: tag.desc,
 					// CreatedAt in images.Images is ignored due to a bug of containerd.
 					// See the comment lines for imageClientCtx.
 				}
 
 				sfx := []string{""}
 				if nameCanonical && !strings.ContainsRune(targetName, '@') {
-					sfx = append(sfx, "@"+desc.Digest.String())
+					sfx = append(sfx, "@"+tag.desc.Digest.String())
 				}
 				for _, sfx := range sfx {
 					img.Name = targetName + sfx
@@ -368,11 +412,20 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 				}
 			}
 			// Collect names for pushing in finalize
-			if e.push {
-				namesToPush = append(namesToPush, targetName)
+			if tag.push {
+				tagsToPush = append(tagsToPush, tag)
 			}
 		}
-		resp[exptypes.ExporterImageNameKey] = e.opts.ImageName
+		names := make([]string, len(tags))
+		for i, tag := range tags {
+			names[i] = tag.Name
+		}
+		resp[exptypes.ExporterImageNameKey] = strings.Join(names, ",")
+		dt, err := tagResults(tags)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		resp[exptypes.ExporterImageTagsKey] = string(dt)
 	}
 
 	resp[exptypes.ExporterImageDigestKey] = desc.Digest.String()
@@ -380,6 +433,10 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 		resp[exptypes.ExporterImageConfigDigestKey] = v
 		delete(desc.Annotations, exptypes.ExporterConfigDigestKey)
 	}
+	if v, ok := desc.Annotations[exptypes.ExporterDedupeSavedKey]; ok {
+		resp[exptypes.ExporterImageDedupeSavedKey] = v
+		delete(desc.Annotations, exptypes.ExporterDedupeSavedKey)
+	}
 
 	dtdesc, err := json.Marshal(desc)
 	if err != nil {
@@ -387,26 +444,56 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 	}
 	resp[exptypes.ExporterImageDescriptorKey] = base64.StdEncoding.EncodeToString(dtdesc)
 
+	var report *exporter.Report
+	if e.diffReference != "" {
+		diffDone := progress.OneOff(ctx, "comparing with "+e.diffReference)
+		diff, err := e.diffImage(ctx, src, buildInfo.SessionID, *desc)
+		if err != nil {
+			return nil, nil, nil, diffDone(err)
+		}
+		diffDone(nil)
+		if diff != nil {
+			dt, err := json.Marshal(diff)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			resp[exptypes.ExporterImageDiffKey] = base64.StdEncoding.EncodeToString(dt)
+			report = &exporter.Report{MediaType: exptypes.ImageDiffMediaType, Data: dt}
+		} else {
+			bklog.G(ctx).Infof("reference image %s not found, skipping image diff", e.diffReference)
+		}
+	}
+
 	// Create descref so descriptor is recorded in build history.
 	// Transfer lease ownership to descref - caller releases after finalize.
-	descref = NewDescriptorReference(*desc, done)
+	descref = &descriptorReference{
+		desc:    *desc,
+		release: done,
+		report:  report,
+	}
 
-	if len(namesToPush) == 0 {
+	if len(tagsToPush) == 0 {
 		return resp, nil, descref, nil
 	}
 
 	// Create finalize callback for pushing
 	finalize := func(ctx context.Context) error {
-		for _, targetName := range namesToPush {
-			err := e.pushImage(ctx, src, buildInfo.SessionID, targetName, desc.Digest)
+		for _, tag := range tagsToPush {
+			err := e.pushImage(ctx, src, buildInfo.SessionID, tag.Name, tag.desc.Digest)
 			if err != nil {
 				var statusErr remoteserrors.ErrUnexpectedStatus
 				if errors.As(err, &statusErr) {
 					err = errutil.WithDetails(err)
 				}
-				return errors.Wrapf(err, "failed to push %v", targetName)
+				return errors.Wrapf(err, "failed to push %v", tag.Name)
 			}
+			tag.status = exptypes.TagStatusPushed
+		}
+		dt, err := tagResults(tags)
+		if err != nil {
+			return err
 		}
+		resp[exptypes.ExporterImageTagsKey] = string(dt)
 		return nil
 	}
 
@@ -414,6 +501,16 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 }
 
 func (e *imageExporterInstance) pushImage(ctx context.Context, src *exporter.Source, sessionID string, targetName string, dgst digest.Digest) error {
+	mprovider, annotations, err := e.imageProvider(ctx, src, sessionID)
+	if err != nil {
+		return err
+	}
+	return push.Push(ctx, e.opt.SessionManager, sessionID, mprovider, e.opt.ImageWriter.ContentStore(), dgst, targetName, e.insecure, e.opt.RegistryHosts, e.pushByDigest, annotations, e.pushOpt)
+}
+
+// imageProvider returns a provider for the blobs of the exported image and the
+// annotations of the layer descriptors.
+func (e *imageExporterInstance) imageProvider(ctx context.Context, src *exporter.Source, sessionID string) (*contentutil.MultiProvider, map[digest.Digest]map[string]string, error) {
 	var refs []cache.ImmutableRef
 	if src.Ref != nil {
 		refs = append(refs, src.Ref)
@@ -430,7 +527,7 @@ func (e *imageExporterInstance) pushImage(ctx context.Context, src *exporter.Sou
 	for _, ref := range refs {
 		remotes, err := ref.GetRemotes(ctx, false, e.opts.RefCfg, false, session.NewGroup(sessionID))
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 		remote := remotes[0]
 		for _, desc := range remote.Descriptors {
@@ -438,7 +535,7 @@ func (e *imageExporterInstance) pushImage(ctx context.Context, src *exporter.Sou
 			addAnnotations(annotations, desc)
 		}
 	}
-	return push.Push(ctx, e.opt.SessionManager, sessionID, mprovider, e.opt.ImageWriter.ContentStore(), dgst, targetName, e.insecure, e.opt.RegistryHosts, e.pushByDigest, annotations)
+	return mprovider, annotations, nil
 }
 
 func (e *imageExporterInstance) unpackImage(ctx context.Context, img images.Image, src *exporter.Source, s session.Group) (err0 error) {
@@ -606,6 +703,11 @@ func NewDescriptorReference(desc ocispecs.Descriptor, release func(context.Conte
 type descriptorReference struct {
 	desc    ocispecs.Descriptor
 	release func(context.Context) error
+	report  *exporter.Report
+}
+
+func (d *descriptorReference) Report() *exporter.Report {
+	return d.report
 }
 
 func (d *descriptorReference) Descriptor() ocispecs.Descriptor {
@@ -0,0 +1,94 @@
+package exptypes
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/moby/buildkit/solver/result"
+	"github.com/pkg/errors"
+)
+
+// Artifacts describes the named artifacts of a result. Artifact refs are
+// stored in the refs map of the result next to the platform refs. Because a
+// result can't have both a single ref and a refs map, a single ref is moved
+// into the map under the Ref key when the result has artifacts.
+type Artifacts struct {
+	Ref       string `json:",omitempty"`
+	Artifacts []Artifact
+}
+
+type Artifact struct {
+	// ID is the key of the artifact in the refs map of the result.
+	ID   string
+	Name string
+	// Platform is the ID of the platform the artifact was built for. It is
+	// only set for multi-platform results.
+	Platform string `json:",omitempty"`
+}
+
+// validArtifactName matches the names of artifacts, the same names that are
+// allowed for the stages of a Dockerfile. Exporters use the name as a
+// directory of the output, so it can't contain path separators or be a
+// relative path like "..".
+var validArtifactName = regexp.MustCompile(`^[a-z][a-z0-9-_.]*$`)
+
+func ParseArtifacts(meta map[string][]byte) (Artifacts, error) {
+	var as Artifacts
+	dt, ok := meta[ExporterArtifactsKey]
+	if !ok {
+		return as, nil
+	}
+	if err := json.Unmarshal(dt, &as); err != nil {
+		return Artifacts{}, errors.Wrapf(err, "failed to parse artifacts")
+	}
+	for _, a := range as.Artifacts {
+		if a.ID == "" || a.Name == "" {
+			return Artifacts{}, errors.Errorf("invalid artifact %+v", a)
+		}
+		if !validArtifactName.MatchString(a.Name) {
+			return Artifacts{}, errors.Errorf("invalid artifact name %q", a.Name)
+		}
+	}
+	return as, nil
+}
+
+// SplitArtifacts separates the artifact refs of res from the platform refs.
+// It returns a copy of res without the artifacts and a result holding only
+// the artifact refs and their metadata. The artifacts result is nil if res
+// has no artifacts.
+func SplitArtifacts[T comparable](res *result.Result[T]) (*result.Result[T], *result.Result[T], error) {
+	as, err := ParseArtifacts(res.Metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, ok := res.Metadata[ExporterArtifactsKey]; !ok {
+		return res, nil, nil
+	}
+
+	out := res.Clone()
+	delete(out.Metadata, ExporterArtifactsKey)
+
+	artifacts := &result.Result[T]{}
+	artifacts.AddMeta(ExporterArtifactsKey, res.Metadata[ExporterArtifactsKey])
+	for _, a := range as.Artifacts {
+		ref, ok := out.Refs[a.ID]
+		if !ok {
+			return nil, nil, errors.Errorf("missing ref for artifact %s", a.Name)
+		}
+		artifacts.AddRef(a.ID, ref)
+		delete(out.Refs, a.ID)
+	}
+
+	if as.Ref != "" {
+		ref, ok := out.Refs[as.Ref]
+		if !ok {
+			return nil, nil, errors.Errorf("missing ref %s for result with artifacts", as.Ref)
+		}
+		out.SetRef(ref)
+		delete(out.Refs, as.Ref)
+	}
+	if len(out.Refs) == 0 {
+		out.Refs = nil
+	}
+	return out, artifacts, nil
+}
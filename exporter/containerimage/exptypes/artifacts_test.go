@@ -0,0 +1,80 @@
+package exptypes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/moby/buildkit/solver/result"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitArtifacts(t *testing.T) {
+	t.Parallel()
+
+	res := &result.Result[string]{}
+	res.AddMeta(ExporterPlatformsKey, []byte("{}"))
+	_, artifacts, err := SplitArtifacts(res)
+	require.NoError(t, err)
+	require.Nil(t, artifacts)
+
+	res = &result.Result[string]{}
+	res.AddRef("linux/amd64", "image")
+	res.AddRef("artifact/report", "report")
+	dt, err := json.Marshal(Artifacts{
+		Ref:       "linux/amd64",
+		Artifacts: []Artifact{{ID: "artifact/report", Name: "report"}},
+	})
+	require.NoError(t, err)
+	res.AddMeta(ExporterArtifactsKey, dt)
+
+	out, artifacts, err := SplitArtifacts(res)
+	require.NoError(t, err)
+	require.Equal(t, "image", out.Ref)
+	require.Nil(t, out.Refs)
+	require.NotContains(t, out.Metadata, ExporterArtifactsKey)
+	require.Equal(t, map[string]string{"artifact/report": "report"}, artifacts.Refs)
+	require.Equal(t, dt, artifacts.Metadata[ExporterArtifactsKey])
+
+	// the input result is not modified
+	require.Len(t, res.Refs, 2)
+	require.Contains(t, res.Metadata, ExporterArtifactsKey)
+
+	res = &result.Result[string]{}
+	res.AddRef("linux/amd64", "amd64")
+	res.AddRef("linux/arm64", "arm64")
+	res.AddRef("artifact/linux/arm64/report", "report")
+	dt, err = json.Marshal(Artifacts{
+		Artifacts: []Artifact{{ID: "artifact/linux/arm64/report", Name: "report", Platform: "linux/arm64"}},
+	})
+	require.NoError(t, err)
+	res.AddMeta(ExporterArtifactsKey, dt)
+
+	out, artifacts, err = SplitArtifacts(res)
+	require.NoError(t, err)
+	require.Empty(t, out.Ref)
+	require.Equal(t, map[string]string{"linux/amd64": "amd64", "linux/arm64": "arm64"}, out.Refs)
+	require.Equal(t, map[string]string{"artifact/linux/arm64/report": "report"}, artifacts.Refs)
+
+	res = &result.Result[string]{}
+	res.AddMeta(ExporterArtifactsKey, dt)
+	_, _, err = SplitArtifacts(res)
+	require.ErrorContains(t, err, "missing ref for artifact report")
+}
+
+func TestParseArtifacts(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"report", "test-results_1.0"} {
+		dt, err := json.Marshal(Artifacts{Artifacts: []Artifact{{ID: "artifact/" + name, Name: name}}})
+		require.NoError(t, err)
+		_, err = ParseArtifacts(map[string][]byte{ExporterArtifactsKey: dt})
+		require.NoError(t, err, name)
+	}
+
+	for _, name := range []string{".", "..", "../out", "a/b", `a\b`, "/report", "Report", "1report"} {
+		dt, err := json.Marshal(Artifacts{Artifacts: []Artifact{{ID: "artifact/report", Name: name}}})
+		require.NoError(t, err)
+		_, err = ParseArtifacts(map[string][]byte{ExporterArtifactsKey: dt})
+		require.ErrorContains(t, err, "invalid artifact name", name)
+	}
+}
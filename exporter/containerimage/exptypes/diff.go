@@ -0,0 +1,64 @@
+package exptypes
+
+import (
+	digest "github.com/opencontainers/go-digest"
+)
+
+// ImageDiffMediaType is the media type of the ImageDiff report stored in the
+// build history.
+const ImageDiffMediaType = "application/vnd.buildkit.image-diff.v0+json"
+
+// ImageDiff describes the changes of an exported image compared to the
+// reference image set with OptKeyDiffReference.
+type ImageDiff struct {
+	Reference       string         `json:"reference"`
+	ReferenceDigest digest.Digest  `json:"referenceDigest"`
+	Platforms       []PlatformDiff `json:"platforms"`
+}
+
+// PlatformDiff describes the changes of the image for a single platform.
+type PlatformDiff struct {
+	Platform string `json:"platform"`
+	// NewPlatform is set if the reference image has no image for the
+	// platform. All layers and files are reported as new then.
+	NewPlatform   bool        `json:"newPlatform,omitempty"`
+	Layers        []LayerDiff `json:"layers"`
+	RemovedLayers []LayerDiff `json:"removedLayers,omitempty"`
+	Files         FilesDiff   `json:"files"`
+}
+
+type LayerStatus string
+
+const (
+	LayerStatusNew    LayerStatus = "new"
+	LayerStatusReused LayerStatus = "reused"
+)
+
+type LayerDiff struct {
+	Digest digest.Digest `json:"digest"`
+	DiffID digest.Digest `json:"diffID"`
+	Size   int64         `json:"size"`
+	Status LayerStatus   `json:"status,omitempty"`
+}
+
+// FilesDiff summarizes the changed files of the image filesystem. Directories
+// are not listed. The lists are sorted by path and contain at most
+// MaxDiffFiles entries each.
+type FilesDiff struct {
+	Added     []FileDiff `json:"added,omitempty"`
+	Removed   []FileDiff `json:"removed,omitempty"`
+	Modified  []FileDiff `json:"modified,omitempty"`
+	SizeDelta int64      `json:"sizeDelta"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+// MaxDiffFiles is the maximum number of files in each list of FilesDiff.
+const MaxDiffFiles = 10000
+
+type FileDiff struct {
+	Path string `json:"path"`
+	// Size is the size in the exported image, or in the reference image for
+	// removed files.
+	Size      int64 `json:"size"`
+	SizeDelta int64 `json:"sizeDelta,omitempty"`
+}
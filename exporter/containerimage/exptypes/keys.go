@@ -18,6 +18,15 @@ var (
 	// Value: bool <true|false>
 	OptKeyPushByDigest ImageExporterOptKey = "push-by-digest"
 
+	// Number of times a failed upload of the push is retried.
+	// Value: int
+	OptKeyPushRetries ImageExporterOptKey = "push-retries"
+
+	// Size of the chunks blobs are uploaded in. Failed chunked uploads are
+	// resumed instead of started again.
+	// Value: size in bytes, e.g. 64MiB
+	OptKeyPushChunkSize ImageExporterOptKey = "push-chunk-size"
+
 	// Allow pushing to insecure HTTP registry.
 	// Value: bool <true|false>
 	OptKeyInsecure ImageExporterOptKey = "registry.insecure"
@@ -86,4 +95,34 @@ var (
 	// Rewrite timestamps in layers to match SOURCE_DATE_EPOCH
 	// Value: bool <true|false>
 	OptKeyRewriteTimestamp ImageExporterOptKey = "rewrite-timestamp"
+
+	// Squash layers of the image into a single layer. "from-base" keeps the
+	// layers of the base image and squashes the layers added on top of it.
+	// "range" squashes the layers selected with OptKeySquashRange.
+	// Value: string <all|from-base|range>
+	OptKeySquash ImageExporterOptKey = "squash"
+
+	// Layers squashed by squash=range, as zero-based inclusive indexes.
+	// Value: string <from>:<to>
+	OptKeySquashRange ImageExporterOptKey = "squash-range"
+
+	// Maximum number of layers of the image. The smallest adjacent layers are
+	// squashed until the image fits the budget.
+	// Value: int
+	OptKeyMaxLayers ImageExporterOptKey = "max-layers"
+
+	// Remove files from layers that are identical to the file in the layers
+	// below. The filesystem of the image does not change.
+	// Value: bool <true|false>
+	OptKeyDedupeFiles ImageExporterOptKey = "dedupe-files"
+
+	// Reference image the exported image is compared with. The report of the
+	// changed layers and files is returned in ExporterImageDiffKey.
+	// Value: image reference
+	OptKeyDiffReference ImageExporterOptKey = "diff-reference"
+
+	// Names of the image with options for each name. Names in the list
+	// override the options of the same names in OptKeyName.
+	// Value: JSON array of TagOptions
+	OptKeyTags ImageExporterOptKey = "tags"
 )
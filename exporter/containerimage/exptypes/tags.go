@@ -0,0 +1,72 @@
+package exptypes
+
+import (
+	"encoding/json"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// TagPushPolicy controls if an image name is pushed to the registry.
+type TagPushPolicy string
+
+const (
+	// TagPushDefault pushes the name if the push option of the exporter is set.
+	TagPushDefault TagPushPolicy = ""
+	// TagPushAlways always pushes the name.
+	TagPushAlways TagPushPolicy = "always"
+	// TagPushNever never pushes the name.
+	TagPushNever TagPushPolicy = "never"
+	// TagPushIfChanged pushes the name only if the tag in the registry
+	// points to a different digest.
+	TagPushIfChanged TagPushPolicy = "if-changed"
+	// TagPushIfNotExists pushes the name only if the tag does not exist in
+	// the registry. Exporting fails if the existing tag points to a different
+	// digest.
+	TagPushIfNotExists TagPushPolicy = "if-not-exists"
+)
+
+// TagOptions are the options of a single image name set with OptKeyTags.
+type TagOptions struct {
+	Name string        `json:"name"`
+	Push TagPushPolicy `json:"push,omitempty"`
+	// Annotations are added to the root manifest or index of the image for
+	// this name only. The name then points to a different digest than the
+	// image digest of the exporter response.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// TagStatus describes what the exporter did with an image name.
+type TagStatus string
+
+const (
+	TagStatusPushed    TagStatus = "pushed"
+	TagStatusUnchanged TagStatus = "unchanged"
+	TagStatusSkipped   TagStatus = "skipped"
+)
+
+// TagResult is returned for each image name in ExporterImageTagsKey.
+type TagResult struct {
+	Name   string        `json:"name"`
+	Digest digest.Digest `json:"digest"`
+	Status TagStatus     `json:"status"`
+}
+
+// ParseTagOptions parses the value of OptKeyTags.
+func ParseTagOptions(v string) ([]TagOptions, error) {
+	var tags []TagOptions
+	if err := json.Unmarshal([]byte(v), &tags); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", OptKeyTags)
+	}
+	for _, t := range tags {
+		if t.Name == "" {
+			return nil, errors.Errorf("empty name in %s", OptKeyTags)
+		}
+		switch t.Push {
+		case TagPushDefault, TagPushAlways, TagPushNever, TagPushIfChanged, TagPushIfNotExists:
+		default:
+			return nil, errors.Errorf("invalid push policy %q for %s", t.Push, t.Name)
+		}
+	}
+	return tags, nil
+}
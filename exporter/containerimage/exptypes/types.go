@@ -9,13 +9,18 @@ import (
 
 const (
 	ExporterConfigDigestKey      = "config.digest"
+	ExporterDedupeSavedKey       = "dedupe.saved"
 	ExporterImageNameKey         = "image.name"
 	ExporterImageDigestKey       = "containerimage.digest"
 	ExporterImageConfigKey       = "containerimage.config"
 	ExporterImageConfigDigestKey = "containerimage.config.digest"
 	ExporterImageDescriptorKey   = "containerimage.descriptor"
+	ExporterImageTagsKey         = "containerimage.tags"
+	ExporterImageDiffKey         = "containerimage.diff"
+	ExporterImageDedupeSavedKey  = "containerimage.dedupe.saved"
 	ExporterImageBaseConfigKey   = "containerimage.base.config"
 	ExporterPlatformsKey         = "refs.platforms"
+	ExporterArtifactsKey         = "refs.artifacts"
 )
 
 // KnownRefMetadataKeys are the subset of exporter keys that can be suffixed by
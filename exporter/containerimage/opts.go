@@ -21,6 +21,12 @@ type ImageCommitOpts struct {
 
 	ForceInlineAttestations bool // force inline attestations to be attached
 	RewriteTimestamp        bool // rewrite timestamps in layers to match the epoch
+
+	Squash      SquashMode
+	SquashRange *[2]int // inclusive layer indexes for SquashRange
+	MaxLayers   int
+
+	DedupeFiles bool // remove files identical to the layers below
 }
 
 func (c *ImageCommitOpts) Load(ctx context.Context, opt map[string]string) (map[string]string, error) {
@@ -60,6 +66,19 @@ func (c *ImageCommitOpts) Load(ctx context.Context, opt map[string]string) (map[
 			err = parseBool(&c.RefCfg.PreferNonDistributable, k, v)
 		case exptypes.OptKeyRewriteTimestamp:
 			err = parseBool(&c.RewriteTimestamp, k, v)
+		case exptypes.OptKeySquash:
+			c.Squash, err = parseSquashMode(v)
+		case exptypes.OptKeySquashRange:
+			c.SquashRange, err = parseSquashRange(v)
+		case exptypes.OptKeyDedupeFiles:
+			err = parseBool(&c.DedupeFiles, k, v)
+		case exptypes.OptKeyMaxLayers:
+			c.MaxLayers, err = strconv.Atoi(v)
+			if err != nil {
+				err = errors.Wrapf(err, "non-int value specified for %s", k)
+			} else if c.MaxLayers < 1 {
+				err = errors.Errorf("invalid value %d specified for %s, must be positive", c.MaxLayers, k)
+			}
 		default:
 			rest[k] = v
 		}
@@ -79,6 +98,9 @@ func (c *ImageCommitOpts) Load(ctx context.Context, opt map[string]string) (map[
 }
 
 func (c *ImageCommitOpts) Validate() error {
+	if (c.Squash == SquashRange) != (c.SquashRange != nil) {
+		return errors.New("exporter option \"squash-range\" must be set together with \"squash=range\"")
+	}
 	if c.OCITypes == nil {
 		return nil
 	}
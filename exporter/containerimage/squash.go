@@ -0,0 +1,360 @@
+package containerimage
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/containerd/v2/pkg/labels"
+	"github.com/moby/buildkit/cache"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/util/progress"
+	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+type SquashMode string
+
+const (
+	SquashNone     SquashMode = ""
+	SquashAll      SquashMode = "all"
+	SquashFromBase SquashMode = "from-base"
+	SquashRange    SquashMode = "range"
+)
+
+func parseSquashMode(v string) (SquashMode, error) {
+	switch m := SquashMode(v); m {
+	case SquashNone, SquashAll, SquashFromBase, SquashRange:
+		return m, nil
+	default:
+		return "", errors.Errorf("invalid squash mode %q, must be one of all, from-base or range", v)
+	}
+}
+
+func parseSquashRange(v string) (*[2]int, error) {
+	from, to, ok := strings.Cut(v, ":")
+	if !ok {
+		return nil, errors.Errorf("invalid squash range %q, must be <from>:<to>", v)
+	}
+	var r [2]int
+	for i, s := range []string{from, to} {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid squash range %q", v)
+		}
+		if n < 0 {
+			return nil, errors.Errorf("invalid squash range %q, indexes must not be negative", v)
+		}
+		r[i] = n
+	}
+	if r[0] > r[1] {
+		return nil, errors.Errorf("invalid squash range %q, from must not be greater than to", v)
+	}
+	return &r, nil
+}
+
+// layerGroup is a range of layers [start, end) that is exported as a single
+// layer.
+type layerGroup struct {
+	start, end int
+}
+
+func (g layerGroup) squashed() bool {
+	return g.end-g.start > 1
+}
+
+// planSquash groups the layers of an image according to the squash options.
+// baseLayers is the number of layers shared with the base image. It returns nil
+// if no layers need to be squashed.
+func planSquash(opts *ImageCommitOpts, sizes []int64, baseLayers int) ([]layerGroup, error) {
+	n := len(sizes)
+	groups := make([]layerGroup, n)
+	for i := range groups {
+		groups[i] = layerGroup{start: i, end: i + 1}
+	}
+	switch opts.Squash {
+	case SquashAll:
+		groups = joinGroups(groups, 0, n)
+	case SquashFromBase:
+		groups = joinGroups(groups, baseLayers, n)
+	case SquashRange:
+		if opts.SquashRange == nil {
+			return nil, errors.New("squash=range requires squash-range")
+		}
+		from, to := opts.SquashRange[0], opts.SquashRange[1]
+		if to >= n {
+			return nil, errors.Errorf("invalid squash range %d:%d for image with %d layers", from, to, n)
+		}
+		groups = joinGroups(groups, from, to+1)
+	}
+
+	if opts.MaxLayers > 0 {
+		groupSize := func(g layerGroup) int64 {
+			var size int64
+			for _, s := range sizes[g.start:g.end] {
+				size += s
+			}
+			return size
+		}
+		for len(groups) > opts.MaxLayers {
+			// join the smallest adjacent pair, keeping the base image
+			// layers shareable as long as possible
+			best := -1
+			var bestSize int64
+			for _, keepBase := range []bool{true, false} {
+				for i := 0; i+1 < len(groups); i++ {
+					if keepBase && groups[i].start < baseLayers {
+						continue
+					}
+					size := groupSize(groups[i]) + groupSize(groups[i+1])
+					if best == -1 || size < bestSize {
+						best, bestSize = i, size
+					}
+				}
+				if best != -1 {
+					break
+				}
+			}
+			groups = joinGroups(groups, groups[best].start, groups[best+1].end)
+		}
+	}
+
+	for _, g := range groups {
+		if g.squashed() {
+			return groups, nil
+		}
+	}
+	return nil, nil
+}
+
+// joinGroups replaces the groups covering the layers [start, end) with a single
+// group.
+func joinGroups(groups []layerGroup, start, end int) []layerGroup {
+	if end-start < 2 {
+		return groups
+	}
+	out := make([]layerGroup, 0, len(groups))
+	for _, g := range groups {
+		switch {
+		case g.end <= start || g.start >= end:
+			out = append(out, g)
+		case len(out) > 0 && out[len(out)-1].end > start:
+			out[len(out)-1].end = max(out[len(out)-1].end, g.end)
+		default:
+			out = append(out, layerGroup{start: min(g.start, start), end: max(g.end, end)})
+		}
+	}
+	return out
+}
+
+// baseImageLayers returns the number of bottom layers of the remote that are
+// shared with the base image.
+func baseImageLayers(remote *solver.Remote, baseImg *dockerspec.DockerOCIImage) int {
+	if baseImg == nil {
+		return 0
+	}
+	var n int
+	for i, desc := range remote.Descriptors {
+		if i >= len(baseImg.RootFS.DiffIDs) || digest.Digest(desc.Annotations[labels.LabelUncompressed]) != baseImg.RootFS.DiffIDs[i] {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// squashLayers squashes the layers of ref according to the squash options. It
+// returns nil if no layers need to be squashed. Otherwise the returned ref must
+// be released by the caller and the returned remote replaces the remote of ref.
+// Layers that are not squashed keep their blobs.
+func (ic *ImageWriter) squashLayers(ctx context.Context, opts *ImageCommitOpts, ref cache.ImmutableRef, remote *solver.Remote, baseImg *dockerspec.DockerOCIImage, sg session.Group) (_ cache.ImmutableRef, _ *solver.Remote, _ []layerGroup, rerr error) {
+	if ref == nil || (opts.Squash == SquashNone && opts.MaxLayers == 0) {
+		return nil, remote, nil, nil
+	}
+	layers := ref.LayerChain()
+	defer layers.Release(context.WithoutCancel(ctx))
+	if len(layers) != len(remote.Descriptors) {
+		return nil, nil, nil, errors.Errorf("unexpected layer count %d for %d blobs", len(layers), len(remote.Descriptors))
+	}
+
+	sizes := make([]int64, len(remote.Descriptors))
+	for i, desc := range remote.Descriptors {
+		sizes[i] = desc.Size
+	}
+	groups, err := planSquash(opts, sizes, baseImageLayers(remote, baseImg))
+	if err != nil || groups == nil {
+		return nil, remote, nil, err
+	}
+	if ic.opt.CacheAccessor == nil {
+		return nil, nil, nil, errors.New("squashing layers is not supported by this worker")
+	}
+	cm := ic.opt.CacheAccessor
+
+	squashDone := progress.OneOff(ctx, "squashing layers")
+	defer func() {
+		squashDone(rerr)
+	}()
+
+	states := newLayerStates(cm, layers)
+	defer states.Release(context.WithoutCancel(ctx))
+
+	parents := make([]cache.ImmutableRef, 0, len(groups))
+	for _, g := range groups {
+		if !g.squashed() {
+			single, err := states.single(ctx, g.start)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			parents = append(parents, single)
+			continue
+		}
+		lower, err := states.state(ctx, g.start)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		upper, err := states.state(ctx, g.end)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		squashed, err := cm.Diff(ctx, lower, upper, nil, cache.ComputeDiff, cache.WithDescription("squashed layers"))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		states.refs = append(states.refs, squashed)
+		parents = append(parents, squashed)
+	}
+
+	out, err := cm.Merge(ctx, parents, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	remotes, err := out.GetRemotes(ctx, true, opts.RefCfg, false, sg)
+	if err != nil {
+		out.Release(context.WithoutCancel(ctx))
+		return nil, nil, nil, err
+	}
+	if len(remotes[0].Descriptors) != len(groups) {
+		out.Release(context.WithoutCancel(ctx))
+		return nil, nil, nil, errors.Errorf("unexpected layer count %d after squashing to %d layers", len(remotes[0].Descriptors), len(groups))
+	}
+	return out, remotes[0], groups, nil
+}
+
+// layerStates provides refs for the filesystem states between the layers of
+// an image. Refs created for the states are owned by layerStates.
+type layerStates struct {
+	cm      cache.Accessor
+	layers  cache.RefList
+	singles []cache.ImmutableRef
+	refs    cache.RefList
+}
+
+func newLayerStates(cm cache.Accessor, layers cache.RefList) *layerStates {
+	return &layerStates{
+		cm:      cm,
+		layers:  layers,
+		singles: make([]cache.ImmutableRef, len(layers)),
+	}
+}
+
+// single returns a ref containing only the changes of layer i.
+func (s *layerStates) single(ctx context.Context, i int) (cache.ImmutableRef, error) {
+	if s.singles[i] != nil {
+		return s.singles[i], nil
+	}
+	layer := s.layers[i]
+	chain := layer.LayerChain()
+	defer chain.Release(context.WithoutCancel(ctx))
+	if len(chain) == 1 {
+		s.singles[i] = layer
+		return layer, nil
+	}
+	single, err := s.cm.Diff(ctx, chain[len(chain)-2], layer, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.refs = append(s.refs, single)
+	s.singles[i] = single
+	return single, nil
+}
+
+// state returns the filesystem after the first n layers, or nil for scratch.
+func (s *layerStates) state(ctx context.Context, n int) (cache.ImmutableRef, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	// layers that are applied on top of all the layers below them already
+	// contain the state
+	chain := s.layers[n-1].LayerChain()
+	defer chain.Release(context.WithoutCancel(ctx))
+	if len(chain) == n {
+		ok := true
+		for i, l := range chain {
+			if l.ID() != s.layers[i].ID() {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return s.layers[n-1], nil
+		}
+	}
+	for i := range n {
+		if _, err := s.single(ctx, i); err != nil {
+			return nil, err
+		}
+	}
+	st, err := s.cm.Merge(ctx, s.singles[:n], nil)
+	if err != nil {
+		return nil, err
+	}
+	s.refs = append(s.refs, st)
+	return st, nil
+}
+
+func (s *layerStates) Release(ctx context.Context) error {
+	return s.refs.Release(ctx)
+}
+
+// squashHistory marks the history of every squashed layer but the topmost of
+// its group as empty so that the history matches the squashed layers. The
+// history is first normalized against the layers of ref, the unsquashed
+// image, so that a history that doesn't cover all the layers is mapped to the
+// right groups.
+func squashHistory(ctx context.Context, config []byte, groups []layerGroup, ref cache.ImmutableRef) ([]byte, error) {
+	if len(config) == 0 || groups == nil {
+		return config, nil
+	}
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(config, &m); err != nil {
+		return nil, errors.Wrap(err, "failed to parse image config for squash")
+	}
+	history, err := parseHistoryFromConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	history = normalizeHistory(ctx, history, ref, groups[len(groups)-1].end)
+	var layer, group int
+	for i, h := range history {
+		if h.EmptyLayer {
+			continue
+		}
+		for group < len(groups) && groups[group].end <= layer {
+			group++
+		}
+		if group < len(groups) && layer != groups[group].end-1 {
+			history[i].EmptyLayer = true
+		}
+		layer++
+	}
+	dt, err := json.Marshal(history)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal history")
+	}
+	m["history"] = dt
+	dt, err = json.Marshal(m)
+	return dt, errors.Wrap(err, "failed to marshal config after squash")
+}
@@ -0,0 +1,153 @@
+package containerimage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanSquash(t *testing.T) {
+	t.Parallel()
+
+	sizes := []int64{100, 50, 10, 20, 5, 30}
+	tcs := []struct {
+		name       string
+		opts       ImageCommitOpts
+		baseLayers int
+		expected   []layerGroup
+		err        string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:     "all",
+			opts:     ImageCommitOpts{Squash: SquashAll},
+			expected: []layerGroup{{0, 6}},
+		},
+		{
+			name:       "from-base",
+			opts:       ImageCommitOpts{Squash: SquashFromBase},
+			baseLayers: 2,
+			expected:   []layerGroup{{0, 1}, {1, 2}, {2, 6}},
+		},
+		{
+			name:       "from-base single layer",
+			opts:       ImageCommitOpts{Squash: SquashFromBase},
+			baseLayers: 5,
+		},
+		{
+			name:     "range",
+			opts:     ImageCommitOpts{Squash: SquashRange, SquashRange: &[2]int{1, 3}},
+			expected: []layerGroup{{0, 1}, {1, 4}, {4, 5}, {5, 6}},
+		},
+		{
+			name: "range out of bounds",
+			opts: ImageCommitOpts{Squash: SquashRange, SquashRange: &[2]int{1, 6}},
+			err:  "invalid squash range",
+		},
+		{
+			name:     "max-layers",
+			opts:     ImageCommitOpts{MaxLayers: 4},
+			expected: []layerGroup{{0, 1}, {1, 2}, {2, 5}, {5, 6}},
+		},
+		{
+			name:       "max-layers prefers non-base layers",
+			opts:       ImageCommitOpts{MaxLayers: 3},
+			baseLayers: 3,
+			expected:   []layerGroup{{0, 1}, {1, 3}, {3, 6}},
+		},
+		{
+			name:       "max-layers squashes base",
+			opts:       ImageCommitOpts{MaxLayers: 2},
+			baseLayers: 3,
+			expected:   []layerGroup{{0, 1}, {1, 6}},
+		},
+		{
+			name:     "max-layers after range",
+			opts:     ImageCommitOpts{Squash: SquashRange, SquashRange: &[2]int{0, 1}, MaxLayers: 3},
+			expected: []layerGroup{{0, 2}, {2, 5}, {5, 6}},
+		},
+		{
+			name: "max-layers not reached",
+			opts: ImageCommitOpts{MaxLayers: 10},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			groups, err := planSquash(&tc.opts, sizes, tc.baseLayers)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, groups)
+		})
+	}
+}
+
+func TestParseSquashRange(t *testing.T) {
+	t.Parallel()
+
+	r, err := parseSquashRange("2:4")
+	require.NoError(t, err)
+	require.Equal(t, [2]int{2, 4}, *r)
+
+	for _, v := range []string{"2", "a:4", "-1:2", "4:2"} {
+		_, err := parseSquashRange(v)
+		require.Error(t, err, v)
+	}
+}
+
+func TestSquashHistory(t *testing.T) {
+	t.Parallel()
+
+	config, err := json.Marshal(map[string]any{
+		"architecture": "amd64",
+		"history": []ocispecs.History{
+			{CreatedBy: "base"},
+			{CreatedBy: "env", EmptyLayer: true},
+			{CreatedBy: "run 1"},
+			{CreatedBy: "run 2"},
+			{CreatedBy: "copy"},
+		},
+	})
+	require.NoError(t, err)
+
+	dt, err := squashHistory(context.TODO(), config, []layerGroup{{0, 1}, {1, 3}, {3, 4}}, nil)
+	require.NoError(t, err)
+	history, err := parseHistoryFromConfig(dt)
+	require.NoError(t, err)
+
+	var empty []bool
+	for _, h := range history {
+		empty = append(empty, h.EmptyLayer)
+	}
+	require.Equal(t, []bool{false, true, true, false, false}, empty)
+	require.Contains(t, string(dt), `"architecture":"amd64"`)
+
+	// the history only covers the base layer, the items of the other
+	// layers are added before they are squashed
+	config, err = json.Marshal(map[string]any{
+		"architecture": "amd64",
+		"history": []ocispecs.History{
+			{CreatedBy: "base"},
+			{CreatedBy: "env", EmptyLayer: true},
+		},
+	})
+	require.NoError(t, err)
+
+	dt, err = squashHistory(context.TODO(), config, []layerGroup{{0, 1}, {1, 3}}, nil)
+	require.NoError(t, err)
+	history, err = parseHistoryFromConfig(dt)
+	require.NoError(t, err)
+
+	empty = nil
+	for _, h := range history {
+		empty = append(empty, h.EmptyLayer)
+	}
+	require.Equal(t, []bool{false, true, true, false}, empty)
+}
@@ -0,0 +1,144 @@
+package containerimage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/util/progress"
+	"github.com/moby/buildkit/util/push"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+type imageTag struct {
+	exptypes.TagOptions
+	desc ocispecs.Descriptor
+	// push is set if the name is pushed when the export is finalized. The
+	// status only changes to pushed once the push has succeeded.
+	push   bool
+	status exptypes.TagStatus
+}
+
+// resolveTags returns the names the image is exported with. The names of the
+// tags option override the options of the same names in the name option.
+func (e *imageExporterInstance) resolveTags(ctx context.Context, desc ocispecs.Descriptor, sessionID string) ([]*imageTag, error) {
+	var tags []*imageTag
+	byName := map[string]*imageTag{}
+	add := func(opt exptypes.TagOptions) {
+		if t, ok := byName[opt.Name]; ok {
+			t.TagOptions = opt
+			return
+		}
+		t := &imageTag{TagOptions: opt}
+		byName[opt.Name] = t
+		tags = append(tags, t)
+	}
+	if e.opts.ImageName != "" {
+		for name := range strings.SplitSeq(e.opts.ImageName, ",") {
+			add(exptypes.TagOptions{Name: name})
+		}
+	}
+	for _, opt := range e.tags {
+		add(opt)
+	}
+
+	for _, t := range tags {
+		t.desc = desc
+		if len(t.Annotations) > 0 {
+			d, err := annotateRoot(ctx, e.opt.ImageWriter.ContentStore(), desc, t.Annotations)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to add annotations for %s", t.Name)
+			}
+			t.desc = d
+		}
+
+		t.status = exptypes.TagStatusSkipped
+		switch t.Push {
+		case exptypes.TagPushDefault:
+			t.push = e.push
+		case exptypes.TagPushAlways:
+			t.push = true
+		case exptypes.TagPushNever:
+		case exptypes.TagPushIfChanged, exptypes.TagPushIfNotExists:
+			if e.pushByDigest {
+				return nil, errors.Errorf("push policy %s can't be used with %s", t.Push, exptypes.OptKeyPushByDigest)
+			}
+			checkDone := progress.OneOff(ctx, "checking tag "+t.Name)
+			current, err := push.ResolveTag(ctx, e.opt.SessionManager, sessionID, t.Name, e.insecure, e.opt.RegistryHosts)
+			if err != nil {
+				return nil, checkDone(err)
+			}
+			checkDone(nil)
+			switch {
+			case current == nil:
+				t.push = true
+			case current.Digest == t.desc.Digest:
+				t.status = exptypes.TagStatusUnchanged
+			case t.Push == exptypes.TagPushIfNotExists:
+				return nil, errors.Errorf("tag %s already exists with digest %s", t.Name, current.Digest)
+			default:
+				t.push = true
+			}
+		}
+	}
+	return tags, nil
+}
+
+func tagResults(tags []*imageTag) ([]byte, error) {
+	res := make([]exptypes.TagResult, len(tags))
+	for i, t := range tags {
+		res[i] = exptypes.TagResult{
+			Name:   t.Name,
+			Digest: t.desc.Digest,
+			Status: t.status,
+		}
+	}
+	return json.Marshal(res)
+}
+
+// annotateRoot writes a copy of the root manifest or index of the image with
+// additional annotations. The garbage collection labels of the original blob
+// are copied so the children stay referenced.
+func annotateRoot(ctx context.Context, cs content.Store, desc ocispecs.Descriptor, annotations map[string]string) (ocispecs.Descriptor, error) {
+	dt, err := content.ReadBlob(ctx, cs, desc)
+	if err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(dt, &root); err != nil {
+		return ocispecs.Descriptor{}, errors.Wrapf(err, "failed to parse %s", desc.Digest)
+	}
+	existing := map[string]string{}
+	if v, ok := root["annotations"]; ok {
+		if err := json.Unmarshal(v, &existing); err != nil {
+			return ocispecs.Descriptor{}, errors.Wrapf(err, "failed to parse annotations of %s", desc.Digest)
+		}
+	}
+	maps.Copy(existing, annotations)
+	if root["annotations"], err = json.Marshal(existing); err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+	if dt, err = json.MarshalIndent(root, "", "  "); err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+
+	info, err := cs.Info(ctx, desc.Digest)
+	if err != nil {
+		return ocispecs.Descriptor{}, err
+	}
+
+	newDesc := desc
+	newDesc.Digest = digest.FromBytes(dt)
+	newDesc.Size = int64(len(dt))
+	newDesc.Annotations = maps.Clone(desc.Annotations)
+	if err := content.WriteBlob(ctx, cs, newDesc.Digest.String(), bytes.NewReader(dt), newDesc, content.WithLabels(info.Labels)); err != nil {
+		return ocispecs.Descriptor{}, errors.Wrap(err, "error writing annotated root")
+	}
+	return newDesc, nil
+}
@@ -0,0 +1,89 @@
+package containerimage
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/util/contentutil"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTagOptions(t *testing.T) {
+	t.Parallel()
+
+	tags, err := exptypes.ParseTagOptions(`[{"name":"docker.io/foo/bar:v1","push":"if-not-exists"},{"name":"example.com/bar:latest","annotations":{"foo":"bar"}}]`)
+	require.NoError(t, err)
+	require.Len(t, tags, 2)
+	require.Equal(t, exptypes.TagPushIfNotExists, tags[0].Push)
+	require.Equal(t, map[string]string{"foo": "bar"}, tags[1].Annotations)
+
+	_, err = exptypes.ParseTagOptions(`[{"name":"foo","push":"sometimes"}]`)
+	require.ErrorContains(t, err, "invalid push policy")
+
+	_, err = exptypes.ParseTagOptions(`[{"push":"always"}]`)
+	require.ErrorContains(t, err, "empty name")
+}
+
+func TestResolveTags(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	cs := contentutil.NewBuffer()
+	dt := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[],"annotations":{"a":"1"}}`)
+	desc := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageIndex,
+		Digest:    digest.FromBytes(dt),
+		Size:      int64(len(dt)),
+	}
+	require.NoError(t, content.WriteBlob(ctx, cs, desc.Digest.String(), bytes.NewReader(dt), desc))
+
+	iw, err := NewImageWriter(WriterOpt{ContentStore: cs})
+	require.NoError(t, err)
+	e := &imageExporterInstance{
+		imageExporter: &imageExporter{opt: Opt{ImageWriter: iw}},
+		opts:          ImageCommitOpts{ImageName: "foo:v1,foo:v2"},
+		push:          true,
+		tags: []exptypes.TagOptions{
+			{Name: "foo:v2", Push: exptypes.TagPushNever},
+			{Name: "example.com/foo:v1", Annotations: map[string]string{"b": "2"}},
+		},
+	}
+
+	tags, err := e.resolveTags(ctx, desc, "")
+	require.NoError(t, err)
+	require.Len(t, tags, 3)
+
+	// names are only marked as pushed once the push has succeeded
+	require.Equal(t, "foo:v1", tags[0].Name)
+	require.True(t, tags[0].push)
+	require.Equal(t, exptypes.TagStatusSkipped, tags[0].status)
+	require.Equal(t, desc.Digest, tags[0].desc.Digest)
+
+	require.Equal(t, "foo:v2", tags[1].Name)
+	require.False(t, tags[1].push)
+	require.Equal(t, exptypes.TagStatusSkipped, tags[1].status)
+
+	require.Equal(t, "example.com/foo:v1", tags[2].Name)
+	require.True(t, tags[2].push)
+	require.NotEqual(t, desc.Digest, tags[2].desc.Digest)
+
+	dt, err = content.ReadBlob(ctx, cs, tags[2].desc)
+	require.NoError(t, err)
+	var idx ocispecs.Index
+	require.NoError(t, json.Unmarshal(dt, &idx))
+	require.Equal(t, map[string]string{"a": "1", "b": "2"}, idx.Annotations)
+	require.Equal(t, ocispecs.MediaTypeImageIndex, idx.MediaType)
+
+	dt, err = tagResults(tags)
+	require.NoError(t, err)
+	var res []exptypes.TagResult
+	require.NoError(t, json.Unmarshal(dt, &res))
+	require.Len(t, res, 3)
+	require.Equal(t, tags[2].desc.Digest, res[2].Digest)
+	require.Equal(t, exptypes.TagStatusSkipped, res[1].Status)
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"reflect"
 	"strconv"
 	"strings"
@@ -51,10 +52,11 @@ import (
 const attestationManifestArtifactType = "application/vnd.docker.attestation.manifest.v1+json"
 
 type WriterOpt struct {
-	Snapshotter  snapshot.Snapshotter
-	ContentStore content.Store
-	Applier      diff.Applier
-	Differ       diff.Comparer
+	Snapshotter   snapshot.Snapshotter
+	ContentStore  content.Store
+	Applier       diff.Applier
+	Differ        diff.Comparer
+	CacheAccessor cache.Accessor // used for squashing layers
 }
 
 func NewImageWriter(opt WriterOpt) (*ImageWriter, error) {
@@ -162,12 +164,29 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 			return nil, err
 		}
 		remote := &remotes[0]
+		squashed, remote, groups, err := ic.squashLayers(ctx, opts, ref, remote, baseImg, session.NewGroup(sessionID))
+		if err != nil {
+			return nil, err
+		}
+		if squashed != nil {
+			defer squashed.Release(context.WithoutCancel(ctx))
+			if config, err = squashHistory(ctx, config, groups, ref); err != nil {
+				return nil, err
+			}
+			ref = squashed
+		}
 		if opts.RewriteTimestamp {
 			remote, err = ic.rewriteRemoteWithEpoch(ctx, opts, remote, baseImg, expEpoch)
 			if err != nil {
 				return nil, err
 			}
 		}
+		// files are deduplicated after the timestamps were rewritten, as
+		// files written again with the same content only match then
+		remote, dedupeSaved, err := ic.dedupeLayers(ctx, opts, ref, remote, baseImg, session.NewGroup(sessionID))
+		if err != nil {
+			return nil, err
+		}
 
 		annotations := opts.Annotations.Platform(nil)
 		if len(annotations.Index) > 0 || len(annotations.IndexDescriptor) > 0 {
@@ -200,6 +219,9 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 			mfstDesc.Platform = &ps.Platforms[0].Platform
 		}
 		mfstDesc.Annotations[exptypes.ExporterConfigDigestKey] = configDesc.Digest.String()
+		if opts.DedupeFiles {
+			mfstDesc.Annotations[exptypes.ExporterDedupeSavedKey] = strconv.FormatInt(dedupeSaved, 10)
+		}
 
 		return mfstDesc, nil
 	}
@@ -247,6 +269,7 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 	labels := map[string]string{}
 
 	var attestationManifests []ocispecs.Descriptor
+	var dedupeSaved int64
 
 	for i, p := range ps.Platforms {
 		r, ok := inp.FindRef(p.ID)
@@ -280,12 +303,28 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 				Provider: ic.opt.ContentStore,
 			}
 		}
+		squashed, remote, groups, err := ic.squashLayers(ctx, opts, r, remote, baseImg, session.NewGroup(sessionID))
+		if err != nil {
+			return nil, err
+		}
+		if squashed != nil {
+			defer squashed.Release(context.WithoutCancel(ctx))
+			if config, err = squashHistory(ctx, config, groups, r); err != nil {
+				return nil, err
+			}
+			r = squashed
+		}
 		if opts.RewriteTimestamp {
 			remote, err = ic.rewriteRemoteWithEpoch(ctx, opts, remote, baseImg, expEpoch)
 			if err != nil {
 				return nil, err
 			}
 		}
+		remote, saved, err := ic.dedupeLayers(ctx, opts, r, remote, baseImg, session.NewGroup(sessionID))
+		if err != nil {
+			return nil, err
+		}
+		dedupeSaved += saved
 
 		var inlineCacheEntry *exptypes.InlineCacheEntry
 		if inlineCacheResult != nil {
@@ -375,6 +414,14 @@ func (ic *ImageWriter) Commit(ctx context.Context, inp *exporter.Source, session
 	}
 	idxDone(nil)
 
+	if opts.DedupeFiles {
+		idxDesc.Annotations = maps.Clone(idxDesc.Annotations)
+		if idxDesc.Annotations == nil {
+			idxDesc.Annotations = make(map[string]string)
+		}
+		idxDesc.Annotations[exptypes.ExporterDedupeSavedKey] = strconv.FormatInt(dedupeSaved, 10)
+	}
+
 	return &idxDesc, nil
 }
 
@@ -841,7 +888,18 @@ func patchImageConfig(dt []byte, descs []ocispecs.Descriptor, history []ocispecs
 }
 
 func normalizeLayersAndHistory(ctx context.Context, remote *solver.Remote, history []ocispecs.History, ref cache.ImmutableRef, oci bool) (*solver.Remote, []ocispecs.History) {
-	refMeta := getRefMetadata(ref, len(remote.Descriptors))
+	history = normalizeHistory(ctx, history, ref, len(remote.Descriptors))
+
+	// convert between oci and docker media types (or vice versa) if needed
+	remote.Descriptors = compression.ConvertAllLayerMediaTypes(ctx, oci, remote.Descriptors...)
+
+	return remote, history
+}
+
+// normalizeHistory makes the history match the number of layers of the
+// image, adding the missing items from the metadata of the layers of ref.
+func normalizeHistory(ctx context.Context, history []ocispecs.History, ref cache.ImmutableRef, layers int) []ocispecs.History {
+	refMeta := getRefMetadata(ref, layers)
 
 	var historyLayers int
 	for _, h := range history {
@@ -850,14 +908,14 @@ func normalizeLayersAndHistory(ctx context.Context, remote *solver.Remote, histo
 		}
 	}
 
-	if historyLayers > len(remote.Descriptors) {
+	if historyLayers > layers {
 		// this case shouldn't happen but if it does force set history layers empty
 		// from the bottom
 		bklog.G(ctx).Warn("invalid image config with unaccounted layers")
 		historyCopy := make([]ocispecs.History, 0, len(history))
 		var l int
 		for _, h := range history {
-			if l >= len(remote.Descriptors) {
+			if l >= layers {
 				h.EmptyLayer = true
 			}
 			if !h.EmptyLayer {
@@ -868,7 +926,7 @@ func normalizeLayersAndHistory(ctx context.Context, remote *solver.Remote, histo
 		history = historyCopy
 	}
 
-	if len(remote.Descriptors) > historyLayers {
+	if layers > historyLayers {
 		// some history items are missing. add them based on the ref metadata
 		for _, md := range refMeta[historyLayers:] {
 			history = append(history, ocispecs.History{
@@ -921,10 +979,7 @@ func normalizeLayersAndHistory(ctx context.Context, remote *solver.Remote, histo
 		history[i] = h
 	}
 
-	// convert between oci and docker media types (or vice versa) if needed
-	remote.Descriptors = compression.ConvertAllLayerMediaTypes(ctx, oci, remote.Descriptors...)
-
-	return remote, history
+	return history
 }
 
 func RemoveInternalLayerAnnotations(in map[string]string, oci bool) map[string]string {
@@ -25,7 +25,9 @@ type Exporter interface {
 // an error in another operation), the export will be incomplete but no resources
 // will leak. FinalizeFunc performs completion work only, not cleanup.
 //
-// FinalizeFunc is safe to call concurrently with other FinalizeFunc calls.
+// FinalizeFunc is safe to call concurrently with other FinalizeFunc calls. It
+// may update the response map returned by Export of the same exporter, so the
+// response must not be read while the finalize callback runs.
 type FinalizeFunc func(ctx context.Context) error
 
 type ExporterInstance interface {
@@ -54,6 +56,9 @@ type ExportBuildInfo struct {
 	InlineCache          exptypes.InlineCache
 	SessionID            string
 	CompatibilityVersion int
+	// Artifacts are the named artifacts of the build result. Their refs are
+	// not part of the exported source.
+	Artifacts *Source
 }
 
 type DescriptorReference interface {
@@ -61,6 +66,19 @@ type DescriptorReference interface {
 	Descriptor() ocispecs.Descriptor
 }
 
+// Report is a document about an exported result that is stored with the build
+// history record.
+type Report struct {
+	MediaType string
+	Data      []byte
+}
+
+// ReportProvider is implemented by descriptor references that have a report
+// about the export.
+type ReportProvider interface {
+	Report() *Report
+}
+
 type Config struct {
 	// Make the field private in case it is initialized with nil compression.Type
 	compression compression.Config
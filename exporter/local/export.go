@@ -3,6 +3,7 @@ package local
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -124,7 +125,7 @@ func (e *localExporterInstance) Export(ctx context.Context, inp *exporter.Source
 		}
 	}
 
-	platformDirStat := func(k string, opt CreateFSOpts) *fstypes.Stat {
+	dirStat := func(k string, opt CreateFSOpts) *fstypes.Stat {
 		st := &fstypes.Stat{
 			Mode: uint32(os.ModeDir | 0755),
 			Path: strings.ReplaceAll(k, "/", "_"),
@@ -135,6 +136,35 @@ func (e *localExporterInstance) Export(ctx context.Context, inp *exporter.Source
 		return st
 	}
 
+	var artifacts exptypes.Artifacts
+	if buildInfo.Artifacts != nil {
+		artifacts, err = exptypes.ParseArtifacts(buildInfo.Artifacts.Metadata)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !e.opts.UsePlatformSplit(isMap) {
+			names := map[string]string{}
+			for _, a := range artifacts.Artifacts {
+				if p, ok := names[a.Name]; ok {
+					return nil, nil, nil, errors.Errorf("cannot overwrite artifact %s from %s with %s when split option is disabled", a.Name, p, a.Platform)
+				}
+				names[a.Name] = a.Platform
+			}
+		}
+	}
+
+	// artifactsIn returns the artifacts that are written next to the files of
+	// the ref with key k
+	artifactsIn := func(k string) []exptypes.Artifact {
+		var out []exptypes.Artifact
+		for _, a := range artifacts.Artifacts {
+			if !e.opts.UsePlatformSplit(isMap) || a.Platform == k {
+				out = append(out, a)
+			}
+		}
+		return out
+	}
+
 	buildFS := func(ctx context.Context, k string, ref cache.ImmutableRef, attestations []exporter.Attestation, opt CreateFSOpts, wrapPlatformSplit bool) (fsutil.FS, func() error, string, error) {
 		outputFS, cleanup, err := CreateFS(ctx, buildInfo.SessionID, k, ref, attestations, now, isMap, opt)
 		if err != nil {
@@ -147,6 +177,10 @@ func (e *localExporterInstance) Export(ctx context.Context, inp *exporter.Source
 			}
 		}()
 
+		if err := checkArtifactNames(ctx, outputFS, artifactsIn(k)); err != nil {
+			return nil, nil, "", err
+		}
+
 		lbl := "copying files"
 		if !e.opts.UsePlatformSplit(isMap) {
 			// check for duplicate paths
@@ -171,7 +205,7 @@ func (e *localExporterInstance) Export(ctx context.Context, inp *exporter.Source
 		} else {
 			lbl += " " + k
 			if wrapPlatformSplit {
-				outputFS, err = fsutil.SubDirFS([]fsutil.Dir{{FS: outputFS, Stat: platformDirStat(k, opt)}})
+				outputFS, err = fsutil.SubDirFS([]fsutil.Dir{{FS: outputFS, Stat: dirStat(k, opt)}})
 				if err != nil {
 					return nil, nil, "", err
 				}
@@ -182,6 +216,47 @@ func (e *localExporterInstance) Export(ctx context.Context, inp *exporter.Source
 		return outputFS, cleanup, lbl, nil
 	}
 
+	// artifacts are written to a directory named after the artifact, nested
+	// in the platform directory if the platforms are split
+	buildArtifactFS := func(ctx context.Context, a exptypes.Artifact) (fsutil.FS, func() error, error) {
+		ref, ok := buildInfo.Artifacts.FindRef(a.ID)
+		if !ok {
+			return nil, nil, errors.Errorf("failed to find ref for artifact %s", a.Name)
+		}
+		opt := e.opts
+		if e.opts.Epoch == nil {
+			var p *exptypes.Platform
+			for _, pp := range platforms.Platforms {
+				if pp.ID == a.Platform {
+					p = &pp
+				}
+			}
+			tm, err := epoch.ParseSource(inp, p)
+			if err != nil {
+				return nil, nil, err
+			}
+			opt.Epoch = &epoch.Epoch{Value: tm}
+		}
+		outputFS, cleanup, err := CreateFS(ctx, buildInfo.SessionID, a.ID, ref, nil, now, false, opt)
+		if err != nil {
+			return nil, nil, err
+		}
+		dirs := []string{a.Name}
+		if a.Platform != "" && e.opts.UsePlatformSplit(isMap) {
+			dirs = append(dirs, a.Platform)
+		}
+		for _, d := range dirs {
+			outputFS, err = fsutil.SubDirFS([]fsutil.Dir{{FS: outputFS, Stat: dirStat(d, opt)}})
+			if err != nil {
+				if cleanup != nil {
+					_ = cleanup()
+				}
+				return nil, nil, err
+			}
+		}
+		return outputFS, cleanup, nil
+	}
+
 	export := func(ctx context.Context, k string, ref cache.ImmutableRef, attestations []exporter.Attestation, opt CreateFSOpts) func() error {
 		return func() error {
 			outputFS, cleanup, lbl, err := buildFS(ctx, k, ref, attestations, opt, true)
@@ -201,9 +276,9 @@ func (e *localExporterInstance) Export(ctx context.Context, inp *exporter.Source
 		}
 	}
 
-	eg, ctx := errgroup.WithContext(ctx)
+	eg, egCtx := errgroup.WithContext(ctx)
 
-	if mode == client.LocalExporterModeDelete {
+	if mode == client.LocalExporterModeDelete || mode == client.LocalExporterModeSync {
 		eg.Go(func() error {
 			var outputFS fsutil.FS
 			var platformDirs []fsutil.Dir
@@ -237,7 +312,7 @@ func (e *localExporterInstance) Export(ctx context.Context, inp *exporter.Source
 						}
 						opt.Epoch = &epoch.Epoch{Value: tm}
 					}
-					fs, cleanup, _, err := buildFS(ctx, p.ID, r, inp.Attestations[p.ID], opt, !split)
+					fs, cleanup, _, err := buildFS(egCtx, p.ID, r, inp.Attestations[p.ID], opt, !split)
 					if err != nil {
 						return err
 					}
@@ -245,7 +320,7 @@ func (e *localExporterInstance) Export(ctx context.Context, inp *exporter.Source
 						cleanups = append(cleanups, cleanup)
 					}
 					if split {
-						platformDirs = append(platformDirs, fsutil.Dir{FS: fs, Stat: platformDirStat(p.ID, opt)})
+						platformDirs = append(platformDirs, fsutil.Dir{FS: fs, Stat: dirStat(p.ID, opt)})
 					} else {
 						addFS(fs)
 					}
@@ -258,7 +333,18 @@ func (e *localExporterInstance) Export(ctx context.Context, inp *exporter.Source
 					addFS(fs)
 				}
 			} else {
-				fs, cleanup, _, err := buildFS(ctx, "", inp.Ref, nil, e.opts, true)
+				fs, cleanup, _, err := buildFS(egCtx, "", inp.Ref, nil, e.opts, true)
+				if err != nil {
+					return err
+				}
+				if cleanup != nil {
+					cleanups = append(cleanups, cleanup)
+				}
+				addFS(fs)
+			}
+
+			for _, a := range artifacts.Artifacts {
+				fs, cleanup, err := buildArtifactFS(egCtx, a)
 				if err != nil {
 					return err
 				}
@@ -268,9 +354,23 @@ func (e *localExporterInstance) Export(ctx context.Context, inp *exporter.Source
 				addFS(fs)
 			}
 
-			progress, closeProgress := NewProgressHandler(ctx, "copying files")
+			copyOpts := []filesync.CopyToCallerOpt{filesync.WithExporterMultiPlatformTransfer()}
+			if mode == client.LocalExporterModeSync {
+				existing, err := filesync.ListCallerFiles(egCtx, e.id, caller)
+				if err != nil {
+					return errors.Wrap(err, "failed to list destination files")
+				}
+				var replaced []string
+				outputFS, replaced, err = syncFS(egCtx, outputFS, existing)
+				if err != nil {
+					return err
+				}
+				copyOpts = append(copyOpts, filesync.WithSyncReplacedFiles(replaced))
+			}
+
+			progress, closeProgress := NewProgressHandler(egCtx, "copying files")
 			defer closeProgress()
-			return filesync.CopyToCaller(ctx, outputFS, e.id, caller, progress, filesync.WithExporterMultiPlatformTransfer())
+			return filesync.CopyToCaller(egCtx, outputFS, e.id, caller, progress, copyOpts...)
 		})
 	} else if len(platforms.Platforms) > 0 {
 		for _, p := range platforms.Platforms {
@@ -286,18 +386,73 @@ func (e *localExporterInstance) Export(ctx context.Context, inp *exporter.Source
 				}
 				opt.Epoch = &epoch.Epoch{Value: tm}
 			}
-			eg.Go(export(ctx, p.ID, r, inp.Attestations[p.ID], opt))
+			eg.Go(export(egCtx, p.ID, r, inp.Attestations[p.ID], opt))
 		}
 	} else {
-		eg.Go(export(ctx, "", inp.Ref, nil, e.opts))
+		eg.Go(export(egCtx, "", inp.Ref, nil, e.opts))
 	}
 
 	if err := eg.Wait(); err != nil {
 		return nil, nil, nil, err
 	}
+
+	// artifacts are copied after the files of the result, so that they are
+	// not written concurrently to the same destination
+	if mode == client.LocalExporterModeCopy && len(artifacts.Artifacts) > 0 {
+		var outputFS fsutil.FS
+		var cleanups []func() error
+		defer func() {
+			for i := len(cleanups) - 1; i >= 0; i-- {
+				_ = cleanups[i]()
+			}
+		}()
+		for _, a := range artifacts.Artifacts {
+			fs, cleanup, err := buildArtifactFS(ctx, a)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if cleanup != nil {
+				cleanups = append(cleanups, cleanup)
+			}
+			if outputFS == nil {
+				outputFS = fs
+			} else {
+				outputFS = staticfs.NewMergeFS(outputFS, fs)
+			}
+		}
+
+		progress, closeProgress := NewProgressHandler(ctx, "copying artifacts")
+		defer closeProgress()
+		if err := filesync.CopyToCaller(ctx, outputFS, e.id, caller, progress); err != nil {
+			return nil, nil, nil, err
+		}
+	}
 	return nil, nil, nil, nil
 }
 
+// checkArtifactNames returns an error if an artifact would be written to a
+// top-level path of fs, as its files would be merged with the files of the
+// build result.
+func checkArtifactNames(ctx context.Context, fs fsutil.FS, artifacts []exptypes.Artifact) error {
+	if len(artifacts) == 0 {
+		return nil
+	}
+	names := make(map[string]struct{}, len(artifacts))
+	for _, a := range artifacts {
+		names[a.Name] = struct{}{}
+	}
+	return fsWalk(ctx, fs, "", func(p string, _ os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		p = strings.TrimPrefix(filepath.ToSlash(p), "/")
+		if _, ok := names[p]; ok {
+			return errors.Errorf("artifact %s conflicts with /%s of the build result", p, p)
+		}
+		return nil
+	})
+}
+
 // NewProgressHandler returns a callback for reporting transfer progress and a
 // cleanup function that ensures the underlying progress writer is closed.
 // The cleanup function must be called by the caller (typically via defer), so
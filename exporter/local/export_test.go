@@ -0,0 +1,31 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+)
+
+func TestCheckArtifactNames(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin/app"), []byte("app"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("readme"), 0644))
+	fs, err := fsutil.NewFS(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, checkArtifactNames(ctx, fs, nil))
+	require.NoError(t, checkArtifactNames(ctx, fs, []exptypes.Artifact{{ID: "a0", Name: "coverage"}, {ID: "a1", Name: "app"}}))
+
+	err = checkArtifactNames(ctx, fs, []exptypes.Artifact{{ID: "a0", Name: "coverage"}, {ID: "a1", Name: "bin"}})
+	require.ErrorContains(t, err, "artifact bin conflicts with /bin of the build result")
+	err = checkArtifactNames(ctx, fs, []exptypes.Artifact{{ID: "a0", Name: "README"}})
+	require.ErrorContains(t, err, "artifact README conflicts with /README of the build result")
+}
@@ -0,0 +1,105 @@
+package local
+
+import (
+	"context"
+	"io"
+	gofs "io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/moby/buildkit/cache/contenthash"
+	"github.com/moby/buildkit/session/filesync"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/fsutil"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+// syncFS returns a filesystem that keeps the modification times of the
+// destination files that did not change. The client only requests files with
+// different metadata in sync mode, so unchanged files are not transferred and
+// tools depending on modification times don't see them as modified. It also
+// returns the destination files that must be replaced because their contents
+// couldn't be confirmed to be the same, even if their metadata matches.
+func syncFS(ctx context.Context, fs fsutil.FS, existing map[string]*filesync.FileEntry) (fsutil.FS, []string, error) {
+	unchanged := map[string]int64{}
+	var replaced []string
+	err := fs.Walk(ctx, "", func(p string, entry gofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		st, ok := fi.Sys().(*fstypes.Stat)
+		if !ok {
+			return errors.Errorf("invalid fileinfo without stat info: %s", p)
+		}
+		key := strings.TrimPrefix(filepath.ToSlash(p), "/")
+		e, ok := existing[key]
+		if !ok {
+			return nil
+		}
+		same, err := sameContent(ctx, fs, p, st, e)
+		if err != nil {
+			return err
+		}
+		if same {
+			unchanged[key] = e.ModTime
+		} else if os.FileMode(e.Mode).IsRegular() {
+			replaced = append(replaced, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fs, err = fsutil.NewFilterFS(fs, &fsutil.FilterOpt{
+		Map: func(p string, st *fstypes.Stat) fsutil.MapResult {
+			if mtime, ok := unchanged[strings.TrimPrefix(filepath.ToSlash(p), "/")]; ok {
+				st.ModTime = mtime
+			}
+			return fsutil.MapResultKeep
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return fs, replaced, nil
+}
+
+// sameContent reports whether the file in fs has the same type and content as
+// the destination file. Regular files are compared by their content hash.
+// Hardlinks are always transferred.
+func sameContent(ctx context.Context, fs fsutil.FS, p string, st *fstypes.Stat, e *filesync.FileEntry) (bool, error) {
+	mode, emode := os.FileMode(st.Mode), os.FileMode(e.Mode)
+	if mode.Type() != emode.Type() {
+		return false, nil
+	}
+	switch {
+	case mode&os.ModeSymlink != 0:
+		return st.Linkname == e.Linkname, nil
+	case mode.IsRegular():
+		if st.Linkname != "" || st.Size != e.Size || e.Digest == "" {
+			return false, nil
+		}
+		h, err := contenthash.NewFromStat(filesync.SyncFileStat(mode, st.Size))
+		if err != nil {
+			return false, err
+		}
+		rc, err := fs.Open(p)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+		defer rc.Close()
+		if _, err := io.Copy(h, rc); err != nil {
+			return false, errors.WithStack(err)
+		}
+		return digest.NewDigest(digest.SHA256, h).String() == e.Digest, nil
+	default:
+		return false, nil
+	}
+}
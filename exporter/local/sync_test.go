@@ -0,0 +1,109 @@
+package local
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/filesync"
+	"github.com/moby/buildkit/session/testutil"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestSyncMode(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	srcDir := t.TempDir()
+	destDir := t.TempDir()
+	oldTime := time.Unix(1600000000, 0)
+
+	write := func(dir, name, content string, mtime *time.Time) {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+		if mtime != nil {
+			require.NoError(t, os.Chtimes(p, *mtime, *mtime))
+		}
+	}
+	write(srcDir, "unchanged", "same", nil)
+	write(srcDir, "sub/changed", "new", nil)
+	write(srcDir, "added", "added", nil)
+	// same size and modification time, as with SOURCE_DATE_EPOCH
+	write(srcDir, "epoch", "new", &oldTime)
+	require.NoError(t, os.Symlink("unchanged", filepath.Join(srcDir, "link")))
+
+	write(destDir, "unchanged", "same", &oldTime)
+	write(destDir, "sub/changed", "old", &oldTime)
+	write(destDir, "stale", "stale", &oldTime)
+	write(destDir, "epoch", "old", &oldTime)
+	require.NoError(t, os.Symlink("unchanged", filepath.Join(destDir, "link")))
+
+	srcFS, err := fsutil.NewFS(srcDir)
+	require.NoError(t, err)
+
+	s, err := session.NewSession(ctx, "foo")
+	require.NoError(t, err)
+	m, err := session.NewManager()
+	require.NoError(t, err)
+	s.Allow(filesync.NewFSSyncTarget(filesync.WithFSSyncDirSync(1, destDir)))
+
+	dialer := session.Dialer(testutil.TestStream(testutil.Handler(m.HandleConn)))
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return s.Run(ctx, dialer)
+	})
+	g.Go(func() (reterr error) {
+		defer func() {
+			err := s.Close()
+			if reterr == nil {
+				reterr = err
+			}
+		}()
+		c, err := m.Get(ctx, s.ID(), false)
+		if err != nil {
+			return err
+		}
+		existing, err := filesync.ListCallerFiles(ctx, 1, c)
+		if err != nil {
+			return err
+		}
+		require.Len(t, existing, 6)
+		require.Equal(t, "unchanged", existing["link"].Linkname)
+
+		fs, replaced, err := syncFS(ctx, srcFS, existing)
+		if err != nil {
+			return err
+		}
+		require.ElementsMatch(t, []string{"sub/changed", "epoch"}, replaced)
+		return filesync.CopyToCaller(ctx, fs, 1, c, func(int, bool) {}, filesync.WithExporterMultiPlatformTransfer(), filesync.WithSyncReplacedFiles(replaced))
+	})
+	require.NoError(t, g.Wait())
+
+	fi, err := os.Stat(filepath.Join(destDir, "unchanged"))
+	require.NoError(t, err)
+	require.True(t, fi.ModTime().Equal(oldTime), "unexpected modification time %s", fi.ModTime())
+
+	dt, err := os.ReadFile(filepath.Join(destDir, "sub/changed"))
+	require.NoError(t, err)
+	require.Equal(t, "new", string(dt))
+	fi, err = os.Stat(filepath.Join(destDir, "sub/changed"))
+	require.NoError(t, err)
+	require.False(t, fi.ModTime().Equal(oldTime))
+
+	dt, err = os.ReadFile(filepath.Join(destDir, "epoch"))
+	require.NoError(t, err)
+	require.Equal(t, "new", string(dt))
+
+	dt, err = os.ReadFile(filepath.Join(destDir, "added"))
+	require.NoError(t, err)
+	require.Equal(t, "added", string(dt))
+
+	_, err = os.Stat(filepath.Join(destDir, "stale"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,155 @@
+package oci
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/images"
+	archiveexporter "github.com/containerd/containerd/v2/core/images/archive"
+	"github.com/containerd/platforms"
+	"github.com/distribution/reference"
+	"github.com/moby/buildkit/util/attestation"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// dockerManifestItem is an entry of the manifest.json file of a docker
+// archive.
+type dockerManifestItem struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// writeDockerIndexArchive writes a docker archive of a multi-platform image.
+// Like the OCI variant, index.json refers to the image index with the image
+// names in the io.containerd.image.name annotation, so that the image is
+// loaded with all platforms into the containerd image store. manifest.json
+// contains an entry for every platform for loading the archive without the
+// containerd image store. The image names are set on the entry of the
+// platform that is preferred on this host.
+func writeDockerIndexArchive(ctx context.Context, provider content.InfoReaderProvider, w io.Writer, desc ocispecs.Descriptor, names []string) (err error) {
+	items, err := dockerManifestItems(ctx, provider, desc, names)
+	if err != nil {
+		return err
+	}
+	dt, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	// the containerd archive exporter can only write manifest.json for a
+	// single platform of an index, so the archive is copied with a new
+	// manifest.json appended
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(archiveexporter.Export(ctx, provider, pw,
+			archiveexporter.WithManifest(desc, names...),
+			archiveexporter.WithAllPlatforms(),
+			archiveexporter.WithSkipDockerManifest(),
+		))
+	}()
+
+	tr := tar.NewReader(pr)
+	tw := tar.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "manifest.json",
+		Mode:     0644,
+		Size:     int64(len(dt)),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(dt); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func dockerManifestItems(ctx context.Context, provider content.Provider, desc ocispecs.Descriptor, names []string) ([]dockerManifestItem, error) {
+	dt, err := content.ReadBlob(ctx, provider, desc)
+	if err != nil {
+		return nil, err
+	}
+	var idx ocispecs.Index
+	if err := json.Unmarshal(dt, &idx); err != nil {
+		return nil, errors.Wrap(err, "failed to parse image index")
+	}
+
+	var manifests []ocispecs.Descriptor
+	for _, m := range idx.Manifests {
+		if _, ok := m.Annotations[attestation.DockerAnnotationReferenceType]; ok {
+			continue
+		}
+		if images.IsManifestType(m.MediaType) {
+			manifests = append(manifests, m)
+		}
+	}
+	if len(manifests) == 0 {
+		return nil, errors.Errorf("no image manifests in index %s", desc.Digest)
+	}
+
+	tagged := 0
+	matcher := platforms.Default()
+	for i, m := range manifests {
+		if m.Platform == nil {
+			continue
+		}
+		if t := manifests[tagged].Platform; t == nil || matcher.Less(*m.Platform, *t) {
+			tagged = i
+		}
+	}
+
+	var repoTags []string
+	for _, name := range names {
+		named, err := reference.ParseNormalizedNamed(name)
+		if err != nil {
+			return nil, err
+		}
+		repoTags = append(repoTags, reference.FamiliarString(reference.TagNameOnly(named)))
+	}
+
+	items := make([]dockerManifestItem, len(manifests))
+	for i, m := range manifests {
+		dt, err := content.ReadBlob(ctx, provider, m)
+		if err != nil {
+			return nil, err
+		}
+		var mfst ocispecs.Manifest
+		if err := json.Unmarshal(dt, &mfst); err != nil {
+			return nil, errors.Wrap(err, "failed to parse image manifest")
+		}
+		items[i].Config = blobPath(mfst.Config)
+		for _, l := range mfst.Layers {
+			items[i].Layers = append(items[i].Layers, blobPath(l))
+		}
+		if i == tagged {
+			items[i].RepoTags = repoTags
+		}
+	}
+	return items, nil
+}
+
+func blobPath(desc ocispecs.Descriptor) string {
+	return path.Join(ocispecs.ImageBlobsDir, desc.Digest.Algorithm().String(), desc.Digest.Encoded())
+}
@@ -0,0 +1,109 @@
+package oci
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/platforms"
+	"github.com/moby/buildkit/util/attestation"
+	"github.com/moby/buildkit/util/contentutil"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDockerIndexArchive(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	buf := contentutil.NewBuffer()
+	writeBlob := func(mt string, dt []byte) ocispecs.Descriptor {
+		desc := ocispecs.Descriptor{
+			MediaType: mt,
+			Digest:    digest.FromBytes(dt),
+			Size:      int64(len(dt)),
+		}
+		require.NoError(t, content.WriteBlob(ctx, buf, desc.Digest.String(), bytes.NewReader(dt), desc))
+		return desc
+	}
+	writeManifest := func(p string) ocispecs.Descriptor {
+		config := writeBlob(images.MediaTypeDockerSchema2Config, []byte(`{"platform":"`+p+`"}`))
+		layer := writeBlob(images.MediaTypeDockerSchema2LayerGzip, []byte("layer "+p))
+		dt, err := json.Marshal(ocispecs.Manifest{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: images.MediaTypeDockerSchema2Manifest,
+			Config:    config,
+			Layers:    []ocispecs.Descriptor{layer},
+		})
+		require.NoError(t, err)
+		desc := writeBlob(images.MediaTypeDockerSchema2Manifest, dt)
+		plat := platforms.MustParse(p)
+		desc.Platform = &plat
+		return desc
+	}
+
+	other := "linux/riscv64"
+	if platforms.DefaultSpec().Architecture == "riscv64" {
+		other = "linux/ppc64le"
+	}
+	mfsts := []ocispecs.Descriptor{
+		writeManifest(other),
+		writeManifest(platforms.Format(platforms.DefaultSpec())),
+	}
+	att := writeManifest("unknown/unknown")
+	att.Annotations = map[string]string{attestation.DockerAnnotationReferenceType: attestation.DockerAnnotationReferenceTypeDefault}
+
+	dt, err := json.Marshal(ocispecs.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: images.MediaTypeDockerSchema2ManifestList,
+		Manifests: append(mfsts, att),
+	})
+	require.NoError(t, err)
+	idxDesc := writeBlob(images.MediaTypeDockerSchema2ManifestList, dt)
+
+	var out bytes.Buffer
+	require.NoError(t, writeDockerIndexArchive(ctx, buf, &out, idxDesc, []string{"docker.io/library/foo:v1", "example.com/bar"}))
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(&out)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		dt, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[hdr.Name] = dt
+	}
+
+	for _, d := range append(mfsts, att, idxDesc) {
+		require.Contains(t, files, blobPath(d))
+	}
+
+	var idx ocispecs.Index
+	require.NoError(t, json.Unmarshal(files[ocispecs.ImageIndexFile], &idx))
+	require.Len(t, idx.Manifests, 2)
+	require.Equal(t, idxDesc.Digest, idx.Manifests[0].Digest)
+	require.Equal(t, "docker.io/library/foo:v1", idx.Manifests[0].Annotations[images.AnnotationImageName])
+	require.Equal(t, "example.com/bar", idx.Manifests[1].Annotations[images.AnnotationImageName])
+
+	var items []dockerManifestItem
+	require.NoError(t, json.Unmarshal(files["manifest.json"], &items))
+	require.Len(t, items, 2)
+	for i, item := range items {
+		var mfst ocispecs.Manifest
+		require.NoError(t, json.Unmarshal(files[blobPath(mfsts[i])], &mfst))
+		require.Equal(t, blobPath(mfst.Config), item.Config)
+		require.Equal(t, []string{blobPath(mfst.Layers[0])}, item.Layers)
+	}
+	require.Empty(t, items[0].RepoTags)
+	require.Equal(t, []string{"foo:v1", "example.com/bar:latest"}, items[1].RepoTags)
+}
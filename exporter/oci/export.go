@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/containerd/containerd/v2/core/images"
 	archiveexporter "github.com/containerd/containerd/v2/core/images/archive"
 	"github.com/containerd/containerd/v2/core/leases"
 	"github.com/distribution/reference"
@@ -41,7 +42,8 @@ const (
 )
 
 const (
-	keyTar = "tar"
+	keyTar        = "tar"
+	keyDestLayout = "dest-layout"
 )
 
 type Opt struct {
@@ -49,6 +51,9 @@ type Opt struct {
 	ImageWriter    *containerimage.ImageWriter
 	Variant        ExporterVariant
 	LeaseManager   leases.Manager
+	// Layouts are the named OCI layout directories that can be used with
+	// dest-layout
+	Layouts map[string]string
 }
 
 type imageExporter struct {
@@ -90,6 +95,18 @@ func (e *imageExporter) Resolve(ctx context.Context, id int, opt map[string]stri
 				return nil, errors.Wrapf(err, "non-bool value specified for %s", k)
 			}
 			i.tar = b
+		case keyDestLayout:
+			if v == "" {
+				continue
+			}
+			if e.opt.Variant != VariantOCI {
+				return nil, errors.Errorf("%s is not supported by %s exporter", k, e.opt.Variant)
+			}
+			p, ok := e.opt.Layouts[v]
+			if !ok {
+				return nil, errors.Errorf("OCI layout %q is not configured on the daemon", v)
+			}
+			i.layout = p
 		default:
 			if i.meta == nil {
 				i.meta = make(map[string][]byte)
@@ -105,9 +122,10 @@ type imageExporterInstance struct {
 	id    int
 	attrs map[string]string
 
-	opts containerimage.ImageCommitOpts
-	tar  bool
-	meta map[string][]byte
+	opts   containerimage.ImageCommitOpts
+	tar    bool
+	layout string
+	meta   map[string][]byte
 }
 
 func (e *imageExporterInstance) ID() int {
@@ -131,10 +149,6 @@ func (e *imageExporterInstance) Config() *exporter.Config {
 }
 
 func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source, buildInfo exporter.ExportBuildInfo) (_ map[string]string, _ exporter.FinalizeFunc, descref exporter.DescriptorReference, err error) {
-	if e.opt.Variant == VariantDocker && len(src.Refs) > 0 {
-		return nil, nil, nil, errors.New("docker exporter does not currently support exporting manifest lists")
-	}
-
 	src = src.Clone()
 	if src.Metadata == nil {
 		src.Metadata = make(map[string][]byte)
@@ -191,6 +205,10 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 		resp[exptypes.ExporterImageConfigDigestKey] = v
 		delete(desc.Annotations, exptypes.ExporterConfigDigestKey)
 	}
+	if v, ok := desc.Annotations[exptypes.ExporterDedupeSavedKey]; ok {
+		resp[exptypes.ExporterImageDedupeSavedKey] = v
+		delete(desc.Annotations, exptypes.ExporterDedupeSavedKey)
+	}
 
 	dtdesc, err := json.Marshal(desc)
 	if err != nil {
@@ -220,15 +238,6 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 		return nil, nil, nil, errors.Errorf("invalid variant %q", e.opt.Variant)
 	}
 
-	timeoutCtx, cancel := context.WithCancelCause(ctx)
-	timeoutCtx, _ = context.WithTimeoutCause(timeoutCtx, 5*time.Second, errors.WithStack(context.DeadlineExceeded)) //nolint:govet
-	defer func() { cancel(errors.WithStack(context.Canceled)) }()
-
-	caller, err := e.opt.SessionManager.Get(timeoutCtx, buildInfo.SessionID, false)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
 	var refs []cache.ImmutableRef
 	if src.Ref != nil {
 		refs = append(refs, src.Ref)
@@ -263,6 +272,24 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 		return nil, nil, nil, err
 	}
 
+	if e.layout != "" {
+		report := progress.OneOff(ctx, "writing to OCI layout "+e.attrs[keyDestLayout])
+		if err := writeLayout(ctx, e.layout, mprovider, *desc, names); err != nil {
+			return nil, nil, nil, report(err)
+		}
+		report(nil)
+		return resp, nil, nil, nil
+	}
+
+	timeoutCtx, cancel := context.WithCancelCause(ctx)
+	timeoutCtx, _ = context.WithTimeoutCause(timeoutCtx, 5*time.Second, errors.WithStack(context.DeadlineExceeded)) //nolint:govet
+	defer func() { cancel(errors.WithStack(context.Canceled)) }()
+
+	caller, err := e.opt.SessionManager.Get(timeoutCtx, buildInfo.SessionID, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	if e.tar {
 		w, err := filesync.CopyFileWriter(ctx, resp, e.id, caller)
 		if err != nil {
@@ -270,7 +297,12 @@ func (e *imageExporterInstance) Export(ctx context.Context, src *exporter.Source
 		}
 
 		report := progress.OneOff(ctx, "sending tarball")
-		if err := archiveexporter.Export(ctx, mprovider, w, expOpts...); err != nil {
+		if e.opt.Variant == VariantDocker && images.IsIndexType(desc.MediaType) {
+			err = writeDockerIndexArchive(ctx, mprovider, w, *desc, names)
+		} else {
+			err = archiveexporter.Export(ctx, mprovider, w, expOpts...)
+		}
+		if err != nil {
 			w.Close()
 			if grpcerrors.Code(err) == codes.AlreadyExists {
 				return resp, nil, nil, report(nil)
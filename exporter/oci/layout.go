@@ -0,0 +1,48 @@
+package oci
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/containerd/containerd/v2/core/content"
+	contentlocal "github.com/containerd/containerd/v2/plugins/content/local"
+	"github.com/moby/buildkit/client/ociindex"
+	"github.com/moby/buildkit/util/contentutil"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// layoutLocks serializes the index updates of the exports in this process.
+// The index file lock of ociindex fails instead of waiting for other writers.
+var layoutLocks sync.Map // map[string]*sync.Mutex
+
+// writeLayout copies the image to the OCI layout directory at dir and adds it
+// to the index of the layout. Blobs that already exist in the layout are not
+// copied again.
+func writeLayout(ctx context.Context, dir string, provider content.Provider, desc ocispecs.Descriptor, names []string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create OCI layout %s", dir)
+	}
+	store, err := contentlocal.NewStore(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open OCI layout %s", dir)
+	}
+	if err := contentutil.CopyChain(ctx, store, provider, desc); err != nil {
+		return err
+	}
+
+	tags := []ociindex.NameOrTag{ociindex.Tag("latest")}
+	if len(names) > 0 {
+		tags = make([]ociindex.NameOrTag, len(names))
+		for i, n := range names {
+			tags[i] = ociindex.Name(n)
+		}
+	}
+
+	mu, _ := layoutLocks.LoadOrStore(strings.TrimSuffix(dir, "/"), &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+	return ociindex.NewStoreIndex(dir).Put(desc, tags...)
+}
@@ -0,0 +1,63 @@
+package oci
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/moby/buildkit/client/ociindex"
+	"github.com/moby/buildkit/util/contentutil"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteLayout(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	buf := contentutil.NewBuffer()
+	writeBlob := func(mt string, dt []byte) ocispecs.Descriptor {
+		desc := ocispecs.Descriptor{
+			MediaType: mt,
+			Digest:    digest.FromBytes(dt),
+			Size:      int64(len(dt)),
+		}
+		require.NoError(t, content.WriteBlob(ctx, buf, desc.Digest.String(), bytes.NewReader(dt), desc))
+		return desc
+	}
+
+	config := writeBlob(ocispecs.MediaTypeImageConfig, []byte(`{"architecture":"amd64","os":"linux"}`))
+	layer := writeBlob(ocispecs.MediaTypeImageLayerGzip, []byte("layer"))
+	dt, err := json.Marshal(ocispecs.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispecs.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispecs.Descriptor{layer},
+	})
+	require.NoError(t, err)
+	mfst := writeBlob(ocispecs.MediaTypeImageManifest, dt)
+
+	dir := filepath.Join(t.TempDir(), "layout")
+	require.NoError(t, writeLayout(ctx, dir, buf, mfst, []string{"docker.io/library/foo:v1"}))
+	require.NoError(t, writeLayout(ctx, dir, buf, mfst, []string{"docker.io/library/foo:v2"}))
+
+	for _, desc := range []ocispecs.Descriptor{config, layer, mfst} {
+		_, err := os.Stat(filepath.Join(dir, "blobs", desc.Digest.Algorithm().String(), desc.Digest.Encoded()))
+		require.NoError(t, err)
+	}
+
+	idx, err := ociindex.NewStoreIndex(dir).Read()
+	require.NoError(t, err)
+	require.Len(t, idx.Manifests, 2)
+	for _, tag := range []string{"v1", "v2"} {
+		desc, err := ociindex.NewStoreIndex(dir).Get(tag)
+		require.NoError(t, err)
+		require.NotNil(t, desc)
+		require.Equal(t, mfst.Digest, desc.Digest)
+	}
+}
@@ -0,0 +1,253 @@
+package squashfs
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/moby/buildkit/cache"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/exporter"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/exporter/local"
+	"github.com/moby/buildkit/exporter/util/epoch"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/filesync"
+	"github.com/moby/buildkit/util/progress"
+	squashfsutil "github.com/moby/buildkit/util/squashfs"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/fsutil"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+const (
+	keyCompression = "compression"
+	keyBlockSize   = "block-size"
+)
+
+type Opt struct {
+	SessionManager *session.Manager
+}
+
+type squashfsExporter struct {
+	opt Opt
+}
+
+func New(opt Opt) (exporter.Exporter, error) {
+	return &squashfsExporter{opt: opt}, nil
+}
+
+func (e *squashfsExporter) Resolve(ctx context.Context, id int, opt map[string]string) (exporter.ExporterInstance, error) {
+	i := &squashfsExporterInstance{
+		squashfsExporter: e,
+		id:               id,
+		attrs:            opt,
+	}
+	rest, err := i.opts.Load(opt)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range rest {
+		switch k {
+		case keyCompression:
+			switch c := squashfsutil.Compression(v); c {
+			case squashfsutil.CompressionGzip, squashfsutil.CompressionNone:
+				i.fsOpt.Compression = c
+			default:
+				return nil, errors.Errorf("unsupported compression %q for %s exporter, must be %s or %s", v, client.ExporterSquashFS, squashfsutil.CompressionGzip, squashfsutil.CompressionNone)
+			}
+		case keyBlockSize:
+			bs, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "non-int value %s specified for %s", v, k)
+			}
+			if err := squashfsutil.ValidateBlockSize(bs); err != nil {
+				return nil, err
+			}
+			i.fsOpt.BlockSize = bs
+		}
+	}
+	return i, nil
+}
+
+type squashfsExporterInstance struct {
+	*squashfsExporter
+	id    int
+	attrs map[string]string
+
+	opts  local.CreateFSOpts
+	fsOpt squashfsutil.Opt
+}
+
+func (e *squashfsExporterInstance) ID() int {
+	return e.id
+}
+
+func (e *squashfsExporterInstance) Name() string {
+	return "exporting to client squashfs image"
+}
+
+func (e *squashfsExporterInstance) Type() string {
+	return client.ExporterSquashFS
+}
+
+func (e *squashfsExporterInstance) Attrs() map[string]string {
+	return e.attrs
+}
+
+func (e *squashfsExporterInstance) Config() *exporter.Config {
+	return exporter.NewConfig()
+}
+
+func (e *squashfsExporterInstance) Export(ctx context.Context, inp *exporter.Source, buildInfo exporter.ExportBuildInfo) (map[string]string, exporter.FinalizeFunc, exporter.DescriptorReference, error) {
+	var defers []func() error
+
+	defer func() {
+		for _, f := range slices.Backward(defers) {
+			f()
+		}
+	}()
+
+	if e.opts.Epoch == nil {
+		if tm, err := epoch.ParseSource(inp, nil); err != nil {
+			return nil, nil, nil, err
+		} else if tm != nil {
+			e.opts.Epoch = &epoch.Epoch{Value: tm}
+		}
+	}
+
+	now := time.Now().Truncate(time.Second)
+	isMap := len(inp.Refs) > 0
+
+	// image creation time, the earliest epoch of all platforms
+	var mkfsTime *time.Time
+
+	getDir := func(ctx context.Context, k string, ref cache.ImmutableRef, attestations []exporter.Attestation, opt local.CreateFSOpts) (*fsutil.Dir, error) {
+		var tm *time.Time
+		if opt.Epoch != nil {
+			tm = opt.Epoch.Value
+		}
+		// CreateFS overwrites the modification times with the epoch, the
+		// squashfs image clamps them instead
+		opt.Epoch = nil
+		outputFS, cleanup, err := local.CreateFS(ctx, buildInfo.SessionID, k, ref, attestations, now, isMap, opt)
+		if err != nil {
+			return nil, err
+		}
+		if cleanup != nil {
+			defers = append(defers, cleanup)
+		}
+
+		st := &fstypes.Stat{
+			Mode:    uint32(os.ModeDir | 0755),
+			Path:    strings.ReplaceAll(k, "/", "_"),
+			ModTime: now.UnixNano(),
+		}
+		if tm != nil {
+			st.ModTime = tm.UnixNano()
+			if mkfsTime == nil || tm.Before(*mkfsTime) {
+				mkfsTime = tm
+			}
+			outputFS, err = clampFS(outputFS, *tm)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return &fsutil.Dir{
+			FS:   outputFS,
+			Stat: st,
+		}, nil
+	}
+
+	if _, ok := inp.Metadata[exptypes.ExporterPlatformsKey]; isMap && !ok {
+		return nil, nil, nil, errors.New("unable to export multiple refs, missing platforms mapping")
+	}
+	p, err := exptypes.ParsePlatforms(inp.Metadata)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !isMap && len(p.Platforms) > 1 {
+		return nil, nil, nil, errors.New("unable to export multiple platforms without map")
+	}
+
+	var fs fsutil.FS
+
+	if len(p.Platforms) > 0 {
+		dirs := make([]fsutil.Dir, 0, len(p.Platforms))
+		for _, p := range p.Platforms {
+			r, ok := inp.FindRef(p.ID)
+			if !ok {
+				return nil, nil, nil, errors.Errorf("failed to find ref for ID %s", p.ID)
+			}
+			opt := e.opts
+			if e.opts.Epoch == nil {
+				tm, err := epoch.ParseSource(inp, &p)
+				if err != nil {
+					return nil, nil, nil, err
+				}
+				opt.Epoch = &epoch.Epoch{Value: tm}
+			}
+			d, err := getDir(ctx, p.ID, r, inp.Attestations[p.ID], opt)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			dirs = append(dirs, *d)
+		}
+		if isMap {
+			var err error
+			fs, err = fsutil.SubDirFS(dirs)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		} else {
+			fs = dirs[0].FS
+		}
+	} else {
+		d, err := getDir(ctx, "", inp.Ref, nil, e.opts)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		fs = d.FS
+	}
+
+	fsOpt := e.fsOpt
+	fsOpt.Epoch = mkfsTime
+
+	timeoutCtx, cancel := context.WithCancelCause(ctx)
+	defer func() { cancel(errors.WithStack(context.Canceled)) }()
+	timeoutCtx, cancelTimeout := context.WithTimeoutCause(timeoutCtx, 5*time.Second, errors.WithStack(context.DeadlineExceeded))
+	defer cancelTimeout()
+
+	caller, err := e.opt.SessionManager.Get(timeoutCtx, buildInfo.SessionID, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	w, err := filesync.CopyFileWriter(ctx, nil, e.id, caller)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	report := progress.OneOff(ctx, "sending squashfs image")
+	if err := squashfsutil.Write(ctx, fs, w, fsOpt); err != nil {
+		w.Close()
+		return nil, nil, nil, report(err)
+	}
+	return nil, nil, nil, report(w.Close())
+}
+
+// clampFS returns a filesystem with all modification times later than epoch
+// set to epoch.
+func clampFS(fs fsutil.FS, epoch time.Time) (fsutil.FS, error) {
+	return fsutil.NewFilterFS(fs, &fsutil.FilterOpt{
+		Map: func(_ string, st *fstypes.Stat) fsutil.MapResult {
+			if st.ModTime > epoch.UnixNano() {
+				st.ModTime = epoch.UnixNano()
+			}
+			return fsutil.MapResultKeep
+		},
+	})
+}
@@ -0,0 +1,63 @@
+package squashfs
+
+import (
+	"context"
+	gofs "io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+func TestClampFS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	epoch := time.Unix(1600000000, 0)
+	old := time.Unix(1000, 0)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old"), nil, 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "old"), old, old))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new"), nil, 0644))
+
+	fs, err := fsutil.NewFS(dir)
+	require.NoError(t, err)
+	fs, err = clampFS(fs, epoch)
+	require.NoError(t, err)
+
+	mtimes := map[string]int64{}
+	err = fs.Walk(context.TODO(), "/", func(p string, entry gofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		mtimes[p] = fi.Sys().(*fstypes.Stat).ModTime
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{
+		"old": old.UnixNano(),
+		"new": epoch.UnixNano(),
+	}, mtimes)
+}
+
+func TestResolveBlockSize(t *testing.T) {
+	t.Parallel()
+
+	e, err := New(Opt{})
+	require.NoError(t, err)
+
+	_, err = e.Resolve(context.TODO(), 0, map[string]string{keyBlockSize: "65536"})
+	require.NoError(t, err)
+
+	for _, v := range []string{"1024", "5000", "2097152"} {
+		_, err = e.Resolve(context.TODO(), 0, map[string]string{keyBlockSize: v})
+		require.ErrorContains(t, err, "must be a power of two between 4KiB and 1MiB")
+	}
+}
@@ -0,0 +1,322 @@
+package verifier
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/go-csvvalue"
+)
+
+func init() {
+	Register("max-size", newMaxSize)
+	Register("forbidden-paths", newForbiddenPaths)
+	Register("world-writable", newBool(func() Verifier { return worldWritable{} }))
+	Register("setuid", newBool(func() Verifier { return setuid{} }))
+	Register("required-labels", newRequiredLabels)
+}
+
+// mergeFuncs combine the values of a verifier that is set both by the daemon
+// and by the build. The result is at least as strict as each value.
+var mergeFuncs = map[string]func(a, b string) (string, error){
+	"max-size":        mergeMaxSize,
+	"forbidden-paths": mergeLists,
+	"world-writable":  mergeBools,
+	"setuid":          mergeBools,
+	"required-labels": mergeLists,
+}
+
+func mergeMaxSize(a, b string) (string, error) {
+	la, err := units.RAMInBytes(a)
+	if err != nil {
+		return "", err
+	}
+	lb, err := units.RAMInBytes(b)
+	if err != nil {
+		return "", err
+	}
+	if lb < la {
+		return b, nil
+	}
+	return a, nil
+}
+
+func mergeLists(a, b string) (string, error) {
+	out, err := splitList(a)
+	if err != nil {
+		return "", err
+	}
+	lb, err := splitList(b)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range lb {
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return JoinList(out), nil
+}
+
+func mergeBools(a, b string) (string, error) {
+	ba, err := strconv.ParseBool(a)
+	if err != nil {
+		return "", err
+	}
+	bb, err := strconv.ParseBool(b)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatBool(ba || bb), nil
+}
+
+func newBool(f func() Verifier) NewFunc {
+	return func(value string) (Verifier, error) {
+		b, err := strconv.ParseBool(value)
+		if err != nil || !b {
+			return nil, err
+		}
+		return f(), nil
+	}
+}
+
+// splitList parses a comma-separated list. Values that contain a comma are
+// quoted like CSV fields.
+func splitList(value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	p := csvvalue.NewParser()
+	p.TrimLeadingSpace = true
+	fields, err := p.Fields(value, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid list %q", value)
+	}
+	var out []string
+	for _, v := range fields {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// JoinList formats values as the value of a list option, quoting the values
+// that contain a comma.
+func JoinList(values []string) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(values)
+	w.Flush()
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// maxSize rejects results whose regular files are larger than the limit in
+// total.
+type maxSize struct {
+	limit int64
+}
+
+func newMaxSize(value string) (Verifier, error) {
+	limit, err := units.RAMInBytes(value)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, errors.Errorf("invalid size %q", value)
+	}
+	return maxSize{limit: limit}, nil
+}
+
+func (maxSize) Name() string {
+	return "max-size"
+}
+
+func (v maxSize) Verify(t *Target) []Violation {
+	var size int64
+	for _, f := range t.Files {
+		if f.Mode.IsRegular() {
+			size += f.Size
+		}
+	}
+	if size <= v.limit {
+		return nil
+	}
+	return []Violation{{
+		Message: fmt.Sprintf("size %s exceeds the limit of %s", units.BytesSize(float64(size)), units.BytesSize(float64(v.limit))),
+	}}
+}
+
+// forbiddenPaths rejects results that contain files matching any of the
+// patterns. Patterns without a slash match the base name of a file, other
+// patterns match the full path. Files below a matching directory are not
+// reported separately.
+type forbiddenPaths struct {
+	patterns []string
+}
+
+func newForbiddenPaths(value string) (Verifier, error) {
+	patterns, err := splitList(value)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range patterns {
+		if strings.Contains(p, "/") {
+			p = path.Clean("/" + p)
+			patterns[i] = p
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern %q", p)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	return forbiddenPaths{patterns: patterns}, nil
+}
+
+func (forbiddenPaths) Name() string {
+	return "forbidden-paths"
+}
+
+func (v forbiddenPaths) Verify(t *Target) []Violation {
+	var out []Violation
+	var lastDir string
+	for _, f := range t.Files {
+		if lastDir != "" && strings.HasPrefix(f.Path, lastDir+"/") {
+			continue
+		}
+		p, ok := v.match(f.Path)
+		if !ok {
+			continue
+		}
+		out = append(out, Violation{
+			Path:    f.Path,
+			Message: fmt.Sprintf("path matches forbidden pattern %q", p),
+		})
+		if f.Mode.IsDir() {
+			lastDir = f.Path
+		}
+	}
+	return out
+}
+
+func (v forbiddenPaths) match(p string) (string, bool) {
+	for _, pattern := range v.patterns {
+		name := p
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(p)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// worldWritable rejects results that contain files or directories that any
+// user can write to. Symlinks and directories with the sticky bit, like /tmp,
+// are allowed.
+type worldWritable struct{}
+
+func (worldWritable) Name() string {
+	return "world-writable"
+}
+
+func (worldWritable) Verify(t *Target) []Violation {
+	var out []Violation
+	for _, f := range t.Files {
+		if f.Mode&0o002 == 0 || f.Mode&fs.ModeSymlink != 0 {
+			continue
+		}
+		if f.Mode.IsDir() && f.Mode&fs.ModeSticky != 0 {
+			continue
+		}
+		out = append(out, Violation{
+			Path:    f.Path,
+			Message: fmt.Sprintf("world-writable %s", kind(f.Mode)),
+		})
+	}
+	return out
+}
+
+// setuid rejects results that contain setuid or setgid files. Setgid
+// directories are allowed.
+type setuid struct{}
+
+func (setuid) Name() string {
+	return "setuid"
+}
+
+func (setuid) Verify(t *Target) []Violation {
+	var out []Violation
+	for _, f := range t.Files {
+		if f.Mode.IsDir() {
+			continue
+		}
+		switch {
+		case f.Mode&fs.ModeSetuid != 0:
+			out = append(out, Violation{Path: f.Path, Message: "setuid " + kind(f.Mode)})
+		case f.Mode&fs.ModeSetgid != 0:
+			out = append(out, Violation{Path: f.Path, Message: "setgid " + kind(f.Mode)})
+		}
+	}
+	return out
+}
+
+func kind(mode fs.FileMode) string {
+	if mode.IsDir() {
+		return "directory"
+	}
+	return "file"
+}
+
+// requiredLabels rejects results whose image config does not have all the
+// labels. A label can be required to have a value with key=value.
+type requiredLabels struct {
+	labels []string
+}
+
+func newRequiredLabels(value string) (Verifier, error) {
+	labels, err := splitList(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return requiredLabels{labels: labels}, nil
+}
+
+func (requiredLabels) Name() string {
+	return "required-labels"
+}
+
+func (v requiredLabels) Verify(t *Target) []Violation {
+	if t.Artifact != "" {
+		// artifacts don't have an image config
+		return nil
+	}
+	var labels map[string]string
+	if t.Config != nil {
+		labels = t.Config.Config.Labels
+	}
+	var out []Violation
+	for _, l := range v.labels {
+		k, want, hasValue := strings.Cut(l, "=")
+		got, ok := labels[k]
+		switch {
+		case !ok:
+			out = append(out, Violation{Message: fmt.Sprintf("missing label %q", k)})
+		case hasValue && got != want:
+			out = append(out, Violation{Message: fmt.Sprintf("label %q is %q instead of %q", k, got, want)})
+		}
+	}
+	return out
+}
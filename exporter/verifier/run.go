@@ -0,0 +1,126 @@
+package verifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/moby/buildkit/cache"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/snapshot"
+	"github.com/moby/buildkit/solver/result"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// CheckResult runs the verifiers on every platform of the build result.
+func CheckResult(ctx context.Context, res *result.Result[cache.ImmutableRef], sessionID string, verifiers []Verifier) error {
+	if len(verifiers) == 0 || res.IsEmpty() {
+		return nil
+	}
+	ps, err := exptypes.ParsePlatforms(res.Metadata)
+	if err != nil {
+		return err
+	}
+
+	targets := make([]*Target, 0, len(ps.Platforms))
+	for _, p := range ps.Platforms {
+		ref, ok := res.FindRef(p.ID)
+		if !ok {
+			return errors.Errorf("failed to find ref for ID %s", p.ID)
+		}
+		t := &Target{Platform: p.ID}
+		if dt, ok := res.Metadata[fmt.Sprintf("%s/%s", exptypes.ExporterImageConfigKey, p.ID)]; ok {
+			t.Config = &ocispecs.Image{}
+			if err := json.Unmarshal(dt, t.Config); err != nil {
+				return errors.Wrap(err, "failed to parse image config")
+			}
+		} else if dt, ok := res.Metadata[exptypes.ExporterImageConfigKey]; ok {
+			t.Config = &ocispecs.Image{}
+			if err := json.Unmarshal(dt, t.Config); err != nil {
+				return errors.Wrap(err, "failed to parse image config")
+			}
+		}
+		if ref != nil {
+			if t.Files, err = readFiles(ctx, ref, sessionID); err != nil {
+				return err
+			}
+		}
+		targets = append(targets, t)
+	}
+	return Verify(targets, verifiers)
+}
+
+// CheckArtifacts runs the verifiers on every artifact of the build result.
+func CheckArtifacts(ctx context.Context, artifacts *result.Result[cache.ImmutableRef], sessionID string, verifiers []Verifier) error {
+	if len(verifiers) == 0 || artifacts == nil {
+		return nil
+	}
+	as, err := exptypes.ParseArtifacts(artifacts.Metadata)
+	if err != nil {
+		return err
+	}
+
+	targets := make([]*Target, 0, len(as.Artifacts))
+	for _, a := range as.Artifacts {
+		ref, ok := artifacts.FindRef(a.ID)
+		if !ok {
+			return errors.Errorf("failed to find ref for artifact %s", a.Name)
+		}
+		t := &Target{Platform: a.Platform, Artifact: a.Name}
+		if ref != nil {
+			if t.Files, err = readFiles(ctx, ref, sessionID); err != nil {
+				return err
+			}
+		}
+		targets = append(targets, t)
+	}
+	return Verify(targets, verifiers)
+}
+
+func readFiles(ctx context.Context, ref cache.ImmutableRef, sessionID string) ([]File, error) {
+	mount, err := ref.Mount(ctx, true, session.NewGroup(sessionID))
+	if err != nil {
+		return nil, err
+	}
+	lm := snapshot.LocalMounter(mount)
+	root, err := lm.Mount()
+	if err != nil {
+		return nil, err
+	}
+	defer lm.Unmount()
+
+	var files []File
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{
+			Path: "/" + filepath.ToSlash(rel),
+			Mode: fi.Mode(),
+			Size: fi.Size(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read build result")
+	}
+	return files, nil
+}
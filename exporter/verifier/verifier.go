@@ -0,0 +1,201 @@
+package verifier
+
+import (
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/moby/buildkit/solver/errdefs"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// AttrPrefix is the prefix of the exporter attributes that configure
+// verifiers, e.g. verify.max-size=1GB.
+const AttrPrefix = "verify."
+
+// maxViolations limits the number of violations reported in the error of a
+// failed verification.
+const maxViolations = 100
+
+// Verifier checks the build result of a platform before it is exported.
+type Verifier interface {
+	Name() string
+	Verify(t *Target) []Violation
+}
+
+// Target is the build result of a single platform, or one of its artifacts.
+type Target struct {
+	Platform string
+	// Artifact is the name of the artifact, if the target is an artifact of
+	// the build result.
+	Artifact string
+	// Config is the image config of the result, nil if the frontend did not
+	// return one.
+	Config *ocispecs.Image
+	// Files are all the files of the result filesystem in lexical order.
+	Files []File
+}
+
+type File struct {
+	// Path is the absolute path of the file in the result filesystem.
+	Path string
+	Mode fs.FileMode
+	Size int64
+}
+
+type Violation struct {
+	Path    string
+	Message string
+}
+
+// NewFunc creates a verifier from the value of its option. Nil is returned if
+// the value disables the verifier.
+type NewFunc func(value string) (Verifier, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]NewFunc{}
+)
+
+// Register makes a verifier available with the option name.
+func Register(name string, f NewFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = f
+}
+
+// New creates the verifiers for the options. Options are keyed by the name
+// of the verifier.
+func New(opts map[string]string) ([]Verifier, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	var out []Verifier
+	for _, name := range slices.Sorted(maps.Keys(opts)) {
+		f, ok := registry[name]
+		if !ok {
+			return nil, errors.Errorf("unknown verifier %q", name)
+		}
+		v, err := f(opts[name])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for verifier %s", name)
+		}
+		if v != nil {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// SplitAttrs separates the verifier options from the other exporter
+// attributes.
+func SplitAttrs(attrs map[string]string) (opts map[string]string, rest map[string]string) {
+	for k, v := range attrs {
+		if name, ok := strings.CutPrefix(k, AttrPrefix); ok {
+			if opts == nil {
+				opts = map[string]string{}
+			}
+			opts[name] = v
+			continue
+		}
+		if rest == nil {
+			rest = map[string]string{}
+		}
+		rest[k] = v
+	}
+	return opts, rest
+}
+
+// MergeOpts adds the verifier options of an exporter to opts. The options of
+// multiple exporters must not conflict.
+func MergeOpts(opts, exporterOpts map[string]string) (map[string]string, error) {
+	for k, v := range exporterOpts {
+		if v2, ok := opts[k]; ok && v2 != v {
+			return nil, errors.Errorf("conflicting values for verifier %s: %q and %q", k, v2, v)
+		}
+		if opts == nil {
+			opts = map[string]string{}
+		}
+		opts[k] = v
+	}
+	return opts, nil
+}
+
+// MergeEnforcedOpts adds the options that the daemon enforces to the options
+// of a build. Verifiers that both set are combined so that the result is at
+// least as strict as each of them: lists are joined, the smaller size limit is
+// used and checks are enabled if either enables them.
+func MergeEnforcedOpts(opts, enforced map[string]string) (map[string]string, error) {
+	out := maps.Clone(opts)
+	if out == nil {
+		out = map[string]string{}
+	}
+	for k, v := range enforced {
+		v2, ok := out[k]
+		if !ok || v2 == v {
+			out[k] = v
+			continue
+		}
+		merge, ok := mergeFuncs[k]
+		if !ok {
+			out[k] = v
+			continue
+		}
+		merged, err := merge(v, v2)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for verifier %s", k)
+		}
+		out[k] = merged
+	}
+	return out, nil
+}
+
+// Verify runs the verifiers on the targets and returns a
+// errdefs.VerificationFailedError if any of them rejects the build result.
+func Verify(targets []*Target, verifiers []Verifier) error {
+	var violations []*errdefs.VerifierViolation
+	var total int
+	for _, t := range targets {
+		for _, v := range verifiers {
+			for _, vi := range v.Verify(t) {
+				total++
+				if len(violations) < maxViolations {
+					msg := vi.Message
+					if t.Artifact != "" {
+						msg += " in artifact " + t.Artifact
+					}
+					violations = append(violations, &errdefs.VerifierViolation{
+						Verifier: v.Name(),
+						Platform: t.Platform,
+						Path:     vi.Path,
+						Message:  msg,
+					})
+				}
+			}
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("build result failed verification:")
+	for _, vi := range violations {
+		fmt.Fprintf(&b, "\n  - %s: ", vi.Verifier)
+		if vi.Path != "" {
+			fmt.Fprintf(&b, "%s: ", vi.Path)
+		}
+		b.WriteString(vi.Message)
+		if len(targets) > 1 {
+			fmt.Fprintf(&b, " (%s)", vi.Platform)
+		}
+	}
+	if total > len(violations) {
+		fmt.Fprintf(&b, "\n  ... and %d more", total-len(violations))
+	}
+	return errdefs.WithVerificationFailed(errors.New(b.String()), violations)
+}
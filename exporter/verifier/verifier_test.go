@@ -0,0 +1,198 @@
+package verifier
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/moby/buildkit/solver/errdefs"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	verifiers, err := New(map[string]string{
+		"max-size":        "1KB",
+		"forbidden-paths": "root/.ssh,*.pem",
+		"world-writable":  "true",
+		"setuid":          "1",
+		"required-labels": "org.opencontainers.image.source,team=build",
+	})
+	require.NoError(t, err)
+	require.Len(t, verifiers, 5)
+
+	target := &Target{
+		Platform: "linux/amd64",
+		Config: &ocispecs.Image{Config: ocispecs.ImageConfig{Labels: map[string]string{
+			"team": "other",
+		}}},
+		Files: []File{
+			{Path: "/etc", Mode: fs.ModeDir | 0o755},
+			{Path: "/etc/ssl", Mode: fs.ModeDir | 0o755},
+			{Path: "/etc/ssl/key.pem", Mode: 0o600, Size: 600},
+			{Path: "/root", Mode: fs.ModeDir | 0o700},
+			{Path: "/root/.ssh", Mode: fs.ModeDir | 0o700},
+			{Path: "/root/.ssh/id_rsa.pem", Mode: 0o600, Size: 600},
+			{Path: "/tmp", Mode: fs.ModeDir | fs.ModeSticky | 0o777},
+			{Path: "/usr/bin/passwd", Mode: fs.ModeSetuid | 0o755},
+			{Path: "/usr/bin/link", Mode: fs.ModeSymlink | 0o777},
+			{Path: "/var/data", Mode: 0o666},
+		},
+	}
+
+	err = Verify([]*Target{target}, verifiers)
+	var verr *errdefs.VerificationFailedError
+	require.ErrorAs(t, err, &verr)
+
+	type violation struct {
+		verifier, path, message string
+	}
+	var got []violation
+	for _, v := range verr.Violations {
+		require.Equal(t, "linux/amd64", v.Platform)
+		got = append(got, violation{v.Verifier, v.Path, v.Message})
+	}
+	require.Equal(t, []violation{
+		{"forbidden-paths", "/etc/ssl/key.pem", `path matches forbidden pattern "*.pem"`},
+		{"forbidden-paths", "/root/.ssh", `path matches forbidden pattern "/root/.ssh"`},
+		{"max-size", "", "size 1.172KiB exceeds the limit of 1KiB"},
+		{"required-labels", "", `missing label "org.opencontainers.image.source"`},
+		{"required-labels", "", `label "team" is "other" instead of "build"`},
+		{"setuid", "/usr/bin/passwd", "setuid file"},
+		{"world-writable", "/var/data", "world-writable file"},
+	}, got)
+	require.ErrorContains(t, err, "forbidden-paths: /root/.ssh: path matches")
+
+	// disabled verifiers are skipped
+	verifiers, err = New(map[string]string{"world-writable": "false", "forbidden-paths": ""})
+	require.NoError(t, err)
+	require.Empty(t, verifiers)
+
+	_, err = New(map[string]string{"unknown": "true"})
+	require.ErrorContains(t, err, `unknown verifier "unknown"`)
+
+	_, err = New(map[string]string{"max-size": "big"})
+	require.ErrorContains(t, err, "invalid value for verifier max-size")
+}
+
+func TestVerifyLimit(t *testing.T) {
+	t.Parallel()
+
+	target := &Target{Platform: "linux/amd64"}
+	for range maxViolations + 5 {
+		target.Files = append(target.Files, File{Path: "/file", Mode: 0o777})
+	}
+	verifiers, err := New(map[string]string{"world-writable": "true"})
+	require.NoError(t, err)
+
+	err = Verify([]*Target{target}, verifiers)
+	var verr *errdefs.VerificationFailedError
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr.Violations, maxViolations)
+	require.ErrorContains(t, err, "... and 5 more")
+
+	target.Files = nil
+	require.NoError(t, Verify([]*Target{target}, verifiers))
+}
+
+func TestVerifyArtifact(t *testing.T) {
+	t.Parallel()
+
+	verifiers, err := New(map[string]string{
+		"forbidden-paths": "*.key",
+		"required-labels": "team",
+	})
+	require.NoError(t, err)
+
+	// artifacts have no image config, so labels are not required
+	target := &Target{
+		Artifact: "keys",
+		Files: []File{
+			{Path: "/id.key", Mode: 0o600, Size: 10},
+		},
+	}
+	err = Verify([]*Target{target}, verifiers)
+	var verr *errdefs.VerificationFailedError
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr.Violations, 1)
+	require.Equal(t, "forbidden-paths", verr.Violations[0].Verifier)
+	require.Equal(t, "/id.key", verr.Violations[0].Path)
+	require.Equal(t, `path matches forbidden pattern "*.key" in artifact keys`, verr.Violations[0].Message)
+}
+
+func TestAttrs(t *testing.T) {
+	t.Parallel()
+
+	opts, rest := SplitAttrs(map[string]string{
+		"name":            "foo",
+		"verify.max-size": "1GB",
+	})
+	require.Equal(t, map[string]string{"max-size": "1GB"}, opts)
+	require.Equal(t, map[string]string{"name": "foo"}, rest)
+
+	opts, err := MergeOpts(opts, map[string]string{"max-size": "1GB", "setuid": "true"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"max-size": "1GB", "setuid": "true"}, opts)
+
+	_, err = MergeOpts(opts, map[string]string{"max-size": "2GB"})
+	require.ErrorContains(t, err, "conflicting values for verifier max-size")
+}
+
+func TestMergeEnforcedOpts(t *testing.T) {
+	t.Parallel()
+
+	opts, err := MergeEnforcedOpts(map[string]string{
+		"max-size":        "1GB",
+		"forbidden-paths": "*.key",
+		"required-labels": "team",
+		"setuid":          "true",
+	}, map[string]string{
+		"max-size":        "2GB",
+		"forbidden-paths": "/root/.ssh, *.key",
+		"required-labels": "team",
+		"setuid":          "false",
+		"world-writable":  "true",
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"max-size":        "1GB",
+		"forbidden-paths": "/root/.ssh,*.key",
+		"required-labels": "team",
+		"setuid":          "true",
+		"world-writable":  "true",
+	}, opts)
+
+	// the stricter limit of the daemon is kept
+	opts, err = MergeEnforcedOpts(map[string]string{"max-size": "4GB"}, map[string]string{"max-size": "2GB"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"max-size": "2GB"}, opts)
+
+	opts, err = MergeEnforcedOpts(nil, map[string]string{"setuid": "true"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"setuid": "true"}, opts)
+
+	_, err = MergeEnforcedOpts(map[string]string{"max-size": "big"}, map[string]string{"max-size": "2GB"})
+	require.ErrorContains(t, err, "invalid value for verifier max-size")
+
+	// values with a comma are quoted
+	opts, err = MergeEnforcedOpts(map[string]string{
+		"required-labels": "team",
+	}, map[string]string{
+		"required-labels": JoinList([]string{"vendor=Example, Inc."}),
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"required-labels": `"vendor=Example, Inc.",team`}, opts)
+}
+
+func TestListValues(t *testing.T) {
+	t.Parallel()
+
+	l, err := splitList(` /root/.ssh, "vendor=Example, Inc.",,`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/root/.ssh", "vendor=Example, Inc."}, l)
+	require.Equal(t, `/root/.ssh,"vendor=Example, Inc."`, JoinList(l))
+
+	_, err = New(map[string]string{"required-labels": `"team`})
+	require.ErrorContains(t, err, "invalid value for verifier required-labels")
+}
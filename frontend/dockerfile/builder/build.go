@@ -17,6 +17,7 @@ import (
 	"github.com/moby/buildkit/frontend/gateway/client"
 	gwpb "github.com/moby/buildkit/frontend/gateway/pb"
 	"github.com/moby/buildkit/frontend/subrequests/convertllb"
+	"github.com/moby/buildkit/frontend/subrequests/graph"
 	"github.com/moby/buildkit/frontend/subrequests/lint"
 	"github.com/moby/buildkit/frontend/subrequests/outline"
 	"github.com/moby/buildkit/frontend/subrequests/targets"
@@ -72,19 +73,24 @@ func Build(ctx context.Context, c client.Client) (_ *client.Result, err error) {
 		return nil, capsError
 	}
 
-	convertOpt := dockerfile2llb.ConvertOpt{
-		Config:       bc.Config,
-		Client:       bc,
-		SourceMap:    src.SourceMap,
-		MetaResolver: c,
-		Warn: func(rulename, description, url, msg string, location []parser.Range) {
+	warn := func(src *dockerui.Source) linter.LintWarnFunc {
+		return func(rulename, description, url, msg string, location []parser.Range) {
 			startLine := 0
 			if len(location) > 0 {
 				startLine = location[0].Start.Line
 			}
 			msg = linter.LintFormatShort(rulename, msg, startLine)
 			src.Warn(ctx, msg, warnOpts(location, [][]byte{[]byte(description)}, url))
-		},
+		}
+	}
+
+	convertOpt := dockerfile2llb.ConvertOpt{
+		Config:       bc.Config,
+		Client:       bc,
+		SourceMap:    src.SourceMap,
+		MetaResolver: c,
+		Warn:         warn(src),
+		IncludeWarn:  warn,
 	}
 
 	if res, ok, err := bc.HandleSubrequest(ctx, dockerui.RequestHandler{
@@ -92,7 +98,7 @@ func Build(ctx context.Context, c client.Client) (_ *client.Result, err error) {
 			return dockerfile2llb.Dockerfile2Outline(ctx, src.Data, convertOpt)
 		},
 		ListTargets: func(ctx context.Context) (*targets.List, error) {
-			return dockerfile2llb.ListTargets(ctx, src.Data)
+			return dockerfile2llb.ListTargets(ctx, src.Data, convertOpt)
 		},
 		Lint: func(ctx context.Context) (*lint.LintResults, error) {
 			return dockerfile2llb.DockerfileLint(ctx, src.Data, convertOpt)
@@ -100,6 +106,9 @@ func Build(ctx context.Context, c client.Client) (_ *client.Result, err error) {
 		ConvertLLB: func(ctx context.Context) (*convertllb.Result, error) {
 			return dockerfile2llb.DockerfileConvertLLB(ctx, src.Data, convertOpt)
 		},
+		Graph: func(ctx context.Context) (*graph.Graph, error) {
+			return dockerfile2llb.Dockerfile2Graph(ctx, src.Data, convertOpt)
+		},
 	}); err != nil {
 		return nil, err
 	} else if ok {
@@ -108,7 +117,8 @@ func Build(ctx context.Context, c client.Client) (_ *client.Result, err error) {
 
 	defer func() {
 		var el *parser.LocationError
-		if errors.As(err, &el) {
+		// errors in included Dockerfiles already have their source set
+		if errors.As(err, &el) && len(errdefs.Sources(err)) == 0 {
 			for _, l := range el.Locations {
 				err = wrapSource(err, src.SourceMap, l)
 			}
@@ -138,6 +148,7 @@ func Build(ctx context.Context, c client.Client) (_ *client.Result, err error) {
 		opt.TargetPlatform = platform
 		if idx != 0 {
 			opt.Warn = nil
+			opt.IncludeWarn = nil
 		}
 
 		dfRes, err := dockerfile2llb.Dockerfile2LLB(ctx, src.Data, opt)
@@ -163,6 +174,33 @@ func Build(ctx context.Context, c client.Client) (_ *client.Result, err error) {
 			return nil, err
 		}
 
+		if err := dfRes.ValidateImage(ctx, ref); err != nil {
+			return nil, err
+		}
+
+		artifacts := make([]dockerui.BuildArtifact, 0, len(dfRes.Artifacts))
+		for _, a := range dfRes.Artifacts {
+			def, err := a.State.Marshal(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to marshal LLB definition for artifact %s", a.Name)
+			}
+			r, err := c.Solve(ctx, client.SolveRequest{
+				Definition:   def.ToPB(),
+				CacheImports: bc.CacheImports,
+			})
+			if err != nil {
+				return nil, err
+			}
+			ref, err := r.SingleRef()
+			if err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, dockerui.BuildArtifact{
+				Name:      a.Name,
+				Reference: ref,
+			})
+		}
+
 		var p ocispecs.Platform
 		if platform != nil {
 			p = *platform
@@ -176,6 +214,7 @@ func Build(ctx context.Context, c client.Client) (_ *client.Result, err error) {
 			Image:     dfRes.Image,
 			BaseImage: dfRes.BaseImage,
 			Epoch:     dfRes.Epoch,
+			Artifacts: artifacts,
 		}, nil
 	})
 	if err != nil {
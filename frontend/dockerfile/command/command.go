@@ -7,11 +7,16 @@ const (
 	Arg         = "arg"
 	Cmd         = "cmd"
 	Copy        = "copy"
+	Else        = "else"
+	EndIf       = "endif"
 	Entrypoint  = "entrypoint"
 	Env         = "env"
+	Export      = "export"
 	Expose      = "expose"
 	From        = "from"
 	Healthcheck = "healthcheck"
+	If          = "if"
+	Include     = "include"
 	Label       = "label"
 	Maintainer  = "maintainer"
 	Onbuild     = "onbuild"
@@ -29,11 +34,16 @@ var Commands = map[string]struct{}{
 	Arg:         {},
 	Cmd:         {},
 	Copy:        {},
+	Else:        {},
+	EndIf:       {},
 	Entrypoint:  {},
 	Env:         {},
+	Export:      {},
 	Expose:      {},
 	From:        {},
 	Healthcheck: {},
+	If:          {},
+	Include:     {},
 	Label:       {},
 	Maintainer:  {},
 	Onbuild:     {},
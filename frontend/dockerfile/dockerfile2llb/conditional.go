@@ -0,0 +1,80 @@
+package dockerfile2llb
+
+import (
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/pkg/errors"
+)
+
+// conditionalBlock is an IF block of the stage being dispatched.
+type conditionalBlock struct {
+	// enabled is true if the instructions of the current branch of the block
+	// are dispatched
+	enabled bool
+	// matched is true if the condition of the block was true
+	matched bool
+	// skipped is true if the block is inside a branch that is not dispatched
+	skipped bool
+}
+
+// conditionalBlocks tracks the nested IF blocks while dispatching the
+// instructions of a stage.
+type conditionalBlocks []conditionalBlock
+
+// enter handles the IF, ELSE and ENDIF instructions and returns true if cmd
+// needs to be dispatched. Conditions are evaluated with the environment of
+// the stage at the point of the IF instruction.
+func (b *conditionalBlocks) enter(d *dispatchState, cmd command, opt dispatchOpt) (bool, error) {
+	skipped := len(*b) > 0 && !(*b)[len(*b)-1].enabled
+	switch c := cmd.Command.(type) {
+	case *instructions.IfCommand:
+		block := conditionalBlock{skipped: skipped}
+		if !skipped {
+			ok, err := evaluateCondition(d, cmd, c, opt)
+			if err != nil {
+				return false, err
+			}
+			block.enabled, block.matched = ok, ok
+		}
+		*b = append(*b, block)
+		return false, nil
+	case *instructions.ElseCommand:
+		if len(*b) == 0 {
+			return false, errors.New("ELSE without matching IF")
+		}
+		block := &(*b)[len(*b)-1]
+		block.enabled = !block.skipped && !block.matched
+		return false, nil
+	case *instructions.EndIfCommand:
+		if len(*b) == 0 {
+			return false, errors.New("ENDIF without matching IF")
+		}
+		*b = (*b)[:len(*b)-1]
+		return false, nil
+	}
+	return !skipped, nil
+}
+
+func evaluateCondition(d *dispatchState, cmd command, c *instructions.IfCommand, opt dispatchOpt) (bool, error) {
+	opt.lint = opt.lint.WithMergedConfigFromComments(cmd.Comments())
+	err := c.Expand(func(word string) (string, error) {
+		env := getEnv(d.state)
+		newword, unmatched, err := opt.shlex.ProcessWord(word, env)
+		reportUnmatchedVariables(cmd, d.buildArgs, env, unmatched, &opt)
+		return newword, err
+	})
+	if err != nil {
+		return false, err
+	}
+	switch len(c.Condition) {
+	case 1:
+		return c.Condition[0] != "", nil
+	case 3:
+		switch c.Condition[1] {
+		case "==":
+			return c.Condition[0] == c.Condition[2], nil
+		case "!=":
+			return c.Condition[0] != c.Condition[2], nil
+		}
+	}
+	return false, errors.Errorf("invalid condition for IF: %v", c.Condition)
+}
@@ -0,0 +1,87 @@
+package dockerfile2llb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/linter"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditionalBlocks(t *testing.T) {
+	t.Parallel()
+
+	ast, err := parser.Parse(strings.NewReader(`FROM scratch
+IF $TARGETARCH == arm64
+ENV A=1
+IF $VARIANT
+ENV B=1
+ENDIF
+ELSE
+ENV C=1
+IF $UNDEFINED != ""
+ENV D=1
+ELSE
+ENV E=1
+ENDIF
+ENDIF
+ENV F=1
+`))
+	require.NoError(t, err)
+	stages, _, err := instructions.Parse(ast.AST, nil)
+	require.NoError(t, err)
+
+	var warnings []string
+	opt := dispatchOpt{
+		shlex: shell.NewLex('\\'),
+		lint: linter.New(&linter.Config{
+			Warn: func(rulename, _, _, _ string, _ []parser.Range) {
+				warnings = append(warnings, rulename)
+			},
+		}),
+	}
+
+	dispatched := func(env ...string) []string {
+		d := &dispatchState{state: llb.Scratch()}
+		for _, kv := range env {
+			k, v, _ := strings.Cut(kv, "=")
+			d.state = d.state.AddEnv(k, v)
+		}
+		var blocks conditionalBlocks
+		var out []string
+		for _, ic := range stages[0].Commands {
+			// commands are expanded in place, so each run gets its own copy
+			ic = cloneCommand(ic)
+			ok, err := blocks.enter(d, command{Command: ic}, opt)
+			require.NoError(t, err)
+			if ok {
+				out = append(out, ic.(*instructions.EnvCommand).Env[0].Key)
+			}
+		}
+		require.Empty(t, blocks)
+		return out
+	}
+
+	warnings = nil
+	require.Equal(t, []string{"A", "B", "F"}, dispatched("TARGETARCH=arm64", "VARIANT=foo"))
+	require.Empty(t, warnings)
+
+	require.Equal(t, []string{"A", "F"}, dispatched("TARGETARCH=arm64", "VARIANT="))
+
+	warnings = nil
+	require.Equal(t, []string{"C", "E", "F"}, dispatched("TARGETARCH=amd64", "VARIANT=foo"))
+	require.Equal(t, []string{linter.RuleUndefinedVar.Name}, warnings)
+}
+
+func cloneCommand(cmd instructions.Command) instructions.Command {
+	if c, ok := cmd.(*instructions.IfCommand); ok {
+		cp := *c
+		cp.Condition = append([]string(nil), c.Condition...)
+		return &cp
+	}
+	return cmd
+}
@@ -63,7 +63,16 @@ type ConvertOpt struct {
 	MetaResolver   llb.ImageMetaResolver
 	LLBCaps        *apicaps.CapSet
 	Warn           linter.LintWarnFunc
-	AllStages      bool
+	// WarnWithFix is called instead of Warn with the edits that fix the
+	// warning. Warn is used if it is nil.
+	WarnWithFix linter.LintFixFunc
+	// IncludeWarn returns the function for the warnings of the Dockerfile
+	// included from src. Warnings of included Dockerfiles are not reported if
+	// it is nil.
+	IncludeWarn func(src *dockerui.Source) linter.LintWarnFunc
+	// IncludeWarnWithFix is like IncludeWarn for WarnWithFix.
+	IncludeWarnWithFix func(src *dockerui.Source) linter.LintFixFunc
+	AllStages          bool
 }
 
 type SBOMTargets struct {
@@ -77,8 +86,14 @@ type Result struct {
 	BaseImage *dockerspec.DockerOCIImage
 	SBOM      *SBOMTargets
 	Epoch     *time.Time
+	// Artifacts are the paths marked with the EXPORT instruction in the
+	// stages of the build, sorted by name.
+	Artifacts []Artifact
 
 	IsIgnoreCache bool
+
+	// target is the dispatched target stage the image is validated against.
+	target *dispatchState
 }
 
 func Dockerfile2LLB(ctx context.Context, dt []byte, opt ConvertOpt) (*Result, error) {
@@ -91,6 +106,7 @@ func Dockerfile2LLB(ctx context.Context, dt []byte, opt ConvertOpt) (*Result, er
 		State:     ds.state,
 		Image:     &ds.image,
 		BaseImage: ds.baseImg,
+		target:    ds,
 		SBOM: &SBOMTargets{
 			Core:   ds.state,
 			Extras: map[string]llb.State{},
@@ -103,7 +119,8 @@ func Dockerfile2LLB(ctx context.Context, dt []byte, opt ConvertOpt) (*Result, er
 	if ds.ignoreCache {
 		res.IsIgnoreCache = true
 	}
-	for dsi := range allReachableStages(ds) {
+	reachable := allReachableStages(ds)
+	for dsi := range reachable {
 		if ds != dsi && dsi.scanStage {
 			res.SBOM.Extras[dsi.stageName] = dsi.state
 			if dsi.ignoreCache {
@@ -112,6 +129,11 @@ func Dockerfile2LLB(ctx context.Context, dt []byte, opt ConvertOpt) (*Result, er
 		}
 	}
 
+	res.Artifacts, err = collectArtifacts(reachable)
+	if err != nil {
+		return nil, err
+	}
+
 	return res, nil
 }
 
@@ -135,8 +157,18 @@ func DockerfileConvertLLB(ctx context.Context, dt []byte, opt ConvertOpt) (*conv
 func DockerfileLint(ctx context.Context, dt []byte, opt ConvertOpt) (*lint.LintResults, error) {
 	results := &lint.LintResults{}
 	sourceIndex := results.AddSource(opt.SourceMap)
-	opt.Warn = func(rulename, description, url, fmtmsg string, location []parser.Range) {
-		results.AddWarning(rulename, description, url, fmtmsg, sourceIndex, location)
+	warn := func(sourceIndex int) linter.LintFixFunc {
+		return func(rulename, description, url, fmtmsg string, location []parser.Range, edits []linter.TextEdit) {
+			lintEdits := make([]lint.TextEdit, len(edits))
+			for i, e := range edits {
+				lintEdits[i] = lint.NewTextEdit(e.Range, e.NewText)
+			}
+			results.AddWarning(rulename, description, url, fmtmsg, sourceIndex, location, lintEdits...)
+		}
+	}
+	opt.WarnWithFix = warn(sourceIndex)
+	opt.IncludeWarnWithFix = func(src *dockerui.Source) linter.LintFixFunc {
+		return warn(results.AddSource(src.SourceMap))
 	}
 	// for lint, no target means all targets
 	if opt.Target == "" {
@@ -151,6 +183,10 @@ func DockerfileLint(ctx context.Context, dt []byte, opt ConvertOpt) (*lint.LintR
 			Message: err.Error(),
 		}
 		if errors.As(err, &errLoc) {
+			var incErr *includeError
+			if errors.As(err, &incErr) {
+				sourceIndex = results.AddSource(incErr.include.source.SourceMap)
+			}
 			ranges := mergeLocations(errLoc.Locations...)
 			buildErr.Location = toPBLocation(sourceIndex, ranges)
 		}
@@ -159,13 +195,24 @@ func DockerfileLint(ctx context.Context, dt []byte, opt ConvertOpt) (*lint.LintR
 	return results, nil
 }
 
-func ListTargets(ctx context.Context, dt []byte) (*targets.List, error) {
+func ListTargets(ctx context.Context, dt []byte, opt ConvertOpt) (*targets.List, error) {
 	dockerfile, err := parser.Parse(bytes.NewReader(dt))
 	if err != nil {
 		return nil, err
 	}
 
-	stages, _, err := instructions.Parse(dockerfile.AST, nil)
+	stages, _, includeCmds, err := instructions.ParseWithIncludes(dockerfile.AST, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateStageTemplates(stages); err != nil {
+		return nil, err
+	}
+	if err := validateConditionals(stages); err != nil {
+		return nil, err
+	}
+
+	includes, err := parseIncludes(ctx, includeCmds, &opt, nil, dockerfile.EscapeToken)
 	if err != nil {
 		return nil, err
 	}
@@ -181,10 +228,26 @@ func ListTargets(ctx context.Context, dt []byte) (*targets.List, error) {
 			Default:     i == len(stages)-1,
 			Base:        s.BaseName,
 			Platform:    s.Platform,
-			Location:    toSourceLocation(s.Location),
+			Location:    toSourceLocation(0, s.Location),
+			Template:    s.Template,
+			Args:        templateArgsMap(s.TemplateArgs),
 		}
 		l.Targets = append(l.Targets, t)
 	}
+	for _, inc := range includes {
+		l.Sources = append(l.Sources, inc.source.Data)
+		for _, s := range inc.stages {
+			l.Targets = append(l.Targets, targets.Target{
+				Name:        s.Name,
+				Description: s.DocComment,
+				Base:        s.BaseName,
+				Platform:    s.Platform,
+				Location:    toSourceLocation(inc.sourceIndex, s.Location),
+				Template:    s.Template,
+				Args:        templateArgsMap(s.TemplateArgs),
+			})
+		}
+	}
 	return l, nil
 }
 
@@ -200,7 +263,11 @@ func newRuleLinter(dt []byte, opt *ConvertOpt) (*linter.Linter, error) {
 			return nil, errors.Wrapf(err, "failed to parse check options")
 		}
 	}
+	if opt.Client != nil {
+		lintConfig.Policy = opt.Client.LinterPolicy
+	}
 	lintConfig.Warn = opt.Warn
+	lintConfig.WarnWithFix = opt.WarnWithFix
 	return linter.New(lintConfig), nil
 }
 
@@ -216,6 +283,10 @@ type dispatchContext struct {
 	allDispatchStates *dispatchStates
 	proxyEnv          *llb.ProxyEnv
 	namedContext      func(string, dockerui.ContextOpt) (*dockerui.NamedContext, error)
+	// stageIncludes is the included Dockerfile of each stage, or nil for the
+	// stages of the main Dockerfile.
+	stageIncludes  []*includedDockerfile
+	includedStages int
 }
 
 func namedContextFunc(opt ConvertOpt) func(string, dockerui.ContextOpt) (*dockerui.NamedContext, error) {
@@ -256,6 +327,7 @@ func toDispatchState(ctx context.Context, dt []byte, opt ConvertOpt) (*dispatchS
 	if err != nil {
 		return nil, err
 	}
+	lint.Source = linter.NewSource(dt, dockerfile)
 
 	// Moby still uses the `dockerfile.PrintWarnings` method to print non-empty
 	// continuation line warnings. We iterate over those warnings here.
@@ -270,15 +342,27 @@ func toDispatchState(ctx context.Context, dt []byte, opt ConvertOpt) (*dispatchS
 		}
 	}
 
-	stages, argCmds, err := instructions.Parse(dockerfile.AST, lint)
+	stages, argCmds, includeCmds, err := instructions.ParseWithIncludes(dockerfile.AST, lint)
 	if err != nil {
 		return nil, err
 	}
 	if len(stages) == 0 {
 		return nil, errors.New("dockerfile contains no stages to build")
 	}
+	if err := validateStageTemplates(stages); err != nil {
+		return nil, err
+	}
+	if err := validateConditionals(stages); err != nil {
+		return nil, err
+	}
 	validateStageNames(stages, lint)
 	validateCommandCasing(stages, lint)
+	lint.CheckPolicy(dockerfile.AST, opt.Target, true)
+
+	includes, err := parseIncludes(ctx, includeCmds, &opt, lint, dockerfile.EscapeToken)
+	if err != nil {
+		return nil, err
+	}
 
 	platformOpt := buildPlatformOpt(&opt)
 	targetName := opt.Target
@@ -293,6 +377,17 @@ func toDispatchState(ctx context.Context, dt []byte, opt ConvertOpt) (*dispatchS
 	// Validate that base images continue to be valid even
 	// when no build arguments are used.
 	validateBaseImagesWithDefaultArgs(stages, shlex, globalArgs, argCmds, lint)
+	for _, inc := range includes {
+		validateBaseImagesWithDefaultArgs(inc.stages, shlex, globalArgs, inc.metaArgs, inc.lint)
+	}
+
+	mainArgCmds := argCmds
+	argCmds, err = includedMetaArgs(argCmds, includes, opt.BuildArgs)
+	if err != nil {
+		return nil, err
+	}
+	numStages := len(stages)
+	stages, stageIncludes := mergeIncludedStages(stages, includes)
 
 	// Rebuild the arguments using the provided build arguments
 	// for the remainder of the build.
@@ -300,6 +395,7 @@ func toDispatchState(ctx context.Context, dt []byte, opt ConvertOpt) (*dispatchS
 	if err != nil {
 		return nil, err
 	}
+	setMetaArgSources(outline.allArgs, mainArgCmds, includes)
 
 	var resolvedEpoch *time.Time
 	if sourceDateEpoch, ok := getBuildArgValue(opt.BuildArgs, globalArgs, "SOURCE_DATE_EPOCH"); ok {
@@ -327,6 +423,8 @@ func toDispatchState(ctx context.Context, dt []byte, opt ConvertOpt) (*dispatchS
 		allDispatchStates: newDispatchStates(),
 		proxyEnv:          proxyEnvFromBuildArgs(opt.BuildArgs),
 		namedContext:      namedContextFunc(opt),
+		stageIncludes:     stageIncludes,
+		includedStages:    len(stages) - numStages,
 	}
 
 	if err := dctx.buildDispatchStates(stages); err != nil {
@@ -342,7 +440,7 @@ func toDispatchState(ctx context.Context, dt []byte, opt ConvertOpt) (*dispatchS
 		return nil, err
 	}
 
-	allReachable, err := dctx.resolveStages(ctx, target)
+	allReachable, err := dctx.resolveStages(ctx, target, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -355,6 +453,7 @@ func toDispatchState(ctx context.Context, dt []byte, opt ConvertOpt) (*dispatchS
 	if err := dctx.finalizeResultImage(ctx, target, ctxPaths, buildContext); err != nil {
 		return nil, err
 	}
+	target.includes = includes
 
 	return target, nil
 }
@@ -394,12 +493,23 @@ func setBuildArgValue(buildArgs map[string]string, globalArgs *llb.EnvList, key,
 	return globalArgs
 }
 
-func (dctx *dispatchContext) buildDispatchStates(stages []instructions.Stage) error {
+func (dctx *dispatchContext) buildDispatchStates(stages []instructions.Stage) (err error) {
+	var inc *includedDockerfile
+	defer func() {
+		err = inc.wrapError(err)
+	}()
 	for i, st := range stages {
-		lint := dctx.lint.WithMergedConfigFromComments(st.Comments)
+		inc = dctx.stageIncludes[i]
+		lint := inc.linter(dctx.lint).WithMergedConfigFromComments(st.Comments)
 
-		nameMatch, err := dctx.shlex.ProcessWordWithMatches(st.BaseName, dctx.globalArgs)
-		argKeys := unusedFromArgsCheckKeys(dctx.globalArgs, dctx.outline.allArgs)
+		templateArgs, err := expandTemplateArgs(st, dctx.shlex, dctx.globalArgs)
+		if err != nil {
+			return parser.WithLocation(err, st.Location)
+		}
+		globalArgs, _ := withTemplateArgs(templateArgs, dctx.globalArgs, nil)
+
+		nameMatch, err := dctx.shlex.ProcessWordWithMatches(st.BaseName, globalArgs)
+		argKeys := unusedFromArgsCheckKeys(globalArgs, dctx.outline.allArgs)
 		reportUnusedFromArgs(argKeys, nameMatch.Unmatched, st.Location, lint)
 		used := nameMatch.Matched
 		if used == nil {
@@ -423,13 +533,15 @@ func (dctx *dispatchContext) buildDispatchStates(stages []instructions.Stage) er
 			prefixPlatform: dctx.opt.MultiPlatformRequested,
 			outline:        dctx.outline.clone(),
 			epoch:          dctx.epoch,
+			include:        inc,
+			templateArgs:   templateArgs,
 		}
 
 		if v := st.Platform; v != "" {
-			platMatch, err := dctx.shlex.ProcessWordWithMatches(v, dctx.globalArgs)
-			argKeys := unusedFromArgsCheckKeys(dctx.globalArgs, dctx.outline.allArgs)
+			platMatch, err := dctx.shlex.ProcessWordWithMatches(v, globalArgs)
+			argKeys := unusedFromArgsCheckKeys(globalArgs, dctx.outline.allArgs)
 			reportUnusedFromArgs(argKeys, platMatch.Unmatched, st.Location, lint)
-			reportRedundantTargetPlatform(st.Platform, platMatch, st.Location, dctx.globalArgs, lint)
+			reportRedundantTargetPlatform(st.Platform, platMatch, st.Location, globalArgs, lint)
 			reportConstPlatformDisallowed(st.Name, platMatch, st.Location, lint)
 
 			if err != nil {
@@ -439,14 +551,14 @@ func (dctx *dispatchContext) buildDispatchStates(stages []instructions.Stage) er
 			if platMatch.Result == "" {
 				err := errors.Errorf("empty platform value from expression %s", v)
 				err = parser.WithLocation(err, st.Location)
-				err = wrapSuggestAny(err, platMatch.Unmatched, dctx.globalArgs.Keys())
+				err = wrapSuggestAny(err, platMatch.Unmatched, globalArgs.Keys())
 				return err
 			}
 
 			p, err := platforms.Parse(platMatch.Result)
 			if err != nil {
 				err = parser.WithLocation(err, st.Location)
-				err = wrapSuggestAny(err, platMatch.Unmatched, dctx.globalArgs.Keys())
+				err = wrapSuggestAny(err, platMatch.Unmatched, globalArgs.Keys())
 				return parser.WithLocation(errors.Wrapf(err, "failed to parse platform %s", v), st.Location)
 			}
 
@@ -475,7 +587,7 @@ func (dctx *dispatchContext) buildDispatchStates(stages []instructions.Stage) er
 		}
 
 		if st.Name == "" {
-			ds.stageName = fmt.Sprintf("stage-%d", i)
+			ds.stageName = fmt.Sprintf("stage-%d", i-dctx.includedStages)
 		}
 
 		dctx.allDispatchStates.addState(ds)
@@ -500,6 +612,10 @@ func (dctx *dispatchContext) buildDispatchStates(stages []instructions.Stage) er
 		if dctx.opt.Client != nil {
 			ds.ignoreCache = dctx.opt.Client.IsNoCache(st.Name)
 		}
+
+		if err := dctx.addOverlayOutputs(ds, stages); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -518,15 +634,30 @@ func (dctx *dispatchContext) resolveTarget() (*dispatchState, error) {
 func (dctx *dispatchContext) buildStageDependencyGraph() error {
 	for _, d := range dctx.allDispatchStates.states {
 		d.commands = make([]command, len(d.stage.Commands))
+		var blocks int
 		for i, cmd := range d.stage.Commands {
+			switch cmd.(type) {
+			case *instructions.IfCommand:
+				blocks++
+			case *instructions.EndIfCommand:
+				blocks--
+			}
 			newCmd, err := toCommand(cmd, dctx.allDispatchStates, dctx.shlex)
 			if err != nil {
-				return err
+				return d.include.wrapError(err)
 			}
+			newCmd.conditional = blocks > 0
 			d.commands[i] = newCmd
 			for _, src := range newCmd.sources {
 				if src != nil {
-					d.deps[src] = cmd
+					if blocks > 0 {
+						if d.conditionalDeps == nil {
+							d.conditionalDeps = make(map[*dispatchState]instructions.Command)
+						}
+						d.conditionalDeps[src] = cmd
+					} else {
+						d.deps[src] = cmd
+					}
 					if src.unregistered {
 						dctx.allDispatchStates.addState(src)
 					}
@@ -534,6 +665,13 @@ func (dctx *dispatchContext) buildStageDependencyGraph() error {
 			}
 		}
 	}
+	for _, d := range dctx.allDispatchStates.states {
+		for src := range d.conditionalDeps {
+			for s := range allReachableStages(src) {
+				s.conditional = true
+			}
+		}
+	}
 
 	if err := validateCircularDependency(dctx.allDispatchStates.states); err != nil {
 		return err
@@ -545,7 +683,9 @@ func (dctx *dispatchContext) buildStageDependencyGraph() error {
 	return nil
 }
 
-func (dctx *dispatchContext) resolveStages(ctx context.Context, target *dispatchState) (map[*dispatchState]struct{}, error) {
+// resolveStages resolves the stages the target depends on. The stages in
+// done have already been resolved and initialized.
+func (dctx *dispatchContext) resolveStages(ctx context.Context, target *dispatchState, done map[*dispatchState]struct{}) (map[*dispatchState]struct{}, error) {
 	var allReachable map[*dispatchState]struct{}
 	for {
 		var err error
@@ -557,6 +697,9 @@ func (dctx *dispatchContext) resolveStages(ctx context.Context, target *dispatch
 		// initialize onbuild triggers in case they create new dependencies
 		newDeps := false
 		for d := range allReachable {
+			if _, ok := done[d]; ok {
+				continue
+			}
 			d.init()
 
 			onbuilds := slices.Clone(d.image.Config.OnBuild)
@@ -571,7 +714,7 @@ func (dctx *dispatchContext) resolveStages(ctx context.Context, target *dispatch
 
 			if len(onbuilds) > 0 {
 				if b, err := initOnBuildTriggers(d, onbuilds, dctx.allDispatchStates, dctx.shlex); err != nil {
-					return nil, parser.SetLocation(err, d.stage.Location)
+					return nil, d.include.wrapError(parser.SetLocation(err, d.stage.Location))
 				} else if b {
 					newDeps = true
 				}
@@ -590,9 +733,13 @@ func (dctx *dispatchContext) resolveReachableStages(ctx context.Context, all []*
 	eg, ctx := errgroup.WithContext(ctx)
 	for _, d := range all {
 		_, reachable := allReachable[d]
-		if dctx.opt.AllStages {
+		if dctx.opt.AllStages && !(d.unregistered && d.conditional) {
 			reachable = true
 		}
+		if !reachable && d.conditional {
+			// resolved when the command that needs it is dispatched
+			continue
+		}
 		if d.base == nil && !d.dispatched && !d.resolved {
 			d.resolved = reachable // avoid re-resolving if called again after onbuild
 			if d.stage.BaseName == emptyImageName && d.namedContext == nil {
@@ -619,13 +766,18 @@ func (dctx *dispatchContext) resolveReachableStages(ctx context.Context, all []*
 func (dctx *dispatchContext) resolveBaseImage(ctx context.Context, d *dispatchState, reachable bool) (err error) {
 	defer func() {
 		if err != nil {
-			err = parser.WithLocation(err, d.stage.Location)
+			err = d.include.wrapError(parser.WithLocation(err, d.stage.Location))
 		}
 		if d.unregistered {
 			d.dispatched = true
 		}
 	}()
 	origName := d.stage.BaseName
+	if d.origBaseName != "" {
+		// resolved again after it became reachable
+		origName = d.origBaseName
+	}
+	d.origBaseName = origName
 	ref, err := reference.ParseNormalizedNamed(d.stage.BaseName)
 	if err != nil {
 		return errors.Wrapf(err, "failed to parse stage name %q", d.stage.BaseName)
@@ -685,6 +837,7 @@ func (dctx *dispatchContext) resolveBaseImage(ctx context.Context, d *dispatchSt
 			d.image = *img
 			d.state = st.Platform(*platform)
 			d.platform = platform
+			d.baseContext = true
 			return nil
 		}
 
@@ -750,7 +903,9 @@ func (dctx *dispatchContext) resolveBaseImage(ctx context.Context, d *dispatchSt
 			location(dctx.opt.SourceMap, d.stage.Location),
 		)
 		if reachable {
-			validateBaseImagePlatform(origName, *platform, d.image.Platform, d.stage.Location, dctx.lint)
+			lint := d.include.linter(dctx.lint)
+			validateBaseImagePlatform(origName, *platform, d.image.Platform, d.stage.Location, lint)
+			validateBaseImagePinned(origName, d.stage.Location, lint)
 		}
 	}
 	d.platform = platform
@@ -775,11 +930,53 @@ func (dctx *dispatchContext) dispatchStages(ctx context.Context, allReachable ma
 		}
 	}
 
-	for _, d := range dctx.allDispatchStates.states {
-		if !dctx.opt.AllStages {
-			if _, ok := allReachable[d]; !ok || d.dispatched {
+	var dispatchStage func(d *dispatchState) error
+	// dispatchSources dispatches the stages that a command depends on that
+	// have not been dispatched yet. These are the stages used by commands
+	// inside IF blocks, that are not resolved before the condition is
+	// evaluated, and the stages that create overlay mount outputs.
+	dispatchSources := func(d *dispatchState, cmd command) error {
+		for _, src := range cmd.sources {
+			if src == nil {
 				continue
 			}
+			if _, ok := d.deps[src]; !ok {
+				d.deps[src] = cmd.Command
+			}
+			st := src
+			if src.producer != nil {
+				st = src.producer
+			}
+			if _, ok := allReachable[st]; !ok && !st.dispatched {
+				reachable, err := dctx.resolveStages(ctx, st, allReachable)
+				if err != nil {
+					return err
+				}
+				maps.Copy(allReachable, reachable)
+				for _, s := range dctx.allDispatchStates.states {
+					if _, ok := reachable[s]; ok && !s.dispatched {
+						if err := dispatchStage(s); err != nil {
+							return err
+						}
+					}
+				}
+			} else if src.producer != nil && !st.dispatched {
+				if err := dispatchStage(st); err != nil {
+					return err
+				}
+			}
+			if err := checkOverlayOutput(src); err != nil {
+				return d.include.wrapError(parser.WithLocation(err, cmd.Location()))
+			}
+		}
+		return nil
+	}
+
+	dispatchStage = func(d *dispatchState) error {
+		if d.base != nil {
+			if err := checkOverlayOutput(d.base); err != nil {
+				return d.include.wrapError(parser.WithLocation(err, d.stage.Location))
+			}
 		}
 		d.init()
 		d.dispatched = true
@@ -811,21 +1008,27 @@ func (dctx *dispatchContext) dispatchStages(ctx context.Context, allReachable ma
 		}
 		if d.image.Config.WorkingDir != "" {
 			if err := dispatchWorkdir(d, &instructions.WorkdirCommand{Path: d.image.Config.WorkingDir}, false, nil); err != nil {
-				return nil, nil, parser.WithLocation(err, d.stage.Location)
+				return d.include.wrapError(parser.WithLocation(err, d.stage.Location))
 			}
 		}
 		if d.image.Config.User != "" {
 			if err := dispatchUser(d, &instructions.UserCommand{User: d.image.Config.User}, false); err != nil {
-				return nil, nil, parser.WithLocation(err, d.stage.Location)
+				return d.include.wrapError(parser.WithLocation(err, d.stage.Location))
 			}
 		}
 
 		d.state = d.state.Network(dctx.opt.NetworkMode)
 
+		globalArgs, buildArgValues := withTemplateArgs(d.templateArgs, dctx.globalArgs, dctx.opt.BuildArgs)
+		provenance, err := templateProvenance(d.stageName, d.stage, d.templateArgs)
+		if err != nil {
+			return d.include.wrapError(parser.WithLocation(err, d.stage.Location))
+		}
+
 		dopt := dispatchOpt{
 			allDispatchStates:   dctx.allDispatchStates,
-			globalArgs:          dctx.globalArgs,
-			buildArgValues:      dctx.opt.BuildArgs,
+			globalArgs:          globalArgs,
+			buildArgValues:      buildArgValues,
 			shlex:               dctx.shlex,
 			buildContext:        llb.NewState(buildContext),
 			proxyEnv:            dctx.proxyEnv,
@@ -839,14 +1042,26 @@ func (dctx *dispatchContext) dispatchStages(ctx context.Context, allReachable ma
 			cgroupParent:        dctx.opt.CgroupParent,
 			linuxResources:      dctx.opt.LinuxResources,
 			llbCaps:             dctx.opt.LLBCaps,
-			sourceMap:           dctx.opt.SourceMap,
-			lint:                dctx.lint,
+			sourceMap:           d.include.sourceMap(dctx.opt.SourceMap),
+			provenance:          provenance,
+			lint:                d.include.linter(dctx.lint),
 			dockerIgnoreMatcher: dockerIgnoreMatcher,
 		}
 
+		var blocks conditionalBlocks
 		for _, cmd := range d.commands {
+			ok, err := blocks.enter(d, cmd, dopt)
+			if err != nil {
+				return d.include.wrapError(parser.WithLocation(err, cmd.Location()))
+			}
+			if !ok {
+				continue
+			}
+			if err := dispatchSources(d, cmd); err != nil {
+				return err
+			}
 			if err := dispatch(d, cmd, dopt); err != nil {
-				return nil, nil, parser.WithLocation(err, cmd.Location())
+				return d.include.wrapError(parser.WithLocation(err, cmd.Location()))
 			}
 		}
 		d.opt = dopt
@@ -873,6 +1088,21 @@ func (dctx *dispatchContext) dispatchStages(ctx context.Context, allReachable ma
 				}
 			}
 		}
+		return nil
+	}
+
+	for _, d := range dctx.allDispatchStates.states {
+		if !dctx.opt.AllStages {
+			if _, ok := allReachable[d]; !ok || d.dispatched {
+				continue
+			}
+		} else if d.unregistered && d.conditional {
+			// images are only resolved for the branches that are dispatched
+			continue
+		}
+		if err := dispatchStage(d); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// Ensure the entirety of the target state is marked as used.
@@ -889,6 +1119,8 @@ func (dctx *dispatchContext) finalizeResultImage(ctx context.Context, target *di
 	}
 	maps.Copy(target.image.Config.Labels, dctx.opt.Labels)
 
+	validateFinalStageUser(target, target.include.linter(dctx.lint))
+
 	// If lint.Error() returns an error, it means that
 	// there were warnings, and that our linter has been
 	// configured to return an error on warnings,
@@ -996,6 +1228,7 @@ type dispatchOpt struct {
 	linuxResources      *pb.LinuxResources
 	llbCaps             *apicaps.CapSet
 	sourceMap           *llb.SourceMap
+	provenance          llb.ConstraintsOpt
 	lint                *linter.Linter
 	dockerIgnoreMatcher *patternmatcher.PatternMatcher
 }
@@ -1070,6 +1303,8 @@ func dispatch(d *dispatchState, cmd command, opt dispatchOpt) error {
 	case *instructions.WorkdirCommand:
 		err = dispatchWorkdir(d, c, true, &opt)
 	case *instructions.AddCommand:
+		validateAddChecksum(c, opt.lint)
+		validateCopyChmod(c.Chmod, c.Location(), opt.lint)
 		err = dispatchCopy(d, copyConfig{
 			params:          c.SourcesAndDest,
 			excludePatterns: c.ExcludePatterns,
@@ -1115,6 +1350,8 @@ func dispatch(d *dispatchState, cmd command, opt dispatchOpt) error {
 		err = dispatchShell(d, c)
 	case *instructions.ArgCommand:
 		err = dispatchArg(d, c, &opt)
+	case *instructions.ExportCommand:
+		err = dispatchExport(d, c, &opt)
 	case *instructions.CopyCommand:
 		l := opt.buildContext
 		var ignoreMatcher *patternmatcher.PatternMatcher
@@ -1127,6 +1364,7 @@ func dispatch(d *dispatchState, cmd command, opt dispatchOpt) error {
 		} else {
 			ignoreMatcher = opt.dockerIgnoreMatcher
 		}
+		validateCopyChmod(c.Chmod, c.Location(), opt.lint)
 		err = dispatchCopy(d, copyConfig{
 			params:          c.SourcesAndDest,
 			excludePatterns: c.ExcludePatterns,
@@ -1174,8 +1412,17 @@ type dispatchState struct {
 	resolved     bool // resolved is set to true if base image has been resolved
 	onBuildInit  bool
 	deps         map[*dispatchState]instructions.Command
-	buildArgs    []instructions.KeyValuePairOptional
-	commands     []command
+	// conditionalDeps are the dependencies of the commands inside IF blocks.
+	// They are added to deps when the command is dispatched.
+	conditionalDeps map[*dispatchState]instructions.Command
+	// conditional is set if the stage is only needed by commands inside IF
+	// blocks. It is resolved when such a command is dispatched.
+	conditional bool
+	// producer is the stage with the RUN instruction that creates the state,
+	// if it is a named output of an overlay mount.
+	producer  *dispatchState
+	buildArgs []instructions.KeyValuePairOptional
+	commands  []command
 	// ctxPaths marks the paths this dispatchState uses from the build context.
 	ctxPaths map[string]struct{}
 	// paths marks the paths that are used by this dispatchState.
@@ -1198,6 +1445,22 @@ type dispatchState struct {
 	entrypoint  instructionTracker
 	cmd         instructionTracker
 	healthcheck instructionTracker
+
+	// include is the included Dockerfile that defines the stage, or nil for
+	// the stages of the main Dockerfile.
+	include *includedDockerfile
+	// includes are all included Dockerfiles. Only set for the target stage.
+	includes []*includedDockerfile
+	// templateArgs are the expanded args of the stage template the stage is
+	// instantiated from.
+	templateArgs instructions.KeyValuePairs
+	// artifacts are the paths of the stage marked with EXPORT.
+	artifacts []exportedArtifact
+	// origBaseName is the name of the base image as written in the
+	// Dockerfile, before it was resolved.
+	origBaseName string
+	// baseContext is set if the base image is loaded from a named context.
+	baseContext bool
 }
 
 func (ds *dispatchState) asyncLocalOpts() []llb.LocalOption {
@@ -1277,6 +1540,8 @@ type command struct {
 	instructions.Command
 	sources   []*dispatchState
 	isOnBuild bool
+	// conditional is set for the commands inside IF blocks
+	conditional bool
 }
 
 // initOnBuildTriggers initializes the onbuild triggers and creates the commands and dependecies for them.
@@ -1331,7 +1596,7 @@ func dispatchEnv(d *dispatchState, c *instructions.EnvCommand, lint *linter.Lint
 	for _, e := range c.Env {
 		if e.NoDelim {
 			msg := linter.RuleLegacyKeyValueFormat.Format(c.Name())
-			lint.Run(&linter.RuleLegacyKeyValueFormat, c.Location(), msg)
+			lint.RunWithFix(&linter.RuleLegacyKeyValueFormat, c.Location(), linter.FixLegacyKeyValue(c.Location()), msg)
 		}
 		validateNoSecretKey("ENV", e.Key, c.Location(), lint)
 		commitMessage.WriteString(" " + e.String())
@@ -1349,6 +1614,8 @@ func dispatchRun(d *dispatchState, c *instructions.RunCommand, proxy *llb.ProxyE
 	// Run command can potentially access any file. Mark the full filesystem as used.
 	d.paths["/"] = struct{}{}
 
+	validateRunSecurity(c, dopt.lint)
+
 	args := c.CmdLine
 	if len(c.Files) > 0 {
 		if len(args) != 1 || !c.PrependShell {
@@ -1408,7 +1675,7 @@ func dispatchRun(d *dispatchState, c *instructions.RunCommand, proxy *llb.ProxyE
 		args = withShell(d.image, args)
 	}
 
-	opt = append(opt, llb.Args(args), dfCmd(c), location(dopt.sourceMap, c.Location()))
+	opt = append(opt, llb.Args(args), dfCmd(c), location(dopt.sourceMap, c.Location()), dopt.provenance)
 	if d.ignoreCache {
 		opt = append(opt, llb.IgnoreCache)
 	}
@@ -1416,7 +1683,7 @@ func dispatchRun(d *dispatchState, c *instructions.RunCommand, proxy *llb.ProxyE
 		opt = append(opt, llb.WithProxy(*proxy))
 	}
 
-	runMounts, err := dispatchRunMounts(d, c, sources, dopt)
+	runMounts, overlayOutputs, err := dispatchRunMounts(d, c, sources, dopt)
 	if err != nil {
 		return err
 	}
@@ -1501,7 +1768,9 @@ func dispatchRun(d *dispatchState, c *instructions.RunCommand, proxy *llb.ProxyE
 		}
 	}
 
-	d.state = d.state.Run(opt...).Root()
+	run := d.state.Run(opt...)
+	dispatchOverlayOutputs(d, run, overlayOutputs)
+	d.state = run.Root()
 	return commitToHistory(&d.image, "RUN "+runCommandString(args, d.buildArgs, env), true, &d.state, d.epoch)
 }
 
@@ -1555,6 +1824,7 @@ func dispatchWorkdir(d *dispatchState, c *instructions.WorkdirCommand, commit bo
 			d.state = d.state.File(llb.Mkdir(wd, 0755, mkdirOpt...),
 				llb.WithCustomName(prefixCommand(d, uppercaseCmd(processCmdEnv(opt.shlex, c.String(), env)), d.prefixPlatform, &platform, env)),
 				location(opt.sourceMap, c.Location()),
+				opt.provenance,
 				llb.Platform(*d.platform),
 			)
 			withLayer = true
@@ -1577,7 +1847,7 @@ func dispatchLabel(d *dispatchState, c *instructions.LabelCommand, lint *linter.
 	for _, v := range c.Labels {
 		if v.NoDelim {
 			msg := linter.RuleLegacyKeyValueFormat.Format(c.Name())
-			lint.Run(&linter.RuleLegacyKeyValueFormat, c.Location(), msg)
+			lint.RunWithFix(&linter.RuleLegacyKeyValueFormat, c.Location(), linter.FixLegacyKeyValue(c.Location()), msg)
 		}
 		d.image.Config.Labels[v.Key] = v.Value
 		commitMessage.WriteString(" " + v.String())
@@ -1597,7 +1867,7 @@ func dispatchCmd(d *dispatchState, c *instructions.CmdCommand, lint *linter.Lint
 	if c.PrependShell {
 		if len(d.image.Config.Shell) == 0 {
 			msg := linter.RuleJSONArgsRecommended.Format(c.Name())
-			lint.Run(&linter.RuleJSONArgsRecommended, c.Location(), msg)
+			lint.RunWithFix(&linter.RuleJSONArgsRecommended, c.Location(), linter.FixJSONArgs(c.Location()), msg)
 		}
 		args = withShell(d.image, args)
 	}
@@ -1612,8 +1882,17 @@ func dispatchEntrypoint(d *dispatchState, c *instructions.EntrypointCommand, lin
 	args := c.CmdLine
 	if c.PrependShell {
 		if len(d.image.Config.Shell) == 0 {
+			// the CMD of the stage becomes arguments of an entrypoint in
+			// JSON form, so the fix would change what the image runs
+			var fix linter.FixFunc
+			if !slices.ContainsFunc(d.stage.Commands, func(cmd instructions.Command) bool {
+				_, ok := cmd.(*instructions.CmdCommand)
+				return ok
+			}) {
+				fix = linter.FixJSONArgs(c.Location())
+			}
 			msg := linter.RuleJSONArgsRecommended.Format(c.Name())
-			lint.Run(&linter.RuleJSONArgsRecommended, c.Location(), msg)
+			lint.RunWithFix(&linter.RuleJSONArgsRecommended, c.Location(), fix, msg)
 		}
 		args = withShell(d.image, args)
 	}
@@ -1701,7 +1980,7 @@ func dispatchArg(d *dispatchState, c *instructions.ArgCommand, opt *dispatchOpt)
 			arg.Value = &v
 		}
 
-		ai := argInfo{definition: arg, location: c.Location()}
+		ai := argInfo{definition: arg, location: c.Location(), sourceIndex: d.sourceIndex()}
 
 		if arg.Value != nil {
 			if _, ok := nonEnvArgs[arg.Key]; !ok {
@@ -0,0 +1,11 @@
+//go:build dfconditional
+
+package dockerfile2llb
+
+import (
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+func validateConditionals(_ []instructions.Stage) error {
+	return nil
+}
@@ -322,6 +322,7 @@ func dispatchCopy(d *dispatchState, cfg copyConfig) error {
 	fileOpt := []llb.ConstraintsOpt{
 		llb.WithCustomName(pgName),
 		location(cfg.opt.sourceMap, cfg.location),
+		cfg.opt.provenance,
 	}
 	if d.ignoreCache {
 		fileOpt = append(fileOpt, llb.IgnoreCache)
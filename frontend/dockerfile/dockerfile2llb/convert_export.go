@@ -0,0 +1,30 @@
+//go:build dfexport
+
+package dockerfile2llb
+
+import (
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+)
+
+func dispatchExport(d *dispatchState, c *instructions.ExportCommand, opt *dispatchOpt) error {
+	src, err := pathRelativeToWorkingDir(d.state, c.Source, *d.platform)
+	if err != nil {
+		return err
+	}
+	env := getEnv(d.state)
+	st := llb.Scratch().File(llb.Copy(d.state, src, "/", &llb.CopyInfo{
+		FollowSymlinks:      true,
+		CopyDirContentsOnly: true,
+		AllowWildcard:       true,
+	}),
+		llb.WithCustomName(prefixCommand(d, uppercaseCmd(processCmdEnv(opt.shlex, c.String(), env)), d.prefixPlatform, d.platform, env)),
+		location(opt.sourceMap, c.Location()),
+		opt.provenance,
+	)
+	d.artifacts = append(d.artifacts, exportedArtifact{
+		Artifact: Artifact{Name: c.Artifact, State: st},
+		cmd:      c,
+	})
+	return nil
+}
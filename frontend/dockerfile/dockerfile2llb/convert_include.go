@@ -0,0 +1,104 @@
+//go:build dfinclude
+
+package dockerfile2llb
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/linter"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/pkg/errors"
+)
+
+func parseIncludes(ctx context.Context, includes []instructions.IncludeCommand, opt *ConvertOpt, lint *linter.Linter, escapeToken rune) ([]*includedDockerfile, error) {
+	if len(includes) == 0 {
+		return nil, nil
+	}
+	if opt.Client == nil {
+		return nil, parser.WithLocation(errors.New("INCLUDE requires a build client"), includes[0].Location())
+	}
+
+	out := make([]*includedDockerfile, 0, len(includes))
+	for i, c := range includes {
+		src, err := opt.Client.ReadInclude(ctx, c.Source, "Dockerfile")
+		if err != nil {
+			return nil, parser.WithLocation(err, c.Location())
+		}
+		inc := &includedDockerfile{
+			namespace:   c.Namespace,
+			sourceIndex: i + 1,
+			source:      src,
+		}
+
+		if lint != nil {
+			// warnings are reported for the source of the included Dockerfile
+			l := *lint
+			l.Warn = nil
+			l.WarnWithFix = nil
+			if lint.Warn != nil && opt.IncludeWarn != nil {
+				l.Warn = opt.IncludeWarn(src)
+			}
+			if lint.WarnWithFix != nil && opt.IncludeWarnWithFix != nil {
+				l.WarnWithFix = opt.IncludeWarnWithFix(src)
+			}
+			inc.lint = &l
+		}
+
+		dockerfile, err := parser.Parse(bytes.NewReader(src.Data))
+		if err != nil {
+			return nil, inc.wrapError(err)
+		}
+		if dockerfile.EscapeToken != escapeToken {
+			return nil, parser.WithLocation(errors.Errorf("included Dockerfile %s needs to use the same escape character", c.Source), c.Location())
+		}
+		if inc.lint != nil {
+			inc.lint.Source = linter.NewSource(src.Data, dockerfile)
+		}
+		for _, warning := range dockerfile.Warnings {
+			if warning.URL == linter.RuleNoEmptyContinuation.URL {
+				location := []parser.Range{*warning.Location}
+				msg := linter.RuleNoEmptyContinuation.Format()
+				inc.lint.Run(&linter.RuleNoEmptyContinuation, location, msg)
+			}
+		}
+
+		stages, metaArgs, nested, err := instructions.ParseWithIncludes(dockerfile.AST, inc.lint)
+		if err != nil {
+			return nil, inc.wrapError(err)
+		}
+		if len(nested) > 0 {
+			return nil, inc.wrapError(parser.WithLocation(errors.New("INCLUDE is not supported in included Dockerfiles"), nested[0].Location()))
+		}
+		if len(stages) == 0 {
+			return nil, parser.WithLocation(errors.Errorf("included Dockerfile %s contains no stages", c.Source), c.Location())
+		}
+		if err := validateStageTemplates(stages); err != nil {
+			return nil, inc.wrapError(err)
+		}
+		if err := validateConditionals(stages); err != nil {
+			return nil, inc.wrapError(err)
+		}
+		validateStageNames(stages, inc.lint)
+		validateCommandCasing(stages, inc.lint)
+		// required instructions of the policy are only checked for the target
+		// stage, so they are checked for the included Dockerfile only if the
+		// target is one of its stages
+		target, isTarget := cutNamespace(opt.Target, c.Namespace)
+		inc.lint.CheckPolicy(dockerfile.AST, target, isTarget)
+
+		namespaceStages(stages, c.Namespace)
+		inc.stages = stages
+		inc.metaArgs = metaArgs
+		out = append(out, inc)
+	}
+	return out, nil
+}
+
+// cutNamespace returns the name of a stage without the namespace of the
+// included Dockerfile.
+func cutNamespace(name, namespace string) (string, bool) {
+	return strings.CutPrefix(strings.ToLower(name), namespace+".")
+}
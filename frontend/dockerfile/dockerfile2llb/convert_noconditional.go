@@ -0,0 +1,21 @@
+//go:build !dfconditional
+
+package dockerfile2llb
+
+import (
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/pkg/errors"
+)
+
+func validateConditionals(stages []instructions.Stage) error {
+	for _, st := range stages {
+		for _, cmd := range st.Commands {
+			switch cmd.(type) {
+			case *instructions.IfCommand, *instructions.ElseCommand, *instructions.EndIfCommand:
+				return parser.WithLocation(errors.Errorf("%s is only supported in Dockerfile frontend 1.21-labs or later", cmd.Name()), cmd.Location())
+			}
+		}
+	}
+	return nil
+}
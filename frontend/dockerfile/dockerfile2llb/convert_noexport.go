@@ -0,0 +1,12 @@
+//go:build !dfexport
+
+package dockerfile2llb
+
+import (
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/pkg/errors"
+)
+
+func dispatchExport(_ *dispatchState, _ *instructions.ExportCommand, _ *dispatchOpt) error {
+	return errors.New("EXPORT is only supported in Dockerfile frontend 1.21-labs or later")
+}
@@ -0,0 +1,19 @@
+//go:build !dfinclude
+
+package dockerfile2llb
+
+import (
+	"context"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/linter"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/pkg/errors"
+)
+
+func parseIncludes(_ context.Context, includes []instructions.IncludeCommand, _ *ConvertOpt, _ *linter.Linter, _ rune) ([]*includedDockerfile, error) {
+	if len(includes) > 0 {
+		return nil, parser.WithLocation(errors.New("INCLUDE is only supported in Dockerfile frontend 1.21-labs or later"), includes[0].Location())
+	}
+	return nil, nil
+}
@@ -0,0 +1,12 @@
+//go:build !dfrunoverlay
+
+package dockerfile2llb
+
+import (
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/pkg/errors"
+)
+
+func validateOverlayMount(_ *instructions.Mount) error {
+	return errors.New("overlay mounts are only supported in Dockerfile frontend 1.21-labs or later")
+}
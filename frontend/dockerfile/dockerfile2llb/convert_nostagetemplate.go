@@ -0,0 +1,18 @@
+//go:build !dfstagetemplate
+
+package dockerfile2llb
+
+import (
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/pkg/errors"
+)
+
+func validateStageTemplates(stages []instructions.Stage) error {
+	for _, st := range stages {
+		if st.Template != "" {
+			return parser.WithLocation(errors.New("stage templates are only supported in Dockerfile frontend 1.21-labs or later"), st.Location)
+		}
+	}
+	return nil
+}
@@ -61,8 +61,9 @@ func setCacheUIDGID(m *instructions.Mount, st llb.State) llb.State {
 	return st.File(llb.Mkdir("/cache", mode, llb.WithUIDGID(uid, gid)), llb.WithCustomName("[internal] setting cache mount permissions"))
 }
 
-func dispatchRunMounts(d *dispatchState, c *instructions.RunCommand, sources []*dispatchState, opt dispatchOpt) ([]llb.RunOption, error) {
+func dispatchRunMounts(d *dispatchState, c *instructions.RunCommand, sources []*dispatchState, opt dispatchOpt) ([]llb.RunOption, []overlayOutput, error) {
 	var out []llb.RunOption
+	var outputs []overlayOutput
 	mounts := instructions.GetMounts(c)
 
 	for i, mount := range mounts {
@@ -74,7 +75,7 @@ func dispatchRunMounts(d *dispatchState, c *instructions.RunCommand, sources []*
 			src := sources[i]
 			st = src.state
 			if !src.dispatched {
-				return nil, errors.Errorf("cannot mount from stage %q to %q, stage needs to be defined before current command", mount.From, mount.Target)
+				return nil, nil, errors.Errorf("cannot mount from stage %q to %q, stage needs to be defined before current command", mount.From, mount.Target)
 			}
 		}
 		var mountOpts []llb.MountOption
@@ -87,7 +88,7 @@ func dispatchRunMounts(d *dispatchState, c *instructions.RunCommand, sources []*
 		if mount.Type == instructions.MountTypeSecret {
 			secret, err := dispatchSecret(d, mount, c.Location())
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			out = append(out, secret)
 			continue
@@ -95,14 +96,21 @@ func dispatchRunMounts(d *dispatchState, c *instructions.RunCommand, sources []*
 		if mount.Type == instructions.MountTypeSSH {
 			ssh, err := dispatchSSH(d, mount, c.Location())
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			out = append(out, ssh)
 			continue
 		}
+		var output *dispatchState
+		if mount.Type == instructions.MountTypeOverlay {
+			var err error
+			if output, err = overlayMountOutput(d, mount, opt); err != nil {
+				return nil, nil, err
+			}
+		}
 		if mount.ReadOnly {
 			mountOpts = append(mountOpts, llb.Readonly)
-		} else if mount.Type == instructions.MountTypeBind && opt.llbCaps.Supports(pb.CapExecMountBindReadWriteNoOutput) == nil {
+		} else if (mount.Type == instructions.MountTypeBind || (mount.Type == instructions.MountTypeOverlay && output == nil)) && opt.llbCaps.Supports(pb.CapExecMountBindReadWriteNoOutput) == nil {
 			mountOpts = append(mountOpts, llb.ForceNoOutput)
 		}
 		if mount.Type == instructions.MountTypeCache {
@@ -122,14 +130,20 @@ func dispatchRunMounts(d *dispatchState, c *instructions.RunCommand, sources []*
 		if !system.IsAbsolutePath(filepath.Clean(mount.Target)) {
 			dir, err := d.state.GetDir(context.TODO())
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			target = filepath.Join("/", dir, mount.Target)
 		}
 		if target == "/" {
-			return nil, errors.Errorf("invalid mount target %q", target)
+			return nil, nil, errors.Errorf("invalid mount target %q", target)
 		}
-		if src := path.Join("/", mount.Source); src != "/" {
+		if src := path.Join("/", mount.Source); src != "/" && output != nil {
+			// the source is mounted as the root of its own state, so that the
+			// changes recorded in the output are relative to the mount
+			st = llb.Scratch().File(llb.Copy(st, src, "/", &llb.CopyInfo{
+				CopyDirContentsOnly: true,
+			}), llb.WithCustomNamef("[internal] copying overlay mount source %s", src))
+		} else if src != "/" {
 			mountOpts = append(mountOpts, llb.SourcePath(src))
 		} else if mount.UID != nil || mount.GID != nil || mount.Mode != nil {
 			st = setCacheUIDGID(mount, st)
@@ -137,6 +151,9 @@ func dispatchRunMounts(d *dispatchState, c *instructions.RunCommand, sources []*
 		}
 
 		out = append(out, llb.AddMount(target, st, mountOpts...))
+		if output != nil {
+			outputs = append(outputs, overlayOutput{target: target, lower: st, output: output})
+		}
 
 		if mount.From == "" {
 			d.ctxPaths[path.Join("/", filepath.ToSlash(mount.Source))] = struct{}{}
@@ -145,5 +162,5 @@ func dispatchRunMounts(d *dispatchState, c *instructions.RunCommand, sources []*
 			source.paths[path.Join("/", filepath.ToSlash(mount.Source))] = struct{}{}
 		}
 	}
-	return out, nil
+	return out, outputs, nil
 }
@@ -38,8 +38,9 @@ func dispatchSecret(d *dispatchState, m *instructions.Mount, loc []parser.Range)
 
 	if _, ok := d.outline.secrets[id]; !ok {
 		d.outline.secrets[id] = secretInfo{
-			location: loc,
-			required: m.Required,
+			location:    loc,
+			sourceIndex: d.sourceIndex(),
+			required:    m.Required,
 		}
 	}
 
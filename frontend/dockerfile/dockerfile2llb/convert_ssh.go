@@ -18,8 +18,9 @@ func dispatchSSH(d *dispatchState, m *instructions.Mount, loc []parser.Range) (l
 	}
 	if _, ok := d.outline.ssh[id]; !ok {
 		d.outline.ssh[id] = sshInfo{
-			location: loc,
-			required: m.Required,
+			location:    loc,
+			sourceIndex: d.sourceIndex(),
+			required:    m.Required,
 		}
 	}
 
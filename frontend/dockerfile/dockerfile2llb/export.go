@@ -0,0 +1,41 @@
+package dockerfile2llb
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/pkg/errors"
+)
+
+// Artifact is a path of a stage marked as a named artifact with the EXPORT
+// instruction.
+type Artifact struct {
+	Name  string
+	State llb.State
+}
+
+type exportedArtifact struct {
+	Artifact
+	cmd *instructions.ExportCommand
+}
+
+// collectArtifacts returns the artifacts of the stages sorted by name.
+func collectArtifacts(stages map[*dispatchState]struct{}) ([]Artifact, error) {
+	byName := map[string]Artifact{}
+	for d := range stages {
+		for _, a := range d.artifacts {
+			if _, ok := byName[a.Name]; ok {
+				return nil, d.include.wrapError(parser.WithLocation(errors.Errorf("duplicate EXPORT name %q", a.Name), a.cmd.Location()))
+			}
+			byName[a.Name] = a.Artifact
+		}
+	}
+	artifacts := make([]Artifact, 0, len(byName))
+	for _, name := range slices.Sorted(maps.Keys(byName)) {
+		artifacts = append(artifacts, byName[name])
+	}
+	return artifacts, nil
+}
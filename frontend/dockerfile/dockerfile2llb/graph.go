@@ -0,0 +1,192 @@
+package dockerfile2llb
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/containerd/platforms"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/subrequests/graph"
+)
+
+// Dockerfile2Graph returns the dependency graph of all the stages of the
+// Dockerfile. Base images are resolved but no stage is solved.
+func Dockerfile2Graph(ctx context.Context, dt []byte, opt ConvertOpt) (*graph.Graph, error) {
+	opt.AllStages = true
+	target, err := toDispatchState(ctx, dt, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &graph.Graph{
+		Sources: [][]byte{dt},
+	}
+	for _, inc := range target.includes {
+		g.Sources = append(g.Sources, inc.source.Data)
+	}
+
+	all := target.opt.allDispatchStates
+	states := slices.Clone(all.states)
+	// the named outputs of overlay mounts are only registered by name
+	var outputs []string
+	for name, d := range all.statesByName {
+		if !slices.Contains(states, d) {
+			outputs = append(outputs, name)
+		}
+	}
+	slices.Sort(outputs)
+	for _, name := range outputs {
+		states = append(states, all.statesByName[name])
+	}
+
+	ids := map[string]struct{}{}
+	addStage := func(s graph.Stage) {
+		if _, ok := ids[s.ID]; ok {
+			return
+		}
+		ids[s.ID] = struct{}{}
+		g.Stages = append(g.Stages, s)
+	}
+	addEdge := func(d, src *dispatchState, cmd instructions.Command, conditional bool) {
+		// images and named contexts are only added to the graph if a stage
+		// depends on them
+		if src.unregistered {
+			addStage(src.graphStage(target))
+		}
+		e := graphEdge(d, src, cmd)
+		e.Conditional = conditional
+		g.Edges = append(g.Edges, e)
+	}
+
+	for _, d := range states {
+		if d.unregistered {
+			continue
+		}
+		s := d.graphStage(target)
+		addStage(s)
+
+		if d.base != nil {
+			g.Edges = append(g.Edges, graph.Edge{
+				From:     s.ID,
+				To:       graphID(d.base),
+				Type:     graph.EdgeTypeFrom,
+				Location: s.Location,
+			})
+		} else if d.baseContext {
+			ctxID := "context:" + d.origBaseName
+			addStage(graph.Stage{
+				ID:   ctxID,
+				Name: d.origBaseName,
+				Type: graph.StageTypeContext,
+			})
+			g.Edges = append(g.Edges, graph.Edge{
+				From:     s.ID,
+				To:       ctxID,
+				Type:     graph.EdgeTypeFrom,
+				Location: s.Location,
+			})
+		}
+
+		if len(d.commands) == 0 {
+			for src, cmd := range d.deps {
+				addEdge(d, src, cmd, false)
+			}
+			for src, cmd := range d.conditionalDeps {
+				addEdge(d, src, cmd, true)
+			}
+			continue
+		}
+		// the commands of IF blocks are listed whether their branch is
+		// taken or not, so the graph doesn't depend on the build args
+		for _, cmd := range d.commands {
+			for _, src := range cmd.sources {
+				// mounts without a source, like cache mounts, are mounted
+				// from scratch and don't depend on another stage
+				if src == nil || (src.unregistered && src.stage.BaseName == emptyImageName) {
+					continue
+				}
+				addEdge(d, src, cmd.Command, cmd.conditional)
+			}
+		}
+	}
+	return g, nil
+}
+
+// graphStage returns the node of the state in the graph.
+func (ds *dispatchState) graphStage(target *dispatchState) graph.Stage {
+	s := graph.Stage{
+		ID:       graphID(ds),
+		Name:     ds.stage.Name,
+		Type:     graph.StageTypeStage,
+		Target:   ds == target,
+		Args:     ds.graphArgs(),
+		Location: toSourceLocation(ds.sourceIndex(), ds.stage.Location),
+	}
+	if ds.unregistered {
+		s.Type = graph.StageTypeImage
+	}
+	if ds.namedContext != nil || (ds.unregistered && ds.baseContext) {
+		s.Type = graph.StageTypeContext
+	} else if ds.base == nil && !ds.baseContext {
+		s.Base = ds.stage.BaseName
+	}
+	if ds.platform != nil {
+		s.Platform = platforms.FormatAll(*ds.platform)
+	}
+	return s
+}
+
+// graphID returns the ID of the state in the graph. Images and named contexts
+// that are not the base of a stage are prefixed with their type, as the names
+// of stages can't contain a colon.
+func graphID(d *dispatchState) string {
+	if d.unregistered {
+		name := d.origBaseName
+		if name == "" {
+			name = d.stage.BaseName
+		}
+		if d.baseContext {
+			return "context:" + name
+		}
+		return "image:" + name
+	}
+	if d.stageName != "" {
+		return d.stageName
+	}
+	if d.stage.Name != "" {
+		return d.stage.Name
+	}
+	return "stage-0"
+}
+
+func graphEdge(d, src *dispatchState, cmd instructions.Command) graph.Edge {
+	e := graph.Edge{
+		From:     graphID(d),
+		To:       graphID(src),
+		Location: toSourceLocation(d.sourceIndex(), cmd.Location()),
+	}
+	switch cmd.(type) {
+	case *instructions.CopyCommand:
+		e.Type = graph.EdgeTypeCopy
+	case *instructions.RunCommand:
+		e.Type = graph.EdgeTypeMount
+	default:
+		e.Type = strings.ToLower(cmd.Name())
+	}
+	return e
+}
+
+// graphArgs returns the sorted names of the build args used by the stage.
+func (ds *dispatchState) graphArgs() []string {
+	ds.outline.markAllUsed(ds.outline.usedArgs, map[string]struct{}{})
+
+	var args []string
+	for k := range ds.outline.usedArgs {
+		if a, ok := ds.outline.allArgs[k]; ok {
+			args = append(args, a.definition.Key)
+		}
+	}
+	slices.Sort(args)
+	return slices.Compact(args)
+}
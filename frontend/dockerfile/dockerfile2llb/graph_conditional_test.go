@@ -0,0 +1,69 @@
+//go:build dfconditional
+
+package dockerfile2llb
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/moby/buildkit/frontend/subrequests/graph"
+	"github.com/moby/buildkit/util/appcontext"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerfile2GraphConditional(t *testing.T) {
+	t.Parallel()
+
+	df := `FROM scratch AS arm-tools
+FROM scratch AS amd-tools
+
+FROM scratch AS build
+ARG VARIANT
+IF $TARGETARCH == arm64
+COPY --from=arm-tools /bin /bin
+ELSE
+RUN --mount=from=amd-tools,target=/tools true
+ENDIF
+IF $VARIANT
+COPY --from=alpine /etc/os-release /
+ENDIF
+COPY --from=amd-tools /lib /lib
+`
+	type edge struct {
+		from, to, typ string
+		conditional   bool
+	}
+	for _, arch := range []string{"amd64", "arm64"} {
+		g, err := Dockerfile2Graph(appcontext.Context(), []byte(df), ConvertOpt{
+			TargetPlatform: &ocispecs.Platform{OS: "linux", Architecture: arch},
+		})
+		require.NoError(t, err)
+
+		// the edges of the branches that are not taken are listed
+		var edges []edge
+		for _, e := range g.Edges {
+			edges = append(edges, edge{e.From, e.To, e.Type, e.Conditional})
+		}
+		require.Equal(t, []edge{
+			{"build", "arm-tools", graph.EdgeTypeCopy, true},
+			{"build", "amd-tools", graph.EdgeTypeMount, true},
+			{"build", "image:alpine", graph.EdgeTypeCopy, true},
+			{"build", "amd-tools", graph.EdgeTypeCopy, false},
+		}, edges, arch)
+
+		var ids []string
+		for _, s := range g.Stages {
+			ids = append(ids, s.ID)
+		}
+		require.Equal(t, []string{"arm-tools", "amd-tools", "build", "image:alpine"}, ids)
+
+		dt, err := json.Marshal(g)
+		require.NoError(t, err)
+		b := bytes.NewBuffer(nil)
+		require.NoError(t, graph.PrintDOT(dt, b))
+		require.Contains(t, b.String(), `"build" -> "arm-tools" [label="copy", style=dashed];`)
+		require.Contains(t, b.String(), `"build" -> "amd-tools" [label="copy"];`)
+	}
+}
@@ -0,0 +1,95 @@
+package dockerfile2llb
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/moby/buildkit/frontend/dockerui"
+	"github.com/moby/buildkit/frontend/subrequests/graph"
+	"github.com/moby/buildkit/util/appcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerfile2Graph(t *testing.T) {
+	t.Parallel()
+
+	df := `ARG BASE=scratch
+ARG VERSION
+FROM ${BASE} AS base
+ARG VERSION
+RUN echo $VERSION
+
+FROM scratch AS tools
+
+FROM base AS build
+COPY --from=tools /bin /bin
+RUN --mount=from=tools,target=/tools --mount=type=cache,target=/cache true
+
+FROM scratch
+COPY --from=build /out /
+`
+	g, err := Dockerfile2Graph(appcontext.Context(), []byte(df), ConvertOpt{})
+	require.NoError(t, err)
+
+	require.Len(t, g.Sources, 1)
+	require.Len(t, g.Stages, 4)
+
+	require.Equal(t, "base", g.Stages[0].ID)
+	require.Equal(t, graph.StageTypeStage, g.Stages[0].Type)
+	require.Equal(t, "scratch", g.Stages[0].Base)
+	require.Equal(t, []string{"BASE", "VERSION"}, g.Stages[0].Args)
+	require.False(t, g.Stages[0].Target)
+	require.NotNil(t, g.Stages[0].Location)
+
+	require.Equal(t, "tools", g.Stages[1].ID)
+	require.Empty(t, g.Stages[1].Args)
+
+	require.Equal(t, "build", g.Stages[2].ID)
+	require.Empty(t, g.Stages[2].Base)
+	// args of the FROM of the base stage are inherited
+	require.Equal(t, []string{"BASE"}, g.Stages[2].Args)
+
+	require.Equal(t, "stage-3", g.Stages[3].ID)
+	require.Empty(t, g.Stages[3].Name)
+	require.True(t, g.Stages[3].Target)
+
+	type edge struct{ from, to, typ string }
+	var edges []edge
+	for _, e := range g.Edges {
+		require.NotNil(t, e.Location)
+		edges = append(edges, edge{e.From, e.To, e.Type})
+	}
+	require.Equal(t, []edge{
+		{"build", "base", graph.EdgeTypeFrom},
+		{"build", "tools", graph.EdgeTypeCopy},
+		{"build", "tools", graph.EdgeTypeMount},
+		{"stage-3", "build", graph.EdgeTypeCopy},
+	}, edges)
+
+	dt, err := json.Marshal(g)
+	require.NoError(t, err)
+	b := bytes.NewBuffer(nil)
+	require.NoError(t, graph.PrintDOT(dt, b))
+	require.Contains(t, b.String(), `"build" -> "base" [label="from"];`)
+	require.Contains(t, b.String(), `"stage-3" [label="stage-3\nscratch", style=bold];`)
+}
+
+func TestDockerfile2GraphTarget(t *testing.T) {
+	t.Parallel()
+
+	df := `FROM scratch AS a
+FROM scratch AS b
+COPY --from=a / /
+`
+	g, err := Dockerfile2Graph(appcontext.Context(), []byte(df), ConvertOpt{
+		Config: dockerui.Config{
+			Target: "a",
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, g.Stages, 2)
+	require.True(t, g.Stages[0].Target)
+	require.False(t, g.Stages[1].Target)
+	require.Len(t, g.Edges, 1)
+}
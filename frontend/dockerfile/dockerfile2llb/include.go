@@ -0,0 +1,246 @@
+package dockerfile2llb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/linter"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerui"
+	"github.com/moby/buildkit/solver/errdefs"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/pkg/errors"
+)
+
+// includedDockerfile is a Dockerfile imported with the INCLUDE instruction.
+// The names of its stages are prefixed with the namespace of the instruction.
+type includedDockerfile struct {
+	namespace string
+	// sourceIndex is the index of the Dockerfile in the sources of the
+	// subrequest results. The main Dockerfile has index 0.
+	sourceIndex int
+	source      *dockerui.Source
+	lint        *linter.Linter
+	stages      []instructions.Stage
+	metaArgs    []instructions.ArgCommand
+}
+
+// includeError is an error in an included Dockerfile.
+type includeError struct {
+	error
+	include *includedDockerfile
+}
+
+func (e *includeError) Unwrap() error {
+	return e.error
+}
+
+// linter returns the linter for the instructions of the included Dockerfile,
+// or main if inc is nil.
+func (inc *includedDockerfile) linter(main *linter.Linter) *linter.Linter {
+	if inc == nil {
+		return main
+	}
+	return inc.lint
+}
+
+// sourceMap returns the source of the included Dockerfile, or main if inc is
+// nil.
+func (inc *includedDockerfile) sourceMap(main *llb.SourceMap) *llb.SourceMap {
+	if inc == nil {
+		return main
+	}
+	return inc.source.SourceMap
+}
+
+// wrapError attributes the location of err to the source of the included
+// Dockerfile. Errors without a location are returned unchanged.
+func (inc *includedDockerfile) wrapError(err error) error {
+	if inc == nil || err == nil {
+		return err
+	}
+	var el *parser.LocationError
+	if !errors.As(err, &el) {
+		return err
+	}
+	var ie *includeError
+	if errors.As(err, &ie) {
+		return err
+	}
+	sm := inc.source.SourceMap
+	src := &errdefs.Source{
+		Info: &pb.SourceInfo{
+			Data:       sm.Data,
+			Filename:   sm.Filename,
+			Language:   sm.Language,
+			Definition: sm.Definition.ToPB(),
+		},
+		Ranges: toPBLocation(inc.sourceIndex, mergeLocations(el.Locations...)).Ranges,
+	}
+	return errdefs.WithSource(&includeError{error: err, include: inc}, src)
+}
+
+// namespaceStages prefixes the names of the stages with the namespace and
+// updates the references between the stages. Unnamed stages are named after
+// their index.
+func namespaceStages(stages []instructions.Stage, namespace string) {
+	byIndex := make([]string, len(stages))
+	names := make(map[string]string, len(stages))
+	for i, st := range stages {
+		name := st.Name
+		if name == "" {
+			name = fmt.Sprintf("stage-%d", i)
+		}
+		byIndex[i] = namespace + "." + name
+		if st.Name != "" {
+			names[st.Name] = byIndex[i]
+		}
+	}
+	mapName := func(name string) string {
+		if n, ok := names[strings.ToLower(name)]; ok {
+			return n
+		}
+		return name
+	}
+
+	defined := make(map[string]struct{}, len(stages))
+	for i := range stages {
+		st := &stages[i]
+		// the base can only refer to a previous stage
+		if _, ok := defined[strings.ToLower(st.BaseName)]; ok {
+			st.BaseName = mapName(st.BaseName)
+		}
+		if st.Name != "" {
+			defined[st.Name] = struct{}{}
+		}
+		st.Name = byIndex[i]
+
+		for _, cmd := range st.Commands {
+			switch c := cmd.(type) {
+			case *instructions.CopyCommand:
+				if index, err := strconv.Atoi(c.From); err == nil {
+					if index >= 0 && index < len(byIndex) {
+						c.From = byIndex[index]
+					}
+				} else if c.From != "" {
+					c.From = mapName(c.From)
+				}
+			case *instructions.RunCommand:
+				instructions.SetMountFromMapping(c, mapName)
+			}
+		}
+	}
+}
+
+// mergeIncludedStages returns the stages of the included Dockerfiles followed
+// by the stages of the main Dockerfile, and the included Dockerfile of each
+// stage. Stage indexes in the main Dockerfile are updated to account for the
+// included stages.
+func mergeIncludedStages(stages []instructions.Stage, includes []*includedDockerfile) ([]instructions.Stage, []*includedDockerfile) {
+	var merged []instructions.Stage
+	var stageIncludes []*includedDockerfile
+	for _, inc := range includes {
+		merged = append(merged, inc.stages...)
+		for range inc.stages {
+			stageIncludes = append(stageIncludes, inc)
+		}
+	}
+	if offset := len(merged); offset > 0 {
+		for _, st := range stages {
+			for _, cmd := range st.Commands {
+				if c, ok := cmd.(*instructions.CopyCommand); ok {
+					if index, err := strconv.Atoi(c.From); err == nil {
+						c.From = strconv.Itoa(index + offset)
+					}
+				}
+			}
+		}
+	}
+	merged = append(merged, stages...)
+	stageIncludes = append(stageIncludes, make([]*includedDockerfile, len(stages))...)
+	return merged, stageIncludes
+}
+
+// includedMetaArgs returns the meta args of the included Dockerfiles
+// followed by the meta args of the main Dockerfile. The meta args of all
+// Dockerfiles share a single scope, so an error is returned if included
+// Dockerfiles set different default values for the same meta arg, unless the
+// main Dockerfile sets a default value or a build argument sets its value.
+func includedMetaArgs(argCmds []instructions.ArgCommand, includes []*includedDockerfile, buildArgs map[string]string) ([]instructions.ArgCommand, error) {
+	if len(includes) == 0 {
+		return argCmds, nil
+	}
+	overridden := map[string]struct{}{}
+	for k := range buildArgs {
+		overridden[k] = struct{}{}
+	}
+	for _, cmd := range argCmds {
+		for _, kp := range cmd.Args {
+			if kp.Value != nil {
+				overridden[kp.Key] = struct{}{}
+			}
+		}
+	}
+
+	type metaArgDefault struct {
+		include *includedDockerfile
+		value   string
+	}
+	defaults := map[string]metaArgDefault{}
+	var merged []instructions.ArgCommand
+	for _, inc := range includes {
+		for _, cmd := range inc.metaArgs {
+			for _, kp := range cmd.Args {
+				if _, ok := overridden[kp.Key]; ok || kp.Value == nil {
+					continue
+				}
+				if prev, ok := defaults[kp.Key]; ok && prev.include != inc && prev.value != *kp.Value {
+					err := errors.Errorf("global build argument %s has different default values in the included Dockerfiles %s and %s, set it in the main Dockerfile or with a build argument", kp.Key, prev.include.namespace, inc.namespace)
+					return nil, inc.wrapError(parser.WithLocation(err, cmd.Location()))
+				}
+				defaults[kp.Key] = metaArgDefault{include: inc, value: *kp.Value}
+			}
+		}
+		merged = append(merged, inc.metaArgs...)
+	}
+	return append(merged, argCmds...), nil
+}
+
+// setMetaArgSources sets the source index of the meta args defined in the
+// included Dockerfiles.
+func setMetaArgSources(allArgs map[string]argInfo, argCmds []instructions.ArgCommand, includes []*includedDockerfile) {
+	if len(includes) == 0 {
+		return
+	}
+	sources := map[string]int{}
+	for _, inc := range includes {
+		for _, cmd := range inc.metaArgs {
+			for _, kp := range cmd.Args {
+				sources[kp.Key] = inc.sourceIndex
+			}
+		}
+	}
+	// the main Dockerfile overrides the meta args of the included ones
+	for _, cmd := range argCmds {
+		for _, kp := range cmd.Args {
+			delete(sources, kp.Key)
+		}
+	}
+	for k, idx := range sources {
+		if ai, ok := allArgs[k]; ok {
+			ai.sourceIndex = idx
+			allArgs[k] = ai
+		}
+	}
+}
+
+// sourceIndex returns the index of the Dockerfile that defines the stage.
+func (ds *dispatchState) sourceIndex() int {
+	if ds.include == nil {
+		return 0
+	}
+	return ds.include.sourceIndex
+}
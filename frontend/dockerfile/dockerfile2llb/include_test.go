@@ -0,0 +1,105 @@
+package dockerfile2llb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerui"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceStages(t *testing.T) {
+	t.Parallel()
+
+	parse := func(dockerfile string) []instructions.Stage {
+		ast, err := parser.Parse(strings.NewReader(dockerfile))
+		require.NoError(t, err)
+		stages, _, err := instructions.Parse(ast.AST, nil)
+		require.NoError(t, err)
+		return stages
+	}
+
+	included := parse(`
+FROM alpine AS base
+FROM base AS Builder
+RUN --mount=from=base,target=/base --mount=from=alpine,target=/alpine true
+FROM busybox
+COPY --from=0 /a /a
+COPY --from=builder /b /b
+COPY --from=alpine /c /c
+`)
+	namespaceStages(included, "common")
+	require.Equal(t, "common.base", included[0].Name)
+	require.Equal(t, "alpine", included[0].BaseName)
+	require.Equal(t, "common.builder", included[1].Name)
+	require.Equal(t, "common.base", included[1].BaseName)
+	require.Equal(t, "common.stage-2", included[2].Name)
+
+	mounts := instructions.GetMounts(included[1].Commands[0].(*instructions.RunCommand))
+	require.Equal(t, "common.base", mounts[0].From)
+	require.Equal(t, "alpine", mounts[1].From)
+
+	copyFrom := func(st instructions.Stage, i int) string {
+		return st.Commands[i].(*instructions.CopyCommand).From
+	}
+	require.Equal(t, "common.base", copyFrom(included[2], 0))
+	require.Equal(t, "common.builder", copyFrom(included[2], 1))
+	require.Equal(t, "alpine", copyFrom(included[2], 2))
+
+	main := parse(`
+FROM common.builder
+FROM scratch
+COPY --from=0 /a /a
+`)
+	stages, stageIncludes := mergeIncludedStages(main, []*includedDockerfile{{namespace: "common", stages: included}})
+	require.Len(t, stages, 5)
+	require.Equal(t, "common.stage-2", stages[2].Name)
+	require.Equal(t, "common.builder", stages[3].BaseName)
+	require.Equal(t, "3", copyFrom(stages[4], 0))
+	require.Len(t, stageIncludes, 5)
+	require.NotNil(t, stageIncludes[2])
+	require.Nil(t, stageIncludes[3])
+}
+
+func TestIncludedMetaArgs(t *testing.T) {
+	t.Parallel()
+
+	include := func(namespace, dockerfile string) *includedDockerfile {
+		ast, err := parser.Parse(strings.NewReader(dockerfile))
+		require.NoError(t, err)
+		_, metaArgs, err := instructions.Parse(ast.AST, nil)
+		require.NoError(t, err)
+		sm := llb.NewSourceMap(nil, namespace+".Dockerfile", "Dockerfile", []byte(dockerfile))
+		sm.Definition = &llb.Definition{}
+		return &includedDockerfile{
+			namespace: namespace,
+			source:    &dockerui.Source{SourceMap: sm},
+			metaArgs:  metaArgs,
+		}
+	}
+	a := include("a", "ARG VERSION=1\nARG FOO\nFROM scratch\n")
+	b := include("b", "ARG VERSION=2\nARG FOO=bar\nFROM scratch\n")
+	c := include("c", "ARG VERSION\nARG FOO=bar\nFROM scratch\n")
+
+	args, err := includedMetaArgs(nil, []*includedDockerfile{a, c}, nil)
+	require.NoError(t, err)
+	require.Len(t, args, 4)
+
+	_, err = includedMetaArgs(nil, []*includedDockerfile{a, c, b}, nil)
+	require.ErrorContains(t, err, "global build argument VERSION has different default values in the included Dockerfiles a and b")
+
+	// the main Dockerfile and build arguments set the value for all
+	// included Dockerfiles
+	main := []instructions.ArgCommand{{Args: []instructions.KeyValuePairOptional{{Key: "VERSION"}}}}
+	_, err = includedMetaArgs(main, []*includedDockerfile{a, b}, nil)
+	require.Error(t, err)
+	main[0].Args[0].Value = new("3")
+	args, err = includedMetaArgs(main, []*includedDockerfile{a, b}, nil)
+	require.NoError(t, err)
+	require.Len(t, args, 5)
+	_, err = includedMetaArgs(nil, []*includedDockerfile{a, b}, map[string]string{"VERSION": "3"})
+	require.NoError(t, err)
+}
@@ -18,20 +18,23 @@ type outlineCapture struct {
 }
 
 type argInfo struct {
-	value      string
-	definition instructions.KeyValuePairOptional
-	deps       map[string]struct{}
-	location   []parser.Range
+	value       string
+	definition  instructions.KeyValuePairOptional
+	deps        map[string]struct{}
+	location    []parser.Range
+	sourceIndex int
 }
 
 type secretInfo struct {
-	required bool
-	location []parser.Range
+	required    bool
+	location    []parser.Range
+	sourceIndex int
 }
 
 type sshInfo struct {
-	required bool
-	location []parser.Range
+	required    bool
+	location    []parser.Range
+	sourceIndex int
 }
 
 func newOutlineCapture() outlineCapture {
@@ -76,7 +79,7 @@ func (ds *dispatchState) args(visited map[string]struct{}) []outline.Arg {
 					Name:        a.definition.Key,
 					Value:       a.value,
 					Description: a.definition.DocComment,
-					Location:    toSourceLocation(a.location),
+					Location:    toSourceLocation(a.sourceIndex, a.location),
 				})
 				visited[k] = struct{}{}
 			}
@@ -100,7 +103,7 @@ func (ds *dispatchState) secrets(visited map[string]struct{}) []outline.Secret {
 			secrets = append(secrets, outline.Secret{
 				Name:     k,
 				Required: v.required,
-				Location: toSourceLocation(v.location),
+				Location: toSourceLocation(v.sourceIndex, v.location),
 			})
 			visited[k] = struct{}{}
 		}
@@ -121,7 +124,7 @@ func (ds *dispatchState) ssh(visited map[string]struct{}) []outline.SSH {
 			ssh = append(ssh, outline.SSH{
 				Name:     k,
 				Required: v.required,
-				Location: toSourceLocation(v.location),
+				Location: toSourceLocation(v.sourceIndex, v.location),
 			})
 			visited[k] = struct{}{}
 		}
@@ -151,10 +154,15 @@ func (ds *dispatchState) Outline(dt []byte) outline.Outline {
 		return compLocation(ssh[i].Location, ssh[j].Location)
 	})
 
+	sources := [][]byte{dt}
+	for _, inc := range ds.includes {
+		sources = append(sources, inc.source.Data)
+	}
+
 	out := outline.Outline{
 		Name:        ds.stage.Name,
 		Description: ds.stage.DocComment,
-		Sources:     [][]byte{dt},
+		Sources:     sources,
 		Args:        args,
 		Secrets:     secrets,
 		SSH:         ssh,
@@ -163,7 +171,7 @@ func (ds *dispatchState) Outline(dt []byte) outline.Outline {
 	return out
 }
 
-func toSourceLocation(r []parser.Range) *pb.Location {
+func toSourceLocation(sourceIndex int, r []parser.Range) *pb.Location {
 	if len(r) == 0 {
 		return nil
 	}
@@ -180,7 +188,7 @@ func toSourceLocation(r []parser.Range) *pb.Location {
 			},
 		}
 	}
-	return &pb.Location{Ranges: arr}
+	return &pb.Location{SourceIndex: int32(sourceIndex), Ranges: arr}
 }
 
 func compLocation(a, b *pb.Location) bool {
@@ -0,0 +1,94 @@
+package dockerfile2llb
+
+import (
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/pkg/errors"
+)
+
+// overlayOutput is an overlay mount of a RUN instruction with a named output.
+type overlayOutput struct {
+	target string
+	lower  llb.State
+	output *dispatchState
+}
+
+// addOverlayOutputs registers a state for each named output of the overlay
+// mounts of the stage. The states are not part of the list of stages, so they
+// don't change the stage indexes, and are dispatched with the RUN instruction
+// that creates them.
+func (dctx *dispatchContext) addOverlayOutputs(ds *dispatchState, stages []instructions.Stage) error {
+	for _, cmd := range ds.stage.Commands {
+		c, ok := cmd.(*instructions.RunCommand)
+		if !ok {
+			continue
+		}
+		for _, m := range instructions.GetMounts(c) {
+			if m.Output == "" {
+				continue
+			}
+			if _, ok := instructions.HasStage(stages, m.Output); ok {
+				return parser.WithLocation(errors.Errorf("overlay mount output %q conflicts with the name of a stage", m.Output), c.Location())
+			}
+			if _, ok := dctx.allDispatchStates.findStateByName(m.Output); ok {
+				return parser.WithLocation(errors.Errorf("duplicate overlay mount output %q", m.Output), c.Location())
+			}
+			dctx.allDispatchStates.statesByName[m.Output] = &dispatchState{
+				stage: instructions.Stage{
+					Name:     m.Output,
+					BaseName: emptyImageName,
+					Location: c.Location(),
+				},
+				deps:      map[*dispatchState]instructions.Command{ds: c},
+				producer:  ds,
+				ctxPaths:  make(map[string]struct{}),
+				paths:     make(map[string]struct{}),
+				stageName: m.Output,
+				outline:   ds.outline.clone(),
+				epoch:     ds.epoch,
+				include:   ds.include,
+			}
+		}
+	}
+	return nil
+}
+
+// dispatchOverlayOutputs sets the states of the named outputs of the overlay
+// mounts of a RUN instruction to the changes made to the mounts.
+func dispatchOverlayOutputs(d *dispatchState, run llb.ExecState, outputs []overlayOutput) {
+	for _, o := range outputs {
+		out := o.output
+		out.state = llb.Diff(o.lower, run.GetMount(o.target))
+		out.platform = d.platform
+		out.image = emptyImage(*d.platform)
+		out.dispatched = true
+	}
+}
+
+// checkOverlayOutput returns an error if the state is a named output of an
+// overlay mount that has not been created yet.
+func checkOverlayOutput(out *dispatchState) error {
+	if out.producer == nil || out.dispatched {
+		return nil
+	}
+	return errors.Errorf("overlay mount output %q is not available, the RUN instruction of stage %q that creates it is skipped or runs later", out.stageName, out.producer.stageName)
+}
+
+func overlayMountOutput(d *dispatchState, mount *instructions.Mount, opt dispatchOpt) (*dispatchState, error) {
+	if err := validateOverlayMount(mount); err != nil {
+		return nil, err
+	}
+	if mount.Output == "" {
+		return nil, nil
+	}
+	if opt.llbCaps != nil && opt.llbCaps.Supports(pb.CapDiffOp) != nil {
+		return nil, errors.New("overlay mount outputs are not supported by the BuildKit version, diff operations are required")
+	}
+	out, ok := opt.allDispatchStates.findStateByName(mount.Output)
+	if !ok || out.deps[d] == nil {
+		return nil, errors.Errorf("overlay mount output %q not found", mount.Output)
+	}
+	return out, nil
+}
@@ -0,0 +1,35 @@
+package dockerfile2llb
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/util/appcontext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayMountOutputNames(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		dockerfile    string
+		expectedError string
+	}{
+		{
+			dockerfile: `FROM scratch AS src
+FROM scratch AS gen
+RUN --mount=type=overlay,from=src,target=/src,output=gen true
+`,
+			expectedError: `overlay mount output "gen" conflicts with the name of a stage`,
+		},
+		{
+			dockerfile: `FROM scratch
+RUN --mount=type=overlay,target=/a,output=gen true
+RUN --mount=type=overlay,target=/b,output=gen true
+`,
+			expectedError: `duplicate overlay mount output "gen"`,
+		},
+	} {
+		_, err := Dockerfile2LLB(appcontext.Context(), []byte(tc.dockerfile), ConvertOpt{})
+		require.ErrorContains(t, err, tc.expectedError)
+	}
+}
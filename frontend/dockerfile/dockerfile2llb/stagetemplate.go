@@ -0,0 +1,87 @@
+package dockerfile2llb
+
+import (
+	"encoding/json"
+	"maps"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	"github.com/pkg/errors"
+)
+
+// expandTemplateArgs expands the values of the args a stage template is
+// instantiated with using the global args.
+func expandTemplateArgs(st instructions.Stage, shlex *shell.Lex, globalArgs *llb.EnvList) (instructions.KeyValuePairs, error) {
+	if len(st.TemplateArgs) == 0 {
+		return nil, nil
+	}
+	args := make(instructions.KeyValuePairs, len(st.TemplateArgs))
+	for i, kv := range st.TemplateArgs {
+		v, _, err := shlex.ProcessWord(kv.Value, globalArgs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to process arg %s for stage template %s", kv.Key, st.Template)
+		}
+		args[i] = instructions.KeyValuePair{Key: kv.Key, Value: v}
+	}
+	return args, nil
+}
+
+// withTemplateArgs returns the global args and the build arg values for a
+// stage instantiated from a template. The args of the template override the
+// global args that are defined and the build args.
+func withTemplateArgs(args instructions.KeyValuePairs, globalArgs *llb.EnvList, buildArgs map[string]string) (*llb.EnvList, map[string]string) {
+	if len(args) == 0 {
+		return globalArgs, buildArgs
+	}
+	buildArgs = maps.Clone(buildArgs)
+	if buildArgs == nil {
+		buildArgs = map[string]string{}
+	}
+	for _, kv := range args {
+		if _, ok := globalArgs.Get(kv.Key); ok {
+			globalArgs = globalArgs.AddOrReplace(kv.Key, kv.Value)
+		}
+		buildArgs[kv.Key] = kv.Value
+	}
+	return globalArgs, buildArgs
+}
+
+// templateArgsMap returns the args a stage template is instantiated with as a
+// map, or nil if there are none.
+func templateArgsMap(args instructions.KeyValuePairs) map[string]string {
+	if len(args) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(args))
+	for _, kv := range args {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
+
+// templateInstance is the provenance metadata of a stage template instance.
+type templateInstance struct {
+	Template string            `json:"template"`
+	Args     map[string]string `json:"args,omitempty"`
+}
+
+// templateProvenance returns the constraint that records the stage template
+// and the args a stage is instantiated with for its build steps in the
+// provenance attestation. The key includes the name of the instance, so a
+// step that several instances share records all of them.
+func templateProvenance(name string, st instructions.Stage, args instructions.KeyValuePairs) (llb.ConstraintsOpt, error) {
+	if st.Template == "" {
+		return llb.WithProvenanceMetadata(nil), nil
+	}
+	dt, err := json.Marshal(templateInstance{
+		Template: st.Template,
+		Args:     templateArgsMap(args),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal args for stage template %s", st.Template)
+	}
+	return llb.WithProvenanceMetadata(map[string]string{
+		"com.docker.dockerfile.v1.template.instance." + name: string(dt),
+	}), nil
+}
@@ -0,0 +1,98 @@
+package dockerfile2llb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+	"github.com/moby/buildkit/solver/pb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTemplateArgs(t *testing.T) {
+	t.Parallel()
+
+	globalArgs := (&llb.EnvList{}).AddOrReplace("GO_VERSION", "1.24").AddOrReplace("VARIANT", "default")
+	buildArgs := map[string]string{"VARIANT": "cli", "OTHER": "1"}
+
+	st := instructions.Stage{
+		Template: "builder",
+		TemplateArgs: instructions.KeyValuePairs{
+			{Key: "VARIANT", Value: "foo-${GO_VERSION}"},
+			{Key: "EXTRA", Value: "bar"},
+		},
+	}
+	args, err := expandTemplateArgs(st, shell.NewLex('\\'), globalArgs)
+	require.NoError(t, err)
+	require.Equal(t, instructions.KeyValuePairs{{Key: "VARIANT", Value: "foo-1.24"}, {Key: "EXTRA", Value: "bar"}}, args)
+
+	stageArgs, stageBuildArgs := withTemplateArgs(args, globalArgs, buildArgs)
+	v, _ := stageArgs.Get("VARIANT")
+	require.Equal(t, "foo-1.24", v)
+	_, ok := stageArgs.Get("EXTRA")
+	require.False(t, ok, "args that are not global are not added to the global args")
+	require.Equal(t, map[string]string{"VARIANT": "foo-1.24", "EXTRA": "bar", "OTHER": "1"}, stageBuildArgs)
+
+	// the global args and the build args of other stages are unchanged
+	v, _ = globalArgs.Get("VARIANT")
+	require.Equal(t, "default", v)
+	require.Equal(t, "cli", buildArgs["VARIANT"])
+
+	stageArgs, stageBuildArgs = withTemplateArgs(nil, globalArgs, buildArgs)
+	require.Same(t, globalArgs, stageArgs)
+	require.Equal(t, buildArgs, stageBuildArgs)
+}
+
+func TestTemplateProvenance(t *testing.T) {
+	t.Parallel()
+
+	provenance := func(name string, st instructions.Stage, args instructions.KeyValuePairs) llb.ConstraintsOpt {
+		opt, err := templateProvenance(name, st, args)
+		require.NoError(t, err)
+		return opt
+	}
+	descriptions := func(st llb.State) map[string]map[string]string {
+		def, err := st.Marshal(context.TODO())
+		require.NoError(t, err)
+		out := map[string]map[string]string{}
+		for _, dt := range def.Def {
+			var op pb.Op
+			require.NoError(t, op.UnmarshalVT(dt))
+			if f := op.GetFile(); f != nil {
+				out[f.Actions[0].GetMkdir().Path] = def.Metadata[digest.FromBytes(dt)].Description
+			}
+		}
+		return out
+	}
+
+	st := llb.Scratch().File(llb.Mkdir("/foo", 0755), provenance("build", instructions.Stage{Name: "build"}, nil))
+	require.Equal(t, map[string]map[string]string{"/foo": nil}, descriptions(st))
+
+	tmpl := instructions.Stage{Template: "builder"}
+	st = llb.Scratch().File(llb.Mkdir("/foo", 0755), provenance("build", tmpl, nil))
+	require.Equal(t, map[string]map[string]string{"/foo": {
+		"llb.provenance.com.docker.dockerfile.v1.template.instance.build": `{"template":"builder"}`,
+	}}, descriptions(st))
+
+	// the step that doesn't depend on the args is shared by both instances
+	instance := func(name, variant string) llb.State {
+		opt := provenance(name, tmpl, instructions.KeyValuePairs{{Key: "VARIANT", Value: variant}})
+		return llb.Scratch().File(llb.Mkdir("/src", 0755), opt).File(llb.Mkdir("/out-"+variant, 0755), opt)
+	}
+	st = llb.Merge([]llb.State{instance("foo-build", "foo"), instance("bar-build", "bar")})
+	require.Equal(t, map[string]map[string]string{
+		"/src": {
+			"llb.provenance.com.docker.dockerfile.v1.template.instance.foo-build": `{"template":"builder","args":{"VARIANT":"foo"}}`,
+			"llb.provenance.com.docker.dockerfile.v1.template.instance.bar-build": `{"template":"builder","args":{"VARIANT":"bar"}}`,
+		},
+		"/out-foo": {
+			"llb.provenance.com.docker.dockerfile.v1.template.instance.foo-build": `{"template":"builder","args":{"VARIANT":"foo"}}`,
+		},
+		"/out-bar": {
+			"llb.provenance.com.docker.dockerfile.v1.template.instance.bar-build": `{"template":"builder","args":{"VARIANT":"bar"}}`,
+		},
+	}, descriptions(st))
+}
@@ -94,13 +94,15 @@ func validateCircularDependency(states []*dispatchState) error {
 		}
 		visited[state] = struct{}{}
 		path[state] = struct{}{}
-		for dep, c := range state.deps {
-			next := append(current, c)
-			if _, ok := path[dep]; ok {
-				return next
-			}
-			if c := visit(dep, next); c != nil {
-				return c
+		for _, deps := range []map[*dispatchState]instructions.Command{state.deps, state.conditionalDeps} {
+			for dep, c := range deps {
+				next := append(current, c)
+				if _, ok := path[dep]; ok {
+					return next
+				}
+				if c := visit(dep, next); c != nil {
+					return c
+				}
 			}
 		}
 		delete(path, state)
@@ -131,7 +133,7 @@ func validateCaseMatch(name string, isMajorityLower bool, location []parser.Rang
 	}
 	if correctCasing != "" {
 		msg := linter.RuleConsistentInstructionCasing.Format(name, correctCasing)
-		lint.Run(&linter.RuleConsistentInstructionCasing, location, msg)
+		lint.RunWithFix(&linter.RuleConsistentInstructionCasing, location, linter.FixKeywordCasing(location, isMajorityLower), msg)
 	}
 }
 
@@ -0,0 +1,304 @@
+package dockerfile2llb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/linter"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/util/system"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+// simpleCommandName matches the first word of a shell command that is run as
+// an executable
+var simpleCommandName = regexp.MustCompile(`^[A-Za-z0-9_./+-]+$`)
+
+// elfMachines maps the ELF machine of an executable to the architecture of
+// the platform it runs on
+var elfMachines = map[elf.Machine]string{
+	elf.EM_386:       "386",
+	elf.EM_X86_64:    "amd64",
+	elf.EM_ARM:       "arm",
+	elf.EM_AARCH64:   "arm64",
+	elf.EM_PPC64:     "ppc64le",
+	elf.EM_S390:      "s390x",
+	elf.EM_RISCV:     "riscv64",
+	elf.EM_LOONGARCH: "loong64",
+}
+
+// ValidateImage checks the config of the image against the files of its
+// rootfs and reports the problems found as lint warnings. The rootfs is only
+// read if one of the rules of the checks is enabled.
+func (r *Result) ValidateImage(ctx context.Context, ref gwclient.Reference) error {
+	d := r.target
+	if d == nil || d.image.OS == "windows" {
+		return nil
+	}
+	lint := d.opt.lint
+	if !lint.IsEnabled(&linter.RuleInvalidExecutable) && !lint.IsEnabled(&linter.RuleUndefinedUser) && !lint.IsEnabled(&linter.RuleWorkdirNotFound) {
+		return nil
+	}
+
+	v := &imageValidator{d: d, ref: ref, lint: lint}
+	v.validateExecutables(ctx)
+	v.validateUser(ctx)
+	v.validateWorkdir(ctx)
+	return lint.Error()
+}
+
+type imageValidator struct {
+	d    *dispatchState
+	ref  gwclient.Reference
+	lint *linter.Linter
+}
+
+func (v *imageValidator) validateExecutables(ctx context.Context) {
+	if !v.lint.IsEnabled(&linter.RuleInvalidExecutable) {
+		return
+	}
+	config := v.d.image.Config
+
+	if len(config.Entrypoint) > 0 {
+		v.validateExecutable(ctx, "ENTRYPOINT", config.Entrypoint[0], lastCommandLocation[*instructions.EntrypointCommand](v.d))
+	} else if len(config.Cmd) > 0 {
+		v.validateExecutable(ctx, "CMD", config.Cmd[0], lastCommandLocation[*instructions.CmdCommand](v.d))
+	}
+
+	hc := config.Healthcheck
+	if hc == nil || len(hc.Test) < 2 {
+		return
+	}
+	location := lastCommandLocation[*instructions.HealthCheckCommand](v.d)
+	switch hc.Test[0] {
+	case "CMD":
+		v.validateExecutable(ctx, "HEALTHCHECK", hc.Test[1], location)
+	case "CMD-SHELL":
+		shell := config.Shell
+		if len(shell) == 0 {
+			shell = defaultShell(v.d.image.OS)
+		}
+		v.validateExecutable(ctx, "HEALTHCHECK", shell[0], location)
+		if fields := strings.Fields(hc.Test[1]); len(fields) > 0 && simpleCommandName.MatchString(fields[0]) {
+			if !linter.IsShellBuiltin(fields[0]) {
+				v.validateExecutable(ctx, "HEALTHCHECK", fields[0], location)
+			}
+		}
+	}
+}
+
+func (v *imageValidator) validateExecutable(ctx context.Context, instruction, name string, location []parser.Range) {
+	if name == "" || strings.Contains(name, "$") {
+		return
+	}
+	reason := v.checkExecutable(ctx, name)
+	if reason == "" {
+		return
+	}
+	msg := linter.RuleInvalidExecutable.Format(instruction, name, reason)
+	v.lint.Run(&linter.RuleInvalidExecutable, location, msg)
+}
+
+// checkExecutable returns the reason the executable can't be run in the
+// image, or an empty string if it can.
+func (v *imageValidator) checkExecutable(ctx context.Context, name string) string {
+	p, st := v.lookPath(ctx, name)
+	if st == nil {
+		return "was not found"
+	}
+	if !isExecutable(st) {
+		return "is not executable"
+	}
+
+	dt, err := v.readFile(ctx, p, 128)
+	if err != nil {
+		return ""
+	}
+	if interpreter, ok := bytes.CutPrefix(dt, []byte("#!")); ok {
+		line, _, _ := bytes.Cut(interpreter, []byte("\n"))
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			return ""
+		}
+		if st, err := v.stat(ctx, fields[0]); err != nil || !isExecutable(st) {
+			return "uses interpreter " + fields[0] + " that was not found"
+		}
+		return ""
+	}
+	if arch, ok := elfArchitecture(dt); ok && arch != v.d.image.Architecture {
+		return "is built for " + arch + ", not " + v.d.image.Architecture
+	}
+	return ""
+}
+
+// lookPath returns the path and the stat of the executable. Names without a
+// slash are looked up in the PATH of the image like exec.LookPath does. The
+// returned stat is nil if the executable was not found.
+func (v *imageValidator) lookPath(ctx context.Context, name string) (string, *fstypes.Stat) {
+	if strings.Contains(name, "/") {
+		p := name
+		if !path.IsAbs(p) {
+			p = path.Join(v.workdir(), p)
+		}
+		st, err := v.stat(ctx, p)
+		if err != nil {
+			return p, nil
+		}
+		return p, st
+	}
+
+	var first string
+	var firstStat *fstypes.Stat
+	for _, dir := range strings.Split(v.pathEnv(), ":") {
+		if !path.IsAbs(dir) {
+			continue
+		}
+		p := path.Join(dir, name)
+		st, err := v.stat(ctx, p)
+		if err != nil || st.IsDir() {
+			continue
+		}
+		if isExecutable(st) {
+			return p, st
+		}
+		if firstStat == nil {
+			first, firstStat = p, st
+		}
+	}
+	return first, firstStat
+}
+
+func (v *imageValidator) validateUser(ctx context.Context) {
+	if !v.lint.IsEnabled(&linter.RuleUndefinedUser) {
+		return
+	}
+	user, group, _ := strings.Cut(v.d.image.Config.User, ":")
+	location := lastCommandLocation[*instructions.UserCommand](v.d)
+	if user != "" && !isNumericID(user) && !v.hasEntry(ctx, "/etc/passwd", user) {
+		msg := linter.RuleUndefinedUser.Format("User", user)
+		v.lint.Run(&linter.RuleUndefinedUser, location, msg)
+	}
+	if group != "" && !isNumericID(group) && !v.hasEntry(ctx, "/etc/group", group) {
+		msg := linter.RuleUndefinedUser.Format("Group", group)
+		v.lint.Run(&linter.RuleUndefinedUser, location, msg)
+	}
+}
+
+// hasEntry returns true if the passwd or group file defines the name.
+func (v *imageValidator) hasEntry(ctx context.Context, filename, name string) bool {
+	dt, err := v.readFile(ctx, filename, 0)
+	if err != nil {
+		return false
+	}
+	s := bufio.NewScanner(bytes.NewReader(dt))
+	for s.Scan() {
+		if entry, _, ok := strings.Cut(s.Text(), ":"); ok && entry == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *imageValidator) validateWorkdir(ctx context.Context) {
+	if !v.lint.IsEnabled(&linter.RuleWorkdirNotFound) {
+		return
+	}
+	dir := v.d.image.Config.WorkingDir
+	if dir == "" || dir == "/" || strings.Contains(dir, "$") {
+		return
+	}
+	if st, err := v.stat(ctx, dir); err != nil || !st.IsDir() {
+		msg := linter.RuleWorkdirNotFound.Format(dir)
+		v.lint.Run(&linter.RuleWorkdirNotFound, lastCommandLocation[*instructions.WorkdirCommand](v.d), msg)
+	}
+}
+
+func (v *imageValidator) workdir() string {
+	if dir := v.d.image.Config.WorkingDir; dir != "" {
+		return dir
+	}
+	return "/"
+}
+
+func (v *imageValidator) pathEnv() string {
+	for _, env := range v.d.image.Config.Env {
+		if p, ok := strings.CutPrefix(env, "PATH="); ok {
+			return p
+		}
+	}
+	return system.DefaultPathEnv(v.d.image.OS)
+}
+
+// stat returns the stat of the file at p, following symlinks. An empty
+// rootfs has no ref, so an error is returned for all the paths.
+func (v *imageValidator) stat(ctx context.Context, p string) (*fstypes.Stat, error) {
+	if v.ref == nil {
+		return nil, os.ErrNotExist
+	}
+	return v.ref.StatFile(ctx, gwclient.StatRequest{Path: p})
+}
+
+// readFile reads the file at p. If length is set, only the start of the file
+// is read.
+func (v *imageValidator) readFile(ctx context.Context, p string, length int) ([]byte, error) {
+	if v.ref == nil {
+		return nil, os.ErrNotExist
+	}
+	req := gwclient.ReadRequest{Filename: p}
+	if length > 0 {
+		req.Range = &gwclient.FileRange{Length: length}
+	}
+	return v.ref.ReadFile(ctx, req)
+}
+
+// lastCommandLocation returns the location of the last instruction of the
+// stage with the type of T, or the location of the stage if the stage has no
+// such instruction and the value is inherited from the base image.
+func lastCommandLocation[T instructions.Command](d *dispatchState) []parser.Range {
+	location := d.stage.Location
+	for _, cmd := range d.commands {
+		if c, ok := cmd.Command.(T); ok && !cmd.isOnBuild {
+			location = c.Location()
+		}
+	}
+	return location
+}
+
+// elfArchitecture returns the architecture of the ELF executable with the
+// header dt. It returns false if dt isn't an ELF header or the architecture
+// isn't known.
+func elfArchitecture(dt []byte) (string, bool) {
+	if len(dt) < 20 || !bytes.HasPrefix(dt, []byte(elf.ELFMAG)) {
+		return "", false
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if elf.Data(dt[elf.EI_DATA]) == elf.ELFDATA2MSB {
+		order = binary.BigEndian
+	}
+	machine := elf.Machine(order.Uint16(dt[18:20]))
+	arch, ok := elfMachines[machine]
+	if ok && machine == elf.EM_PPC64 && order == binary.BigEndian {
+		arch = "ppc64"
+	}
+	return arch, ok
+}
+
+func isExecutable(st *fstypes.Stat) bool {
+	mode := os.FileMode(st.Mode)
+	return mode.IsRegular() && mode&0111 != 0
+}
+
+func isNumericID(id string) bool {
+	_, err := strconv.ParseUint(id, 10, 32)
+	return err == nil
+}
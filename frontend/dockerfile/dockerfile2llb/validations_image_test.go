@@ -0,0 +1,173 @@
+package dockerfile2llb
+
+import (
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	gwclient "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/util/appcontext"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+func TestValidateImage(t *testing.T) {
+	t.Parallel()
+
+	df := `# check=experimental=InvalidExecutable,UndefinedUser,WorkdirNotFound
+FROM scratch
+ENV PATH=/usr/bin:/bin
+WORKDIR /app
+USER app:staff
+HEALTHCHECK CMD curl -f http://localhost/ || exit 1
+ENTRYPOINT ["server"]
+`
+	amd64 := elfHeader(elf.EM_X86_64)
+	tcases := []struct {
+		name     string
+		files    map[string]testFile
+		expected []string
+	}{
+		{
+			name: "valid",
+			files: map[string]testFile{
+				"/app":          {mode: os.ModeDir | 0755},
+				"/bin/sh":       {mode: 0755, data: amd64},
+				"/bin/server":   {mode: 0755, data: []byte("#!/bin/sh\nexec true\n")},
+				"/usr/bin/curl": {mode: 0755, data: amd64},
+				"/etc/passwd":   {mode: 0644, data: []byte("root:x:0:0::/root:/bin/sh\napp:x:1000:1000::/app:/bin/sh\n")},
+				"/etc/group":    {mode: 0644, data: []byte("root:x:0:\nstaff:x:50:app\n")},
+			},
+		},
+		{
+			name: "invalid",
+			files: map[string]testFile{
+				"/app":          {mode: 0644},
+				"/bin/sh":       {mode: 0755, data: elfHeader(elf.EM_AARCH64)},
+				"/bin/server":   {mode: 0644, data: amd64},
+				"/usr/bin/curl": {mode: 0755, data: []byte("#!/usr/bin/python3\n")},
+				"/etc/passwd":   {mode: 0644, data: []byte("root:x:0:0::/root:/bin/sh\n")},
+			},
+			expected: []string{
+				"InvalidExecutable:7: ENTRYPOINT executable server is not executable",
+				"InvalidExecutable:6: HEALTHCHECK executable /bin/sh is built for arm64, not amd64",
+				"InvalidExecutable:6: HEALTHCHECK executable curl uses interpreter /usr/bin/python3 that was not found",
+				"UndefinedUser:5: User app is not defined in the image",
+				"UndefinedUser:5: Group staff is not defined in the image",
+				"WorkdirNotFound:4: Working directory /app does not exist in the image",
+			},
+		},
+		{
+			name: "empty",
+			expected: []string{
+				"InvalidExecutable:7: ENTRYPOINT executable server was not found",
+				"InvalidExecutable:6: HEALTHCHECK executable /bin/sh was not found",
+				"InvalidExecutable:6: HEALTHCHECK executable curl was not found",
+				"UndefinedUser:5: User app is not defined in the image",
+				"UndefinedUser:5: Group staff is not defined in the image",
+				"WorkdirNotFound:4: Working directory /app does not exist in the image",
+			},
+		},
+	}
+
+	for _, tc := range tcases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var warnings []string
+			sm := llb.NewSourceMap(nil, "Dockerfile", "Dockerfile", []byte(df))
+			res, err := Dockerfile2LLB(appcontext.Context(), []byte(df), ConvertOpt{
+				SourceMap:      sm,
+				TargetPlatform: &ocispecs.Platform{OS: "linux", Architecture: "amd64"},
+				Warn: func(rulename, _, _, msg string, location []parser.Range) {
+					if rulename == "JSONArgsRecommended" {
+						return
+					}
+					warnings = append(warnings, fmt.Sprintf("%s:%d: %s", rulename, location[0].Start.Line, msg))
+				},
+			})
+			require.NoError(t, err)
+			require.Empty(t, warnings)
+
+			var ref gwclient.Reference
+			if tc.files != nil {
+				ref = testRef(tc.files)
+			}
+			require.NoError(t, res.ValidateImage(context.TODO(), ref))
+			require.ElementsMatch(t, tc.expected, warnings)
+		})
+	}
+}
+
+func TestValidateImageDisabled(t *testing.T) {
+	t.Parallel()
+
+	res, err := Dockerfile2LLB(appcontext.Context(), []byte("FROM scratch\nUSER app\n"), ConvertOpt{
+		Warn: func(string, string, string, string, []parser.Range) {},
+	})
+	require.NoError(t, err)
+	// the rootfs isn't read if the checks aren't enabled
+	require.NoError(t, res.ValidateImage(context.TODO(), testRef(nil)))
+}
+
+func elfHeader(machine elf.Machine) []byte {
+	dt := make([]byte, 64)
+	copy(dt, elf.ELFMAG)
+	dt[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	dt[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	binary.LittleEndian.PutUint16(dt[18:], uint16(machine))
+	return dt
+}
+
+type testFile struct {
+	mode os.FileMode
+	data []byte
+}
+
+// testRef is a reference to a rootfs with the files of the map. Reading the
+// rootfs of a nil map fails the test.
+type testRef map[string]testFile
+
+func (r testRef) ToState() (llb.State, error) {
+	return llb.Scratch(), nil
+}
+
+func (r testRef) Evaluate(context.Context) error {
+	return nil
+}
+
+func (r testRef) ReadFile(_ context.Context, req gwclient.ReadRequest) ([]byte, error) {
+	if r == nil {
+		panic("rootfs should not be read")
+	}
+	f, ok := r[req.Filename]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	dt := f.data
+	if req.Range != nil && req.Range.Length < len(dt) {
+		dt = dt[:req.Range.Length]
+	}
+	return dt, nil
+}
+
+func (r testRef) StatFile(_ context.Context, req gwclient.StatRequest) (*fstypes.Stat, error) {
+	if r == nil {
+		panic("rootfs should not be read")
+	}
+	f, ok := r[req.Path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return &fstypes.Stat{Path: req.Path, Mode: uint32(f.mode)}, nil
+}
+
+func (r testRef) ReadDir(context.Context, gwclient.ReadDirRequest) ([]*fstypes.Stat, error) {
+	return nil, nil
+}
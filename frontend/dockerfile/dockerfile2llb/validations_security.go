@@ -0,0 +1,176 @@
+package dockerfile2llb
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/moby/buildkit/frontend/dockerfile/instructions"
+	"github.com/moby/buildkit/frontend/dockerfile/linter"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+var (
+	// shellSeparators splits a shell script into simple commands
+	shellSeparators = regexp.MustCompile(`&&|\|\||[;|&\n]`)
+	// urlWithCredentials matches URLs with a username and password
+	urlWithCredentials = regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://[^\s/:@'"]+:[^\s/@'"]+@[^\s'"]+`)
+)
+
+func validateBaseImagePinned(name string, location []parser.Range, lint *linter.Linter) {
+	ref, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return
+	}
+	if _, ok := ref.(reference.Digested); !ok {
+		msg := linter.RuleBaseImageNotPinned.Format(name)
+		lint.Run(&linter.RuleBaseImageNotPinned, location, msg)
+	}
+}
+
+func validateAddChecksum(c *instructions.AddCommand, lint *linter.Linter) {
+	if c.Checksum != "" {
+		return
+	}
+	for _, src := range c.SourcePaths {
+		if isHTTPSource(src) {
+			msg := linter.RuleAddRemoteWithoutChecksum.Format(src)
+			lint.Run(&linter.RuleAddRemoteWithoutChecksum, c.Location(), msg)
+		}
+	}
+}
+
+func validateFinalStageUser(d *dispatchState, lint *linter.Linter) {
+	if d.image.OS == "windows" || !isRootUser(d.image.Config.User) {
+		return
+	}
+	location := d.stage.Location
+	for _, cmd := range d.commands {
+		if c, ok := cmd.Command.(*instructions.UserCommand); ok && !cmd.isOnBuild {
+			location = c.Location()
+		}
+	}
+	msg := linter.RuleRootUserInFinalStage.Format(d.stageName)
+	lint.Run(&linter.RuleRootUserInFinalStage, location, msg)
+}
+
+func isRootUser(user string) bool {
+	name, _, _ := strings.Cut(user, ":")
+	return name == "" || name == "root" || name == "0"
+}
+
+func validateRunSecurity(c *instructions.RunCommand, lint *linter.Linter) {
+	location := c.Location()
+	if instructions.GetSecurity(c) == instructions.SecurityInsecure {
+		lint.Run(&linter.RuleRunSecurityInsecure, location, linter.RuleRunSecurityInsecure.Format())
+	}
+	if instructions.GetNetwork(c) == instructions.NetworkHost {
+		lint.Run(&linter.RuleRunNetworkHost, location, linter.RuleRunNetworkHost.Format())
+	}
+
+	script := strings.Join(c.CmdLine, " ")
+	for _, f := range c.Files {
+		script += "\n" + f.Data
+	}
+	for _, u := range urlWithCredentials.FindAllString(script, -1) {
+		if redacted, ok := redactURLCredentials(u); ok {
+			msg := linter.RuleCredentialsInURL.Format(redacted)
+			lint.Run(&linter.RuleCredentialsInURL, location, msg)
+		}
+	}
+
+	if !c.PrependShell {
+		// exec form runs a single command
+		checkShellCommand(c.CmdLine, location, lint)
+		return
+	}
+	for _, cmd := range shellSeparators.Split(script, -1) {
+		checkShellCommand(strings.Fields(cmd), location, lint)
+	}
+}
+
+// redactURLCredentials returns the URL with the password removed. URLs that
+// take the password from a variable are ignored.
+func redactURLCredentials(s string) (string, bool) {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return "", false
+	}
+	if p, ok := u.User.Password(); !ok || p == "" || strings.HasPrefix(p, "$") {
+		return "", false
+	}
+	return u.Redacted(), true
+}
+
+// checkShellCommand checks the arguments of a simple shell command.
+func checkShellCommand(args []string, location []parser.Range, lint *linter.Linter) {
+	// skip variable assignments and command wrappers
+	for len(args) > 0 && (strings.Contains(args[0], "=") || args[0] == "sudo" || args[0] == "env" || args[0] == "exec") {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return
+	}
+	switch args[0] {
+	case "apt-get", "/usr/bin/apt-get":
+		if isAptGetInstallWithRecommends(args[1:]) {
+			lint.Run(&linter.RuleAptGetInstallRecommends, location, linter.RuleAptGetInstallRecommends.Format())
+		}
+	case "chmod", "/bin/chmod", "/usr/bin/chmod":
+		for _, arg := range args[1:] {
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+			// the first argument that is not a flag is the mode
+			if isWorldWritableMode(arg) {
+				msg := linter.RuleWorldWritablePermissions.Format(arg)
+				lint.Run(&linter.RuleWorldWritablePermissions, location, msg)
+			}
+			break
+		}
+	}
+}
+
+func isAptGetInstallWithRecommends(args []string) bool {
+	install := false
+	for _, arg := range args {
+		switch {
+		case arg == "install":
+			install = true
+		case arg == "--no-install-recommends":
+			return false
+		case strings.EqualFold(arg, "APT::Install-Recommends=false"), strings.EqualFold(arg, "APT::Install-Recommends=0"):
+			return false
+		}
+	}
+	return install
+}
+
+// isWorldWritableMode returns true if the mode for chmod makes files writable
+// by other users. Directories with the sticky bit are allowed.
+func isWorldWritableMode(mode string) bool {
+	if v, err := strconv.ParseUint(mode, 8, 32); err == nil {
+		return v&0o002 != 0 && v&0o1000 == 0
+	}
+	for _, clause := range strings.Split(mode, ",") {
+		who, perms, ok := strings.Cut(clause, "+")
+		if !ok {
+			if who, perms, ok = strings.Cut(clause, "="); !ok {
+				continue
+			}
+		}
+		if strings.ContainsAny(who, "oa") && strings.Contains(perms, "w") && !strings.Contains(perms, "t") {
+			return true
+		}
+	}
+	return false
+}
+
+func validateCopyChmod(chmod string, location []parser.Range, lint *linter.Linter) {
+	if chmod != "" && isWorldWritableMode(chmod) {
+		msg := linter.RuleWorldWritablePermissions.Format(chmod)
+		lint.Run(&linter.RuleWorldWritablePermissions, location, msg)
+	}
+}
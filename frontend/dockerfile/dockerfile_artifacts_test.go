@@ -0,0 +1,105 @@
+//go:build dfexport
+
+package dockerfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/continuity/fs/fstest"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/frontend/dockerui"
+	"github.com/moby/buildkit/util/testutil/integration"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+)
+
+func init() {
+	allTests = append(allTests, integration.TestFuncs(
+		testExportArtifacts,
+	)...)
+}
+
+func testExportArtifacts(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	f := getFrontend(t, sb)
+
+	dockerfile := []byte(`
+FROM busybox AS build
+WORKDIR /out
+RUN echo -n app > app && mkdir reports && echo -n passed > reports/unit.txt
+EXPORT reports Test-Reports
+
+FROM busybox AS unused
+RUN echo -n unused > /unused
+EXPORT /unused unused
+
+FROM scratch
+COPY --from=build /out/app /app
+EXPORT /app binary
+`)
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+	)
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	destDir := t.TempDir()
+
+	_, err = f.Solve(sb.Context(), c, client.SolveOpt{
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+			dockerui.DefaultLocalNameContext:    dir,
+		},
+		Exports: []client.ExportEntry{
+			{
+				Type:      client.ExporterLocal,
+				OutputDir: destDir,
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	dt, err := os.ReadFile(filepath.Join(destDir, "app"))
+	require.NoError(t, err)
+	require.Equal(t, "app", string(dt))
+
+	dt, err = os.ReadFile(filepath.Join(destDir, "test-reports/unit.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "passed", string(dt))
+
+	dt, err = os.ReadFile(filepath.Join(destDir, "binary/app"))
+	require.NoError(t, err)
+	require.Equal(t, "app", string(dt))
+
+	// artifacts of stages the target doesn't depend on are not exported
+	_, err = os.Stat(filepath.Join(destDir, "unused"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	dockerfile = []byte(`
+FROM scratch AS a
+COPY Dockerfile /
+EXPORT /Dockerfile report
+
+FROM a
+EXPORT /Dockerfile report
+`)
+
+	dir = integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+	)
+
+	_, err = f.Solve(sb.Context(), c, client.SolveOpt{
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+			dockerui.DefaultLocalNameContext:    dir,
+		},
+	}, nil)
+	require.ErrorContains(t, err, `duplicate EXPORT name "report"`)
+}
@@ -0,0 +1,142 @@
+//go:build dfconditional
+
+package dockerfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/continuity/fs/fstest"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/frontend/dockerui"
+	"github.com/moby/buildkit/util/testutil/integration"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+)
+
+func init() {
+	allTests = append(allTests, integration.TestFuncs(
+		testConditionalInstructions,
+		testConditionalSources,
+	)...)
+}
+
+func testConditionalInstructions(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	f := getFrontend(t, sb)
+
+	dockerfile := []byte(`
+FROM scratch
+ARG VARIANT
+IF $VARIANT == foo
+COPY foo /
+ELSE
+COPY bar /
+IF $EXTRA
+COPY extra /
+ENDIF
+ENDIF
+`)
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+		fstest.CreateFile("foo", []byte("foo"), 0600),
+		fstest.CreateFile("bar", []byte("bar"), 0600),
+	)
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	for _, tc := range []struct {
+		variant  string
+		expected string
+		missing  string
+	}{
+		{"foo", "foo", "bar"},
+		{"other", "bar", "foo"},
+	} {
+		destDir := t.TempDir()
+		_, err = f.Solve(sb.Context(), c, client.SolveOpt{
+			FrontendAttrs: map[string]string{
+				"build-arg:VARIANT": tc.variant,
+			},
+			LocalMounts: map[string]fsutil.FS{
+				dockerui.DefaultLocalNameDockerfile: dir,
+				dockerui.DefaultLocalNameContext:    dir,
+			},
+			Exports: []client.ExportEntry{
+				{
+					Type:      client.ExporterLocal,
+					OutputDir: destDir,
+				},
+			},
+		}, nil)
+		require.NoError(t, err)
+
+		dt, err := os.ReadFile(filepath.Join(destDir, tc.expected))
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, string(dt))
+
+		// skipped instructions don't copy anything, even if the source is
+		// missing
+		_, err = os.Stat(filepath.Join(destDir, tc.missing))
+		require.ErrorIs(t, err, os.ErrNotExist)
+		_, err = os.Stat(filepath.Join(destDir, "extra"))
+		require.ErrorIs(t, err, os.ErrNotExist)
+	}
+}
+
+func testConditionalSources(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	f := getFrontend(t, sb)
+
+	// the image of the skipped branch doesn't exist, so the build fails if
+	// it is resolved
+	dockerfile := []byte(`
+FROM scratch AS foo
+COPY foo /
+
+FROM scratch
+ARG VARIANT
+IF $VARIANT == foo
+COPY --from=foo /foo /
+ELSE
+COPY --from=docker.io/library/this-image-does-not-exist:missing /bar /
+ENDIF
+`)
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+		fstest.CreateFile("foo", []byte("foo"), 0600),
+	)
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	destDir := t.TempDir()
+	_, err = f.Solve(sb.Context(), c, client.SolveOpt{
+		FrontendAttrs: map[string]string{
+			"build-arg:VARIANT": "foo",
+		},
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+			dockerui.DefaultLocalNameContext:    dir,
+		},
+		Exports: []client.ExportEntry{
+			{
+				Type:      client.ExporterLocal,
+				OutputDir: destDir,
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	dt, err := os.ReadFile(filepath.Join(destDir, "foo"))
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(dt))
+}
@@ -0,0 +1,197 @@
+package dockerfile
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/containerd/continuity/fs/fstest"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/frontend/dockerui"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/frontend/subrequests"
+	"github.com/moby/buildkit/frontend/subrequests/graph"
+	"github.com/moby/buildkit/util/testutil/integration"
+	"github.com/moby/buildkit/util/testutil/workers"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+)
+
+var graphTests = integration.TestFuncs(
+	testGraph,
+	testGraphDescribeDefinition,
+)
+
+func testGraph(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	workers.CheckFeatureCompat(t, sb, workers.FeatureFrontendGraph)
+	f := getFrontend(t, sb)
+	if _, ok := f.(*clientFrontend); !ok {
+		t.Skip("only test with client frontend")
+	}
+
+	dockerfile := []byte(`
+ARG GO_VERSION=1.23
+FROM busybox AS base
+ARG GO_VERSION
+RUN echo $GO_VERSION
+
+FROM scratch AS tools
+
+FROM base AS build
+COPY --from=alpine /etc/alpine-release /
+RUN --mount=from=tools,target=/tools true
+
+FROM scratch
+COPY --from=build /out /
+`)
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+	)
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	called := false
+	frontend := func(ctx context.Context, c gateway.Client) (*gateway.Result, error) {
+		res, err := c.Solve(ctx, gateway.SolveRequest{
+			FrontendOpt: map[string]string{
+				"frontend.caps": "moby.buildkit.frontend.subrequests",
+				"requestid":     "frontend.graph",
+			},
+			Frontend: "dockerfile.v0",
+		})
+		require.NoError(t, err)
+
+		g, err := unmarshalGraph(res)
+		require.NoError(t, err)
+
+		require.Equal(t, 1, len(g.Sources))
+		require.Equal(t, dockerfile, g.Sources[0])
+
+		stages := map[string]graph.Stage{}
+		for _, s := range g.Stages {
+			stages[s.ID] = s
+		}
+		require.Len(t, stages, 5)
+
+		base := stages["base"]
+		require.Equal(t, graph.StageTypeStage, base.Type)
+		require.True(t, strings.HasPrefix(base.Base, "docker.io/library/busybox:latest@sha256:"), base.Base)
+		require.Equal(t, []string{"GO_VERSION"}, base.Args)
+		require.Equal(t, int32(3), base.Location.Ranges[0].Start.Line)
+
+		img := stages["image:alpine"]
+		require.Equal(t, graph.StageTypeImage, img.Type)
+		require.True(t, strings.HasPrefix(img.Base, "docker.io/library/alpine:latest@sha256:"), img.Base)
+
+		require.True(t, stages["stage-3"].Target)
+		require.False(t, stages["build"].Target)
+
+		type edge struct{ from, to, typ string }
+		var edges []edge
+		for _, e := range g.Edges {
+			edges = append(edges, edge{e.From, e.To, e.Type})
+		}
+		require.ElementsMatch(t, []edge{
+			{"build", "base", graph.EdgeTypeFrom},
+			{"build", "image:alpine", graph.EdgeTypeCopy},
+			{"build", "tools", graph.EdgeTypeMount},
+			{"stage-3", "build", graph.EdgeTypeCopy},
+		}, edges)
+
+		dot, ok := res.Metadata["result.dot"]
+		require.True(t, ok)
+		require.Contains(t, string(dot), `"build" -> "base" [label="from"];`)
+
+		called = true
+		return nil, nil
+	}
+
+	_, err = c.Build(sb.Context(), client.SolveOpt{
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+		},
+	}, "", frontend, nil)
+	require.NoError(t, err)
+
+	require.True(t, called)
+}
+
+func testGraphDescribeDefinition(t *testing.T, sb integration.Sandbox) {
+	workers.CheckFeatureCompat(t, sb, workers.FeatureFrontendGraph)
+	f := getFrontend(t, sb)
+	if _, ok := f.(*clientFrontend); !ok {
+		t.Skip("only test with client frontend")
+	}
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	dockerfile := []byte(integration.UnixOrWindows(
+		`
+FROM scratch
+COPY Dockerfile Dockerfile
+`,
+		`
+FROM nanoserver
+COPY Dockerfile Dockerfile
+`,
+	))
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+	)
+
+	called := false
+
+	frontend := func(ctx context.Context, c gateway.Client) (*gateway.Result, error) {
+		reqs, err := subrequests.Describe(ctx, c)
+		require.NoError(t, err)
+
+		require.Greater(t, len(reqs), 0)
+
+		hasGraph := false
+
+		for _, req := range reqs {
+			if req.Name != "frontend.graph" {
+				continue
+			}
+			hasGraph = true
+			require.Equal(t, subrequests.RequestType("rpc"), req.Type)
+			require.NotEqual(t, "", req.Version)
+		}
+		require.True(t, hasGraph)
+
+		called = true
+		return nil, nil
+	}
+
+	_, err = c.Build(sb.Context(), client.SolveOpt{
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+		},
+	}, "", frontend, nil)
+	require.NoError(t, err)
+
+	require.True(t, called)
+}
+
+func unmarshalGraph(res *gateway.Result) (*graph.Graph, error) {
+	dt, ok := res.Metadata["result.json"]
+	if !ok {
+		return nil, errors.New("missing frontend.graph")
+	}
+	var g graph.Graph
+	if err := json.Unmarshal(dt, &g); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
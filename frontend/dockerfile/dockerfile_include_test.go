@@ -0,0 +1,206 @@
+//go:build dfinclude
+
+package dockerfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/continuity/fs/fstest"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/frontend/dockerui"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/errdefs"
+	"github.com/moby/buildkit/util/testutil/integration"
+	"github.com/moby/buildkit/util/testutil/workers"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+)
+
+func init() {
+	allTests = append(allTests, integration.TestFuncs(
+		testIncludeStages,
+		testIncludeError,
+		testIncludeTargets,
+	)...)
+}
+
+func testIncludeStages(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	f := getFrontend(t, sb)
+
+	common := []byte(`
+ARG GREETING=hello
+FROM busybox AS base
+ARG GREETING
+RUN echo -n $GREETING > /greeting
+
+FROM base AS builder
+RUN --mount=from=base,target=/base cp /base/greeting /out
+
+FROM scratch
+COPY --from=1 /out /out
+`)
+
+	dockerfile := []byte(`
+INCLUDE ./common/Dockerfile AS common
+
+FROM busybox AS base
+RUN echo -n main > /main
+
+FROM scratch
+COPY --from=common.stage-2 /out /included
+COPY --from=0 /main /main
+`)
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+		fstest.CreateDir("common", 0700),
+		fstest.CreateFile("common/Dockerfile", common, 0600),
+	)
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	destDir := t.TempDir()
+
+	_, err = f.Solve(sb.Context(), c, client.SolveOpt{
+		FrontendAttrs: map[string]string{
+			"build-arg:GREETING": "hi",
+		},
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+			dockerui.DefaultLocalNameContext:    dir,
+		},
+		Exports: []client.ExportEntry{
+			{
+				Type:      client.ExporterLocal,
+				OutputDir: destDir,
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	dt, err := os.ReadFile(filepath.Join(destDir, "included"))
+	require.NoError(t, err)
+	require.Equal(t, "hi", string(dt))
+
+	dt, err = os.ReadFile(filepath.Join(destDir, "main"))
+	require.NoError(t, err)
+	require.Equal(t, "main", string(dt))
+}
+
+func testIncludeError(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	f := getFrontend(t, sb)
+
+	common := []byte(`
+FROM busybox AS base
+RUN --mount=type=invalid true
+`)
+
+	dockerfile := []byte(`
+INCLUDE common.Dockerfile AS common
+FROM common.base
+`)
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+		fstest.CreateFile("common.Dockerfile", common, 0600),
+	)
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = f.Solve(sb.Context(), c, client.SolveOpt{
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+			dockerui.DefaultLocalNameContext:    dir,
+		},
+	}, nil)
+	require.Error(t, err)
+
+	srcs := errdefs.Sources(err)
+	require.Len(t, srcs, 1)
+	require.Equal(t, "common.Dockerfile", srcs[0].Info.Filename)
+	require.Equal(t, common, srcs[0].Info.Data)
+	require.Equal(t, int32(3), srcs[0].Ranges[0].Start.Line)
+}
+
+func testIncludeTargets(t *testing.T, sb integration.Sandbox) {
+	workers.CheckFeatureCompat(t, sb, workers.FeatureFrontendTargets)
+	f := getFrontend(t, sb)
+	if _, ok := f.(*clientFrontend); !ok {
+		t.Skip("only test with client frontend")
+	}
+
+	common := []byte(`
+# base is the shared base stage
+FROM alpine AS base
+`)
+
+	dockerfile := []byte(`
+INCLUDE common.Dockerfile AS common
+FROM common.base AS release
+`)
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+		fstest.CreateFile("common.Dockerfile", common, 0600),
+	)
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	called := false
+	frontend := func(ctx context.Context, c gateway.Client) (*gateway.Result, error) {
+		res, err := c.Solve(ctx, gateway.SolveRequest{
+			FrontendOpt: map[string]string{
+				"frontend.caps": "moby.buildkit.frontend.subrequests",
+				"requestid":     "frontend.targets",
+			},
+			Frontend: "dockerfile.v0",
+		})
+		require.NoError(t, err)
+
+		list, err := unmarshalTargets(res)
+		require.NoError(t, err)
+
+		require.Equal(t, [][]byte{dockerfile, common}, list.Sources)
+		require.Len(t, list.Targets, 2)
+
+		target := list.Targets[0]
+		require.Equal(t, "release", target.Name)
+		require.Equal(t, "common.base", target.Base)
+		require.True(t, target.Default)
+		require.Equal(t, int32(0), target.Location.SourceIndex)
+
+		target = list.Targets[1]
+		require.Equal(t, "common.base", target.Name)
+		require.Equal(t, "is the shared base stage", target.Description)
+		require.False(t, target.Default)
+		require.Equal(t, int32(1), target.Location.SourceIndex)
+		require.Equal(t, int32(3), target.Location.Ranges[0].Start.Line)
+
+		called = true
+		return nil, nil
+	}
+
+	_, err = c.Build(sb.Context(), client.SolveOpt{
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+			dockerui.DefaultLocalNameContext:    dir,
+		},
+	}, "", frontend, nil)
+	require.NoError(t, err)
+
+	require.True(t, called)
+}
@@ -0,0 +1,89 @@
+//go:build dfrunoverlay
+
+package dockerfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/continuity/fs/fstest"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/frontend/dockerui"
+	"github.com/moby/buildkit/util/testutil/integration"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+)
+
+func init() {
+	allTests = append(allTests, integration.TestFuncs(
+		testRunOverlayMount,
+	)...)
+}
+
+func testRunOverlayMount(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	f := getFrontend(t, sb)
+
+	// the output is used by a stage that is defined before the stage that
+	// creates it
+	dockerfile := []byte(`
+FROM busybox AS src
+RUN mkdir /src && echo -n input > /src/input
+
+FROM scratch AS result
+COPY --from=generated / /generated
+COPY --from=src /src/input /original
+
+FROM busybox AS build
+RUN --mount=type=overlay,from=src,source=/src,target=/src,output=generated \
+  cat /src/input > /src/output && echo -n changed > /src/input
+RUN --mount=type=overlay,from=src,source=/src,target=/src \
+  echo -n discarded > /src/input
+
+FROM result
+`)
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+	)
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	destDir := t.TempDir()
+
+	_, err = f.Solve(sb.Context(), c, client.SolveOpt{
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+			dockerui.DefaultLocalNameContext:    dir,
+		},
+		Exports: []client.ExportEntry{
+			{
+				Type:      client.ExporterLocal,
+				OutputDir: destDir,
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	dt, err := os.ReadFile(filepath.Join(destDir, "generated/output"))
+	require.NoError(t, err)
+	require.Equal(t, "input", string(dt))
+
+	dt, err = os.ReadFile(filepath.Join(destDir, "generated/input"))
+	require.NoError(t, err)
+	require.Equal(t, "changed", string(dt))
+
+	// the source stage is not changed by the overlay mounts
+	dt, err = os.ReadFile(filepath.Join(destDir, "original"))
+	require.NoError(t, err)
+	require.Equal(t, "input", string(dt))
+
+	// the output only contains the changes to the mount
+	entries, err := os.ReadDir(filepath.Join(destDir, "generated"))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
@@ -804,6 +804,72 @@ RUN echo -n "$SOURCE_DATE_EPOCH" >/epoch
 	require.Equal(t, archiveMaxTime.Unix(), readOCIImageCreated(t, dt).Unix())
 }
 
+func testSourceDateEpochDedupeFiles(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	workers.CheckFeatureCompat(t, sb, workers.FeatureOCIExporter, workers.FeatureSourceDateEpoch)
+	f := getFrontend(t, sb)
+
+	dockerfile := []byte(`
+FROM busybox
+RUN echo foo >/foo && echo bar >/bar
+RUN echo foo >/foo && echo baz >/bar
+`)
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+	)
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	out := filepath.Join(t.TempDir(), "out.tar")
+	outW, err := os.Create(out)
+	require.NoError(t, err)
+
+	tm := time.Unix(1700000001, 0).UTC()
+
+	_, err = f.Solve(sb.Context(), c, client.SolveOpt{
+		FrontendAttrs: map[string]string{
+			"build-arg:SOURCE_DATE_EPOCH": fmt.Sprintf("%d", tm.Unix()),
+		},
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+			dockerui.DefaultLocalNameContext:    dir,
+		},
+		Exports: []client.ExportEntry{
+			{
+				Type: client.ExporterOCI,
+				Attrs: map[string]string{
+					"rewrite-timestamp": "true",
+					"dedupe-files":      "true",
+				},
+				Output: fixedWriteCloser(outW),
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	dt, err := os.ReadFile(out)
+	require.NoError(t, err)
+
+	mfst := readOCIManifest(t, dt)
+	require.GreaterOrEqual(t, len(mfst.Layers), 3)
+
+	// /foo was written again with the same content, its timestamp only
+	// matches the one in the layer below after both were clamped
+	layerMap := readOCILayerMap(t, dt, mfst.Layers[len(mfst.Layers)-1])
+	require.NotContains(t, layerMap, "foo")
+	require.Contains(t, layerMap, "bar")
+	require.Equal(t, "baz\n", string(layerMap["bar"].Data))
+
+	layerMap = readOCILayerMap(t, dt, mfst.Layers[len(mfst.Layers)-2])
+	require.Contains(t, layerMap, "foo")
+	require.Equal(t, "foo\n", string(layerMap["foo"].Data))
+	require.Equal(t, tm.Unix(), layerMap["foo"].Header.ModTime.Unix())
+}
+
 func testReproSourceDateEpoch(t *testing.T, sb integration.Sandbox) {
 	integration.SkipOnPlatform(t, "windows", "COPY --link requires diffApply which is not supported on Windows")
 	workers.CheckFeatureCompat(t, sb, workers.FeatureOCIExporter, workers.FeatureSourceDateEpoch)
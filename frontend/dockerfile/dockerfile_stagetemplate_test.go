@@ -0,0 +1,150 @@
+//go:build dfstagetemplate
+
+package dockerfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/continuity/fs/fstest"
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/frontend/dockerui"
+	gateway "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/util/testutil/integration"
+	"github.com/moby/buildkit/util/testutil/workers"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+)
+
+func init() {
+	allTests = append(allTests, integration.TestFuncs(
+		testStageTemplate,
+		testStageTemplateTargets,
+	)...)
+}
+
+func testStageTemplate(t *testing.T, sb integration.Sandbox) {
+	integration.SkipOnPlatform(t, "windows")
+	f := getFrontend(t, sb)
+
+	dockerfile := []byte(`
+ARG BASE=busybox
+FROM ${BASE} AS builder
+ARG VARIANT=default
+RUN mkdir /out && echo -n $VARIANT > /out/$VARIANT
+
+FROM builder(VARIANT=foo) AS foo-build
+FROM builder(VARIANT=bar) AS bar-build
+RUN echo -n extra > /out/extra
+
+FROM scratch
+COPY --from=builder /out /
+COPY --from=foo-build /out /
+COPY --from=bar-build /out /
+`)
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+	)
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	destDir := t.TempDir()
+
+	_, err = f.Solve(sb.Context(), c, client.SolveOpt{
+		FrontendAttrs: map[string]string{
+			"build-arg:VARIANT": "cli",
+		},
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+			dockerui.DefaultLocalNameContext:    dir,
+		},
+		Exports: []client.ExportEntry{
+			{
+				Type:      client.ExporterLocal,
+				OutputDir: destDir,
+			},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	for _, v := range []string{"cli", "foo", "bar"} {
+		dt, err := os.ReadFile(filepath.Join(destDir, v))
+		require.NoError(t, err)
+		require.Equal(t, v, string(dt))
+	}
+
+	dt, err := os.ReadFile(filepath.Join(destDir, "extra"))
+	require.NoError(t, err)
+	require.Equal(t, "extra", string(dt))
+}
+
+func testStageTemplateTargets(t *testing.T, sb integration.Sandbox) {
+	workers.CheckFeatureCompat(t, sb, workers.FeatureFrontendTargets)
+	f := getFrontend(t, sb)
+	if _, ok := f.(*clientFrontend); !ok {
+		t.Skip("only test with client frontend")
+	}
+
+	dockerfile := []byte(`
+# builder builds a variant
+FROM alpine AS builder
+
+FROM builder(VARIANT=foo) AS foo-build
+`)
+
+	dir := integration.Tmpdir(
+		t,
+		fstest.CreateFile("Dockerfile", dockerfile, 0600),
+	)
+
+	c, err := client.New(sb.Context(), sb.Address())
+	require.NoError(t, err)
+	defer c.Close()
+
+	called := false
+	frontend := func(ctx context.Context, c gateway.Client) (*gateway.Result, error) {
+		res, err := c.Solve(ctx, gateway.SolveRequest{
+			FrontendOpt: map[string]string{
+				"frontend.caps": "moby.buildkit.frontend.subrequests",
+				"requestid":     "frontend.targets",
+			},
+			Frontend: "dockerfile.v0",
+		})
+		require.NoError(t, err)
+
+		list, err := unmarshalTargets(res)
+		require.NoError(t, err)
+		require.Len(t, list.Targets, 2)
+
+		target := list.Targets[0]
+		require.Equal(t, "builder", target.Name)
+		require.Empty(t, target.Template)
+		require.Nil(t, target.Args)
+
+		target = list.Targets[1]
+		require.Equal(t, "foo-build", target.Name)
+		require.Equal(t, "alpine", target.Base)
+		require.Equal(t, "builder", target.Template)
+		require.Equal(t, map[string]string{"VARIANT": "foo"}, target.Args)
+		require.True(t, target.Default)
+
+		called = true
+		return nil, nil
+	}
+
+	_, err = c.Build(sb.Context(), client.SolveOpt{
+		LocalMounts: map[string]fsutil.FS{
+			dockerui.DefaultLocalNameDockerfile: dir,
+			dockerui.DefaultLocalNameContext:    dir,
+		},
+	}, "", frontend, nil)
+	require.NoError(t, err)
+
+	require.True(t, called)
+}
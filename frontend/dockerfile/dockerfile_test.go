@@ -257,6 +257,7 @@ var reproTests = integration.TestFuncs(
 	testSourceDateEpochStageInvalid,
 	testSourceDateEpochNamedContextHTTPLastModified,
 	testSourceDateEpochNamedContextHTTPArchive,
+	testSourceDateEpochDedupeFiles,
 
 	// dockerfile_workdir_test.go
 	testWorkdirSourceDateEpochReproducible,
@@ -308,6 +309,7 @@ func TestIntegration(t *testing.T) {
 	integration.Run(t, heredocTests, opts...)
 	integration.Run(t, outlineTests, opts...)
 	integration.Run(t, targetsTests, opts...)
+	integration.Run(t, graphTests, opts...)
 
 	// the rest of the tests are meant for non-Windows, skipping on Windows.
 	integration.SkipOnPlatform(t, "windows")
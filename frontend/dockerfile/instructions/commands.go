@@ -507,6 +507,73 @@ type ShellCommand struct {
 	Shell []string
 }
 
+// IncludeCommand imports the stages of another Dockerfile. The stages are
+// available with the name of the stage prefixed by the namespace.
+//
+//	INCLUDE ./common/Dockerfile AS common
+//	FROM common.builder
+type IncludeCommand struct {
+	withNameAndCode
+	Source    string
+	Namespace string
+}
+
+// ExportCommand marks a path of the stage as a named artifact of the build.
+//
+//	EXPORT /out/coverage.txt coverage
+type ExportCommand struct {
+	withNameAndCode
+	Source   string
+	Artifact string
+}
+
+// Expand variables
+func (c *ExportCommand) Expand(expander SingleWordExpander) error {
+	p, err := expander(c.Source)
+	if err != nil {
+		return err
+	}
+	c.Source = p
+	return nil
+}
+
+// IfCommand starts a block of instructions that are only dispatched if the
+// condition is true. The condition is a value that is true if it is not
+// empty, or two values compared with == or !=.
+//
+//	IF $TARGETARCH == arm64
+type IfCommand struct {
+	withNameAndCode
+	Condition []string
+}
+
+// Expand variables
+func (c *IfCommand) Expand(expander SingleWordExpander) error {
+	for i, v := range c.Condition {
+		p, err := expander(v)
+		if err != nil {
+			return err
+		}
+		c.Condition[i] = p
+	}
+	return nil
+}
+
+// ElseCommand starts the block of instructions that are dispatched if the
+// condition of the IF block is false.
+//
+//	ELSE
+type ElseCommand struct {
+	withNameAndCode
+}
+
+// EndIfCommand ends an IF block.
+//
+//	ENDIF
+type EndIfCommand struct {
+	withNameAndCode
+}
+
 // Stage represents a bundled collection of commands.
 //
 // Each stage begins with a FROM command (which is consumed into the Stage),
@@ -522,6 +589,13 @@ type Stage struct {
 	BaseName string    // name of the base stage or source
 	Platform string    // platform of base source to use
 
+	// Template is the name of the stage the stage is instantiated from with
+	// the FROM template(KEY=value) syntax. The stage starts with the base and
+	// the commands of the template.
+	Template string
+	// TemplateArgs are the build args the template is instantiated with.
+	TemplateArgs KeyValuePairs
+
 	DocComment string // doc-comment directly above the stage
 
 	SourceCode string         // contents of the defining FROM command
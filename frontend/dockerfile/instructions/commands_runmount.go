@@ -18,14 +18,18 @@ const (
 	MountTypeTmpfs  MountType = "tmpfs"
 	MountTypeSecret MountType = "secret"
 	MountTypeSSH    MountType = "ssh"
+	// MountTypeOverlay is a writable bind mount. The changes to the mount can
+	// be exported as a named output.
+	MountTypeOverlay MountType = "overlay"
 )
 
 var allowedMountTypes = map[MountType]struct{}{
-	MountTypeBind:   {},
-	MountTypeCache:  {},
-	MountTypeTmpfs:  {},
-	MountTypeSecret: {},
-	MountTypeSSH:    {},
+	MountTypeBind:    {},
+	MountTypeCache:   {},
+	MountTypeTmpfs:   {},
+	MountTypeSecret:  {},
+	MountTypeSSH:     {},
+	MountTypeOverlay: {},
 }
 
 type ShareMode string
@@ -89,6 +93,9 @@ func setMountState(cmd *RunCommand, expander SingleWordExpander) error {
 		if err != nil {
 			return err
 		}
+		if st.mapFrom != nil && m.From != "" {
+			m.From = st.mapFrom(m.From)
+		}
 		mounts[i] = m
 	}
 	st.mounts = mounts
@@ -107,9 +114,26 @@ func GetMounts(cmd *RunCommand) []*Mount {
 	return getMountState(cmd).mounts
 }
 
+// SetMountFromMapping sets a function that maps the from option of the
+// mounts of the command. The mapping is also applied when the mounts are
+// parsed again to expand variables.
+func SetMountFromMapping(cmd *RunCommand, fn func(string) string) {
+	st := getMountState(cmd)
+	if st == nil {
+		return
+	}
+	st.mapFrom = fn
+	for _, m := range st.mounts {
+		if m.From != "" {
+			m.From = fn(m.From)
+		}
+	}
+}
+
 type mountState struct {
-	flag   *Flag
-	mounts []*Mount
+	flag    *Flag
+	mounts  []*Mount
+	mapFrom func(string) string
 }
 
 type Mount struct {
@@ -128,6 +152,9 @@ type Mount struct {
 	Mode *uint64
 	UID  *uint64
 	GID  *uint64
+	// Output is the name the changes to an overlay mount are available as in
+	// the following stages.
+	Output string
 }
 
 func parseMount(val string, expander SingleWordExpander) (*Mount, error) {
@@ -180,6 +207,10 @@ func parseMount(val string, expander SingleWordExpander) (*Mount, error) {
 			if idx := strings.IndexByte(value, '$'); idx != -1 && idx != len(value)-1 {
 				return nil, errors.Errorf("'%s' doesn't support variable expansion, define alias stage instead", key)
 			}
+		} else if key == "output" {
+			if strings.Contains(value, "$") {
+				return nil, errors.Errorf("'%s' doesn't support variable expansion", key)
+			}
 		} else {
 			// if we don't have an expander, defer evaluation to later
 			continue
@@ -257,9 +288,11 @@ func parseMount(val string, expander SingleWordExpander) (*Mount, error) {
 			m.GID = &gid
 		case "env":
 			m.Env = &value
+		case "output":
+			m.Output = strings.ToLower(value)
 		default:
 			allKeys := []string{
-				"type", "from", "source", "target", "readonly", "id", "sharing", "required", "size", "mode", "uid", "gid", "src", "dst", "destination", "ro", "rw", "readwrite", "env",
+				"type", "from", "source", "target", "readonly", "id", "sharing", "required", "size", "mode", "uid", "gid", "src", "dst", "destination", "ro", "rw", "readwrite", "env", "output",
 			}
 			return nil, suggest.WrapError(errors.Errorf("unexpected key '%s' in '%s'", key, field), key, allKeys, true)
 		}
@@ -280,7 +313,7 @@ func parseMount(val string, expander SingleWordExpander) (*Mount, error) {
 	}
 
 	if roAuto {
-		if m.Type == MountTypeCache || m.Type == MountTypeTmpfs {
+		if m.Type == MountTypeCache || m.Type == MountTypeTmpfs || m.Type == MountTypeOverlay {
 			m.ReadOnly = false
 		} else {
 			m.ReadOnly = true
@@ -306,5 +339,20 @@ func parseMount(val string, expander SingleWordExpander) (*Mount, error) {
 		return nil, errors.Errorf("invalid cache sharing set for %v mount", m.Type)
 	}
 
+	// the type is only known once the mount is expanded
+	if expander != nil {
+		if m.Type == MountTypeOverlay && m.ReadOnly {
+			return nil, errors.New("overlay mount can't be read-only")
+		}
+		if m.Output != "" {
+			if m.Type != MountTypeOverlay {
+				return nil, errors.Errorf("output not allowed for %q type mounts", m.Type)
+			}
+			if !validStageName.MatchString(m.Output) {
+				return nil, errors.Errorf("invalid output name %q for overlay mount, name can't start with a number or contain symbols", m.Output)
+			}
+		}
+	}
+
 	return m, nil
 }
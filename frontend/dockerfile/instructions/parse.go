@@ -18,6 +18,7 @@ import (
 	"github.com/moby/buildkit/util/suggest"
 	dockerspec "github.com/moby/docker-image-spec/specs-go/v1"
 	"github.com/pkg/errors"
+	"github.com/tonistiigi/go-csvvalue"
 )
 
 type parseRequest struct {
@@ -83,7 +84,7 @@ func ParseInstructionWithLinter(node *parser.Node, lint *linter.Linter) (v any,
 		return parseEnv(req)
 	case command.Maintainer:
 		msg := linter.RuleMaintainerDeprecated.Format()
-		lint.Run(&linter.RuleMaintainerDeprecated, node.Location(), msg)
+		lint.RunWithFix(&linter.RuleMaintainerDeprecated, node.Location(), linter.FixMaintainer(node.Location()), msg)
 		return parseMaintainer(req)
 	case command.Label:
 		return parseLabel(req)
@@ -98,7 +99,7 @@ func ParseInstructionWithLinter(node *parser.Node, lint *linter.Linter) (v any,
 		}
 		if !doesFromCaseMatchAsCase(req) {
 			msg := linter.RuleFromAsCasing.Format(req.command, req.args[1])
-			lint.Run(&linter.RuleFromAsCasing, node.Location(), msg)
+			lint.RunWithFix(&linter.RuleFromAsCasing, node.Location(), linter.FixFromAsCasing(node.Location()), msg)
 		}
 		fromCmd, err := parseFrom(req)
 		if err != nil {
@@ -141,6 +142,16 @@ func ParseInstructionWithLinter(node *parser.Node, lint *linter.Linter) (v any,
 		return argCmd, nil
 	case command.Shell:
 		return parseShell(req)
+	case command.Include:
+		return parseInclude(req)
+	case command.If:
+		return parseIf(req)
+	case command.Else:
+		return parseElse(req)
+	case command.EndIf:
+		return parseEndIf(req)
+	case command.Export:
+		return parseExport(req)
 	}
 	return nil, suggest.WrapError(&UnknownInstructionError{Instruction: node.Value, Line: node.StartLine}, node.Value, allInstructionNames(), false)
 }
@@ -183,10 +194,26 @@ func (e *parseError) Unwrap() error {
 // Parse a Dockerfile into a collection of buildable stages.
 // metaArgs is a collection of ARG instructions that occur before the first FROM.
 func Parse(ast *parser.Node, lint *linter.Linter) (stages []Stage, metaArgs []ArgCommand, err error) {
+	stages, metaArgs, includes, err := ParseWithIncludes(ast, lint)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(includes) > 0 {
+		return nil, nil, parser.WithLocation(errors.New("INCLUDE is not supported in this context"), includes[0].Location())
+	}
+	return stages, metaArgs, nil
+}
+
+// ParseWithIncludes parses a Dockerfile like Parse and additionally returns
+// the INCLUDE instructions that occur before the first FROM.
+func ParseWithIncludes(ast *parser.Node, lint *linter.Linter) (stages []Stage, metaArgs []ArgCommand, includes []IncludeCommand, err error) {
+	// stageNodes are the nodes of the commands of each stage, used to
+	// instantiate stage templates
+	var stageNodes [][]*parser.Node
 	for _, n := range ast.Children {
 		cmd, err := ParseInstructionWithLinter(n, lint)
 		if err != nil {
-			return nil, nil, &parseError{inner: err, node: n}
+			return nil, nil, nil, &parseError{inner: err, node: n}
 		}
 		if len(stages) == 0 {
 			// meta arg case
@@ -196,19 +223,74 @@ func Parse(ast *parser.Node, lint *linter.Linter) (stages []Stage, metaArgs []Ar
 			}
 		}
 		switch c := cmd.(type) {
+		case *IncludeCommand:
+			if len(stages) > 0 {
+				return nil, nil, nil, parser.WithLocation(errors.New("INCLUDE is only allowed before the first FROM"), n.Location())
+			}
+			for _, inc := range includes {
+				if inc.Namespace == c.Namespace {
+					return nil, nil, nil, parser.WithLocation(errors.Errorf("duplicate INCLUDE namespace %q", c.Namespace), n.Location())
+				}
+			}
+			includes = append(includes, *c)
 		case *Stage:
+			// the stages of an included Dockerfile are named after its
+			// namespace, another stage with such a name would replace them
+			for _, inc := range includes {
+				if strings.HasPrefix(c.Name, inc.Namespace+".") {
+					return nil, nil, nil, parser.WithLocation(errors.Errorf("stage name %q conflicts with the stages of INCLUDE namespace %q", c.Name, inc.Namespace), n.Location())
+				}
+			}
+			var nodes []*parser.Node
+			if c.Template != "" {
+				if nodes, err = instantiateStageTemplate(c, stages, stageNodes); err != nil {
+					return nil, nil, nil, parser.WithLocation(err, n.Location())
+				}
+			}
 			stages = append(stages, *c)
+			stageNodes = append(stageNodes, nodes)
 		case Command:
 			stage, err := CurrentStage(stages)
 			if err != nil {
-				return nil, nil, parser.WithLocation(err, n.Location())
+				return nil, nil, nil, parser.WithLocation(err, n.Location())
 			}
 			stage.AddCommand(c)
+			stageNodes[len(stageNodes)-1] = append(stageNodes[len(stageNodes)-1], n)
 		default:
-			return nil, nil, parser.WithLocation(errors.Errorf("%T is not a command type", cmd), n.Location())
+			return nil, nil, nil, parser.WithLocation(errors.Errorf("%T is not a command type", cmd), n.Location())
 		}
 	}
-	return stages, metaArgs, nil
+	for _, st := range stages {
+		if err := validateConditionalBlocks(st.Commands); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return stages, metaArgs, includes, nil
+}
+
+// instantiateStageTemplate sets the base and the commands of a stage from its
+// template. The commands are parsed again from the nodes of the template so
+// the stages don't share any state. It returns the nodes of the commands.
+func instantiateStageTemplate(s *Stage, stages []Stage, stageNodes [][]*parser.Node) ([]*parser.Node, error) {
+	idx, ok := HasStage(stages, s.Template)
+	if !ok {
+		return nil, errors.Errorf("stage template %q not found, templates need to be defined before they are used", s.Template)
+	}
+	template := stages[idx]
+	s.BaseName = template.BaseName
+	if s.Platform == "" {
+		s.Platform = template.Platform
+	}
+	s.TemplateArgs = append(slices.Clone(template.TemplateArgs), s.TemplateArgs...)
+	for _, node := range stageNodes[idx] {
+		// warnings have already been reported for the template
+		cmd, err := ParseCommand(node)
+		if err != nil {
+			return nil, err
+		}
+		s.AddCommand(cmd)
+	}
+	return slices.Clone(stageNodes[idx]), nil
 }
 
 func parseKvps(args []string, cmdName string) (KeyValuePairs, error) {
@@ -403,22 +485,61 @@ func parseFrom(req parseRequest) (*Stage, error) {
 		return nil, err
 	}
 
+	template, templateArgs, err := parseStageTemplate(req.args[0])
+	if err != nil {
+		return nil, err
+	}
+
 	code := strings.TrimSpace(req.original)
 	return &Stage{
-		BaseName:   req.args[0],
-		OrigCmd:    req.command,
-		Name:       stageName,
-		SourceCode: code,
-		Commands:   []Command{},
-		Platform:   flPlatform.Value,
-		Location:   req.location,
-		Comments:   req.comments,
-		DocComment: getDocComment(req.comments, stageName),
+		BaseName:     req.args[0],
+		OrigCmd:      req.command,
+		Name:         stageName,
+		SourceCode:   code,
+		Commands:     []Command{},
+		Platform:     flPlatform.Value,
+		Template:     template,
+		TemplateArgs: templateArgs,
+		Location:     req.location,
+		Comments:     req.comments,
+		DocComment:   getDocComment(req.comments, stageName),
 	}, nil
 }
 
+var stageTemplateRef = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9-_.]*)\((.*)\)$`)
+
+// parseStageTemplate parses the template(KEY=value,...) syntax of FROM. It
+// returns an empty template name if the base isn't a stage template.
+func parseStageTemplate(base string) (string, KeyValuePairs, error) {
+	m := stageTemplateRef.FindStringSubmatch(base)
+	if m == nil {
+		return "", nil, nil
+	}
+	template := strings.ToLower(m[1])
+	if m[2] == "" {
+		return template, nil, nil
+	}
+	fields, err := csvvalue.Fields(m[2], nil)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "failed to parse args for stage template %s", m[1])
+	}
+	args := make(KeyValuePairs, 0, len(fields))
+	for _, field := range fields {
+		k, v, ok := strings.Cut(field, "=")
+		if !ok || k == "" {
+			return "", nil, errors.Errorf("invalid arg %q for stage template %s, expected KEY=value", field, m[1])
+		}
+		args = append(args, KeyValuePair{Key: k, Value: v})
+	}
+	return template, args, nil
+}
+
 var validStageName = regexp.MustCompile("^[a-z][a-z0-9-_.]*$")
 
+// validIncludeNamespace doesn't allow dots so the namespace can be
+// separated from the names of the included stages.
+var validIncludeNamespace = regexp.MustCompile("^[a-z][a-z0-9-_]*$")
+
 func parseBuildStageName(args []string) (stageName string, err error) {
 	switch {
 	case len(args) == 3 && strings.EqualFold(args[1], "as"):
@@ -433,6 +554,108 @@ func parseBuildStageName(args []string) (stageName string, err error) {
 	return stageName, nil
 }
 
+func parseInclude(req parseRequest) (*IncludeCommand, error) {
+	if len(req.args) != 3 || !strings.EqualFold(req.args[1], "as") {
+		return nil, errors.New("INCLUDE requires a source and a namespace: INCLUDE <source> AS <namespace>")
+	}
+	if err := req.flags.Parse(); err != nil {
+		return nil, err
+	}
+	namespace := strings.ToLower(req.args[2])
+	if !validIncludeNamespace.MatchString(namespace) {
+		return nil, errors.Errorf("invalid namespace for INCLUDE: %q, namespace can't start with a number or contain symbols", req.args[2])
+	}
+	return &IncludeCommand{
+		Source:          req.args[0],
+		Namespace:       namespace,
+		withNameAndCode: newWithNameAndCode(req),
+	}, nil
+}
+
+func parseExport(req parseRequest) (*ExportCommand, error) {
+	if len(req.args) != 2 {
+		return nil, errors.New("EXPORT requires a source and a name: EXPORT <src> <name>")
+	}
+	if err := req.flags.Parse(); err != nil {
+		return nil, err
+	}
+	name := strings.ToLower(req.args[1])
+	if !validStageName.MatchString(name) {
+		return nil, errors.Errorf("invalid name for EXPORT: %q, name can't start with a number or contain symbols", req.args[1])
+	}
+	return &ExportCommand{
+		Source:          req.args[0],
+		Artifact:        name,
+		withNameAndCode: newWithNameAndCode(req),
+	}, nil
+}
+
+func parseIf(req parseRequest) (*IfCommand, error) {
+	if err := req.flags.Parse(); err != nil {
+		return nil, err
+	}
+	if len(req.args) != 1 && (len(req.args) != 3 || (req.args[1] != "==" && req.args[1] != "!=")) {
+		return nil, errors.New("IF requires a condition: IF <value>, IF <value> == <value> or IF <value> != <value>")
+	}
+	return &IfCommand{
+		Condition:       req.args,
+		withNameAndCode: newWithNameAndCode(req),
+	}, nil
+}
+
+func parseElse(req parseRequest) (*ElseCommand, error) {
+	if len(req.args) != 0 {
+		return nil, errTooManyArguments("ELSE")
+	}
+	if err := req.flags.Parse(); err != nil {
+		return nil, err
+	}
+	return &ElseCommand{withNameAndCode: newWithNameAndCode(req)}, nil
+}
+
+func parseEndIf(req parseRequest) (*EndIfCommand, error) {
+	if len(req.args) != 0 {
+		return nil, errTooManyArguments("ENDIF")
+	}
+	if err := req.flags.Parse(); err != nil {
+		return nil, err
+	}
+	return &EndIfCommand{withNameAndCode: newWithNameAndCode(req)}, nil
+}
+
+// validateConditionalBlocks checks that every IF block of the commands of a
+// stage has at most one ELSE and is closed with ENDIF.
+func validateConditionalBlocks(cmds []Command) error {
+	type block struct {
+		cmd     *IfCommand
+		hasElse bool
+	}
+	var blocks []block
+	for _, cmd := range cmds {
+		switch c := cmd.(type) {
+		case *IfCommand:
+			blocks = append(blocks, block{cmd: c})
+		case *ElseCommand:
+			if len(blocks) == 0 {
+				return parser.WithLocation(errors.New("ELSE without matching IF"), c.Location())
+			}
+			if blocks[len(blocks)-1].hasElse {
+				return parser.WithLocation(errors.New("IF can only have one ELSE"), c.Location())
+			}
+			blocks[len(blocks)-1].hasElse = true
+		case *EndIfCommand:
+			if len(blocks) == 0 {
+				return parser.WithLocation(errors.New("ENDIF without matching IF"), c.Location())
+			}
+			blocks = blocks[:len(blocks)-1]
+		}
+	}
+	if len(blocks) > 0 {
+		return parser.WithLocation(errors.New("IF without matching ENDIF, IF blocks need to be closed in the same stage"), blocks[len(blocks)-1].cmd.Location())
+	}
+	return nil
+}
+
 func parseOnBuild(req parseRequest) (*OnbuildCommand, error) {
 	if len(req.args) == 0 {
 		return nil, errAtLeastOneArgument("ONBUILD")
@@ -445,7 +668,7 @@ func parseOnBuild(req parseRequest) (*OnbuildCommand, error) {
 	switch strings.ToUpper(triggerInstruction) {
 	case "ONBUILD":
 		return nil, errors.New("Chaining ONBUILD via `ONBUILD ONBUILD` isn't allowed")
-	case "MAINTAINER", "FROM":
+	case "MAINTAINER", "FROM", "INCLUDE", "IF", "ELSE", "ENDIF", "EXPORT":
 		return nil, errors.Errorf("%s isn't allowed as an ONBUILD trigger", triggerInstruction)
 	}
 
@@ -272,6 +272,211 @@ func TestRunCmdFlagsUsed(t *testing.T) {
 	require.Equal(t, []string{"mount"}, c.(*RunCommand).FlagsUsed)
 }
 
+func TestRunOverlayMount(t *testing.T) {
+	expand := func(word string) (string, error) {
+		return strings.ReplaceAll(word, "$TYPE", "overlay"), nil
+	}
+	parseRun := func(dockerfile string) (*RunCommand, error) {
+		ast, err := parser.Parse(strings.NewReader(dockerfile))
+		require.NoError(t, err)
+		c, err := ParseInstruction(ast.AST.Children[0])
+		if err != nil {
+			return nil, err
+		}
+		run := c.(*RunCommand)
+		return run, run.Expand(expand)
+	}
+
+	run, err := parseRun("RUN --mount=type=$TYPE,from=src,target=/src,output=Gen make")
+	require.NoError(t, err)
+	mounts := GetMounts(run)
+	require.Len(t, mounts, 1)
+	require.Equal(t, MountTypeOverlay, mounts[0].Type)
+	require.Equal(t, "src", mounts[0].From)
+	require.Equal(t, "gen", mounts[0].Output)
+	require.False(t, mounts[0].ReadOnly)
+
+	for _, tc := range []struct {
+		dockerfile    string
+		expectedError string
+	}{
+		{"RUN --mount=type=overlay,target=/src,ro true", "overlay mount can't be read-only"},
+		{"RUN --mount=type=bind,target=/src,output=gen true", "output not allowed for \"bind\" type mounts"},
+		{"RUN --mount=type=overlay,target=/src,output=1gen true", "invalid output name \"1gen\""},
+		{"RUN --mount=type=overlay,target=/src,output=$NAME true", "'output' doesn't support variable expansion"},
+	} {
+		_, err := parseRun(tc.dockerfile)
+		require.ErrorContains(t, err, tc.expectedError, tc.dockerfile)
+	}
+}
+
+func TestParseExport(t *testing.T) {
+	ast, err := parser.Parse(strings.NewReader("EXPORT /out/report.xml Test-Report"))
+	require.NoError(t, err)
+	c, err := ParseInstruction(ast.AST.Children[0])
+	require.NoError(t, err)
+	export, ok := c.(*ExportCommand)
+	require.True(t, ok)
+	require.Equal(t, "/out/report.xml", export.Source)
+	require.Equal(t, "test-report", export.Artifact)
+
+	for _, tc := range []struct {
+		dockerfile    string
+		expectedError string
+	}{
+		{"EXPORT /out", "EXPORT requires a source and a name"},
+		{"EXPORT /out a b", "EXPORT requires a source and a name"},
+		{"EXPORT /out 1report", "invalid name for EXPORT: \"1report\""},
+		{"ONBUILD EXPORT /out report", "EXPORT isn't allowed as an ONBUILD trigger"},
+	} {
+		ast, err := parser.Parse(strings.NewReader(tc.dockerfile))
+		require.NoError(t, err)
+		_, err = ParseInstruction(ast.AST.Children[0])
+		require.ErrorContains(t, err, tc.expectedError, tc.dockerfile)
+	}
+}
+
+func TestParseWithIncludes(t *testing.T) {
+	dockerfile := `ARG VERSION=1
+INCLUDE ./common/Dockerfile AS Common
+INCLUDE https://github.com/moby/buildkit.git#master:Dockerfile AS bk
+FROM common.base
+RUN --mount=from=bk.gobuild-base,target=/src true
+`
+	ast, err := parser.Parse(strings.NewReader(dockerfile))
+	require.NoError(t, err)
+
+	stages, metaArgs, includes, err := ParseWithIncludes(ast.AST, nil)
+	require.NoError(t, err)
+	require.Len(t, stages, 1)
+	require.Len(t, metaArgs, 1)
+	require.Len(t, includes, 2)
+	require.Equal(t, "./common/Dockerfile", includes[0].Source)
+	require.Equal(t, "common", includes[0].Namespace)
+	require.Equal(t, "https://github.com/moby/buildkit.git#master:Dockerfile", includes[1].Source)
+	require.Equal(t, "bk", includes[1].Namespace)
+
+	_, _, err = Parse(ast.AST, nil)
+	require.ErrorContains(t, err, "INCLUDE is not supported")
+
+	for _, tc := range []struct {
+		dockerfile    string
+		expectedError string
+	}{
+		{"INCLUDE ./Dockerfile\nFROM scratch", "INCLUDE requires a source and a namespace"},
+		{"INCLUDE ./Dockerfile FOR common\nFROM scratch", "INCLUDE requires a source and a namespace"},
+		{"INCLUDE ./Dockerfile AS common.base\nFROM scratch", "invalid namespace for INCLUDE"},
+		{"INCLUDE ./Dockerfile AS 1common\nFROM scratch", "invalid namespace for INCLUDE"},
+		{"INCLUDE ./a/Dockerfile AS common\nINCLUDE ./b/Dockerfile AS COMMON\nFROM scratch", "duplicate INCLUDE namespace \"common\""},
+		{"INCLUDE ./Dockerfile AS common\nFROM scratch AS Common.base", "stage name \"common.base\" conflicts with the stages of INCLUDE namespace \"common\""},
+		{"FROM scratch\nINCLUDE ./Dockerfile AS common", "INCLUDE is only allowed before the first FROM"},
+		{"FROM scratch\nONBUILD INCLUDE ./Dockerfile AS common", "INCLUDE isn't allowed as an ONBUILD trigger"},
+	} {
+		ast, err := parser.Parse(strings.NewReader(tc.dockerfile))
+		require.NoError(t, err)
+		_, _, _, err = ParseWithIncludes(ast.AST, nil)
+		require.ErrorContains(t, err, tc.expectedError, tc.dockerfile)
+	}
+}
+
+func TestParseStageTemplate(t *testing.T) {
+	dockerfile := `FROM --platform=$BUILDPLATFORM golang:alpine AS builder
+ARG VARIANT
+RUN echo $VARIANT > /variant
+
+FROM builder(VARIANT=foo) AS foo-build
+FROM --platform=linux/arm64 builder("VARIANT=bar,baz",EXTRA=1) AS bar-build
+RUN true
+FROM foo-build(EXTRA=2) AS foo-extra
+`
+	ast, err := parser.Parse(strings.NewReader(dockerfile))
+	require.NoError(t, err)
+
+	stages, _, err := Parse(ast.AST, nil)
+	require.NoError(t, err)
+	require.Len(t, stages, 4)
+
+	require.Empty(t, stages[0].Template)
+
+	foo := stages[1]
+	require.Equal(t, "builder", foo.Template)
+	require.Equal(t, KeyValuePairs{{Key: "VARIANT", Value: "foo"}}, foo.TemplateArgs)
+	require.Equal(t, "golang:alpine", foo.BaseName)
+	require.Equal(t, "$BUILDPLATFORM", foo.Platform)
+	require.Len(t, foo.Commands, 2)
+	// commands are not shared with the template
+	require.NotSame(t, stages[0].Commands[1], foo.Commands[1])
+
+	bar := stages[2]
+	require.Equal(t, KeyValuePairs{{Key: "VARIANT", Value: "bar,baz"}, {Key: "EXTRA", Value: "1"}}, bar.TemplateArgs)
+	require.Equal(t, "linux/arm64", bar.Platform)
+	require.Len(t, bar.Commands, 3)
+
+	extra := stages[3]
+	require.Equal(t, "foo-build", extra.Template)
+	require.Equal(t, KeyValuePairs{{Key: "VARIANT", Value: "foo"}, {Key: "EXTRA", Value: "2"}}, extra.TemplateArgs)
+	require.Equal(t, "golang:alpine", extra.BaseName)
+	require.Len(t, extra.Commands, 2)
+
+	for _, tc := range []struct {
+		dockerfile    string
+		expectedError string
+	}{
+		{"FROM builder(VARIANT=foo)", "stage template \"builder\" not found"},
+		{"FROM builder(VARIANT=foo) AS a\nFROM alpine AS builder", "stage template \"builder\" not found"},
+		{"FROM alpine AS builder\nFROM builder(VARIANT)", "invalid arg \"VARIANT\" for stage template builder"},
+		{"FROM alpine AS builder\nFROM builder(=foo)", "invalid arg \"=foo\" for stage template builder"},
+	} {
+		ast, err := parser.Parse(strings.NewReader(tc.dockerfile))
+		require.NoError(t, err)
+		_, _, err = Parse(ast.AST, nil)
+		require.ErrorContains(t, err, tc.expectedError, tc.dockerfile)
+	}
+}
+
+func TestParseConditionalBlocks(t *testing.T) {
+	dockerfile := `FROM alpine
+IF $TARGETARCH == arm64
+RUN true
+ELSE
+IF $VARIANT
+RUN false
+ENDIF
+ENDIF
+`
+	ast, err := parser.Parse(strings.NewReader(dockerfile))
+	require.NoError(t, err)
+
+	stages, _, err := Parse(ast.AST, nil)
+	require.NoError(t, err)
+	require.Len(t, stages, 1)
+	require.Len(t, stages[0].Commands, 7)
+	require.Equal(t, []string{"$TARGETARCH", "==", "arm64"}, stages[0].Commands[0].(*IfCommand).Condition)
+	require.Equal(t, []string{"$VARIANT"}, stages[0].Commands[3].(*IfCommand).Condition)
+
+	for _, tc := range []struct {
+		dockerfile    string
+		expectedError string
+	}{
+		{"FROM alpine\nIF\nENDIF", "IF requires a condition"},
+		{"FROM alpine\nIF $A = b\nENDIF", "IF requires a condition"},
+		{"FROM alpine\nIF $A == b c\nENDIF", "IF requires a condition"},
+		{"FROM alpine\nIF $A\nELSE foo\nENDIF", "Bad input to ELSE, too many arguments"},
+		{"FROM alpine\nIF $A\nRUN true", "IF without matching ENDIF"},
+		{"FROM alpine\nIF $A\nFROM alpine\nENDIF", "IF without matching ENDIF"},
+		{"FROM alpine\nENDIF", "ENDIF without matching IF"},
+		{"FROM alpine\nELSE\nENDIF", "ELSE without matching IF"},
+		{"FROM alpine\nIF $A\nELSE\nELSE\nENDIF", "IF can only have one ELSE"},
+		{"FROM alpine\nONBUILD IF $A", "IF isn't allowed as an ONBUILD trigger"},
+		{"IF $A\nFROM alpine", "no build stage in current context"},
+	} {
+		ast, err := parser.Parse(strings.NewReader(tc.dockerfile))
+		require.NoError(t, err)
+		_, _, err = Parse(ast.AST, nil)
+		require.ErrorContains(t, err, tc.expectedError, tc.dockerfile)
+	}
+}
+
 func BenchmarkParseBuildStageName(b *testing.B) {
 	b.ReportAllocs()
 	stageNames := []string{"STAGE_NAME", "StageName", "St4g3N4m3"}
@@ -0,0 +1,259 @@
+package linter
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+// TextEdit replaces the text of Range in the Dockerfile with NewText. Lines
+// are 1-based, characters are 0-based byte offsets in the line and the end of
+// the range is exclusive.
+type TextEdit struct {
+	Range   parser.Range
+	NewText string
+}
+
+// FixFunc returns the edits of the Dockerfile that resolve a rule violation.
+type FixFunc func(src *Source) []TextEdit
+
+// Source is the Dockerfile that fixes are created for.
+type Source struct {
+	lines       []string
+	escapeToken rune
+	// lower is set if most instructions of the Dockerfile use lowercase
+	// keywords. New keywords are written in the same casing.
+	lower bool
+}
+
+func NewSource(dt []byte, res *parser.Result) *Source {
+	lines := strings.Split(string(dt), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+	var lowerCount, upperCount int
+	for _, node := range res.AST.Children {
+		fields := strings.Fields(node.Original)
+		if len(fields) == 0 {
+			continue
+		}
+		switch kw := fields[0]; kw {
+		case strings.ToLower(kw):
+			lowerCount++
+		case strings.ToUpper(kw):
+			upperCount++
+		}
+	}
+	return &Source{lines: lines, escapeToken: res.EscapeToken, lower: lowerCount > upperCount}
+}
+
+// Line returns the line n of the Dockerfile without the line ending.
+func (s *Source) Line(n int) (string, bool) {
+	if n < 1 || n > len(s.lines) {
+		return "", false
+	}
+	return s.lines[n-1], true
+}
+
+// instruction returns the tokens of an instruction that is not split over
+// multiple lines if its keyword is one of keywords.
+func (s *Source) instruction(location []parser.Range, keywords ...string) (int, string, []token, bool) {
+	if len(location) != 1 || location[0].Start.Line != location[0].End.Line {
+		return 0, "", nil, false
+	}
+	n := location[0].Start.Line
+	line, ok := s.Line(n)
+	if !ok {
+		return 0, "", nil, false
+	}
+	toks := tokens(line)
+	if len(toks) == 0 || !slices.ContainsFunc(keywords, func(k string) bool {
+		return strings.EqualFold(k, toks[0].text)
+	}) {
+		return 0, "", nil, false
+	}
+	return n, line, toks, true
+}
+
+type token struct {
+	start, end int
+	text       string
+}
+
+func tokens(line string) []token {
+	var out []token
+	start := -1
+	for i, r := range line {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				out = append(out, token{start: start, end: i, text: line[start:i]})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		out = append(out, token{start: start, end: len(line), text: line[start:]})
+	}
+	return out
+}
+
+func edit(line, start, end int, newText string) TextEdit {
+	return TextEdit{
+		Range: parser.Range{
+			Start: parser.Position{Line: line, Character: start},
+			End:   parser.Position{Line: line, Character: end},
+		},
+		NewText: newText,
+	}
+}
+
+// matchCase returns s in the case of the keyword ref.
+func matchCase(s, ref string) string {
+	if ref == strings.ToLower(ref) {
+		return strings.ToLower(s)
+	}
+	return strings.ToUpper(s)
+}
+
+// FixKeywordCasing changes the keyword of the instruction to lowercase or
+// uppercase.
+func FixKeywordCasing(location []parser.Range, lower bool) FixFunc {
+	return func(src *Source) []TextEdit {
+		if len(location) == 0 {
+			return nil
+		}
+		n := location[0].Start.Line
+		line, ok := src.Line(n)
+		if !ok {
+			return nil
+		}
+		toks := tokens(line)
+		if len(toks) == 0 {
+			return nil
+		}
+		kw := toks[0]
+		text := strings.ToUpper(kw.text)
+		if lower {
+			text = strings.ToLower(kw.text)
+		}
+		return []TextEdit{edit(n, kw.start, kw.end, text)}
+	}
+}
+
+// FixFromAsCasing changes the casing of the AS keyword of a FROM instruction
+// to match the casing of the FROM keyword.
+func FixFromAsCasing(location []parser.Range) FixFunc {
+	return func(src *Source) []TextEdit {
+		n, _, toks, ok := src.instruction(location, "from")
+		if !ok {
+			return nil
+		}
+		// FROM [--flags] image AS name
+		if len(toks) < 4 || !strings.EqualFold(toks[len(toks)-2].text, "as") {
+			return nil
+		}
+		as := toks[len(toks)-2]
+		return []TextEdit{edit(n, as.start, as.end, matchCase(as.text, toks[0].text))}
+	}
+}
+
+// FixMaintainer replaces a MAINTAINER instruction with the equivalent LABEL
+// instruction.
+func FixMaintainer(location []parser.Range) FixFunc {
+	return func(src *Source) []TextEdit {
+		n, line, toks, ok := src.instruction(location, "maintainer")
+		if !ok || len(toks) < 2 {
+			return nil
+		}
+		value := strings.TrimSpace(line[toks[1].start:])
+		quoted, ok := src.quote(value)
+		if !ok {
+			return nil
+		}
+		label := "LABEL"
+		if src.lower {
+			label = "label"
+		}
+		label += " org.opencontainers.image.authors=" + quoted
+		return []TextEdit{edit(n, toks[0].start, len(line), label)}
+	}
+}
+
+// FixLegacyKeyValue changes the legacy "KEY value" format of an ENV or LABEL
+// instruction to "KEY=value".
+func FixLegacyKeyValue(location []parser.Range) FixFunc {
+	return func(src *Source) []TextEdit {
+		n, line, toks, ok := src.instruction(location, "env", "label")
+		if !ok || len(toks) < 3 || strings.Contains(toks[1].text, "=") {
+			return nil
+		}
+		value := strings.TrimRightFunc(line[toks[2].start:], unicode.IsSpace)
+		end := toks[2].start + len(value)
+		if len(toks) > 3 {
+			var ok bool
+			if value, ok = src.quote(value); !ok {
+				return nil
+			}
+		}
+		return []TextEdit{edit(n, toks[1].end, end, "="+value)}
+	}
+}
+
+var plainWord = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]+$`)
+
+// shellKeywords are the reserved words of the shell that start a compound
+// command.
+var shellKeywords = []string{"case", "for", "function", "if", "select", "time", "until", "while"}
+
+// shellBuiltins are the commands run by the shell without looking up an
+// executable.
+var shellBuiltins = map[string]struct{}{
+	".": {}, ":": {}, "[": {}, "cd": {}, "command": {}, "echo": {}, "eval": {},
+	"exec": {}, "exit": {}, "export": {}, "false": {}, "if": {}, "printf": {},
+	"read": {}, "set": {}, "source": {}, "test": {}, "true": {}, "type": {},
+	"unset": {},
+}
+
+// IsShellBuiltin reports whether the command name is run by the shell itself.
+func IsShellBuiltin(name string) bool {
+	_, ok := shellBuiltins[name]
+	return ok
+}
+
+// FixJSONArgs changes a CMD or ENTRYPOINT instruction in shell form to the
+// JSON form. Commands that use any shell features are not changed, including
+// variable assignments before the command, reserved words and builtins.
+func FixJSONArgs(location []parser.Range) FixFunc {
+	return func(src *Source) []TextEdit {
+		n, _, toks, ok := src.instruction(location, "cmd", "entrypoint")
+		if !ok || len(toks) < 2 {
+			return nil
+		}
+		if strings.Contains(toks[1].text, "=") || slices.Contains(shellKeywords, toks[1].text) || IsShellBuiltin(toks[1].text) {
+			return nil
+		}
+		args := make([]string, 0, len(toks)-1)
+		for _, t := range toks[1:] {
+			if !plainWord.MatchString(t.text) {
+				return nil
+			}
+			args = append(args, strconv.Quote(t.text))
+		}
+		return []TextEdit{edit(n, toks[1].start, toks[len(toks)-1].end, "["+strings.Join(args, ", ")+"]")}
+	}
+}
+
+// quote returns value in double quotes. Values with quotes, escape characters
+// or variables are not quoted, as quoting could change their meaning.
+func (s *Source) quote(value string) (string, bool) {
+	if strings.ContainsAny(value, `"'$`+string(s.escapeToken)) {
+		return "", false
+	}
+	return `"` + value + `"`, true
+}
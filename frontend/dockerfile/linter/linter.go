@@ -16,7 +16,11 @@ type Config struct {
 	ReturnAsError     bool
 	SkipAll           bool
 	SkipRules         []string
+	Policy            *Policy
 	Warn              LintWarnFunc
+	// WarnWithFix is called instead of Warn with the edits that fix the
+	// violation. Warn is used if it is nil.
+	WarnWithFix LintFixFunc
 }
 
 type Linter struct {
@@ -26,7 +30,14 @@ type Linter struct {
 	ReturnAsError     bool
 	SkipAll           bool
 	SkippedRules      map[string]struct{}
+	Policy            *Policy
 	Warn              LintWarnFunc
+	WarnWithFix       LintFixFunc
+	// Source is the Dockerfile the fixes of the rules are created for. No
+	// fixes are created if it is nil.
+	Source *Source
+	// policyErrors are the called policy rules with error severity.
+	policyErrors *[]string
 }
 
 func New(config *Config) *Linter {
@@ -34,7 +45,10 @@ func New(config *Config) *Linter {
 		SkippedRules:      map[string]struct{}{},
 		ExperimentalRules: map[string]struct{}{},
 		CalledRules:       new([]string),
+		policyErrors:      new([]string),
+		Policy:            config.Policy,
 		Warn:              config.Warn,
+		WarnWithFix:       config.WarnWithFix,
 	}
 	toret.SkipAll = config.SkipAll
 	toret.ExperimentalAll = config.ExperimentalAll
@@ -49,25 +63,55 @@ func New(config *Config) *Linter {
 }
 
 func (lc *Linter) Run(rule LinterRuleI, location []parser.Range, txt ...string) {
-	if lc == nil || lc.Warn == nil || rule.IsDeprecated() {
+	lc.RunWithFix(rule, location, nil, txt...)
+}
+
+// RunWithFix runs the rule like Run. The fix function returns the edits of
+// the Dockerfile that resolve the violation. It is only called if the rule is
+// enabled and may return nil if the violation can't be fixed automatically.
+func (lc *Linter) RunWithFix(rule LinterRuleI, location []parser.Range, fix FixFunc, txt ...string) {
+	if !lc.IsEnabled(rule) {
 		return
 	}
 
+	rulename := rule.RuleName()
+	*lc.CalledRules = append(*lc.CalledRules, rulename)
+	// the severity belongs to the policy rule, a built-in rule with the same
+	// name doesn't fail the build
+	if r, ok := rule.(*PolicyRule); ok && r.Severity == SeverityError {
+		*lc.policyErrors = append(*lc.policyErrors, rulename)
+	}
+	if lc.WarnWithFix == nil {
+		rule.Run(lc.Warn, location, txt...)
+		return
+	}
+	var edits []TextEdit
+	if fix != nil && lc.Source != nil {
+		edits = fix(lc.Source)
+	}
+	if r, ok := rule.(linterRuleWithFix); ok {
+		r.RunWithFix(lc.WarnWithFix, location, edits, txt...)
+		return
+	}
+	rule.Run(func(rulename, description, url, fmtmsg string, location []parser.Range) {
+		lc.WarnWithFix(rulename, description, url, fmtmsg, location, edits)
+	}, location, txt...)
+}
+
+// IsEnabled returns true if violations of the rule are reported. It can be
+// used to skip checks that are expensive to run.
+func (lc *Linter) IsEnabled(rule LinterRuleI) bool {
+	if lc == nil || (lc.Warn == nil && lc.WarnWithFix == nil) || rule.IsDeprecated() {
+		return false
+	}
+
 	rulename := rule.RuleName()
 	if rule.IsExperimental() {
 		_, experimentalOk := lc.ExperimentalRules[rulename]
-		if !lc.ExperimentalAll && !experimentalOk {
-			return
-		}
-	} else {
-		_, skipOk := lc.SkippedRules[rulename]
-		if lc.SkipAll || skipOk {
-			return
-		}
+		return lc.ExperimentalAll || experimentalOk
 	}
-
-	*lc.CalledRules = append(*lc.CalledRules, rulename)
-	rule.Run(lc.Warn, location, txt...)
+	_, skipOk := lc.SkippedRules[rulename]
+	return !lc.SkipAll && !skipOk
 }
 
 func (lc *Linter) WithMergedConfig(other *Config) *Linter {
@@ -121,17 +165,22 @@ func (lc *Linter) WithMergedConfigFromComments(comments []string) *Linter {
 }
 
 func (lc *Linter) Error() error {
-	if lc == nil || !lc.ReturnAsError {
-		return nil
-	}
-	if len(*lc.CalledRules) == 0 {
+	if lc == nil || len(*lc.CalledRules) == 0 {
 		return nil
 	}
 	var rules []string
 	uniqueRules := map[string]struct{}{}
-	for _, r := range *lc.CalledRules {
+	if lc.ReturnAsError {
+		for _, r := range *lc.CalledRules {
+			uniqueRules[r] = struct{}{}
+		}
+	}
+	for _, r := range *lc.policyErrors {
 		uniqueRules[r] = struct{}{}
 	}
+	if len(uniqueRules) == 0 {
+		return nil
+	}
 	for r := range uniqueRules {
 		rules = append(rules, r)
 	}
@@ -145,6 +194,12 @@ type LinterRuleI interface {
 	IsExperimental() bool
 }
 
+// linterRuleWithFix is implemented by the rules that can report the edits
+// fixing a violation.
+type linterRuleWithFix interface {
+	RunWithFix(warn LintFixFunc, location []parser.Range, edits []TextEdit, txt ...string)
+}
+
 type LinterRule[F any] struct {
 	Name         string
 	Description  string
@@ -166,6 +221,14 @@ func (rule *LinterRule[F]) Run(warn LintWarnFunc, location []parser.Range, txt .
 	warn(rule.Name, rule.Description, rule.URL, short, location)
 }
 
+func (rule *LinterRule[F]) RunWithFix(warn LintFixFunc, location []parser.Range, edits []TextEdit, txt ...string) {
+	if len(txt) == 0 {
+		txt = []string{rule.Description}
+	}
+	short := strings.Join(txt, " ")
+	warn(rule.Name, rule.Description, rule.URL, short, location, edits)
+}
+
 func (rule *LinterRule[F]) IsDeprecated() bool {
 	return rule.Deprecated
 }
@@ -184,6 +247,10 @@ func LintFormatShort(rulename, msg string, line int) string {
 
 type LintWarnFunc func(rulename, description, url, fmtmsg string, location []parser.Range)
 
+// LintFixFunc is like LintWarnFunc but also receives the edits of the
+// Dockerfile that fix the violation.
+type LintFixFunc func(rulename, description, url, fmtmsg string, location []parser.Range, edits []TextEdit)
+
 func ParseLintOptions(checkStr string) (*Config, error) {
 	checkStr = strings.TrimSpace(checkStr)
 	if checkStr == "" {
@@ -0,0 +1,42 @@
+package linter
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWithFix(t *testing.T) {
+	t.Parallel()
+
+	loc := []parser.Range{{Start: parser.Position{Line: 1}, End: parser.Position{Line: 1}}}
+	edits := []TextEdit{{Range: loc[0], NewText: "FROM"}}
+	fix := func(*Source) []TextEdit { return edits }
+
+	// rules are reported through Warn if WarnWithFix is not set
+	var warned []string
+	lc := New(&Config{
+		Warn: func(rulename, _, _, _ string, _ []parser.Range) {
+			warned = append(warned, rulename)
+		},
+	})
+	lc.Source = &Source{}
+	lc.RunWithFix(&RuleConsistentInstructionCasing, loc, fix)
+	require.Equal(t, []string{RuleConsistentInstructionCasing.Name}, warned)
+
+	// WarnWithFix receives the edits and replaces Warn
+	var fixed [][]TextEdit
+	lc = New(&Config{
+		Warn: func(string, string, string, string, []parser.Range) {
+			t.Fatal("unexpected call of Warn")
+		},
+		WarnWithFix: func(_, _, _, _ string, _ []parser.Range, edits []TextEdit) {
+			fixed = append(fixed, edits)
+		},
+	})
+	lc.Source = &Source{}
+	lc.RunWithFix(&RuleConsistentInstructionCasing, loc, fix)
+	lc.Run(&RuleConsistentInstructionCasing, loc)
+	require.Equal(t, [][]TextEdit{edits, nil}, fixed)
+}
@@ -0,0 +1,420 @@
+package linter
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/command"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/pkg/errors"
+)
+
+// Severity of a policy rule.
+type Severity string
+
+const (
+	// SeverityWarning reports violations of the rule as warnings.
+	SeverityWarning Severity = "warning"
+	// SeverityError reports violations of the rule as warnings and fails the
+	// build, even if the check options don't return warnings as errors.
+	SeverityError Severity = "error"
+)
+
+var validPolicyRuleName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+// Policy is a set of user-defined rules that are checked in addition to the
+// built-in rules.
+type Policy struct {
+	Rules []*PolicyRule `json:"rules"`
+}
+
+// PolicyRule matches the instructions of a Dockerfile. By default, every
+// instruction that matches the rule is reported. If Require is set, the rule
+// is reported for the target stage if the instructions it runs, including the
+// instructions of the stages and stage templates it is based on, don't
+// satisfy the rule. For instructions whose last occurrence overrides the
+// previous ones, such as USER or CMD, only the last one is matched. For ARG,
+// ENV and LABEL, the last value of each key is matched.
+//
+// Arguments are matched as written in the Dockerfile, without expanding
+// variables. FROM instructions that refer to a previous stage or to scratch
+// and instructions of ONBUILD are not matched.
+type PolicyRule struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	Severity    Severity `json:"severity,omitempty"`
+	// Instruction is the keyword of the matched instructions.
+	Instruction string `json:"instruction"`
+	Require     bool   `json:"require,omitempty"`
+	// Flags match the values of the flags of the instruction. A flag that is
+	// not set is matched as an empty value.
+	Flags map[string]*Pattern `json:"flags,omitempty"`
+	// Key matches the keys of ARG, ENV and LABEL instructions.
+	Key *Pattern `json:"key,omitempty"`
+	// Value matches the values of ARG, ENV and LABEL instructions, the image
+	// of FROM instructions and the arguments of other instructions,
+	// including the content of heredocs.
+	Value *Pattern `json:"value,omitempty"`
+}
+
+// Pattern matches a string if it matches Regexp and doesn't match NotRegexp.
+type Pattern struct {
+	Regexp    string `json:"regexp,omitempty"`
+	NotRegexp string `json:"notRegexp,omitempty"`
+
+	re    *regexp.Regexp
+	notRe *regexp.Regexp
+}
+
+// ParsePolicy parses a policy in JSON format.
+func ParsePolicy(dt []byte) (*Policy, error) {
+	var p Policy
+	dec := json.NewDecoder(bytes.NewReader(dt))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, errors.Wrap(err, "failed to parse check policy")
+	}
+	names := map[string]struct{}{}
+	for i, r := range p.Rules {
+		if r == nil {
+			return nil, errors.Errorf("invalid check policy rule %d", i)
+		}
+		if err := r.init(); err != nil {
+			return nil, errors.Wrapf(err, "invalid check policy rule %q", r.Name)
+		}
+		if _, ok := names[r.Name]; ok {
+			return nil, errors.Errorf("duplicate check policy rule %q", r.Name)
+		}
+		names[r.Name] = struct{}{}
+	}
+	return &p, nil
+}
+
+func (r *PolicyRule) init() error {
+	if !validPolicyRuleName.MatchString(r.Name) {
+		return errors.New("rule name must be alphanumeric and start with a letter")
+	}
+	switch r.Severity {
+	case "":
+		r.Severity = SeverityWarning
+	case SeverityWarning, SeverityError:
+	default:
+		return errors.Errorf("invalid severity %q", r.Severity)
+	}
+	r.Instruction = strings.ToLower(r.Instruction)
+	if _, ok := command.Commands[r.Instruction]; !ok || r.Instruction == command.Onbuild {
+		return errors.Errorf("invalid instruction %q", r.Instruction)
+	}
+	if r.Key != nil && !isKeyValueInstruction(r.Instruction) {
+		return errors.Errorf("key can't be matched for %s instructions", strings.ToUpper(r.Instruction))
+	}
+	for name, p := range r.Flags {
+		if err := p.init(); err != nil {
+			return errors.Wrapf(err, "invalid pattern for flag %q", name)
+		}
+	}
+	if err := r.Key.init(); err != nil {
+		return errors.Wrap(err, "invalid key pattern")
+	}
+	if err := r.Value.init(); err != nil {
+		return errors.Wrap(err, "invalid value pattern")
+	}
+	return nil
+}
+
+func (p *Pattern) init() (err error) {
+	if p == nil {
+		return nil
+	}
+	if p.Regexp == "" && p.NotRegexp == "" {
+		return errors.New("regexp or notRegexp needs to be set")
+	}
+	if p.Regexp != "" {
+		if p.re, err = regexp.Compile(p.Regexp); err != nil {
+			return err
+		}
+	}
+	if p.NotRegexp != "" {
+		if p.notRe, err = regexp.Compile(p.NotRegexp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Pattern) match(s string) bool {
+	if p == nil {
+		return true
+	}
+	return (p.re == nil || p.re.MatchString(s)) && (p.notRe == nil || !p.notRe.MatchString(s))
+}
+
+func (r *PolicyRule) RuleName() string {
+	return r.Name
+}
+
+func (r *PolicyRule) Run(warn LintWarnFunc, location []parser.Range, txt ...string) {
+	warn(r.Name, r.Description, r.URL, r.message(txt), location)
+}
+
+func (r *PolicyRule) RunWithFix(warn LintFixFunc, location []parser.Range, edits []TextEdit, txt ...string) {
+	warn(r.Name, r.Description, r.URL, r.message(txt), location, edits)
+}
+
+func (r *PolicyRule) message(txt []string) string {
+	if len(txt) > 0 {
+		return strings.Join(txt, " ")
+	}
+	return r.Description
+}
+
+func (r *PolicyRule) IsDeprecated() bool {
+	return false
+}
+
+func (r *PolicyRule) IsExperimental() bool {
+	return false
+}
+
+func (r *PolicyRule) matches(node *parser.Node) bool {
+	if !strings.EqualFold(node.Value, r.Instruction) || !r.matchesFlags(node) {
+		return false
+	}
+	if isKeyValueInstruction(r.Instruction) {
+		for _, kv := range keyValues(node) {
+			if r.Key.match(kv[0]) && r.Value.match(kv[1]) {
+				return true
+			}
+		}
+		return false
+	}
+	return r.Value.match(instructionValue(node))
+}
+
+func (r *PolicyRule) matchesFlags(node *parser.Node) bool {
+	for name, p := range r.Flags {
+		values := flagValues(node.Flags, name)
+		if len(values) == 0 {
+			values = []string{""}
+		}
+		if !slices.ContainsFunc(values, p.match) {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfiedBy returns true if the instructions run by a stage satisfy a
+// required rule.
+func (r *PolicyRule) satisfiedBy(nodes []*parser.Node) bool {
+	if isKeyValueInstruction(r.Instruction) {
+		// the last value of each key is effective
+		type keyValue struct {
+			node  *parser.Node
+			value string
+		}
+		var keys []string
+		values := map[string]keyValue{}
+		for _, node := range nodes {
+			if !strings.EqualFold(node.Value, r.Instruction) {
+				continue
+			}
+			for _, kv := range keyValues(node) {
+				if _, ok := values[kv[0]]; !ok {
+					keys = append(keys, kv[0])
+				}
+				values[kv[0]] = keyValue{node: node, value: kv[1]}
+			}
+		}
+		return slices.ContainsFunc(keys, func(k string) bool {
+			kv := values[k]
+			return r.Key.match(k) && r.Value.match(kv.value) && r.matchesFlags(kv.node)
+		})
+	}
+	if isOverridingInstruction(r.Instruction) {
+		for _, node := range slices.Backward(nodes) {
+			if strings.EqualFold(node.Value, r.Instruction) {
+				return r.matches(node)
+			}
+		}
+		return false
+	}
+	return slices.ContainsFunc(nodes, r.matches)
+}
+
+func (r *PolicyRule) detail(stage string) string {
+	if r.Description != "" {
+		return r.Description
+	}
+	keyword := strings.ToUpper(r.Instruction)
+	if r.Require {
+		if stage == "" {
+			return "Stage is missing a required " + keyword + " instruction"
+		}
+		return "Stage " + stage + " is missing a required " + keyword + " instruction"
+	}
+	return keyword + " instruction is not allowed by the check policy"
+}
+
+// CheckPolicy checks the instructions of the Dockerfile against the rules of
+// the policy of the linter. If checkRequired is set, the required
+// instructions are checked for the stage named target, or the last stage if
+// target is empty.
+func (lc *Linter) CheckPolicy(ast *parser.Node, target string, checkRequired bool) {
+	if lc == nil || lc.Policy == nil || ast == nil {
+		return
+	}
+
+	type stage struct {
+		name string
+		from *parser.Node
+		// parent is the index of the previous stage or stage template the
+		// stage is based on, or -1
+		parent   int
+		template bool
+		nodes    []*parser.Node
+	}
+	var stages []*stage
+	names := map[string]int{}
+	for _, node := range ast.Children {
+		if strings.EqualFold(node.Value, command.From) {
+			s := &stage{from: node, parent: -1}
+			if n := node.Next; n != nil && n.Next != nil && strings.EqualFold(n.Next.Value, "as") && n.Next.Next != nil {
+				s.name = strings.ToLower(n.Next.Next.Value)
+			}
+			var isStage bool
+			if node.Next != nil {
+				// stage templates are referenced as template(KEY=value)
+				base, _, isTemplate := strings.Cut(strings.ToLower(node.Next.Value), "(")
+				if idx, ok := names[base]; ok {
+					s.parent = idx
+					s.template = isTemplate
+					isStage = true
+				}
+				isStage = isStage || base == "scratch"
+			}
+			stages = append(stages, s)
+			if s.name != "" {
+				names[s.name] = len(stages) - 1
+			}
+			if isStage {
+				continue
+			}
+		}
+		if len(stages) > 0 {
+			st := stages[len(stages)-1]
+			st.nodes = append(st.nodes, node)
+		}
+		for _, r := range lc.Policy.Rules {
+			if !r.Require && r.matches(node) {
+				lc.Run(r, node.Location(), r.detail(""))
+			}
+		}
+	}
+
+	if !checkRequired || len(stages) == 0 {
+		return
+	}
+	targetStage := stages[len(stages)-1]
+	if target != "" {
+		idx, ok := names[strings.ToLower(target)]
+		if !ok {
+			return
+		}
+		targetStage = stages[idx]
+	}
+
+	// the instructions of the base stages run first. Build arguments are
+	// only inherited from stage templates, as an instance runs the ARG
+	// instructions of its template.
+	nodes := targetStage.nodes
+	for s := targetStage; s.parent >= 0; {
+		inherited := slices.Clone(stages[s.parent].nodes)
+		if !s.template {
+			inherited = slices.DeleteFunc(inherited, func(n *parser.Node) bool {
+				return strings.EqualFold(n.Value, command.Arg)
+			})
+		}
+		nodes = append(inherited, nodes...)
+		s = stages[s.parent]
+	}
+	for _, r := range lc.Policy.Rules {
+		if r.Require && !r.satisfiedBy(nodes) {
+			lc.Run(r, targetStage.from.Location(), r.detail(targetStage.name))
+		}
+	}
+}
+
+// isOverridingInstruction returns true if the last instruction with the
+// keyword overrides the previous ones.
+func isOverridingInstruction(keyword string) bool {
+	switch keyword {
+	case command.User, command.Workdir, command.Cmd, command.Entrypoint, command.Healthcheck, command.StopSignal, command.Shell:
+		return true
+	}
+	return false
+}
+
+func isKeyValueInstruction(keyword string) bool {
+	switch keyword {
+	case command.Arg, command.Env, command.Label:
+		return true
+	}
+	return false
+}
+
+// keyValues returns the key-value pairs of an ARG, ENV or LABEL instruction.
+func keyValues(node *parser.Node) [][2]string {
+	var kvs [][2]string
+	if strings.EqualFold(node.Value, command.Arg) {
+		for n := node.Next; n != nil; n = n.Next {
+			k, v, _ := strings.Cut(n.Value, "=")
+			kvs = append(kvs, [2]string{k, v})
+		}
+		return kvs
+	}
+	// ENV and LABEL are parsed to key, value and separator nodes
+	for n := node.Next; n != nil && n.Next != nil; {
+		kvs = append(kvs, [2]string{n.Value, n.Next.Value})
+		if n.Next.Next == nil {
+			break
+		}
+		n = n.Next.Next.Next
+	}
+	return kvs
+}
+
+// instructionValue returns the arguments of an instruction to match them.
+func instructionValue(node *parser.Node) string {
+	if strings.EqualFold(node.Value, command.From) {
+		if node.Next == nil {
+			return ""
+		}
+		return node.Next.Value
+	}
+	var args []string
+	for n := node.Next; n != nil; n = n.Next {
+		args = append(args, n.Value)
+	}
+	v := strings.Join(args, " ")
+	for _, h := range node.Heredocs {
+		v += "\n" + h.Content
+	}
+	return v
+}
+
+// flagValues returns the values of the flag with the name in flags.
+func flagValues(flags []string, name string) []string {
+	var values []string
+	for _, f := range flags {
+		k, v, _ := strings.Cut(strings.TrimPrefix(f, "--"), "=")
+		if k == name {
+			values = append(values, v)
+		}
+	}
+	return values
+}
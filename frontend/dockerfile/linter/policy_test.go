@@ -0,0 +1,190 @@
+package linter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		policy string
+		err    string
+	}{
+		{`{"rules": [{"name": "Foo", "instruction": "run"}]}`, ""},
+		{`{"rules": [{"name": "foo-bar", "instruction": "RUN"}]}`, "rule name must be alphanumeric"},
+		{`{"rules": [{"name": "Foo", "instruction": "BAR"}]}`, `invalid instruction "bar"`},
+		{`{"rules": [{"name": "Foo", "instruction": "ONBUILD"}]}`, `invalid instruction "onbuild"`},
+		{`{"rules": [{"name": "Foo", "instruction": "RUN", "severity": "fatal"}]}`, `invalid severity "fatal"`},
+		{`{"rules": [{"name": "Foo", "instruction": "RUN", "key": {"regexp": "a"}}]}`, "key can't be matched for RUN instructions"},
+		{`{"rules": [{"name": "Foo", "instruction": "RUN", "value": {}}]}`, "regexp or notRegexp needs to be set"},
+		{`{"rules": [{"name": "Foo", "instruction": "RUN", "flags": {"mount": {"regexp": "("}}}]}`, `invalid pattern for flag "mount"`},
+		{`{"rules": [{"name": "Foo", "instruction": "RUN"}, {"name": "Foo", "instruction": "USER"}]}`, `duplicate check policy rule "Foo"`},
+		{`{"rules": [{"name": "Foo", "instruction": "RUN", "unknown": true}]}`, "unknown field"},
+	} {
+		_, err := ParsePolicy([]byte(tc.policy))
+		if tc.err == "" {
+			require.NoError(t, err, tc.policy)
+		} else {
+			require.ErrorContains(t, err, tc.err, tc.policy)
+		}
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := ParsePolicy([]byte(`{"rules": [
+  {"name": "PinnedBaseImage", "instruction": "FROM", "value": {"notRegexp": "@sha256:"}},
+  {"name": "NoCurlPipeShell", "severity": "error", "instruction": "RUN", "value": {"regexp": "curl[^|]*\\|\\s*sh"}},
+  {"name": "NoHostNetwork", "instruction": "RUN", "flags": {"network": {"regexp": "^host$"}}},
+  {"name": "NoDebugEnv", "instruction": "ENV", "key": {"regexp": "^DEBUG$"}, "value": {"notRegexp": "^0?$"}},
+  {"name": "SourceLabel", "instruction": "LABEL", "require": true, "key": {"regexp": "^org\\.opencontainers\\.image\\.source$"}},
+  {"name": "NonRootUser", "instruction": "USER", "require": true, "value": {"notRegexp": "^(root|0)(:|$)"}}
+]}`))
+	require.NoError(t, err)
+
+	dockerfile := `FROM alpine@sha256:0000000000000000000000000000000000000000000000000000000000000000 AS base
+RUN --network=host apk add curl
+RUN <<EOF
+curl -fsSL https://example.com/install.sh | sh
+EOF
+ENV FOO=bar DEBUG=1
+
+FROM base AS app
+ENV DEBUG=0
+LABEL org.opencontainers.image.source=https://github.com/moby/buildkit
+USER app
+
+FROM scratch AS release
+COPY --from=app / /
+`
+	ast, err := parser.Parse(strings.NewReader(dockerfile))
+	require.NoError(t, err)
+
+	check := func(target string) ([]string, error) {
+		var warnings []string
+		lint := New(&Config{
+			Policy: policy,
+			Warn: func(rulename, _, _, _ string, location []parser.Range) {
+				warnings = append(warnings, fmt.Sprintf("%s:%d", rulename, location[0].Start.Line))
+			},
+		})
+		lint.CheckPolicy(ast.AST, target, true)
+		return warnings, lint.Error()
+	}
+
+	warnings, err := check("")
+	require.Equal(t, []string{"NoHostNetwork:2", "NoCurlPipeShell:3", "NoDebugEnv:6", "SourceLabel:13", "NonRootUser:13"}, warnings)
+	require.ErrorContains(t, err, "lint violation found for rules: NoCurlPipeShell")
+
+	warnings, err = check("app")
+	require.Equal(t, []string{"NoHostNetwork:2", "NoCurlPipeShell:3", "NoDebugEnv:6"}, warnings)
+	require.Error(t, err)
+
+	warnings, _ = check("base")
+	require.Equal(t, []string{"NoHostNetwork:2", "NoCurlPipeShell:3", "NoDebugEnv:6", "SourceLabel:1", "NonRootUser:1"}, warnings)
+}
+
+func TestCheckPolicyBuiltinRuleName(t *testing.T) {
+	t.Parallel()
+
+	policy, err := ParsePolicy([]byte(`{"rules": [
+  {"name": "FromAsCasing", "severity": "error", "instruction": "USER", "value": {"regexp": "^root$"}}
+]}`))
+	require.NoError(t, err)
+
+	ast, err := parser.Parse(strings.NewReader("FROM alpine as base\nUSER app\n"))
+	require.NoError(t, err)
+
+	// the severity of a policy rule doesn't apply to the built-in rule with
+	// the same name
+	lint := New(&Config{
+		Policy: policy,
+		Warn:   func(string, string, string, string, []parser.Range) {},
+	})
+	lint.Run(&RuleFromAsCasing, nil)
+	lint.CheckPolicy(ast.AST, "", true)
+	require.NoError(t, lint.Error())
+
+	ast, err = parser.Parse(strings.NewReader("FROM alpine AS base\nUSER root\n"))
+	require.NoError(t, err)
+	lint.CheckPolicy(ast.AST, "", true)
+	require.ErrorContains(t, lint.Error(), "lint violation found for rules: FromAsCasing")
+}
+
+func TestCheckPolicyRequire(t *testing.T) {
+	t.Parallel()
+
+	policy, err := ParsePolicy([]byte(`{"rules": [
+  {"name": "NonRootUser", "instruction": "USER", "require": true, "value": {"notRegexp": "^(root|0)(:|$)"}},
+  {"name": "SourceLabel", "instruction": "LABEL", "require": true, "key": {"regexp": "^source$"}, "value": {"regexp": "^https://"}},
+  {"name": "VersionArg", "instruction": "ARG", "require": true, "key": {"regexp": "^VERSION$"}}
+]}`))
+	require.NoError(t, err)
+
+	dockerfile := `FROM alpine AS base
+ARG VERSION
+LABEL source=https://example.com
+USER app
+
+FROM base AS final
+ARG VERSION
+
+FROM base AS root
+ARG VERSION
+USER app
+USER root
+
+FROM base AS relabeled
+ARG VERSION
+LABEL source=unknown
+
+FROM base AS template
+ARG VERSION=1
+
+FROM template(VERSION=2) AS instance
+USER 0
+
+FROM base AS noarg
+`
+	ast, err := parser.Parse(strings.NewReader(dockerfile))
+	require.NoError(t, err)
+
+	check := func(target string) []string {
+		var warnings []string
+		lint := New(&Config{
+			Policy: policy,
+			Warn: func(rulename, _, _, _ string, location []parser.Range) {
+				warnings = append(warnings, fmt.Sprintf("%s:%d", rulename, location[0].Start.Line))
+			},
+		})
+		lint.CheckPolicy(ast.AST, target, true)
+		return warnings
+	}
+
+	// the instructions of the base stage are inherited
+	require.Empty(t, check("final"))
+	// only the last USER is effective
+	require.Equal(t, []string{"NonRootUser:9"}, check("root"))
+	// only the last value of a label is effective
+	require.Equal(t, []string{"SourceLabel:14"}, check("relabeled"))
+	// the instance runs the instructions of the template
+	require.Equal(t, []string{"NonRootUser:21"}, check("instance"))
+	require.Empty(t, check("template"))
+	// build arguments are only inherited from templates
+	require.Equal(t, []string{"VersionArg:24"}, check(""))
+
+	lint := New(&Config{
+		Policy: policy,
+		Warn: func(rulename, _, _, _ string, _ []parser.Range) {
+			t.Errorf("unexpected warning %s", rulename)
+		},
+	})
+	lint.CheckPolicy(ast.AST, "root", false)
+}
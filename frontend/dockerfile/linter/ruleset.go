@@ -191,4 +191,103 @@ var (
 		// TODO(crazy-max): deprecate this rule in the future and error out instead
 		// Deprecated: true,
 	}
+	RuleBaseImageNotPinned = LinterRule[func(string) string]{
+		Name:        "BaseImageNotPinned",
+		Description: "Base images should be pinned to a digest",
+		URL:         "https://docs.docker.com/go/dockerfile/rule/base-image-not-pinned/",
+		Format: func(image string) string {
+			return fmt.Sprintf("Base image %s is not pinned to a digest", image)
+		},
+		Experimental: true,
+	}
+	RuleAddRemoteWithoutChecksum = LinterRule[func(string) string]{
+		Name:        "AddRemoteWithoutChecksum",
+		Description: "Remote files added with ADD should be verified with the --checksum flag",
+		URL:         "https://docs.docker.com/go/dockerfile/rule/add-remote-without-checksum/",
+		Format: func(src string) string {
+			return fmt.Sprintf("ADD of remote file %s should be verified with the --checksum flag", src)
+		},
+		Experimental: true,
+	}
+	RuleRootUserInFinalStage = LinterRule[func(string) string]{
+		Name:        "RootUserInFinalStage",
+		Description: "The final stage should not run as the root user",
+		URL:         "https://docs.docker.com/go/dockerfile/rule/root-user-in-final-stage/",
+		Format: func(stage string) string {
+			return fmt.Sprintf("Final stage %s runs as the root user", stage)
+		},
+		Experimental: true,
+	}
+	RuleAptGetInstallRecommends = LinterRule[func() string]{
+		Name:        "AptGetInstallRecommends",
+		Description: "apt-get install should be used with the --no-install-recommends flag",
+		URL:         "https://docs.docker.com/go/dockerfile/rule/apt-get-install-recommends/",
+		Format: func() string {
+			return "apt-get install without --no-install-recommends installs unneeded packages"
+		},
+		Experimental: true,
+	}
+	RuleWorldWritablePermissions = LinterRule[func(string) string]{
+		Name:        "WorldWritablePermissions",
+		Description: "Files should not be writable by all users",
+		URL:         "https://docs.docker.com/go/dockerfile/rule/world-writable-permissions/",
+		Format: func(mode string) string {
+			return fmt.Sprintf("Mode %s makes files writable by all users", mode)
+		},
+		Experimental: true,
+	}
+	RuleRunSecurityInsecure = LinterRule[func() string]{
+		Name:        "RunSecurityInsecure",
+		Description: "RUN --security=insecure should not be used",
+		URL:         "https://docs.docker.com/go/dockerfile/rule/run-security-insecure/",
+		Format: func() string {
+			return "RUN --security=insecure runs the command with full privileges on the host"
+		},
+		Experimental: true,
+	}
+	RuleCredentialsInURL = LinterRule[func(string) string]{
+		Name:        "CredentialsInURL",
+		Description: "Credentials should not be set in URLs of RUN commands",
+		URL:         "https://docs.docker.com/go/dockerfile/rule/credentials-in-url/",
+		Format: func(url string) string {
+			return fmt.Sprintf("URL %s contains plaintext credentials", url)
+		},
+		Experimental: true,
+	}
+	RuleRunNetworkHost = LinterRule[func() string]{
+		Name:        "RunNetworkHost",
+		Description: "RUN --network=host should not be used",
+		URL:         "https://docs.docker.com/go/dockerfile/rule/run-network-host/",
+		Format: func() string {
+			return "RUN --network=host gives the command access to the network of the host"
+		},
+		Experimental: true,
+	}
+	RuleInvalidExecutable = LinterRule[func(string, string, string) string]{
+		Name:        "InvalidExecutable",
+		Description: "Executables of ENTRYPOINT, CMD and HEALTHCHECK should exist in the image and run on its platform",
+		URL:         "https://docs.docker.com/go/dockerfile/rule/invalid-executable/",
+		Format: func(instruction, executable, reason string) string {
+			return fmt.Sprintf("%s executable %s %s", instruction, executable, reason)
+		},
+		Experimental: true,
+	}
+	RuleUndefinedUser = LinterRule[func(string, string) string]{
+		Name:        "UndefinedUser",
+		Description: "The user and group of the image should be defined in the image",
+		URL:         "https://docs.docker.com/go/dockerfile/rule/undefined-user/",
+		Format: func(kind, name string) string {
+			return fmt.Sprintf("%s %s is not defined in the image", kind, name)
+		},
+		Experimental: true,
+	}
+	RuleWorkdirNotFound = LinterRule[func(string) string]{
+		Name:        "WorkdirNotFound",
+		Description: "The working directory of the image should exist in the image",
+		URL:         "https://docs.docker.com/go/dockerfile/rule/workdir-not-found/",
+		Format: func(dir string) string {
+			return fmt.Sprintf("Working directory %s does not exist in the image", dir)
+		},
+		Experimental: true,
+	}
 )
@@ -203,11 +203,16 @@ func init() {
 		command.Arg:         parseNameOrNameVal,
 		command.Cmd:         parseMaybeJSON,
 		command.Copy:        parseMaybeJSONToList,
+		command.Else:        parseStringsWhitespaceDelimited,
+		command.EndIf:       parseStringsWhitespaceDelimited,
 		command.Entrypoint:  parseMaybeJSON,
 		command.Env:         parseEnv,
+		command.Export:      parseStringsWhitespaceDelimited,
 		command.Expose:      parseStringsWhitespaceDelimited,
 		command.From:        parseStringsWhitespaceDelimited,
 		command.Healthcheck: parseHealthConfig,
+		command.If:          parseStringsWhitespaceDelimited,
+		command.Include:     parseStringsWhitespaceDelimited,
 		command.Label:       parseLabel,
 		command.Maintainer:  parseString,
 		command.Onbuild:     parseSubCommand,
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/containerd/platforms"
@@ -23,6 +25,13 @@ type BuildResult struct {
 	Image     *dockerspec.DockerOCIImage
 	BaseImage *dockerspec.DockerOCIImage
 	Epoch     *time.Time
+	Artifacts []BuildArtifact
+}
+
+// BuildArtifact is a named artifact returned next to the result of a build.
+type BuildArtifact struct {
+	Name      string
+	Reference client.Reference
 }
 
 type BuildFunc func(ctx context.Context, platform *ocispecs.Platform, idx int) (*BuildResult, error)
@@ -40,6 +49,8 @@ func (bc *Client) Build(ctx context.Context, fn BuildFunc) (*ResultBuilder, erro
 	expPlatforms := &exptypes.Platforms{
 		Platforms: make([]exptypes.Platform, len(targets)),
 	}
+	expArtifacts := &exptypes.Artifacts{}
+	var artifactsMu sync.Mutex
 
 	eg, ctx := errgroup.WithContext(ctx)
 
@@ -81,7 +92,13 @@ func (bc *Client) Build(ctx context.Context, fn BuildFunc) (*ResultBuilder, erro
 					res.AddMeta(fmt.Sprintf("%s/%s", commonexptypes.ExporterEpochKey, expPlat.ID), []byte(strconv.FormatInt(buildRes.Epoch.Unix(), 10)))
 				}
 			} else {
-				res.SetRef(ref)
+				if len(buildRes.Artifacts) > 0 {
+					// a result can't have both a single ref and a refs map
+					res.AddRef(expPlat.ID, ref)
+					expArtifacts.Ref = expPlat.ID
+				} else {
+					res.SetRef(ref)
+				}
 				res.AddMeta(exptypes.ExporterImageConfigKey, config)
 				if len(baseConfig) > 0 {
 					res.AddMeta(exptypes.ExporterImageBaseConfigKey, baseConfig)
@@ -91,21 +108,41 @@ func (bc *Client) Build(ctx context.Context, fn BuildFunc) (*ResultBuilder, erro
 				}
 			}
 			expPlatforms.Platforms[i] = expPlat
+
+			for _, a := range buildRes.Artifacts {
+				expArtifact := exptypes.Artifact{
+					ID:   "artifact/" + a.Name,
+					Name: a.Name,
+				}
+				if bc.MultiPlatformRequested {
+					expArtifact.ID = fmt.Sprintf("artifact/%s/%s", expPlat.ID, a.Name)
+					expArtifact.Platform = expPlat.ID
+				}
+				res.AddRef(expArtifact.ID, a.Reference)
+				artifactsMu.Lock()
+				expArtifacts.Artifacts = append(expArtifacts.Artifacts, expArtifact)
+				artifactsMu.Unlock()
+			}
 			return nil
 		})
 	}
 	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
+	slices.SortFunc(expArtifacts.Artifacts, func(a, b exptypes.Artifact) int {
+		return strings.Compare(a.ID, b.ID)
+	})
 	return &ResultBuilder{
 		Result:       res,
 		expPlatforms: expPlatforms,
+		expArtifacts: expArtifacts,
 	}, nil
 }
 
 type ResultBuilder struct {
 	*client.Result
 	expPlatforms *exptypes.Platforms
+	expArtifacts *exptypes.Artifacts
 }
 
 func (rb *ResultBuilder) Finalize() (*client.Result, error) {
@@ -115,6 +152,14 @@ func (rb *ResultBuilder) Finalize() (*client.Result, error) {
 	}
 	rb.AddMeta(exptypes.ExporterPlatformsKey, dt)
 
+	if len(rb.expArtifacts.Artifacts) > 0 {
+		dt, err := json.Marshal(rb.expArtifacts)
+		if err != nil {
+			return nil, err
+		}
+		rb.AddMeta(exptypes.ExporterArtifactsKey, dt)
+	}
+
 	return rb.Result, nil
 }
 
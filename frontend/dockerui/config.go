@@ -56,6 +56,8 @@ const (
 	keyMultiPlatformArg     = "build-arg:BUILDKIT_MULTI_PLATFORM"
 	keyHostnameArg          = "build-arg:BUILDKIT_SANDBOX_HOSTNAME"
 	keyDockerfileLintArg    = "build-arg:BUILDKIT_DOCKERFILE_CHECK"
+	keyDockerfilePolicyArg  = "build-arg:BUILDKIT_DOCKERFILE_CHECK_POLICY"
+	keyDockerfilePolicyFile = "build-arg:BUILDKIT_DOCKERFILE_CHECK_POLICY_FILE"
 	keyContextKeepGitDirArg = "build-arg:BUILDKIT_CONTEXT_KEEP_GIT_DIR"
 )
 
@@ -74,6 +76,7 @@ type Config struct {
 	LinuxResources   *pb.LinuxResources
 	Devices          []*pb.CDIDevice
 	LinterConfig     *linter.Config
+	LinterPolicy     *linter.Policy
 
 	CacheImports           []client.CacheOptionsEntry
 	TargetPlatforms        []ocispecs.Platform // nil means default
@@ -93,6 +96,10 @@ type Client struct {
 	dockerignore     []byte
 	dockerignoreMu   sync.Mutex
 	dockerignoreName string
+
+	// linterPolicyFile is the path of the check policy relative to the
+	// directory of the Dockerfile. It is read with the Dockerfile.
+	linterPolicyFile string
 }
 
 type SBOM struct {
@@ -312,6 +319,23 @@ func (bc *Client) init() error {
 		}
 	}
 
+	if v, ok := opts[keyDockerfilePolicyArg]; ok && v != "" {
+		bc.LinterPolicy, err = linter.ParsePolicy([]byte(v))
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse %s", keyDockerfilePolicyArg)
+		}
+	}
+	if v, ok := opts[keyDockerfilePolicyFile]; ok && v != "" {
+		if bc.LinterPolicy != nil {
+			return errors.Errorf("%s and %s can't be used together", keyDockerfilePolicyArg, keyDockerfilePolicyFile)
+		}
+		v = path.Clean(v)
+		if path.IsAbs(v) || v == ".." || strings.HasPrefix(v, "../") {
+			return errors.Errorf("invalid %s %s, the path needs to be relative to the directory of the Dockerfile", keyDockerfilePolicyFile, v)
+		}
+		bc.linterPolicyFile = v
+	}
+
 	bc.localsSessionIDs = parseLocalSessionIDs(opts)
 
 	return nil
@@ -341,6 +365,9 @@ func (bc *Client) ReadEntrypoint(ctx context.Context, lang string, opts ...llb.L
 		name := "load build definition from " + bctx.filename
 
 		filenames := []string{bctx.filename, bctx.filename + ".dockerignore"}
+		if bc.linterPolicyFile != "" {
+			filenames = append(filenames, path.Join(path.Dir(bctx.filename), bc.linterPolicyFile))
+		}
 
 		// dockerfile is also supported casing moby/moby#10858
 		if path.Base(bctx.filename) == DefaultDockerfileName {
@@ -406,6 +433,18 @@ func (bc *Client) ReadEntrypoint(ctx context.Context, lang string, opts ...llb.L
 	smap := llb.NewSourceMap(src, bctx.filename, lang, dt)
 	smap.Definition = def
 
+	if bc.linterPolicyFile != "" {
+		dt, err := ref.ReadFile(ctx, client.ReadRequest{
+			Filename: path.Join(path.Dir(bctx.filename), bc.linterPolicyFile),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read check policy %s", bc.linterPolicyFile)
+		}
+		if bc.LinterPolicy, err = linter.ParsePolicy(dt); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse check policy %s", bc.linterPolicyFile)
+		}
+	}
+
 	dt, err = ref.ReadFile(ctx, client.ReadRequest{
 		Filename: bctx.filename + ".dockerignore",
 	})
@@ -414,6 +453,10 @@ func (bc *Client) ReadEntrypoint(ctx context.Context, lang string, opts ...llb.L
 		bc.dockerignoreName = bctx.filename + ".dockerignore"
 	}
 
+	return bc.newSource(smap, defVtx), nil
+}
+
+func (bc *Client) newSource(smap *llb.SourceMap, defVtx digest.Digest) *Source {
 	return &Source{
 		SourceMap: smap,
 		Warn: func(ctx context.Context, msg string, opts client.WarnOpts) {
@@ -430,7 +473,7 @@ func (bc *Client) ReadEntrypoint(ctx context.Context, lang string, opts ...llb.L
 			}
 			bc.client.Warn(ctx, defVtx, msg, opts)
 		},
-	}, nil
+	}
 }
 
 func (bc *Client) MainContext(ctx context.Context, opts ...llb.LocalOption) (*llb.State, error) {
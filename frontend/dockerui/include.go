@@ -0,0 +1,91 @@
+package dockerui
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/moby/buildkit/frontend/dockerfile/dfgitutil"
+	"github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/pkg/errors"
+)
+
+// ReadInclude reads a Dockerfile included by another Dockerfile. The source
+// is a git URL with the path of the file in the repository as subdirectory, an
+// HTTP URL or a path relative to the root of the main build context.
+func (bc *Client) ReadInclude(ctx context.Context, src string, lang string) (*Source, error) {
+	st, filename, err := bc.includeState(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	def, err := st.Marshal(ctx, bc.marshalOpts()...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal included source")
+	}
+
+	defVtx, err := def.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := bc.client.Solve(ctx, client.SolveRequest{
+		Definition: def.ToPB(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve included dockerfile %s", src)
+	}
+
+	ref, err := res.SingleRef()
+	if err != nil {
+		return nil, err
+	}
+
+	dt, err := ref.ReadFile(ctx, client.ReadRequest{
+		Filename: filename,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read included dockerfile %s", src)
+	}
+
+	smap := llb.NewSourceMap(st, src, lang, dt)
+	smap.Definition = def
+	return bc.newSource(smap, defVtx), nil
+}
+
+func (bc *Client) includeState(ctx context.Context, src string) (*llb.State, string, error) {
+	if g, isGit, err := dfgitutil.ParseGitRef(src); isGit && !g.IndistinguishableFromLocal {
+		if err != nil {
+			return nil, "", err
+		}
+		filename := DefaultDockerfileName
+		if g.SubDir != "" {
+			filename = path.Clean(g.SubDir)
+		}
+		gitOpts := []llb.GitOption{
+			llb.GitRef(g.Ref),
+			WithInternalName("load included dockerfile " + src),
+		}
+		if g.Checksum != "" {
+			gitOpts = append(gitOpts, llb.GitChecksum(g.Checksum))
+		}
+		st := llb.Git(g.Remote, "", gitOpts...)
+		return &st, filename, nil
+	}
+
+	if httpPrefix.MatchString(src) {
+		st := llb.HTTP(src, llb.Filename(DefaultDockerfileName), WithInternalName("load included dockerfile "+src))
+		return &st, DefaultDockerfileName, nil
+	}
+
+	filename := path.Clean(strings.TrimPrefix(src, "/"))
+	if filename == "." || filename == ".." || strings.HasPrefix(filename, "../") {
+		return nil, "", errors.Errorf("included dockerfile %s is outside of the build context", src)
+	}
+	st, err := bc.MainContext(ctx, llb.FollowPaths([]string{filename}))
+	if err != nil {
+		return nil, "", err
+	}
+	return st, filename, nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/moby/buildkit/frontend/gateway/client"
 	"github.com/moby/buildkit/frontend/subrequests"
 	"github.com/moby/buildkit/frontend/subrequests/convertllb"
+	"github.com/moby/buildkit/frontend/subrequests/graph"
 	"github.com/moby/buildkit/frontend/subrequests/lint"
 	"github.com/moby/buildkit/frontend/subrequests/outline"
 	"github.com/moby/buildkit/frontend/subrequests/targets"
@@ -23,6 +24,7 @@ type RequestHandler struct {
 	ListTargets func(context.Context) (*targets.List, error)
 	Lint        func(context.Context) (*lint.LintResults, error)
 	ConvertLLB  func(context.Context) (*convertllb.Result, error)
+	Graph       func(context.Context) (*graph.Graph, error)
 	AllowOther  bool
 }
 
@@ -81,6 +83,18 @@ func (bc *Client) HandleSubrequest(ctx context.Context, h RequestHandler) (*clie
 			res, err := result.ToResult()
 			return res, true, err
 		}
+	case graph.SubrequestGraphDefinition.Name:
+		if f := h.Graph; f != nil {
+			g, err := f(ctx)
+			if err != nil {
+				return nil, false, err
+			}
+			if g == nil {
+				return nil, true, nil
+			}
+			res, err := g.ToResult()
+			return res, true, err
+		}
 	}
 	if h.AllowOther {
 		return nil, false, nil
@@ -96,6 +110,9 @@ func describe(h RequestHandler) (*client.Result, error) {
 	if h.ListTargets != nil {
 		all = append(all, targets.SubrequestsTargetsDefinition)
 	}
+	if h.Graph != nil {
+		all = append(all, graph.SubrequestGraphDefinition)
+	}
 	all = append(all, subrequests.SubrequestsDescribeDefinition)
 	dt, err := json.MarshalIndent(all, "", "  ")
 	if err != nil {
@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/frontend/subrequests"
+	"github.com/moby/buildkit/solver/pb"
+)
+
+const RequestGraph = "frontend.graph"
+
+var SubrequestGraphDefinition = subrequests.Request{
+	Name:        RequestGraph,
+	Version:     "1.0.0",
+	Type:        subrequests.TypeRPC,
+	Description: "Show the dependency graph of the stages of the build",
+	Opts:        []subrequests.Named{},
+	Metadata: []subrequests.Named{
+		{Name: "result.json"},
+		{Name: "result.dot"},
+	},
+}
+
+const (
+	// StageTypeStage is a build stage defined in the Dockerfile.
+	StageTypeStage = "stage"
+	// StageTypeImage is an image that is not the base of a build stage,
+	// for example the source of COPY --from=<image>.
+	StageTypeImage = "image"
+	// StageTypeContext is a named context that replaces a build stage or
+	// image.
+	StageTypeContext = "context"
+)
+
+const (
+	// EdgeTypeFrom is the dependency of a stage on its base.
+	EdgeTypeFrom = "from"
+	// EdgeTypeCopy is a dependency of COPY --from.
+	EdgeTypeCopy = "copy"
+	// EdgeTypeMount is a dependency of RUN --mount=from.
+	EdgeTypeMount = "mount"
+)
+
+type Graph struct {
+	Stages  []Stage  `json:"stages"`
+	Edges   []Edge   `json:"edges,omitempty"`
+	Sources [][]byte `json:"sources,omitempty"`
+}
+
+type Stage struct {
+	// ID identifies the stage in the edges of the graph. It is the name of
+	// the stage for build stages.
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+	// Target is set for the stage that is built by default, or the stage set
+	// with the target option.
+	Target bool `json:"target,omitempty"`
+	// Base is the resolved reference of the base image of the stage.
+	Base     string `json:"base,omitempty"`
+	Platform string `json:"platform,omitempty"`
+	// Args are the build args the stage consumes.
+	Args     []string     `json:"args,omitempty"`
+	Location *pb.Location `json:"location,omitempty"`
+}
+
+type Edge struct {
+	// From is the ID of the stage that depends on the stage of To.
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Type     string       `json:"type"`
+	Location *pb.Location `json:"location,omitempty"`
+	// Conditional is set for the dependencies of the instructions inside IF
+	// blocks, which only apply for some build args or platforms.
+	Conditional bool `json:"conditional,omitempty"`
+}
+
+func (g Graph) ToResult() (*client.Result, error) {
+	res := client.NewResult()
+	dt, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	res.AddMeta("result.json", dt)
+
+	b := bytes.NewBuffer(nil)
+	if err := PrintDOT(dt, b); err != nil {
+		return nil, err
+	}
+	res.AddMeta("result.dot", b.Bytes())
+
+	res.AddMeta("version", []byte(SubrequestGraphDefinition.Version))
+	return res, nil
+}
+
+// PrintDOT writes the graph in the DOT format of Graphviz.
+func PrintDOT(dt []byte, w io.Writer) error {
+	var g Graph
+	if err := json.Unmarshal(dt, &g); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "digraph stages {")
+	for _, s := range g.Stages {
+		label := s.ID
+		if s.Base != "" {
+			label += "\n" + s.Base
+		}
+		attrs := "label=" + strconv.Quote(label)
+		switch {
+		case s.Type == StageTypeImage:
+			attrs += ", shape=box"
+		case s.Type == StageTypeContext:
+			attrs += ", shape=box, style=dashed"
+		case s.Target:
+			attrs += ", style=bold"
+		}
+		fmt.Fprintf(w, "  %s [%s];\n", strconv.Quote(s.ID), attrs)
+	}
+	for _, e := range g.Edges {
+		attrs := "label=" + strconv.Quote(e.Type)
+		if e.Conditional {
+			attrs += ", style=dashed"
+		}
+		fmt.Fprintf(w, "  %s -> %s [%s];\n", strconv.Quote(e.From), strconv.Quote(e.To), attrs)
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
@@ -0,0 +1,143 @@
+package lint
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/pkg/errors"
+)
+
+type span struct {
+	start, end int
+	newText    string
+}
+
+// Fix applies the edits of the warnings of the source at sourceIndex to the
+// data of the source. The edits of a warning are skipped if they overlap with
+// the edits of a previous warning; linting the fixed source again returns
+// those warnings with updated edits. Fix returns the fixed data and the number
+// of warnings that were fixed.
+func (results *LintResults) Fix(sourceIndex int) ([]byte, int, error) {
+	if sourceIndex < 0 || sourceIndex >= len(results.Sources) || results.Sources[sourceIndex] == nil {
+		return nil, 0, errors.Errorf("invalid source index %d", sourceIndex)
+	}
+	dt := results.Sources[sourceIndex].Data
+	lines := lineOffsets(dt)
+
+	var accepted []span
+	var fixed int
+	for _, w := range results.Warnings {
+		if len(w.Edits) == 0 || w.Location == nil || int(w.Location.SourceIndex) != sourceIndex {
+			continue
+		}
+		spans, err := toSpans(dt, lines, w.Edits)
+		if err != nil {
+			return nil, 0, errors.Wrapf(err, "invalid fix for %s", w.RuleName)
+		}
+		if slices.ContainsFunc(spans, func(s span) bool {
+			return slices.ContainsFunc(accepted, s.overlaps)
+		}) || hasOverlap(spans) {
+			continue
+		}
+		accepted = append(accepted, spans...)
+		fixed++
+	}
+	return applySpans(dt, accepted), fixed, nil
+}
+
+// ApplyEdits applies the edits to dt. The edits must not overlap.
+func ApplyEdits(dt []byte, edits []TextEdit) ([]byte, error) {
+	spans, err := toSpans(dt, lineOffsets(dt), edits)
+	if err != nil {
+		return nil, err
+	}
+	if hasOverlap(spans) {
+		return nil, errors.New("overlapping edits")
+	}
+	return applySpans(dt, spans), nil
+}
+
+func (s span) overlaps(other span) bool {
+	if s.start == s.end || other.start == other.end {
+		// insertions only conflict at the same offset
+		return s.start == other.start || (s.start > other.start && s.start < other.end) || (other.start > s.start && other.start < s.end)
+	}
+	return s.start < other.end && other.start < s.end
+}
+
+func hasOverlap(spans []span) bool {
+	for i, s := range spans {
+		for _, other := range spans[i+1:] {
+			if s.overlaps(other) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func applySpans(dt []byte, spans []span) []byte {
+	spans = slices.Clone(spans)
+	slices.SortFunc(spans, func(a, b span) int {
+		return a.start - b.start
+	})
+	var buf bytes.Buffer
+	var last int
+	for _, s := range spans {
+		buf.Write(dt[last:s.start])
+		buf.WriteString(s.newText)
+		last = s.end
+	}
+	buf.Write(dt[last:])
+	return buf.Bytes()
+}
+
+// lineOffsets returns the offsets of the start of each line of dt.
+func lineOffsets(dt []byte) []int {
+	offsets := []int{0}
+	for i, b := range dt {
+		if b == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+func toSpans(dt []byte, lines []int, edits []TextEdit) ([]span, error) {
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		if e.Range == nil {
+			return nil, errors.New("edit without range")
+		}
+		start, err := offset(dt, lines, e.Range.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := offset(dt, lines, e.Range.End)
+		if err != nil {
+			return nil, err
+		}
+		if end < start {
+			return nil, errors.Errorf("invalid range %d:%d-%d:%d", e.Range.Start.Line, e.Range.Start.Character, e.Range.End.Line, e.Range.End.Character)
+		}
+		spans = append(spans, span{start: start, end: end, newText: e.NewText})
+	}
+	return spans, nil
+}
+
+func offset(dt []byte, lines []int, pos *pb.Position) (int, error) {
+	if pos == nil || pos.Line < 1 || int(pos.Line) > len(lines) {
+		return 0, errors.Errorf("invalid position %v", pos)
+	}
+	start := lines[pos.Line-1]
+	end := len(dt)
+	if int(pos.Line) < len(lines) {
+		end = lines[pos.Line] - 1
+	}
+	line := bytes.TrimSuffix(dt[start:end], []byte("\r"))
+	if pos.Character < 0 || int(pos.Character) > len(line) {
+		return 0, errors.Errorf("invalid position %d:%d", pos.Line, pos.Character)
+	}
+	return start + int(pos.Character), nil
+}
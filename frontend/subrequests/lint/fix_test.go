@@ -0,0 +1,37 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEdits(t *testing.T) {
+	t.Parallel()
+
+	edit := func(line, start, end int, newText string) TextEdit {
+		return NewTextEdit(parser.Range{
+			Start: parser.Position{Line: line, Character: start},
+			End:   parser.Position{Line: line, Character: end},
+		}, newText)
+	}
+
+	dt := []byte("FROM alpine\r\nRUN true\nCMD foo")
+	out, err := ApplyEdits(dt, []TextEdit{
+		edit(3, 4, 7, `["foo"]`),
+		edit(1, 0, 4, "from"),
+		edit(2, 8, 8, " && false"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "from alpine\r\nRUN true && false\nCMD [\"foo\"]", string(out))
+
+	_, err = ApplyEdits(dt, []TextEdit{edit(1, 0, 4, "from"), edit(1, 2, 6, "x")})
+	require.ErrorContains(t, err, "overlapping edits")
+
+	_, err = ApplyEdits(dt, []TextEdit{edit(1, 0, 12, "x")})
+	require.ErrorContains(t, err, "invalid position")
+
+	_, err = ApplyEdits(dt, []TextEdit{edit(4, 0, 0, "x")})
+	require.ErrorContains(t, err, "invalid position")
+}
@@ -39,6 +39,24 @@ type Warning struct {
 	URL         string       `json:"url,omitempty"`
 	Detail      string       `json:"detail,omitempty"`
 	Location    *pb.Location `json:"location,omitempty"`
+	// Edits are the changes of the source that fix the warning. All edits of
+	// a warning need to be applied together.
+	Edits []TextEdit `json:"edits,omitempty"`
+}
+
+// TextEdit replaces the text of Range in the source of the warning with
+// NewText. Lines are 1-based, characters are 0-based byte offsets in the line
+// and the end of the range is exclusive.
+type TextEdit struct {
+	Range   *pb.Range `json:"range"`
+	NewText string    `json:"newText"`
+}
+
+func NewTextEdit(r parser.Range, newText string) TextEdit {
+	return TextEdit{
+		Range:   toPBRange(r),
+		NewText: newText,
+	}
 }
 
 func (w *Warning) PrintTo(wr io.Writer, sources []*pb.SourceInfo, scb SourceInfoMap) error {
@@ -89,19 +107,10 @@ func (results *LintResults) AddSource(sourceMap *llb.SourceMap) int {
 	return len(results.Sources) - 1
 }
 
-func (results *LintResults) AddWarning(rulename, description, url, fmtmsg string, sourceIndex int, location []parser.Range) {
+func (results *LintResults) AddWarning(rulename, description, url, fmtmsg string, sourceIndex int, location []parser.Range, edits ...TextEdit) {
 	sourceLocation := []*pb.Range{}
 	for _, loc := range location {
-		sourceLocation = append(sourceLocation, &pb.Range{
-			Start: &pb.Position{
-				Line:      int32(loc.Start.Line),
-				Character: int32(loc.Start.Character),
-			},
-			End: &pb.Position{
-				Line:      int32(loc.End.Line),
-				Character: int32(loc.End.Character),
-			},
-		})
+		sourceLocation = append(sourceLocation, toPBRange(loc))
 	}
 	pbLocation := &pb.Location{
 		SourceIndex: int32(sourceIndex),
@@ -113,9 +122,23 @@ func (results *LintResults) AddWarning(rulename, description, url, fmtmsg string
 		URL:         url,
 		Detail:      fmtmsg,
 		Location:    pbLocation,
+		Edits:       edits,
 	})
 }
 
+func toPBRange(r parser.Range) *pb.Range {
+	return &pb.Range{
+		Start: &pb.Position{
+			Line:      int32(r.Start.Line),
+			Character: int32(r.Start.Character),
+		},
+		End: &pb.Position{
+			Line:      int32(r.End.Line),
+			Character: int32(r.End.Character),
+		},
+	}
+}
+
 func (results *LintResults) ToResult(scb SourceInfoMap) (*client.Result, error) {
 	res := client.NewResult()
 	dt, err := json.MarshalIndent(results, "", "  ")
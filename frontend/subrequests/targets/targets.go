@@ -56,6 +56,10 @@ type Target struct {
 	Base        string       `json:"base,omitempty"`
 	Platform    string       `json:"platform,omitempty"`
 	Location    *pb.Location `json:"location,omitempty"`
+	// Template is the name of the stage template the target is instantiated
+	// from, with the args of the instance.
+	Template string            `json:"template,omitempty"`
+	Args     map[string]string `json:"args,omitempty"`
 }
 
 func PrintTargets(dt []byte, w io.Writer) error {
@@ -3,15 +3,22 @@ package filesync
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
 	io "io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/tarsum"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"github.com/tonistiigi/fsutil"
 	fstypes "github.com/tonistiigi/fsutil/types"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 type Stream interface {
@@ -110,7 +117,8 @@ func recvDiffCopy(ds grpc.ClientStream, dest string, cu CacheUpdater, progress p
 	}))
 }
 
-func syncTargetDiffCopy(ds grpc.ServerStream, dest string, deleteMode bool) error {
+func syncTargetDiffCopy(ds grpc.ServerStream, target fsSyncDirTarget) error {
+	dest := target.outdir
 	if err := os.MkdirAll(dest, 0700); err != nil {
 		return errors.Wrapf(err, "failed to create synctarget dest dir %s", dest)
 	}
@@ -135,16 +143,129 @@ func syncTargetDiffCopy(ds grpc.ServerStream, dest string, deleteMode bool) erro
 	root := fsutil.NewRoot(osRoot)
 	defer root.Close()
 
-	if deleteMode {
+	if target.deleteMode {
 		opt.Merge = false
 		// Request every source file so delete mode mirrors file contents without
 		// relying on fsutil's path-based content comparison.
 		opt.Differ = fsutil.DiffNone
 	}
+	if target.syncMode {
+		// The exporter has compared the file contents already and keeps the
+		// modification time of unchanged files, so only files with different
+		// metadata are requested. The files it couldn't confirm to be
+		// unchanged are removed first, so they are transferred even if their
+		// metadata matches.
+		opt.Merge = false
+		opt.Differ = fsutil.DiffMetadata
+		md, _ := metadata.FromIncomingContext(ds.Context())
+		if slices.Contains(md.Get(keyExporterSyncAll), "1") {
+			opt.Differ = fsutil.DiffNone
+		}
+		for _, p := range md.Get(keyExporterSyncReplace) {
+			if err := osRoot.Remove(filepath.FromSlash(p)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return errors.Wrapf(err, "failed to remove changed file %s", p)
+			}
+		}
+	}
 
 	return errors.WithStack(fsutil.ReceiveRoot(ds.Context(), ds, root, opt))
 }
 
+const listFilesBatchSize = 1000
+
+// listDir walks dest and calls fn with batches of its files. A missing
+// directory has no files.
+func listDir(ctx context.Context, dest string, fn func([]*FileEntry) error) error {
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to open synctarget dest root %s", dest)
+	}
+	defer root.Close()
+
+	var entries []*FileEntry
+	err = fs.WalkDir(root.FS(), ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		e := &FileEntry{
+			Path:    p,
+			Mode:    uint32(fi.Mode()),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime().UnixNano(),
+		}
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			e.Linkname, err = root.Readlink(p)
+			if err != nil {
+				return err
+			}
+		case fi.Mode().IsRegular():
+			e.Digest, err = syncFileDigest(root, p, fi)
+			if err != nil {
+				return err
+			}
+		}
+		entries = append(entries, e)
+		if len(entries) == listFilesBatchSize {
+			if err := fn(entries); err != nil {
+				return err
+			}
+			entries = nil
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(entries) > 0 {
+		return fn(entries)
+	}
+	return nil
+}
+
+// SyncFileStat returns the stat of a regular file that is hashed with the
+// tarsum format of the build cache to compare the contents of the files of the
+// build result and the destination in sync mode. The owner, extended
+// attributes and modification time are not compared, as the receiver doesn't
+// keep them.
+func SyncFileStat(mode os.FileMode, size int64) *fstypes.Stat {
+	return &fstypes.Stat{
+		Mode: uint32(mode),
+		Size: size,
+	}
+}
+
+func syncFileDigest(root *os.Root, p string, fi os.FileInfo) (string, error) {
+	hdr, err := tarsum.FileHeader(SyncFileStat(fi.Mode(), fi.Size()))
+	if err != nil {
+		return "", err
+	}
+	f, err := root.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	tarsum.WriteV1Headers(hdr, h)
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return digest.NewDigest(digest.SHA256, h).String(), nil
+}
+
 func writeTargetFile(ds grpc.ServerStream, wc io.WriteCloser) error {
 	var bm BytesMessage
 	for {
@@ -31,8 +31,14 @@ const (
 
 	keyExporterID                    = "buildkit-attachable-exporter-id"
 	keyExporterMultiPlatformTransfer = "buildkit-exporter-multi-platform"
+	keyExporterSyncReplace           = "buildkit-exporter-sync-replace-bin"
+	keyExporterSyncAll               = "buildkit-exporter-sync-all"
 )
 
+// maxSyncReplaceSize is the maximum size of the paths sent with
+// WithSyncReplacedFiles, so that they fit in the request metadata.
+const maxSyncReplaceSize = 1 << 20
+
 type fsSyncProvider struct {
 	dirs   DirSource
 	p      progressCb
@@ -258,6 +264,7 @@ type fsSyncTarget struct {
 	id         int
 	outdir     string
 	deleteMode bool
+	syncMode   bool
 	f          FileOutputFunc
 }
 
@@ -287,9 +294,21 @@ func WithFSSyncDirDelete(id int, outdir string) FSSyncTarget {
 	}
 }
 
+// WithFSSyncDirSync returns a target that removes destination files not
+// present in the build result. The exporter lists the destination files with
+// ListCallerFiles first so that unchanged files are not transferred again.
+func WithFSSyncDirSync(id int, outdir string) FSSyncTarget {
+	return &fsSyncTarget{
+		id:       id,
+		outdir:   outdir,
+		syncMode: true,
+	}
+}
+
 type fsSyncDirTarget struct {
 	outdir     string
 	deleteMode bool
+	syncMode   bool
 }
 
 func NewFSSyncTarget(targets ...FSSyncTarget) *SyncTarget {
@@ -315,7 +334,7 @@ func (sp *SyncTarget) Add(targets ...FSSyncTarget) {
 			sp.fs[t.id] = t.f
 		}
 		if t.outdir != "" {
-			sp.outdirs[t.id] = fsSyncDirTarget{outdir: t.outdir, deleteMode: t.deleteMode}
+			sp.outdirs[t.id] = fsSyncDirTarget{outdir: t.outdir, deleteMode: t.deleteMode, syncMode: t.syncMode}
 		}
 	}
 }
@@ -346,7 +365,10 @@ func (sp *SyncTarget) DiffCopy(stream FileSend_DiffCopyServer) (err error) {
 		if target.deleteMode && !supportsExporterMultiPlatformTransfer(stream.Context()) {
 			return errors.New("local exporter mode=delete requires a BuildKit daemon with multi-platform local export support")
 		}
-		return syncTargetDiffCopy(stream, target.outdir, target.deleteMode)
+		if target.syncMode && !supportsExporterMultiPlatformTransfer(stream.Context()) {
+			return errors.New("local exporter mode=sync requires a BuildKit daemon with multi-platform local export support")
+		}
+		return syncTargetDiffCopy(stream, target)
 	}
 	f, ok := sp.fs[id]
 	if !ok {
@@ -376,6 +398,20 @@ func (sp *SyncTarget) DiffCopy(stream FileSend_DiffCopyServer) (err error) {
 	return writeTargetFile(stream, wc)
 }
 
+// ListFiles sends the files in the output directory of the exporter. The
+// digests of regular files are computed from their contents and modes, see
+// SyncFileStat.
+func (sp *SyncTarget) ListFiles(_ *ListFilesRequest, stream FileSend_ListFilesServer) error {
+	id := sp.chooser(stream.Context())
+	target, ok := sp.outdirs[id]
+	if !ok {
+		return errors.Errorf("exporter %d not found", id)
+	}
+	return listDir(stream.Context(), target.outdir, func(entries []*FileEntry) error {
+		return stream.Send(&ListFilesResponse{Entries: entries})
+	})
+}
+
 type CopyToCallerOpt func(metadata.MD)
 
 func WithExporterMultiPlatformTransfer() CopyToCallerOpt {
@@ -384,6 +420,24 @@ func WithExporterMultiPlatformTransfer() CopyToCallerOpt {
 	}
 }
 
+// WithSyncReplacedFiles sets the paths of the destination files that are
+// transferred in sync mode even if their metadata matches the build result, as
+// their contents couldn't be confirmed to be the same. If the paths don't fit
+// in the request, all the files are transferred.
+func WithSyncReplacedFiles(paths []string) CopyToCallerOpt {
+	return func(opts metadata.MD) {
+		var size int
+		for _, p := range paths {
+			size += len(p)
+		}
+		if size > maxSyncReplaceSize {
+			opts.Set(keyExporterSyncAll, "1")
+			return
+		}
+		opts.Set(keyExporterSyncReplace, paths...)
+	}
+}
+
 func supportsExporterMultiPlatformTransfer(ctx context.Context) bool {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
@@ -423,6 +477,45 @@ func CopyToCaller(ctx context.Context, fs fsutil.FS, id int, c session.Caller, p
 	return sendDiffCopy(cc, fs, progress)
 }
 
+// ListCallerFiles returns the files in the output directory of the exporter,
+// keyed by their slash-separated path relative to the directory.
+func ListCallerFiles(ctx context.Context, id int, c session.Caller) (map[string]*FileEntry, error) {
+	method := session.MethodURL(FileSend_ServiceDesc.ServiceName, "listfiles")
+	if !c.Supports(method) {
+		return nil, errors.Errorf("method %s not supported by the client", method)
+	}
+
+	ctx = c.Context(ctx)
+	client := NewFileSendClient(c.Conn())
+
+	opts, ok := metadata.FromOutgoingContext(ctx)
+	if !ok {
+		opts = make(map[string][]string)
+	} else {
+		opts = opts.Copy()
+	}
+	opts[keyExporterID] = []string{fmt.Sprint(id)}
+	ctx = metadata.NewOutgoingContext(ctx, opts)
+
+	cc, err := client.ListFiles(ctx, &ListFilesRequest{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	files := map[string]*FileEntry{}
+	for {
+		resp, err := cc.Recv()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, e := range resp.Entries {
+			files[e.Path] = e
+		}
+	}
+}
+
 func CopyFileWriter(ctx context.Context, md map[string]string, id int, c session.Caller) (io.WriteCloser, error) {
 	method := session.MethodURL(FileSend_ServiceDesc.ServiceName, "diffcopy")
 	if !c.Supports(method) {
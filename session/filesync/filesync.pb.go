@@ -67,18 +67,195 @@ func (x *BytesMessage) GetData() []byte {
 	return nil
 }
 
+type ListFilesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFilesRequest) Reset() {
+	*x = ListFilesRequest{}
+	mi := &file_github_com_moby_buildkit_session_filesync_filesync_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFilesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFilesRequest) ProtoMessage() {}
+
+func (x *ListFilesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_session_filesync_filesync_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFilesRequest.ProtoReflect.Descriptor instead.
+func (*ListFilesRequest) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_session_filesync_filesync_proto_rawDescGZIP(), []int{1}
+}
+
+type ListFilesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Entries       []*FileEntry           `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListFilesResponse) Reset() {
+	*x = ListFilesResponse{}
+	mi := &file_github_com_moby_buildkit_session_filesync_filesync_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListFilesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListFilesResponse) ProtoMessage() {}
+
+func (x *ListFilesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_session_filesync_filesync_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListFilesResponse.ProtoReflect.Descriptor instead.
+func (*ListFilesResponse) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_session_filesync_filesync_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListFilesResponse) GetEntries() []*FileEntry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+// FileEntry describes a file in the output directory
+type FileEntry struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Path     string                 `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Mode     uint32                 `protobuf:"varint,2,opt,name=mode,proto3" json:"mode,omitempty"`
+	Size     int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	ModTime  int64                  `protobuf:"varint,4,opt,name=modTime,proto3" json:"modTime,omitempty"`
+	Linkname string                 `protobuf:"bytes,5,opt,name=linkname,proto3" json:"linkname,omitempty"`
+	// digest is the content hash of a regular file, see SyncFileStat
+	Digest        string `protobuf:"bytes,6,opt,name=digest,proto3" json:"digest,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FileEntry) Reset() {
+	*x = FileEntry{}
+	mi := &file_github_com_moby_buildkit_session_filesync_filesync_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FileEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileEntry) ProtoMessage() {}
+
+func (x *FileEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_session_filesync_filesync_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileEntry.ProtoReflect.Descriptor instead.
+func (*FileEntry) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_session_filesync_filesync_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *FileEntry) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *FileEntry) GetMode() uint32 {
+	if x != nil {
+		return x.Mode
+	}
+	return 0
+}
+
+func (x *FileEntry) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *FileEntry) GetModTime() int64 {
+	if x != nil {
+		return x.ModTime
+	}
+	return 0
+}
+
+func (x *FileEntry) GetLinkname() string {
+	if x != nil {
+		return x.Linkname
+	}
+	return ""
+}
+
+func (x *FileEntry) GetDigest() string {
+	if x != nil {
+		return x.Digest
+	}
+	return ""
+}
+
 var File_github_com_moby_buildkit_session_filesync_filesync_proto protoreflect.FileDescriptor
 
 const file_github_com_moby_buildkit_session_filesync_filesync_proto_rawDesc = "" +
 	"\n" +
 	"8github.com/moby/buildkit/session/filesync/filesync.proto\x12\x10moby.filesync.v1\x1a-github.com/tonistiigi/fsutil/types/wire.proto\"\"\n" +
 	"\fBytesMessage\x12\x12\n" +
-	"\x04data\x18\x01 \x01(\fR\x04data2\x83\x01\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"\x12\n" +
+	"\x10ListFilesRequest\"J\n" +
+	"\x11ListFilesResponse\x125\n" +
+	"\aentries\x18\x01 \x03(\v2\x1b.moby.filesync.v1.FileEntryR\aentries\"\x95\x01\n" +
+	"\tFileEntry\x12\x12\n" +
+	"\x04path\x18\x01 \x01(\tR\x04path\x12\x12\n" +
+	"\x04mode\x18\x02 \x01(\rR\x04mode\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x18\n" +
+	"\amodTime\x18\x04 \x01(\x03R\amodTime\x12\x1a\n" +
+	"\blinkname\x18\x05 \x01(\tR\blinkname\x12\x16\n" +
+	"\x06digest\x18\x06 \x01(\tR\x06digest2\x83\x01\n" +
 	"\bFileSync\x12:\n" +
 	"\bDiffCopy\x12\x14.fsutil.types.Packet\x1a\x14.fsutil.types.Packet(\x010\x01\x12;\n" +
-	"\tTarStream\x12\x14.fsutil.types.Packet\x1a\x14.fsutil.types.Packet(\x010\x012Z\n" +
+	"\tTarStream\x12\x14.fsutil.types.Packet\x1a\x14.fsutil.types.Packet(\x010\x012\xb2\x01\n" +
 	"\bFileSend\x12N\n" +
-	"\bDiffCopy\x12\x1e.moby.filesync.v1.BytesMessage\x1a\x1e.moby.filesync.v1.BytesMessage(\x010\x01B+Z)github.com/moby/buildkit/session/filesyncb\x06proto3"
+	"\bDiffCopy\x12\x1e.moby.filesync.v1.BytesMessage\x1a\x1e.moby.filesync.v1.BytesMessage(\x010\x01\x12V\n" +
+	"\tListFiles\x12\".moby.filesync.v1.ListFilesRequest\x1a#.moby.filesync.v1.ListFilesResponse0\x01B+Z)github.com/moby/buildkit/session/filesyncb\x06proto3"
 
 var (
 	file_github_com_moby_buildkit_session_filesync_filesync_proto_rawDescOnce sync.Once
@@ -92,23 +269,29 @@ func file_github_com_moby_buildkit_session_filesync_filesync_proto_rawDescGZIP()
 	return file_github_com_moby_buildkit_session_filesync_filesync_proto_rawDescData
 }
 
-var file_github_com_moby_buildkit_session_filesync_filesync_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_github_com_moby_buildkit_session_filesync_filesync_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
 var file_github_com_moby_buildkit_session_filesync_filesync_proto_goTypes = []any{
-	(*BytesMessage)(nil), // 0: moby.filesync.v1.BytesMessage
-	(*types.Packet)(nil), // 1: fsutil.types.Packet
+	(*BytesMessage)(nil),      // 0: moby.filesync.v1.BytesMessage
+	(*ListFilesRequest)(nil),  // 1: moby.filesync.v1.ListFilesRequest
+	(*ListFilesResponse)(nil), // 2: moby.filesync.v1.ListFilesResponse
+	(*FileEntry)(nil),         // 3: moby.filesync.v1.FileEntry
+	(*types.Packet)(nil),      // 4: fsutil.types.Packet
 }
 var file_github_com_moby_buildkit_session_filesync_filesync_proto_depIdxs = []int32{
-	1, // 0: moby.filesync.v1.FileSync.DiffCopy:input_type -> fsutil.types.Packet
-	1, // 1: moby.filesync.v1.FileSync.TarStream:input_type -> fsutil.types.Packet
-	0, // 2: moby.filesync.v1.FileSend.DiffCopy:input_type -> moby.filesync.v1.BytesMessage
-	1, // 3: moby.filesync.v1.FileSync.DiffCopy:output_type -> fsutil.types.Packet
-	1, // 4: moby.filesync.v1.FileSync.TarStream:output_type -> fsutil.types.Packet
-	0, // 5: moby.filesync.v1.FileSend.DiffCopy:output_type -> moby.filesync.v1.BytesMessage
-	3, // [3:6] is the sub-list for method output_type
-	0, // [0:3] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	3, // 0: moby.filesync.v1.ListFilesResponse.entries:type_name -> moby.filesync.v1.FileEntry
+	4, // 1: moby.filesync.v1.FileSync.DiffCopy:input_type -> fsutil.types.Packet
+	4, // 2: moby.filesync.v1.FileSync.TarStream:input_type -> fsutil.types.Packet
+	0, // 3: moby.filesync.v1.FileSend.DiffCopy:input_type -> moby.filesync.v1.BytesMessage
+	1, // 4: moby.filesync.v1.FileSend.ListFiles:input_type -> moby.filesync.v1.ListFilesRequest
+	4, // 5: moby.filesync.v1.FileSync.DiffCopy:output_type -> fsutil.types.Packet
+	4, // 6: moby.filesync.v1.FileSync.TarStream:output_type -> fsutil.types.Packet
+	0, // 7: moby.filesync.v1.FileSend.DiffCopy:output_type -> moby.filesync.v1.BytesMessage
+	2, // 8: moby.filesync.v1.FileSend.ListFiles:output_type -> moby.filesync.v1.ListFilesResponse
+	5, // [5:9] is the sub-list for method output_type
+	1, // [1:5] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
 }
 
 func init() { file_github_com_moby_buildkit_session_filesync_filesync_proto_init() }
@@ -122,7 +305,7 @@ func file_github_com_moby_buildkit_session_filesync_filesync_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_github_com_moby_buildkit_session_filesync_filesync_proto_rawDesc), len(file_github_com_moby_buildkit_session_filesync_filesync_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   1,
+			NumMessages:   4,
 			NumExtensions: 0,
 			NumServices:   2,
 		},
@@ -150,7 +150,8 @@ var FileSync_ServiceDesc = grpc.ServiceDesc{
 }
 
 const (
-	FileSend_DiffCopy_FullMethodName = "/moby.filesync.v1.FileSend/DiffCopy"
+	FileSend_DiffCopy_FullMethodName  = "/moby.filesync.v1.FileSend/DiffCopy"
+	FileSend_ListFiles_FullMethodName = "/moby.filesync.v1.FileSend/ListFiles"
 )
 
 // FileSendClient is the client API for FileSend service.
@@ -160,6 +161,8 @@ const (
 // FileSend allows sending files from the server back to the client.
 type FileSendClient interface {
 	DiffCopy(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[BytesMessage, BytesMessage], error)
+	// ListFiles returns the current files in the output directory.
+	ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ListFilesResponse], error)
 }
 
 type fileSendClient struct {
@@ -183,6 +186,25 @@ func (c *fileSendClient) DiffCopy(ctx context.Context, opts ...grpc.CallOption)
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type FileSend_DiffCopyClient = grpc.BidiStreamingClient[BytesMessage, BytesMessage]
 
+func (c *fileSendClient) ListFiles(ctx context.Context, in *ListFilesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ListFilesResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FileSend_ServiceDesc.Streams[1], FileSend_ListFiles_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListFilesRequest, ListFilesResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileSend_ListFilesClient = grpc.ServerStreamingClient[ListFilesResponse]
+
 // FileSendServer is the server API for FileSend service.
 // All implementations should embed UnimplementedFileSendServer
 // for forward compatibility.
@@ -190,6 +212,8 @@ type FileSend_DiffCopyClient = grpc.BidiStreamingClient[BytesMessage, BytesMessa
 // FileSend allows sending files from the server back to the client.
 type FileSendServer interface {
 	DiffCopy(grpc.BidiStreamingServer[BytesMessage, BytesMessage]) error
+	// ListFiles returns the current files in the output directory.
+	ListFiles(*ListFilesRequest, grpc.ServerStreamingServer[ListFilesResponse]) error
 }
 
 // UnimplementedFileSendServer should be embedded to have
@@ -202,6 +226,9 @@ type UnimplementedFileSendServer struct{}
 func (UnimplementedFileSendServer) DiffCopy(grpc.BidiStreamingServer[BytesMessage, BytesMessage]) error {
 	return status.Error(codes.Unimplemented, "method DiffCopy not implemented")
 }
+func (UnimplementedFileSendServer) ListFiles(*ListFilesRequest, grpc.ServerStreamingServer[ListFilesResponse]) error {
+	return status.Error(codes.Unimplemented, "method ListFiles not implemented")
+}
 func (UnimplementedFileSendServer) testEmbeddedByValue() {}
 
 // UnsafeFileSendServer may be embedded to opt out of forward compatibility for this service.
@@ -229,6 +256,17 @@ func _FileSend_DiffCopy_Handler(srv interface{}, stream grpc.ServerStream) error
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type FileSend_DiffCopyServer = grpc.BidiStreamingServer[BytesMessage, BytesMessage]
 
+func _FileSend_ListFiles_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListFilesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FileSendServer).ListFiles(m, &grpc.GenericServerStream[ListFilesRequest, ListFilesResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FileSend_ListFilesServer = grpc.ServerStreamingServer[ListFilesResponse]
+
 // FileSend_ServiceDesc is the grpc.ServiceDesc for FileSend service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -243,6 +281,11 @@ var FileSend_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "ListFiles",
+			Handler:       _FileSend_ListFiles_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "github.com/moby/buildkit/session/filesync/filesync.proto",
 }
@@ -642,6 +642,120 @@ func (x *ProvenanceMaterialIncomplete) GetReason() string {
 	return ""
 }
 
+type VerificationFailed struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Violations    []*VerifierViolation   `protobuf:"bytes,1,rep,name=violations,proto3" json:"violations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerificationFailed) Reset() {
+	*x = VerificationFailed{}
+	mi := &file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerificationFailed) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerificationFailed) ProtoMessage() {}
+
+func (x *VerificationFailed) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerificationFailed.ProtoReflect.Descriptor instead.
+func (*VerificationFailed) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *VerificationFailed) GetViolations() []*VerifierViolation {
+	if x != nil {
+		return x.Violations
+	}
+	return nil
+}
+
+type VerifierViolation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Name of the verifier that rejected the build result.
+	Verifier string `protobuf:"bytes,1,opt,name=verifier,proto3" json:"verifier,omitempty"`
+	Platform string `protobuf:"bytes,2,opt,name=platform,proto3" json:"platform,omitempty"`
+	// Path of the file in the build result, if the violation is about a file.
+	Path          string `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"`
+	Message       string `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VerifierViolation) Reset() {
+	*x = VerifierViolation{}
+	mi := &file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VerifierViolation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VerifierViolation) ProtoMessage() {}
+
+func (x *VerifierViolation) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VerifierViolation.ProtoReflect.Descriptor instead.
+func (*VerifierViolation) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *VerifierViolation) GetVerifier() string {
+	if x != nil {
+		return x.Verifier
+	}
+	return ""
+}
+
+func (x *VerifierViolation) GetPlatform() string {
+	if x != nil {
+		return x.Platform
+	}
+	return ""
+}
+
+func (x *VerifierViolation) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *VerifierViolation) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 var File_github_com_moby_buildkit_solver_errdefs_errdefs_proto protoreflect.FileDescriptor
 
 const file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDesc = "" +
@@ -689,7 +803,16 @@ const file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDesc = "" +
 	"\x06method\x18\x03 \x01(\tR\x06method\x12\x10\n" +
 	"\x03uri\x18\x04 \x01(\tR\x03uri\x12\x1b\n" +
 	"\tfinal_uri\x18\x05 \x01(\tR\bfinalUri\x12\x16\n" +
-	"\x06reason\x18\x06 \x01(\tR\x06reasonB)Z'github.com/moby/buildkit/solver/errdefsb\x06proto3"
+	"\x06reason\x18\x06 \x01(\tR\x06reason\"P\n" +
+	"\x12VerificationFailed\x12:\n" +
+	"\n" +
+	"violations\x18\x01 \x03(\v2\x1a.errdefs.VerifierViolationR\n" +
+	"violations\"y\n" +
+	"\x11VerifierViolation\x12\x1a\n" +
+	"\bverifier\x18\x01 \x01(\tR\bverifier\x12\x1a\n" +
+	"\bplatform\x18\x02 \x01(\tR\bplatform\x12\x12\n" +
+	"\x04path\x18\x03 \x01(\tR\x04path\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\tR\amessageB)Z'github.com/moby/buildkit/solver/errdefsb\x06proto3"
 
 var (
 	file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDescOnce sync.Once
@@ -703,7 +826,7 @@ func file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDescGZIP() []
 	return file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDescData
 }
 
-var file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_goTypes = []any{
 	(*Vertex)(nil),                        // 0: errdefs.Vertex
 	(*Source)(nil),                        // 1: errdefs.Source
@@ -716,24 +839,27 @@ var file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_goTypes = []any{
 	(*ContentCache)(nil),                  // 8: errdefs.ContentCache
 	(*ProvenanceMaterialsIncomplete)(nil), // 9: errdefs.ProvenanceMaterialsIncomplete
 	(*ProvenanceMaterialIncomplete)(nil),  // 10: errdefs.ProvenanceMaterialIncomplete
-	nil,                                   // 11: errdefs.Solve.DescriptionEntry
-	(*pb.SourceInfo)(nil),                 // 12: pb.SourceInfo
-	(*pb.Range)(nil),                      // 13: pb.Range
-	(*pb.Op)(nil),                         // 14: pb.Op
+	(*VerificationFailed)(nil),            // 11: errdefs.VerificationFailed
+	(*VerifierViolation)(nil),             // 12: errdefs.VerifierViolation
+	nil,                                   // 13: errdefs.Solve.DescriptionEntry
+	(*pb.SourceInfo)(nil),                 // 14: pb.SourceInfo
+	(*pb.Range)(nil),                      // 15: pb.Range
+	(*pb.Op)(nil),                         // 16: pb.Op
 }
 var file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_depIdxs = []int32{
-	12, // 0: errdefs.Source.info:type_name -> pb.SourceInfo
-	13, // 1: errdefs.Source.ranges:type_name -> pb.Range
-	14, // 2: errdefs.Solve.op:type_name -> pb.Op
+	14, // 0: errdefs.Source.info:type_name -> pb.SourceInfo
+	15, // 1: errdefs.Source.ranges:type_name -> pb.Range
+	16, // 2: errdefs.Solve.op:type_name -> pb.Op
 	7,  // 3: errdefs.Solve.file:type_name -> errdefs.FileAction
 	8,  // 4: errdefs.Solve.cache:type_name -> errdefs.ContentCache
-	11, // 5: errdefs.Solve.description:type_name -> errdefs.Solve.DescriptionEntry
+	13, // 5: errdefs.Solve.description:type_name -> errdefs.Solve.DescriptionEntry
 	10, // 6: errdefs.ProvenanceMaterialsIncomplete.incomplete:type_name -> errdefs.ProvenanceMaterialIncomplete
-	7,  // [7:7] is the sub-list for method output_type
-	7,  // [7:7] is the sub-list for method input_type
-	7,  // [7:7] is the sub-list for extension type_name
-	7,  // [7:7] is the sub-list for extension extendee
-	0,  // [0:7] is the sub-list for field type_name
+	12, // 7: errdefs.VerificationFailed.violations:type_name -> errdefs.VerifierViolation
+	8,  // [8:8] is the sub-list for method output_type
+	8,  // [8:8] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_init() }
@@ -751,7 +877,7 @@ func file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDesc), len(file_github_com_moby_buildkit_solver_errdefs_errdefs_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   12,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
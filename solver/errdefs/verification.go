@@ -0,0 +1,42 @@
+package errdefs
+
+import (
+	"github.com/containerd/typeurl/v2"
+	"github.com/moby/buildkit/util/grpcerrors"
+)
+
+func init() {
+	typeurl.Register((*VerificationFailed)(nil), "github.com/moby/buildkit", "errdefs.VerificationFailed+json")
+}
+
+type VerificationFailedError struct {
+	*VerificationFailed
+	error
+}
+
+func (e *VerificationFailedError) Unwrap() error {
+	return e.error
+}
+
+func (e *VerificationFailedError) ToProto() grpcerrors.TypedErrorProto {
+	return e.VerificationFailed
+}
+
+func (v *VerificationFailed) WrapError(err error) error {
+	return &VerificationFailedError{
+		error:              err,
+		VerificationFailed: v,
+	}
+}
+
+func WithVerificationFailed(err error, violations []*VerifierViolation) error {
+	if err == nil {
+		return nil
+	}
+	return &VerificationFailedError{
+		error: err,
+		VerificationFailed: &VerificationFailed{
+			Violations: violations,
+		},
+	}
+}
@@ -0,0 +1,233 @@
+package llbsolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/remotes"
+	"github.com/containerd/platforms"
+	slsa02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	"github.com/moby/buildkit/solver"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/moby/buildkit/sourcepolicy"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/contentutil"
+	policy "github.com/moby/policy-helpers"
+	"github.com/moby/policy-helpers/image"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// PolicyVerifierProvider returns the verifier used for image signatures
+// referenced by source policy attestation constraints.
+type PolicyVerifierProvider func() (*policy.Verifier, error)
+
+// attestationResolver resolves the attestations of image sources for the
+// attestation constraints of the source policy. Image metadata is resolved
+// directly from the worker so that the policy is not evaluated recursively.
+type attestationResolver struct {
+	*llbBridge
+	platform *pb.Platform
+}
+
+var _ sourcepolicy.AttestationResolver = &attestationResolver{}
+
+func (r *attestationResolver) ResolveAttestations(ctx context.Context, op *pb.SourceOp) (*sourcepolicy.ImageAttestations, error) {
+	w, err := r.resolveWorker()
+	if err != nil {
+		return nil, err
+	}
+	platform := toOCIPlatform(r.platform)
+	if platform == nil {
+		p := platforms.Normalize(platforms.DefaultSpec())
+		platform = &p
+	}
+	opt := sourceresolver.Opt{
+		LogName: "resolve attestations for " + op.Identifier,
+		ImageOpt: &sourceresolver.ResolveImageOpt{
+			Platform:            platform,
+			NoConfig:            true,
+			AttestationChain:    true,
+			ResolveAttestations: []string{slsa02.PredicateSLSAProvenance, slsa1.PredicateSLSAProvenance},
+		},
+	}
+	var resp *sourceresolver.MetaResponse
+	err = inBuilderContext(ctx, r.builder, opt.LogName, op.Identifier+platforms.FormatAll(*platform), func(ctx context.Context, jobCtx solver.JobContext) error {
+		resp, err = w.ResolveSourceMetadata(ctx, op.CloneVT(), opt, r.sm, jobCtx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Image == nil {
+		return &sourcepolicy.ImageAttestations{}, nil
+	}
+	atts, err := imageAttestations(ctx, resp.Image.AttestationChain, platform, r.policyVerifier)
+	if err != nil {
+		return nil, err
+	}
+	atts.Digest = resp.Image.Digest
+	if ac := resp.Image.AttestationChain; ac != nil && ac.Root != "" {
+		atts.Digest = ac.Root
+	}
+	return atts, nil
+}
+
+// imageAttestations returns the verified attestations of the attestation chain.
+// Provenance attestations are only reported if the attestation manifest has a
+// signature that can be verified, and must list the image manifest as subject.
+// Unsigned or invalid attestations are ignored so that the constraints of the
+// rule don't match, instead of failing the policy evaluation.
+func imageAttestations(ctx context.Context, ac *sourceresolver.AttestationChain, platform *ocispecs.Platform, vp PolicyVerifierProvider) (*sourcepolicy.ImageAttestations, error) {
+	atts := &sourcepolicy.ImageAttestations{}
+	if ac == nil || ac.AttestationManifest == "" {
+		return atts, nil
+	}
+	if len(ac.SignatureManifests) == 0 {
+		bklog.G(ctx).Debugf("ignoring unsigned attestations of %s", ac.Root)
+		return atts, nil
+	}
+	if vp == nil {
+		bklog.G(ctx).Debugf("no policy verifier configured, ignoring attestations of %s", ac.Root)
+		return atts, nil
+	}
+	v, err := vp()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create policy verifier")
+	}
+	root, ok := ac.Blobs[ac.Root]
+	if !ok {
+		bklog.G(ctx).Debugf("image index %s not found, ignoring attestations", ac.Root)
+		return atts, nil
+	}
+	prov, err := newAttestationChainProvider(ctx, ac)
+	if err != nil {
+		bklog.G(ctx).Debugf("ignoring attestations of %s: %v", ac.Root, err)
+		return atts, nil
+	}
+	si, err := v.VerifyImage(ctx, prov, root.Descriptor, platform)
+	if err != nil {
+		bklog.G(ctx).Debugf("failed to verify signature of %s, ignoring attestations: %v", ac.Root, err)
+		return atts, nil
+	}
+	if si.Signer != nil && si.Signer.SubjectAlternativeName != "" {
+		atts.Signers = append(atts.Signers, si.Signer.SubjectAlternativeName)
+	}
+	atts.Provenance = signedProvenance(ctx, ac)
+	return atts, nil
+}
+
+// signedProvenance returns the builder IDs of the provenance attestations of
+// the attestation manifest. The signature of the attestation manifest must
+// have been verified, as it is what binds the statements to the image.
+func signedProvenance(ctx context.Context, ac *sourceresolver.AttestationChain) []string {
+	att, ok := ac.Blobs[ac.AttestationManifest]
+	if !ok {
+		bklog.G(ctx).Debugf("attestation manifest %s not found", ac.AttestationManifest)
+		return nil
+	}
+	var mfst ocispecs.Manifest
+	if err := json.Unmarshal(att.Data, &mfst); err != nil {
+		bklog.G(ctx).Debugf("failed to unmarshal attestation manifest %s: %v", ac.AttestationManifest, err)
+		return nil
+	}
+	var ids []string
+	for _, l := range mfst.Layers {
+		switch l.Annotations["in-toto.io/predicate-type"] {
+		case slsa02.PredicateSLSAProvenance, slsa1.PredicateSLSAProvenance:
+		default:
+			continue
+		}
+		blob, ok := ac.Blobs[l.Digest]
+		if !ok || l.Digest.Validate() != nil || l.Digest.Algorithm().FromBytes(blob.Data) != l.Digest {
+			continue
+		}
+		id, err := provenanceBuilderID(blob.Data, ac.ImageManifest)
+		if err != nil {
+			bklog.G(ctx).Debugf("ignoring invalid provenance attestation %s: %v", l.Digest, err)
+			continue
+		}
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// provenanceBuilderID returns the builder ID of an in-toto SLSA provenance
+// statement after checking that its subject is the image manifest.
+func provenanceBuilderID(dt []byte, manifest digest.Digest) (string, error) {
+	var stmt struct {
+		PredicateType string `json:"predicateType"`
+		Subject       []struct {
+			Digest map[string]string `json:"digest"`
+		} `json:"subject"`
+		Predicate json.RawMessage `json:"predicate"`
+	}
+	if err := json.Unmarshal(dt, &stmt); err != nil {
+		return "", errors.Wrap(err, "failed to unmarshal in-toto statement")
+	}
+	var found bool
+	for _, s := range stmt.Subject {
+		if s.Digest[manifest.Algorithm().String()] == manifest.Encoded() {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", errors.Errorf("statement subject does not match image manifest %s", manifest)
+	}
+	switch stmt.PredicateType {
+	case slsa02.PredicateSLSAProvenance:
+		var pred slsa02.ProvenancePredicate
+		if err := json.Unmarshal(stmt.Predicate, &pred); err != nil {
+			return "", errors.Wrap(err, "failed to unmarshal provenance predicate")
+		}
+		return pred.Builder.ID, nil
+	case slsa1.PredicateSLSAProvenance:
+		var pred slsa1.ProvenancePredicate
+		if err := json.Unmarshal(stmt.Predicate, &pred); err != nil {
+			return "", errors.Wrap(err, "failed to unmarshal provenance predicate")
+		}
+		return pred.RunDetails.Builder.ID, nil
+	default:
+		return "", errors.Errorf("unsupported predicate type %q", stmt.PredicateType)
+	}
+}
+
+// attestationChainProvider serves the blobs of a resolved attestation chain
+// for signature verification without contacting the registry again.
+type attestationChainProvider struct {
+	content.Provider
+	ac *sourceresolver.AttestationChain
+}
+
+func newAttestationChainProvider(ctx context.Context, ac *sourceresolver.AttestationChain) (*attestationChainProvider, error) {
+	buf := contentutil.NewBuffer()
+	for dgst, b := range ac.Blobs {
+		if err := content.WriteBlob(ctx, buf, dgst.String(), bytes.NewReader(b.Data), b.Descriptor); err != nil {
+			return nil, errors.Wrapf(err, "failed to add blob %s", dgst)
+		}
+	}
+	return &attestationChainProvider{Provider: buf, ac: ac}, nil
+}
+
+func (p *attestationChainProvider) FetchReferrers(ctx context.Context, dgst digest.Digest, opts ...remotes.FetchReferrersOpt) ([]ocispecs.Descriptor, error) {
+	if dgst != p.ac.AttestationManifest {
+		return nil, nil
+	}
+	var refs []ocispecs.Descriptor
+	for _, d := range p.ac.SignatureManifests {
+		if b, ok := p.ac.Blobs[d]; ok {
+			refs = append(refs, b.Descriptor)
+		}
+	}
+	return refs, nil
+}
+
+var _ image.ReferrersProvider = &attestationChainProvider{}
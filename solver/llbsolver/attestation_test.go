@@ -0,0 +1,95 @@
+package llbsolver
+
+import (
+	"encoding/json"
+	"testing"
+
+	slsa02 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v0.2"
+	slsa1 "github.com/in-toto/in-toto-golang/in_toto/slsa_provenance/v1"
+	"github.com/moby/buildkit/client/llb/sourceresolver"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageAttestations(t *testing.T) {
+	t.Parallel()
+
+	manifest := digest.FromString("manifest")
+	statement := func(subject digest.Digest, predicateType string, predicate any) []byte {
+		dt, err := json.Marshal(map[string]any{
+			"_type":         "https://in-toto.io/Statement/v0.1",
+			"predicateType": predicateType,
+			"subject": []map[string]any{
+				{"name": "_", "digest": map[string]string{"sha256": subject.Encoded()}},
+			},
+			"predicate": predicate,
+		})
+		require.NoError(t, err)
+		return dt
+	}
+	chain := func(stmts ...[]byte) *sourceresolver.AttestationChain {
+		ac := &sourceresolver.AttestationChain{
+			Root:          digest.FromString("index"),
+			ImageManifest: manifest,
+			Blobs:         map[digest.Digest]sourceresolver.Blob{},
+		}
+		var mfst ocispecs.Manifest
+		for _, dt := range stmts {
+			var s struct {
+				PredicateType string `json:"predicateType"`
+			}
+			require.NoError(t, json.Unmarshal(dt, &s))
+			desc := ocispecs.Descriptor{
+				MediaType:   "application/vnd.in-toto+json",
+				Digest:      digest.FromBytes(dt),
+				Size:        int64(len(dt)),
+				Annotations: map[string]string{"in-toto.io/predicate-type": s.PredicateType},
+			}
+			mfst.Layers = append(mfst.Layers, desc)
+			ac.Blobs[desc.Digest] = sourceresolver.Blob{Descriptor: desc, Data: dt}
+		}
+		dt, err := json.Marshal(mfst)
+		require.NoError(t, err)
+		ac.AttestationManifest = digest.FromBytes(dt)
+		ac.Blobs[ac.AttestationManifest] = sourceresolver.Blob{Data: dt}
+		return ac
+	}
+
+	atts, err := imageAttestations(t.Context(), nil, nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, atts.Provenance)
+
+	provenance := chain(
+		statement(manifest, slsa02.PredicateSLSAProvenance, map[string]any{"builder": map[string]any{"id": "https://example.com/v02"}}),
+		statement(manifest, slsa1.PredicateSLSAProvenance, map[string]any{"runDetails": map[string]any{"builder": map[string]any{"id": "https://example.com/v1"}}}),
+		statement(manifest, "https://spdx.dev/Document", map[string]any{}),
+		statement(digest.FromString("other"), slsa1.PredicateSLSAProvenance, map[string]any{"runDetails": map[string]any{"builder": map[string]any{"id": "https://example.com/other"}}}),
+	)
+	require.Equal(t, []string{"https://example.com/v02", "https://example.com/v1"}, signedProvenance(t.Context(), provenance))
+
+	// unsigned provenance is ignored
+	atts, err = imageAttestations(t.Context(), provenance, nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, atts.Provenance)
+	require.Empty(t, atts.Signers)
+
+	// signatures can't be verified without a verifier
+	provenance.SignatureManifests = []digest.Digest{digest.FromString("signature")}
+	atts, err = imageAttestations(t.Context(), provenance, nil, nil)
+	require.NoError(t, err)
+	require.Empty(t, atts.Provenance)
+	require.Empty(t, atts.Signers)
+
+	// statements that don't match their digest are ignored
+	tampered := chain(
+		statement(manifest, slsa1.PredicateSLSAProvenance, map[string]any{"runDetails": map[string]any{"builder": map[string]any{"id": "https://example.com/v1"}}}),
+	)
+	for dgst, b := range tampered.Blobs {
+		if dgst != tampered.AttestationManifest {
+			b.Data = statement(manifest, slsa1.PredicateSLSAProvenance, map[string]any{"runDetails": map[string]any{"builder": map[string]any{"id": "https://example.com/forged"}}})
+			tampered.Blobs[dgst] = b
+		}
+	}
+	require.Empty(t, signedProvenance(t.Context(), tampered))
+}
@@ -42,6 +42,10 @@ type llbBridge struct {
 	sm                        *session.Manager
 	provenanceStore           *provenanceStore
 	proxyNetwork              bool
+	policyVerifier            PolicyVerifierProvider
+
+	policyWarningsMu sync.Mutex
+	policyWarnings   map[digest.Digest]map[string]struct{}
 
 	executorOnce sync.Once
 	executorErr  error
@@ -84,7 +88,7 @@ func (b *llbBridge) loadResult(ctx context.Context, def *pb.Definition, cacheImp
 	if err != nil {
 		return nil, err
 	}
-	var polEngine *sourcepolicy.Engine
+	var polEval SourcePolicyEvaluator
 	if srcPol != nil || len(pol) > 0 {
 		for _, p := range pol {
 			if p == nil {
@@ -97,7 +101,7 @@ func (b *llbBridge) loadResult(ctx context.Context, def *pb.Definition, cacheImp
 		if srcPol != nil {
 			pol = append([]*spb.Policy{srcPol}, pol...)
 		}
-		polEngine = sourcepolicy.NewEngine(pol)
+		polEval = b.policy(pol)
 	}
 	var cms []solver.CacheManager
 	for _, im := range cacheImports {
@@ -142,7 +146,7 @@ func (b *llbBridge) loadResult(ctx context.Context, def *pb.Definition, cacheImp
 	}
 	dpc := &detectPrunedCacheID{}
 
-	edge, err := loadWithProxyNetwork(ctx, def, b.policy(polEngine), b.proxyNetwork, dpc.Load, ValidateEntitlements(ent, w.CDIManager()), WithCacheSources(cms), NormalizeRuntimePlatforms(), WithValidateCaps(), WithLinuxResourcesMetadata())
+	edge, err := loadWithProxyNetwork(ctx, def, polEval, b.proxyNetwork, dpc.Load, ValidateEntitlements(ent, w.CDIManager()), WithCacheSources(cms), NormalizeRuntimePlatforms(), WithValidateCaps(), WithLinuxResourcesMetadata())
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load LLB")
 	}
@@ -162,13 +166,28 @@ func (b *llbBridge) loadResult(ctx context.Context, def *pb.Definition, cacheImp
 	return res, nil
 }
 
-func (b *llbBridge) policy(engine *sourcepolicy.Engine) SourcePolicyEvaluator {
-	if engine == nil {
-		return nil
+// markPolicyWarning records that the source policy warning msg was reported
+// for the vertex dgst. It returns false if it already was.
+func (b *llbBridge) markPolicyWarning(dgst digest.Digest, msg string) bool {
+	b.policyWarningsMu.Lock()
+	defer b.policyWarningsMu.Unlock()
+	if _, ok := b.policyWarnings[dgst][msg]; ok {
+		return false
+	}
+	if b.policyWarnings == nil {
+		b.policyWarnings = map[digest.Digest]map[string]struct{}{}
+	}
+	if b.policyWarnings[dgst] == nil {
+		b.policyWarnings[dgst] = map[string]struct{}{}
 	}
+	b.policyWarnings[dgst][msg] = struct{}{}
+	return true
+}
+
+func (b *llbBridge) policy(pol []*spb.Policy) *policyEvaluator {
 	return &policyEvaluator{
 		llbBridge: b,
-		engine:    engine,
+		engine:    sourcepolicy.NewEngine(pol),
 	}
 }
 
@@ -279,11 +298,12 @@ func (b *llbBridge) resolveSourceMetadata(ctx context.Context, op *pb.SourceOp,
 		opt.SourcePolicies = append(opt.SourcePolicies, pol)
 	}
 
-	engine := sourcepolicy.NewEngine(opt.SourcePolicies)
-
 	if !withPolicy {
 		op.Identifier = normalizedSourceIdentifier(w, op)
-		if _, err := engine.Evaluate(ctx, op); err != nil {
+		if _, err := sourcepolicy.NewEngine(opt.SourcePolicies).Evaluate(ctx, op, sourcepolicy.WithAttestationResolver(&attestationResolver{
+			llbBridge: b,
+			platform:  toPBPlatform(platform),
+		})); err != nil {
 			return nil, errors.Wrap(err, "could not resolve image due to policy")
 		}
 	} else {
@@ -293,7 +313,9 @@ func (b *llbBridge) resolveSourceMetadata(ctx context.Context, op *pb.SourceOp,
 		} else if opt.OCILayoutOpt != nil {
 			p = opt.OCILayoutOpt.Platform
 		}
-		if _, err := b.policy(engine).Evaluate(ctx, &pb.Op{
+		// warnings are reported when the source is loaded, as the vertex
+		// doesn't exist yet
+		if _, _, err := b.policy(opt.SourcePolicies).EvaluateSource(ctx, &pb.Op{
 			Op:       &pb.Op_Source{Source: op},
 			Platform: toPBPlatform(p),
 		}); err != nil {
@@ -29,14 +29,14 @@ import (
 	"google.golang.org/grpc/codes"
 )
 
-func (s *Solver) getSessionExporters(ctx context.Context, sessionID string, id int, inp *exporter.Source) ([]exporter.ExporterInstance, error) {
+func (s *Solver) getSessionExporters(ctx context.Context, sessionID string, id int, inp *exporter.Source) ([]exporter.ExporterInstance, map[string]string, error) {
 	timeoutCtx, cancel := context.WithCancelCause(ctx)
 	timeoutCtx, _ = context.WithTimeoutCause(timeoutCtx, 5*time.Second, errors.WithStack(context.DeadlineExceeded)) //nolint:govet
 	defer func() { cancel(errors.WithStack(context.Canceled)) }()
 
 	caller, err := s.sm.Get(timeoutCtx, sessionID, false)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	client := sessionexporter.NewExporterClient(caller.Conn())
@@ -46,7 +46,7 @@ func (s *Solver) getSessionExporters(ctx context.Context, sessionID string, id i
 		ids = append(ids, ref.ID())
 		return nil
 	}); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	res, err := client.FindExporters(ctx, &sessionexporter.FindExportersRequest{
@@ -56,30 +56,35 @@ func (s *Solver) getSessionExporters(ctx context.Context, sessionID string, id i
 	if err != nil {
 		switch grpcerrors.Code(err) {
 		case codes.Unavailable, codes.Unimplemented:
-			return nil, nil
+			return nil, nil, nil
 		default:
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	w, err := defaultResolver(s.workerController)()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var out []exporter.ExporterInstance
+	var verifierOpts map[string]string
 	for i, req := range res.Exporters {
 		exp, err := w.Exporter(req.Type, s.sm)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		expi, err := exp.Resolve(ctx, id+i, req.Attrs)
+		opts, attrs := verifier.SplitAttrs(req.Attrs)
+		if verifierOpts, err = verifier.MergeOpts(verifierOpts, opts); err != nil {
+			return nil, nil, err
+		}
+		expi, err := exp.Resolve(ctx, id+i, attrs)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		out = append(out, expi)
 	}
-	return out, nil
+	return out, verifierOpts, nil
 }
 
 func (s *Solver) finalizeSessionExport(ctx context.Context, sessionID string, exporterResponse map[string]string) error {
@@ -179,12 +184,34 @@ func exporterVertexID(sessionID string, exporterIndex int) string {
 	return fmt.Sprint(sessionID, "-export-", exporterIndex)
 }
 
-func (s *Solver) runExporters(ctx context.Context, ref string, exporters []exporter.ExporterInstance, inlineCacheExporter inlineCacheExporter, job *solver.Job, cached *result.Result[solver.CachedResult], inp *exporter.Source) (exporterResponse map[string]string, finalizers []exporter.FinalizeFunc, descrefs []exporter.DescriptorReference, err error) {
+// verifiers returns the verifiers for the options of the exporters combined
+// with the ones the daemon enforces.
+func (s *Solver) verifiers(opts map[string]string) ([]verifier.Verifier, error) {
+	merged, err := verifier.MergeEnforcedOpts(opts, s.verifierOpts)
+	if err != nil {
+		return nil, err
+	}
+	return verifier.New(merged)
+}
+
+func (s *Solver) runExporters(ctx context.Context, ref string, exporters []exporter.ExporterInstance, verifiers []verifier.Verifier, inlineCacheExporter inlineCacheExporter, job *solver.Job, cached *result.Result[solver.CachedResult], inp *exporter.Source, artifacts *exporter.Source) (exporterResponses []map[string]string, finalizers []exporter.FinalizeFunc, descrefs []exporter.DescriptorReference, err error) {
 	warnings, err := verifier.CheckInvalidPlatforms(ctx, inp)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
+	if len(verifiers) > 0 {
+		err := inBuilderContext(ctx, job, "verifying build result", identity.NewID(), func(ctx context.Context, _ solver.JobContext) error {
+			if err := verifier.CheckResult(ctx, inp, job.SessionID, verifiers); err != nil {
+				return err
+			}
+			return verifier.CheckArtifacts(ctx, artifacts, job.SessionID, verifiers)
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
 	eg, ctx := errgroup.WithContext(ctx)
 	resps := make([]map[string]string, len(exporters))
 	finalizeFuncs := make([]exporter.FinalizeFunc, len(exporters))
@@ -221,6 +248,7 @@ func (s *Solver) runExporters(ctx context.Context, ref string, exporters []expor
 					SessionID:            job.SessionID,
 					InlineCache:          inlineCache,
 					CompatibilityVersion: compatibilityVersion,
+					Artifacts:            artifacts,
 				})
 				resps[i], finalizeFuncs[i], descs[i] = resp, finalize, desc
 				if expErr != nil {
@@ -247,8 +275,16 @@ func (s *Solver) runExporters(ctx context.Context, ref string, exporters []expor
 		}
 	}
 
+	return resps, finalizeFuncs, descs, nil
+}
+
+// mergeExporterResponses combines the responses of all exporters. It is
+// called after the exporters are finalized as finalizing may update the
+// response of an exporter.
+func mergeExporterResponses(resps []map[string]string) map[string]string {
 	// TODO: separate these out, and return multiple exporter responses to the
 	// client
+	var exporterResponse map[string]string
 	for _, resp := range resps {
 		for k, v := range resp {
 			if exporterResponse == nil {
@@ -257,8 +293,7 @@ func (s *Solver) runExporters(ctx context.Context, ref string, exporters []expor
 			exporterResponse[k] = v
 		}
 	}
-
-	return exporterResponse, finalizeFuncs, descs, nil
+	return exporterResponse
 }
 
 func splitCacheExporters(exporters []RemoteCacheExporter) (rest []RemoteCacheExporter, inline inlineCacheExporter) {
@@ -236,6 +236,27 @@ func (s *Solver) recordBuildHistory(ctx context.Context, id string, req frontend
 				mu.Unlock()
 				return nil
 			})
+			if rp, ok := descref.(exporter.ReportProvider); ok {
+				if report := rp.Report(); report != nil {
+					eg.Go(func() error {
+						desc, release, err := s.saveReport(ctx2, report)
+						if err != nil {
+							return err
+						}
+						mu.Lock()
+						releasers = append(releasers, release)
+						if rec.Result == nil {
+							rec.Result = &controlapi.BuildResultInfo{}
+						}
+						if rec.Result.Reports == nil {
+							rec.Result.Reports = make(map[int64]*controlapi.Descriptor)
+						}
+						rec.Result.Reports[int64(i)] = desc
+						mu.Unlock()
+						return nil
+					})
+				}
+			}
 		}
 		if err1 := eg.Wait(); err == nil {
 			// any error from exporting history record is internal
@@ -334,3 +355,29 @@ func (s *Solver) recordBuildHistory(ctx context.Context, id string, req frontend
 		return err
 	}, nil
 }
+
+// saveReport stores the report of an exporter in the build history.
+func (s *Solver) saveReport(ctx context.Context, report *exporter.Report) (*controlapi.Descriptor, func(), error) {
+	w, err := s.history.OpenBlobWriter(ctx, report.MediaType)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if w != nil {
+			w.Discard()
+		}
+	}()
+	if _, err := w.Write(report.Data); err != nil {
+		return nil, nil, err
+	}
+	desc, release, err := w.Commit(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	w = nil
+	return &controlapi.Descriptor{
+		Digest:    string(desc.Digest),
+		Size:      desc.Size,
+		MediaType: desc.MediaType,
+	}, release, nil
+}
@@ -465,6 +465,11 @@ func (h *Queue) update(ctx context.Context, rec *controlapi.BuildHistoryRecord)
 					return err
 				}
 			}
+			for _, report := range rec.Result.Reports {
+				if err := h.addResource(ctx, l, report, false); err != nil {
+					return err
+				}
+			}
 		}
 		for _, r := range rec.Results {
 			if err := h.addResource(ctx, l, r.ResultDeprecated, true); err != nil {
@@ -5,9 +5,9 @@ import (
 
 	"github.com/moby/buildkit/solver"
 	"github.com/moby/buildkit/solver/pb"
-	"github.com/moby/buildkit/sourcepolicy"
 	spb "github.com/moby/buildkit/sourcepolicy/pb"
 	"github.com/moby/buildkit/util/network"
+	digest "github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 )
 
@@ -58,6 +58,12 @@ func loadProxyNetwork(b solver.Builder) bool {
 }
 
 func (b *llbBridge) ProxyPolicy() (network.ProxyPolicy, error) {
+	return b.proxyPolicy("")
+}
+
+// proxyPolicy returns the policy for the requests of an exec through the
+// proxy network. Warnings are reported on the exec vertex vtx, if set.
+func (b *llbBridge) proxyPolicy(vtx digest.Digest) (network.ProxyPolicy, error) {
 	srcPol, err := loadSourcePolicy(b.builder)
 	if err != nil {
 		return nil, err
@@ -73,5 +79,7 @@ func (b *llbBridge) ProxyPolicy() (network.ProxyPolicy, error) {
 	if srcPol != nil {
 		policies = append(policies, srcPol)
 	}
-	return b.policy(sourcepolicy.NewEngine(policies)), nil
+	pe := b.policy(policies)
+	pe.vertex = vtx
+	return pe, nil
 }
@@ -2,10 +2,12 @@ package llbsolver
 
 import (
 	"context"
+	"fmt"
 	"slices"
 	"strings"
 
 	"github.com/moby/buildkit/client/llb/sourceresolver"
+	"github.com/moby/buildkit/frontend"
 	"github.com/moby/buildkit/frontend/gateway"
 	gatewaypb "github.com/moby/buildkit/frontend/gateway/pb"
 	"github.com/moby/buildkit/solver"
@@ -13,7 +15,9 @@ import (
 	"github.com/moby/buildkit/sourcepolicy"
 	spb "github.com/moby/buildkit/sourcepolicy/pb"
 	"github.com/moby/buildkit/sourcepolicy/policysession"
+	"github.com/moby/buildkit/util/bklog"
 	"github.com/moby/buildkit/worker"
+	digest "github.com/opencontainers/go-digest"
 	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 )
@@ -26,11 +30,20 @@ const (
 // SourcePolicyEvaluator evaluates source operations against configured policies.
 type SourcePolicyEvaluator interface {
 	Evaluate(ctx context.Context, op *pb.Op) (bool, error)
+	// EvaluateSource evaluates the source policy for the op like Evaluate,
+	// but returns the warnings instead of reporting them.
+	EvaluateSource(ctx context.Context, op *pb.Op) (bool, []string, error)
+	// ReportWarnings reports the warnings returned by EvaluateSource on the
+	// vertex dgst.
+	ReportWarnings(ctx context.Context, dgst digest.Digest, warnings []string)
 }
 
 type policyEvaluator struct {
 	*llbBridge
 	engine *sourcepolicy.Engine
+	// vertex is the exec vertex that warnings are reported on, for the
+	// requests that it makes through the proxy network
+	vertex digest.Digest
 }
 
 func normalizedSourceIdentifier(w worker.Worker, op *pb.SourceOp) string {
@@ -44,11 +57,45 @@ func normalizedSourceIdentifier(w worker.Worker, op *pb.SourceOp) string {
 	return id.String()
 }
 
+// Evaluate evaluates the source policy for the op. Warnings are reported on
+// the exec vertex of the evaluator if set, otherwise on the vertex of the op
+// after convert rules were applied.
 func (p *policyEvaluator) Evaluate(ctx context.Context, op *pb.Op) (bool, error) {
-	return p.evaluate(ctx, op, 10)
+	ok, warnings, err := p.EvaluateSource(ctx, op)
+	if err != nil {
+		return false, err
+	}
+	if len(warnings) > 0 {
+		dgst := p.vertex
+		if dgst == "" {
+			dt, err := op.Marshal()
+			if err != nil {
+				return false, err
+			}
+			dgst = digest.FromBytes(dt)
+		}
+		p.ReportWarnings(ctx, dgst, warnings)
+	}
+	return ok, nil
 }
 
-func (p *policyEvaluator) evaluate(ctx context.Context, op *pb.Op, max int) (bool, error) {
+// EvaluateSource evaluates the source policy for the op and returns the
+// warnings instead of reporting them, as the digest of the vertex is only
+// known once all the sources of a definition have been evaluated. The policy
+// is evaluated again every time a source is loaded, as the attestations and
+// signatures it depends on can change between resolutions.
+func (p *policyEvaluator) EvaluateSource(ctx context.Context, op *pb.Op) (bool, []string, error) {
+	var warnings []string
+	ok, err := p.evaluate(ctx, op, 10, func(_ context.Context, msg string) {
+		warnings = append(warnings, msg)
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return ok, warnings, nil
+}
+
+func (p *policyEvaluator) evaluate(ctx context.Context, op *pb.Op, max int, warn func(context.Context, string)) (bool, error) {
 	source := op.GetSource()
 	if source == nil {
 		return false, nil
@@ -58,7 +105,10 @@ func (p *policyEvaluator) evaluate(ctx context.Context, op *pb.Op, max int) (boo
 		return false, err
 	}
 	source.Identifier = normalizedSourceIdentifier(w, source)
-	ok, err := p.engine.Evaluate(ctx, source)
+	ok, err := p.engine.Evaluate(ctx, source, sourcepolicy.WithAttestationResolver(&attestationResolver{
+		llbBridge: p.llbBridge,
+		platform:  op.Platform,
+	}), sourcepolicy.WithWarnFunc(warn))
 	if err != nil {
 		return false, err
 	}
@@ -165,12 +215,19 @@ func (p *policyEvaluator) evaluate(ctx context.Context, op *pb.Op, max int) (boo
 			}
 			source.Identifier = newSrc.Identifier
 			source.Attrs = newSrc.Attrs
-			_, err = p.evaluate(ctx, op, max)
+			_, err = p.evaluate(ctx, op, max, warn)
 			if err != nil {
 				return false, err
 			}
 			return true, nil
 		}
+		if decision.Action == spb.PolicyAction_WARN {
+			warn(ctx, fmt.Sprintf("source %q matched policy warn decision", source.Identifier))
+			for _, m := range decision.GetDenyMessages() {
+				warn(ctx, m.GetMessage())
+			}
+			return ok, nil
+		}
 		if decision.Action != spb.PolicyAction_ALLOW {
 			err := errors.Errorf("source %q not allowed by policy: action %s", source.Identifier, decision.Action.String())
 			return false, policysession.WrapDenyMessages(err, decision.GetDenyMessages())
@@ -179,6 +236,20 @@ func (p *policyEvaluator) evaluate(ctx context.Context, op *pb.Op, max int) (boo
 	}
 }
 
+// ReportWarnings reports source policy warnings as build warnings of the
+// vertex. A warning that was already reported for the vertex in the build,
+// because the source was resolved or loaded before, is skipped.
+func (p *policyEvaluator) ReportWarnings(ctx context.Context, dgst digest.Digest, msgs []string) {
+	for _, msg := range msgs {
+		if !p.markPolicyWarning(dgst, msg) {
+			continue
+		}
+		if err := p.Warn(ctx, dgst, msg, frontend.WarnOpts{}); err != nil {
+			bklog.G(ctx).Warnf("failed to report source policy warning: %v", err)
+		}
+	}
+}
+
 func mapsEqual[K comparable, V comparable](a, b map[K]V) error {
 	if len(a) != len(b) {
 		return errors.Errorf("map length mismatch: %d != %d", len(a), len(b))
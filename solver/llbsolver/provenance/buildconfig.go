@@ -3,6 +3,7 @@ package provenance
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/moby/buildkit/solver"
 	provenancetypes "github.com/moby/buildkit/solver/llbsolver/provenance/types"
@@ -143,11 +144,29 @@ func toBuildSteps(def *pb.Definition, c *Capture, withUsage bool) ([]provenancet
 		if withUsage {
 			s.ResourceUsage = c.Samples[dgst]
 		}
+		if md, ok := def.Metadata[string(dgst)]; ok {
+			s.Metadata = provenanceMetadata(md.GetDescription())
+		}
 		out = append(out, s)
 	}
 	return out, indexes, nil
 }
 
+// provenanceMetadata returns the description keys of an op that are recorded
+// in the provenance, without their prefix.
+func provenanceMetadata(desc map[string]string) map[string]string {
+	var m map[string]string
+	for k, v := range desc {
+		if k, ok := strings.CutPrefix(k, pb.ProvenanceMetadataPrefix); ok {
+			if m == nil {
+				m = map[string]string{}
+			}
+			m[k] = v
+		}
+	}
+	return m
+}
+
 func walkDigests(dgsts []digest.Digest, ops map[digest.Digest]*pb.Op, dgst digest.Digest, visited map[digest.Digest]struct{}) ([]digest.Digest, error) {
 	if _, ok := visited[dgst]; ok {
 		return dgsts, nil
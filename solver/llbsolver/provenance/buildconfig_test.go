@@ -0,0 +1,25 @@
+package provenance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/client/llb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToBuildStepsMetadata(t *testing.T) {
+	t.Parallel()
+
+	st := llb.Scratch().
+		File(llb.Mkdir("/foo", 0755), llb.WithProvenanceMetadata(map[string]string{"template": "builder"}), llb.WithDescription(map[string]string{"other": "1"})).
+		File(llb.Mkdir("/bar", 0755))
+	def, err := st.Marshal(context.TODO())
+	require.NoError(t, err)
+
+	steps, _, err := toBuildSteps(def.ToPB(), &Capture{}, false)
+	require.NoError(t, err)
+	require.Len(t, steps, 3) // the last step is the definition output
+	require.Equal(t, map[string]string{"template": "builder"}, steps[0].Metadata)
+	require.Nil(t, steps[1].Metadata)
+}
@@ -40,6 +40,8 @@ type BuildStep struct {
 	Op            *pb.Op                  `json:"op,omitempty"`
 	Inputs        []string                `json:"inputs,omitempty"`
 	ResourceUsage *resourcestypes.Samples `json:"resourceUsage,omitempty"`
+	// Metadata is set by the client with llb.WithProvenanceMetadata.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type Source struct {
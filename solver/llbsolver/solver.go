@@ -14,6 +14,7 @@ import (
 	"github.com/moby/buildkit/executor/resources"
 	resourcestypes "github.com/moby/buildkit/executor/resources/types"
 	"github.com/moby/buildkit/exporter"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
 	"github.com/moby/buildkit/exporter/verifier"
 	"github.com/moby/buildkit/frontend"
 	"github.com/moby/buildkit/frontend/gateway"
@@ -26,6 +27,7 @@ import (
 	spb "github.com/moby/buildkit/sourcepolicy/pb"
 	"github.com/moby/buildkit/util/entitlements"
 	"github.com/moby/buildkit/util/leaseutil"
+	"github.com/moby/buildkit/util/network"
 	"github.com/moby/buildkit/util/progress"
 	"github.com/moby/buildkit/worker"
 	digest "github.com/opencontainers/go-digest"
@@ -38,6 +40,9 @@ type ExporterRequest struct {
 	Exporters             []exporter.ExporterInstance
 	CacheExporters        []RemoteCacheExporter
 	EnableSessionExporter bool
+	// VerifierOpts are the options of the verifiers requested by the
+	// exporters.
+	VerifierOpts map[string]string
 }
 
 type RemoteCacheExporter struct {
@@ -63,6 +68,9 @@ type Opt struct {
 	ProxyNetwork     bool
 	ProvenanceEnv    map[string]any
 	MeterProvider    metric.MeterProvider
+	PolicyVerifier   PolicyVerifierProvider
+	// Verifiers are the verifier options that are enforced for every build.
+	Verifiers map[string]string
 }
 
 type Solver struct {
@@ -78,6 +86,8 @@ type Solver struct {
 	history                   *history.Queue
 	sysSampler                *resources.Sampler[*resourcestypes.SysSample]
 	proxyNetwork              bool
+	policyVerifier            PolicyVerifierProvider
+	verifierOpts              map[string]string
 	provenanceEnv             map[string]any
 	provenanceStore           *provenanceStore
 	metrics                   *buildMetrics
@@ -116,6 +126,8 @@ func New(opt Opt) (*Solver, error) {
 		entitlements:              opt.Entitlements,
 		history:                   opt.HistoryQueue,
 		proxyNetwork:              opt.ProxyNetwork,
+		policyVerifier:            opt.PolicyVerifier,
+		verifierOpts:              opt.Verifiers,
 		provenanceEnv:             opt.ProvenanceEnv,
 		provenanceStore:           newProvenanceStore(),
 		metrics:                   bm,
@@ -151,7 +163,9 @@ func (s *Solver) resolver() solver.ResolveOpFunc {
 		br := s.bridge(b)
 		return w.ResolveOp(v, br, s.sm, worker.ProxyOpt{
 			Network: br.ProxyNetwork(),
-			Policy:  br.ProxyPolicy,
+			Policy: func() (network.ProxyPolicy, error) {
+				return br.proxyPolicy(v.Digest())
+			},
 		})
 	}
 }
@@ -173,6 +187,7 @@ func (s *Solver) bridge(b solver.Builder, opts ...bridgeOpt) *provenanceBridge {
 		sm:                        s.sm,
 		provenanceStore:           s.provenanceStore,
 		proxyNetwork:              cfg.proxyNetwork,
+		policyVerifier:            s.policyVerifier,
 	}}
 }
 
@@ -337,6 +352,36 @@ func (s *Solver) Solve(ctx context.Context, id string, sessionID string, req fro
 		return nil, err
 	}
 
+	// artifacts are only passed to the exporters that support them and are
+	// not part of the result seen by provenance, verifiers and other exporters
+	res, artifactsRes, err := exptypes.SplitArtifacts(res)
+	if err != nil {
+		return nil, err
+	}
+	var artifacts *exporter.Source
+	if artifactsRes != nil {
+		releasers = append(releasers, func() {
+			artifactsRes.EachRef(func(ref solver.ResultProxy) error {
+				go ref.Release(context.TODO())
+				return nil
+			})
+		})
+		artifacts, err = result.ConvertResult(artifactsRes, func(res solver.ResultProxy) (cache.ImmutableRef, error) {
+			cr, err := res.Result(ctx)
+			if err != nil {
+				return nil, err
+			}
+			workerRef, ok := cr.Sys().(*worker.WorkerRef)
+			if !ok {
+				return nil, errors.Errorf("invalid reference: %T", cr.Sys())
+			}
+			return workerRef.ImmutableRef, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	resProv, err = addProvenanceToResult(res, br)
 	if err != nil {
 		return nil, err
@@ -388,17 +433,25 @@ func (s *Solver) Solve(ctx context.Context, id string, sessionID string, req fro
 
 	cacheExporters, inlineCacheExporter := splitCacheExporters(exp.CacheExporters)
 
+	verifierOpts := exp.VerifierOpts
 	if exp.EnableSessionExporter {
-		exporters, err := s.getSessionExporters(ctx, j.SessionID, len(exp.Exporters), inp)
+		exporters, opts, err := s.getSessionExporters(ctx, j.SessionID, len(exp.Exporters), inp)
 		if err != nil {
 			return nil, err
 		}
 		exp.Exporters = append(exp.Exporters, exporters...)
+		if verifierOpts, err = verifier.MergeOpts(verifierOpts, opts); err != nil {
+			return nil, err
+		}
+	}
+	verifiers, err := s.verifiers(verifierOpts)
+	if err != nil {
+		return nil, err
 	}
 
-	var exporterResponse map[string]string
+	var exporterResponses []map[string]string
 	var finalizers []exporter.FinalizeFunc
-	exporterResponse, finalizers, descrefs, err = s.runExporters(ctx, id, exp.Exporters, inlineCacheExporter, j, cached, inp)
+	exporterResponses, finalizers, descrefs, err = s.runExporters(ctx, id, exp.Exporters, verifiers, inlineCacheExporter, j, cached, inp, artifacts)
 	if err != nil {
 		return nil, err
 	}
@@ -429,6 +482,7 @@ func (s *Solver) Solve(ctx context.Context, id string, sessionID string, req fro
 		return nil, err
 	}
 
+	exporterResponse := mergeExporterResponses(exporterResponses)
 	if exporterResponse == nil {
 		exporterResponse = make(map[string]string)
 	}
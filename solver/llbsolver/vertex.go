@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"sync"
 
 	"github.com/containerd/platforms"
 	"github.com/moby/buildkit/solver"
@@ -393,14 +394,23 @@ func loadLLB(ctx context.Context, def *pb.Definition, polEngine SourcePolicyEval
 		}
 	}
 
+	// source policy warnings are reported once the final digests of the
+	// vertexes are known
+	policyWarnings := make(map[digest.Digest][]string)
 	if polEngine != nil && len(sources) > 0 {
+		var mu sync.Mutex
 		var eg errgroup.Group
 		for dgst := range sources {
 			eg.Go(func() error {
-				op := allOps[dgst]
-				if _, err := polEngine.Evaluate(ctx, op.Op); err != nil {
+				_, warnings, err := polEngine.EvaluateSource(ctx, allOps[dgst].Op)
+				if err != nil {
 					return errors.Wrap(err, "error evaluating the source policy")
 				}
+				if len(warnings) > 0 {
+					mu.Lock()
+					policyWarnings[dgst] = warnings
+					mu.Unlock()
+				}
 				return nil
 			})
 		}
@@ -415,6 +425,9 @@ func loadLLB(ctx context.Context, def *pb.Definition, polEngine SourcePolicyEval
 			return solver.Edge{}, err
 		}
 	}
+	for dgst, warnings := range policyWarnings {
+		polEngine.ReportWarnings(ctx, mutatedDigests[dgst], warnings)
+	}
 
 	if len(allOps) < 2 {
 		return solver.Edge{}, errors.Errorf("invalid LLB with %d vertexes", len(allOps))
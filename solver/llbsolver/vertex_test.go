@@ -1,13 +1,21 @@
 package llbsolver
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
+	"io"
 	"testing"
 
+	"github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/solver"
 	"github.com/moby/buildkit/solver/pb"
+	spb "github.com/moby/buildkit/sourcepolicy/pb"
 	"github.com/moby/buildkit/util/entitlements"
+	"github.com/moby/buildkit/util/progress"
+	"github.com/moby/buildkit/worker"
 	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -300,3 +308,107 @@ func requireVertexOp(t *testing.T, v interface{ Sys() any }) *pb.Op {
 	require.True(t, ok)
 	return op
 }
+
+func TestLoadReportsPolicyWarningsOnFinalVertex(t *testing.T) {
+	pol := []*spb.Policy{{
+		Rules: []*spb.Rule{
+			{
+				Action:   spb.PolicyAction_CONVERT,
+				Selector: &spb.Selector{Identifier: "local://context"},
+				Updates:  &spb.Update{Identifier: "local://converted"},
+			},
+			{
+				Action:   spb.PolicyAction_WARN,
+				Selector: &spb.Selector{Identifier: "local://converted"},
+			},
+		},
+	}}
+	b := &llbBridge{
+		builder:       &policyTestBuilder{},
+		resolveWorker: func() (worker.Worker, error) { return nil, nil },
+	}
+
+	pr, ctx, done := progress.NewContext(t.Context())
+	e, err := Load(ctx, proxyNetworkTestDefinition(t), b.policy(pol))
+	require.NoError(t, err)
+
+	// the policy is evaluated again for a later load, but the warning is
+	// only reported once for the vertex
+	_, err = Load(ctx, proxyNetworkTestDefinition(t), b.policy(pol))
+	require.NoError(t, err)
+	done(nil)
+
+	source := e.Vertex.Inputs()[0].Vertex
+	require.Equal(t, "local://converted", requireVertexOp(t, source).GetSource().Identifier)
+
+	warnings := readVertexWarnings(t, pr)
+	require.Len(t, warnings, 1)
+	require.Equal(t, source.Digest(), warnings[0].Vertex)
+}
+
+func TestProxyPolicyReportsWarningsOnExecVertex(t *testing.T) {
+	b := &llbBridge{
+		builder: &policyTestBuilder{values: map[string]any{
+			keySourcePolicy: &spb.Policy{
+				Rules: []*spb.Rule{{
+					Action:   spb.PolicyAction_WARN,
+					Selector: &spb.Selector{Identifier: "https://example.com/*"},
+				}},
+			},
+		}},
+		resolveWorker: func() (worker.Worker, error) { return nil, nil },
+	}
+	vtx := digest.FromString("exec")
+	pol, err := b.proxyPolicy(vtx)
+	require.NoError(t, err)
+
+	pr, ctx, done := progress.NewContext(t.Context())
+	_, err = pol.Evaluate(ctx, &pb.Op{
+		Op: &pb.Op_Source{
+			Source: &pb.SourceOp{
+				Identifier: "https://example.com/file",
+				Attrs:      map[string]string{pb.AttrProxyMethod: "GET"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	done(nil)
+
+	warnings := readVertexWarnings(t, pr)
+	require.Len(t, warnings, 1)
+	require.Equal(t, vtx, warnings[0].Vertex)
+}
+
+func readVertexWarnings(t *testing.T, pr progress.Reader) []client.VertexWarning {
+	t.Helper()
+	var warnings []client.VertexWarning
+	for {
+		ps, err := pr.Read(t.Context())
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		for _, p := range ps {
+			if w, ok := p.Sys.(client.VertexWarning); ok {
+				warnings = append(warnings, w)
+			}
+		}
+	}
+	return warnings
+}
+
+type policyTestBuilder struct {
+	solver.Builder
+	values map[string]any
+}
+
+func (b *policyTestBuilder) InContext(ctx context.Context, f func(context.Context, solver.JobContext) error) error {
+	return f(ctx, nil)
+}
+
+func (b *policyTestBuilder) EachValue(ctx context.Context, key string, fn func(any) error) error {
+	if v, ok := b.values[key]; ok {
+		return fn(v)
+	}
+	return nil
+}
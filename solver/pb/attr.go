@@ -29,6 +29,10 @@ const AttrMetadataTransferExclude = "local.metadatatransferexclude"
 
 const AttrLLBDefinitionFilename = "llbbuild.filename"
 
+// AttrProxyMethod is set on the source ops synthesized for requests made
+// through the proxy network.
+const AttrProxyMethod = "proxy.method"
+
 const AttrHTTPChecksum = "http.checksum"
 const AttrHTTPFilename = "http.filename"
 const AttrHTTPPerm = "http.perm"
@@ -46,6 +50,8 @@ const AttrImageResolveModePreferLocal = "local"
 const AttrImageRecordType = "image.recordtype"
 const AttrImageLayerLimit = "image.layerlimit"
 const AttrImageChecksum = "image.checksum"
+const AttrImageLazyPull = "image.lazypull"
+const AttrImagePlatformDigests = "image.platformdigests"
 
 const AttrOCILayoutSessionID = "oci.session"
 const AttrOCILayoutStoreID = "oci.store"
@@ -23,3 +23,7 @@ const LLBDefinitionInput = "buildkit.llb.definition"
 
 // LLBDefaultDefinitionFile is a filename containing the definition in LLBBuilder
 const LLBDefaultDefinitionFile = LLBDefinitionInput
+
+// ProvenanceMetadataPrefix is the prefix of the description keys of an op
+// that are recorded for its build step in the provenance attestation
+const ProvenanceMetadataPrefix = "llb.provenance."
@@ -14,12 +14,14 @@ import (
 )
 
 type ImageIdentifier struct {
-	Reference   reference.Spec
-	Platform    *ocispecs.Platform
-	ResolveMode resolver.ResolveMode
-	RecordType  client.UsageRecordType
-	LayerLimit  *int
-	Checksum    digest.Digest
+	Reference       reference.Spec
+	Platform        *ocispecs.Platform
+	ResolveMode     resolver.ResolveMode
+	RecordType      client.UsageRecordType
+	LayerLimit      *int
+	Checksum        digest.Digest
+	LazyPull        bool
+	PlatformDigests []PlatformDigest
 }
 
 func NewImageIdentifier(str string) (*ImageIdentifier, error) {
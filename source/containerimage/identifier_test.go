@@ -3,6 +3,7 @@ package containerimage
 import (
 	"testing"
 
+	"github.com/moby/buildkit/solver/pb"
 	"github.com/stretchr/testify/require"
 )
 
@@ -17,3 +18,17 @@ func TestOCIIdentifierString(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "oci-layout://example.com/repo/layout:latest", id.String())
 }
+
+func TestImageIdentifierLazyPull(t *testing.T) {
+	is := &Source{}
+	id, err := is.registryIdentifier("docker.io/library/busybox:latest", map[string]string{
+		pb.AttrImageLazyPull: "true",
+	}, nil)
+	require.NoError(t, err)
+	require.True(t, id.(*ImageIdentifier).LazyPull)
+
+	_, err = is.registryIdentifier("docker.io/library/busybox:latest", map[string]string{
+		pb.AttrImageLazyPull: "maybe",
+	}, nil)
+	require.ErrorContains(t, err, "invalid lazy pull value")
+}
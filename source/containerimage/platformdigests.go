@@ -0,0 +1,68 @@
+package containerimage
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/containerd/platforms"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// PlatformDigest pins the manifest that is resolved for a platform.
+type PlatformDigest struct {
+	Platform ocispecs.Platform
+	Digest   digest.Digest
+}
+
+// ParsePlatformDigests parses the value of the image.platformdigests
+// attribute, a comma-separated list of platform=digest pairs, e.g.
+// "linux/amd64=sha256:...,linux/arm64=sha256:...".
+func ParsePlatformDigests(v string) ([]PlatformDigest, error) {
+	var out []PlatformDigest
+	for field := range strings.SplitSeq(v, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		ps, ds, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, errors.Errorf("invalid platform digest %q, expected platform=digest", field)
+		}
+		p, err := platforms.Parse(ps)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid platform in platform digest %q", field)
+		}
+		dgst, err := digest.Parse(ds)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid digest in platform digest %q", field)
+		}
+		p = platforms.Normalize(p)
+		if slices.ContainsFunc(out, func(pd PlatformDigest) bool {
+			return platforms.FormatAll(pd.Platform) == platforms.FormatAll(p)
+		}) {
+			return nil, errors.Errorf("duplicate platform %s in platform digests", platforms.FormatAll(p))
+		}
+		out = append(out, PlatformDigest{Platform: p, Digest: dgst})
+	}
+	return out, nil
+}
+
+// verifyPlatformDigest checks that the manifest that was pulled for the
+// platform is the one pinned for that platform. For an image index this is
+// the child manifest selected when pulling, not the index itself. Platforms
+// without a pin are not checked.
+func verifyPlatformDigest(manifest ocispecs.Descriptor, platform ocispecs.Platform, pins []PlatformDigest) error {
+	platform = platforms.Normalize(platform)
+	idx := slices.IndexFunc(pins, func(pd PlatformDigest) bool {
+		return platforms.FormatAll(platforms.Normalize(pd.Platform)) == platforms.FormatAll(platform)
+	})
+	if idx < 0 {
+		return nil
+	}
+	if pin := pins[idx]; manifest.Digest != pin.Digest {
+		return errors.Errorf("pulled manifest %s does not match pinned digest %s for platform %s", manifest.Digest, pin.Digest, platforms.FormatAll(platform))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package containerimage
+
+import (
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlatformDigests(t *testing.T) {
+	amd64 := digest.FromString("amd64")
+	arm64 := digest.FromString("arm64")
+
+	pds, err := ParsePlatformDigests("linux/amd64=" + amd64.String() + ", linux/arm64=" + arm64.String())
+	require.NoError(t, err)
+	require.Equal(t, []PlatformDigest{
+		{Platform: ocispecs.Platform{OS: "linux", Architecture: "amd64"}, Digest: amd64},
+		{Platform: ocispecs.Platform{OS: "linux", Architecture: "arm64"}, Digest: arm64},
+	}, pds)
+
+	_, err = ParsePlatformDigests("linux/amd64")
+	require.ErrorContains(t, err, "expected platform=digest")
+
+	_, err = ParsePlatformDigests("linux/amd64=foo")
+	require.ErrorContains(t, err, "invalid digest")
+
+	_, err = ParsePlatformDigests("linux/arm64=" + amd64.String() + ",linux/arm64/v8=" + arm64.String())
+	require.ErrorContains(t, err, "duplicate platform")
+}
+
+func TestVerifyPlatformDigest(t *testing.T) {
+	amd64 := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageManifest,
+		Digest:    digest.FromString("amd64"),
+		Size:      10,
+	}
+	arm64 := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageManifest,
+		Digest:    digest.FromString("arm64"),
+		Size:      10,
+	}
+	rebuilt := arm64
+	rebuilt.Digest = digest.FromString("arm64-rebuilt")
+
+	pins := []PlatformDigest{
+		{Platform: ocispecs.Platform{OS: "linux", Architecture: "amd64"}, Digest: amd64.Digest},
+		{Platform: ocispecs.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, Digest: arm64.Digest},
+	}
+
+	require.NoError(t, verifyPlatformDigest(amd64, ocispecs.Platform{OS: "linux", Architecture: "amd64"}, pins))
+	// the platform is normalized before it is compared
+	require.NoError(t, verifyPlatformDigest(arm64, ocispecs.Platform{OS: "linux", Architecture: "arm64"}, pins))
+	// platforms without a pin are not checked
+	require.NoError(t, verifyPlatformDigest(rebuilt, ocispecs.Platform{OS: "linux", Architecture: "s390x"}, pins))
+
+	// re-pushed index with a rebuilt child
+	err := verifyPlatformDigest(rebuilt, ocispecs.Platform{OS: "linux", Architecture: "arm64"}, pins)
+	require.ErrorContains(t, err, "pulled manifest "+rebuilt.Digest.String()+" does not match pinned digest "+arm64.Digest.String())
+
+	// a manifest pinned for another platform doesn't satisfy the pin
+	err = verifyPlatformDigest(amd64, ocispecs.Platform{OS: "linux", Architecture: "arm64"}, pins)
+	require.ErrorContains(t, err, "does not match pinned digest")
+}
@@ -37,17 +37,19 @@ import (
 )
 
 type puller struct {
-	CacheAccessor  cache.Accessor
-	LeaseManager   leases.Manager
-	RegistryHosts  docker.RegistryHosts
-	ImageStore     images.Store
-	Mode           resolver.ResolveMode
-	RecordType     client.UsageRecordType
-	Ref            string
-	SessionManager *session.Manager
-	layerLimit     *int
-	checksum       digest.Digest
-	vtx            solver.Vertex
+	CacheAccessor   cache.Accessor
+	LeaseManager    leases.Manager
+	RegistryHosts   docker.RegistryHosts
+	ImageStore      images.Store
+	Mode            resolver.ResolveMode
+	RecordType      client.UsageRecordType
+	Ref             string
+	SessionManager  *session.Manager
+	layerLimit      *int
+	checksum        digest.Digest
+	lazyPull        bool
+	platformDigests []PlatformDigest
+	vtx             solver.Vertex
 	ResolverType
 	store sourceresolver.ResolveImageConfigOptStore
 
@@ -139,6 +141,12 @@ func (p *puller) CacheKey(ctx context.Context, jobCtx solver.JobContext, index i
 			return struct{}{}, errors.Errorf("image digest %s for %s does not match expected checksum %s", p.manifest.MainManifestDesc.Digest, p.Ref, p.checksum)
 		}
 
+		if len(p.platformDigests) > 0 {
+			if err := verifyPlatformDigest(p.manifest.ManifestDesc, p.Platform, p.platformDigests); err != nil {
+				return struct{}{}, errors.Wrapf(err, "failed to verify platform digest for %s", p.Ref)
+			}
+		}
+
 		if ll := p.layerLimit; ll != nil {
 			if *ll > len(p.manifest.Descriptors) {
 				return struct{}{}, errors.Errorf("layer limit %d is greater than the number of layers in the image %d", *ll, len(p.manifest.Descriptors))
@@ -171,6 +179,7 @@ func (p *puller) CacheKey(ctx context.Context, jobCtx solver.JobContext, index i
 					SnapshotLabels: labels,
 					Annotations:    desc.Annotations,
 					Ref:            p.manifest.Ref,
+					LazyPull:       p.lazyPull,
 				}
 			}
 		}
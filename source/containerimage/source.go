@@ -99,6 +99,8 @@ func (is *Source) Resolve(ctx context.Context, id source.Identifier, sm *session
 		store      sourceresolver.ResolveImageConfigOptStore
 		layerLimit *int
 		checksum   digest.Digest
+		lazyPull   bool
+		pins       []PlatformDigest
 	)
 	switch is.ResolverType {
 	case ResolverTypeRegistry:
@@ -115,6 +117,8 @@ func (is *Source) Resolve(ctx context.Context, id source.Identifier, sm *session
 		ref = imageIdentifier.Reference
 		layerLimit = imageIdentifier.LayerLimit
 		checksum = imageIdentifier.Checksum
+		lazyPull = imageIdentifier.LazyPull
+		pins = imageIdentifier.PlatformDigests
 	case ResolverTypeOCILayout:
 		ociIdentifier, ok := id.(*OCIIdentifier)
 		if !ok {
@@ -140,20 +144,22 @@ func (is *Source) Resolve(ctx context.Context, id source.Identifier, sm *session
 		Src:          ref,
 	}
 	p = &puller{
-		CacheAccessor:  is.CacheAccessor,
-		LeaseManager:   is.LeaseManager,
-		Puller:         pullerUtil,
-		RegistryHosts:  is.RegistryHosts,
-		ResolverType:   is.ResolverType,
-		ImageStore:     is.ImageStore,
-		Mode:           mode,
-		RecordType:     recordType,
-		Ref:            ref.String(),
-		SessionManager: sm,
-		vtx:            vtx,
-		store:          store,
-		layerLimit:     layerLimit,
-		checksum:       checksum,
+		CacheAccessor:   is.CacheAccessor,
+		LeaseManager:    is.LeaseManager,
+		Puller:          pullerUtil,
+		RegistryHosts:   is.RegistryHosts,
+		ResolverType:    is.ResolverType,
+		ImageStore:      is.ImageStore,
+		Mode:            mode,
+		RecordType:      recordType,
+		Ref:             ref.String(),
+		SessionManager:  sm,
+		vtx:             vtx,
+		store:           store,
+		layerLimit:      layerLimit,
+		checksum:        checksum,
+		lazyPull:        lazyPull,
+		platformDigests: pins,
 	}
 	return p, nil
 }
@@ -433,6 +439,18 @@ func (is *Source) registryIdentifier(ref string, attrs map[string]string, platfo
 				return nil, errors.Wrapf(err, "invalid image checksum %s", v)
 			}
 			id.Checksum = dgst
+		case pb.AttrImageLazyPull:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid lazy pull value %s", v)
+			}
+			id.LazyPull = b
+		case pb.AttrImagePlatformDigests:
+			pds, err := ParsePlatformDigests(v)
+			if err != nil {
+				return nil, err
+			}
+			id.PlatformDigests = pds
 		}
 	}
 
@@ -0,0 +1,139 @@
+package sourcepolicy
+
+import (
+	"context"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/moby/buildkit/solver/pb"
+	spb "github.com/moby/buildkit/sourcepolicy/pb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// ImageAttestations are the verified attestations of an image.
+type ImageAttestations struct {
+	// Provenance contains the builder IDs of the provenance attestations
+	// bound to the image manifest. Only provenance covered by a verified
+	// signature is included.
+	Provenance []string
+	// Signers contains the identities of the verified signatures.
+	Signers []string
+	// Digest is the digest the image reference resolved to when the
+	// attestations were resolved. The source is pinned to it, so the image
+	// that is pulled is the one the policy was evaluated for.
+	Digest digest.Digest
+}
+
+// AttestationResolver resolves the attestations of an image source.
+// It is only called for rules that have attestation constraints and whose
+// identifier already matched the source.
+type AttestationResolver interface {
+	ResolveAttestations(ctx context.Context, op *pb.SourceOp) (*ImageAttestations, error)
+}
+
+// WithAttestationResolver sets the resolver used to check the attestation
+// constraints of the selectors.
+func WithAttestationResolver(r AttestationResolver) EvaluateOpt {
+	return func(s *evaluateState) {
+		s.resolver = r
+	}
+}
+
+func (s *evaluateState) resolve(ctx context.Context, op *pb.SourceOp) (*ImageAttestations, error) {
+	if s.resolver == nil {
+		return nil, errors.Errorf("attestation constraints are not supported for %s", op.Identifier)
+	}
+	if atts, ok := s.attestations[op.Identifier]; ok {
+		return atts, nil
+	}
+	atts, err := s.resolver.ResolveAttestations(ctx, op)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve attestations for %s", op.Identifier)
+	}
+	if atts == nil {
+		atts = &ImageAttestations{}
+	}
+	if s.attestations == nil {
+		s.attestations = map[string]*ImageAttestations{}
+	}
+	s.attestations[op.Identifier] = atts
+	return atts, nil
+}
+
+// pin pins the image source to the digest that its attestations were
+// resolved for. Without it, the tag could be pushed again between the
+// evaluation of the policy and the pull, and an image whose attestations
+// were never checked would be pulled.
+func (s *evaluateState) pin(op *pb.SourceOp) (bool, error) {
+	atts, ok := s.attestations[op.Identifier]
+	if !ok || atts.Digest == "" {
+		return false, nil
+	}
+	ref, err := reference.ParseNormalizedNamed(strings.TrimPrefix(op.Identifier, "docker-image://"))
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to parse %s", op.Identifier)
+	}
+	if _, ok := ref.(reference.Digested); ok {
+		return false, nil
+	}
+	ref, err = reference.WithDigest(ref, atts.Digest)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to pin %s", op.Identifier)
+	}
+	op.Identifier = "docker-image://" + ref.String()
+	return true, nil
+}
+
+func (e *Engine) matchAttestations(ctx context.Context, st *evaluateState, constraints []*spb.AttestationConstraint, op *pb.SourceOp) (bool, error) {
+	if len(constraints) == 0 {
+		return true, nil
+	}
+	// only images carry attestations
+	if !strings.HasPrefix(op.Identifier, "docker-image://") {
+		return false, nil
+	}
+	atts, err := st.resolve(ctx, op)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range constraints {
+		if c == nil {
+			return false, errors.New("invalid nil attestation constraint")
+		}
+		var ids []string
+		switch c.Type {
+		case spb.AttestationType_PROVENANCE:
+			ids = atts.Provenance
+		case spb.AttestationType_SIGNATURE:
+			ids = atts.Signers
+		default:
+			return false, errors.Errorf("unknown attestation type: %s", c.Type)
+		}
+		ok, err := e.matchIdentity(c, ids)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (e *Engine) matchIdentity(c *spb.AttestationConstraint, ids []string) (bool, error) {
+	if c.Identity == "" {
+		return len(ids) > 0, nil
+	}
+	sel := e.selectorCache(&spb.Selector{Identifier: c.Identity, MatchType: c.MatchType})
+	for _, id := range ids {
+		ok, err := match(sel, id, nil, nil)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid attestation identity %q", c.Identity)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,168 @@
+package sourcepolicy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/moby/buildkit/solver/pb"
+	spb "github.com/moby/buildkit/sourcepolicy/pb"
+	digest "github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAttestationResolver struct {
+	atts  map[string]*ImageAttestations
+	calls int
+}
+
+func (r *fakeAttestationResolver) ResolveAttestations(ctx context.Context, op *pb.SourceOp) (*ImageAttestations, error) {
+	r.calls++
+	atts, ok := r.atts[op.Identifier]
+	if !ok {
+		return nil, errors.Errorf("no attestations for %s", op.Identifier)
+	}
+	return atts, nil
+}
+
+func TestEngineAttestations(t *testing.T) {
+	t.Parallel()
+
+	const (
+		signed   = "docker-image://docker.io/library/signed:latest"
+		unsigned = "docker-image://docker.io/library/unsigned:latest"
+	)
+
+	resolver := func() *fakeAttestationResolver {
+		return &fakeAttestationResolver{
+			atts: map[string]*ImageAttestations{
+				signed: {
+					Provenance: []string{"https://github.com/docker/github-builder/.github/workflows/build.yml@refs/heads/main"},
+					Signers:    []string{"https://github.com/docker/github-builder/.github/workflows/build.yml@refs/heads/main"},
+				},
+				unsigned: {
+					Provenance: []string{"https://example.com/builder"},
+				},
+			},
+		}
+	}
+
+	// deny all images unless signed by the trusted workflow
+	pol := []*spb.Policy{
+		{
+			Rules: []*spb.Rule{
+				{
+					Action: spb.PolicyAction_DENY,
+					Selector: &spb.Selector{
+						Identifier: "docker-image://*",
+					},
+				},
+				{
+					Action: spb.PolicyAction_ALLOW,
+					Selector: &spb.Selector{
+						Identifier: "docker-image://*",
+						Attestations: []*spb.AttestationConstraint{
+							{
+								Type:     spb.AttestationType_SIGNATURE,
+								Identity: "https://github.com/docker/github-builder/*",
+							},
+							{
+								Type: spb.AttestationType_PROVENANCE,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		t.Parallel()
+		r := resolver()
+		mut, err := NewEngine(pol).Evaluate(t.Context(), &pb.SourceOp{Identifier: signed}, WithAttestationResolver(r))
+		require.NoError(t, err)
+		require.False(t, mut)
+		require.Equal(t, 1, r.calls)
+	})
+
+	t.Run("pinned", func(t *testing.T) {
+		t.Parallel()
+		r := resolver()
+		dgst := digest.FromString("signed")
+		r.atts[signed].Digest = dgst
+		op := &pb.SourceOp{Identifier: signed}
+		mut, err := NewEngine(pol).Evaluate(t.Context(), op, WithAttestationResolver(r))
+		require.NoError(t, err)
+		require.True(t, mut)
+		require.Equal(t, signed+"@"+dgst.String(), op.Identifier)
+		require.Equal(t, 1, r.calls)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewEngine(pol).Evaluate(t.Context(), &pb.SourceOp{Identifier: unsigned}, WithAttestationResolver(resolver()))
+		require.ErrorIs(t, err, ErrSourceDenied)
+	})
+
+	t.Run("not an image", func(t *testing.T) {
+		t.Parallel()
+		pol := []*spb.Policy{
+			{
+				Rules: []*spb.Rule{
+					{
+						Action: spb.PolicyAction_DENY,
+						Selector: &spb.Selector{
+							Identifier: "*",
+							Attestations: []*spb.AttestationConstraint{
+								{Type: spb.AttestationType_PROVENANCE},
+							},
+						},
+					},
+				},
+			},
+		}
+		r := resolver()
+		_, err := NewEngine(pol).Evaluate(t.Context(), &pb.SourceOp{Identifier: "https://example.com/file"}, WithAttestationResolver(r))
+		require.NoError(t, err)
+		require.Equal(t, 0, r.calls)
+	})
+
+	t.Run("regex identity", func(t *testing.T) {
+		t.Parallel()
+		pol := []*spb.Policy{
+			{
+				Rules: []*spb.Rule{
+					{
+						Action: spb.PolicyAction_DENY,
+						Selector: &spb.Selector{
+							Identifier: "docker-image://*",
+							Attestations: []*spb.AttestationConstraint{
+								{
+									Type:      spb.AttestationType_PROVENANCE,
+									Identity:  `^https://example\.com/`,
+									MatchType: spb.MatchType_REGEX,
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		_, err := NewEngine(pol).Evaluate(t.Context(), &pb.SourceOp{Identifier: unsigned}, WithAttestationResolver(resolver()))
+		require.ErrorIs(t, err, ErrSourceDenied)
+		_, err = NewEngine(pol).Evaluate(t.Context(), &pb.SourceOp{Identifier: signed}, WithAttestationResolver(resolver()))
+		require.NoError(t, err)
+	})
+
+	t.Run("no resolver", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewEngine(pol).Evaluate(t.Context(), &pb.SourceOp{Identifier: signed})
+		require.ErrorContains(t, err, "attestation constraints are not supported")
+	})
+
+	t.Run("resolver error", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewEngine(pol).Evaluate(t.Context(), &pb.SourceOp{Identifier: "docker-image://docker.io/library/other:latest"}, WithAttestationResolver(resolver()))
+		require.ErrorContains(t, err, "no attestations for")
+	})
+}
@@ -2,6 +2,7 @@ package sourcepolicy
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
 	"github.com/moby/buildkit/solver/pb"
@@ -19,6 +20,42 @@ var (
 	ErrTooManyOps = errors.New("too many operations")
 )
 
+// EvaluateOpt configures a single policy evaluation.
+type EvaluateOpt func(*evaluateState)
+
+// WithWarnFunc sets the function called for sources matched by a WARN rule
+// and for sources that an audit-only policy would deny.
+func WithWarnFunc(f func(ctx context.Context, msg string)) EvaluateOpt {
+	return func(s *evaluateState) {
+		s.warn = f
+	}
+}
+
+type evaluateState struct {
+	resolver     AttestationResolver
+	attestations map[string]*ImageAttestations
+	warn         func(ctx context.Context, msg string)
+	warned       map[string]struct{}
+}
+
+// warnf reports a warning once per evaluation as policies are re-evaluated
+// after every convert.
+func (s *evaluateState) warnf(ctx context.Context, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	bklog.G(ctx).Debug(msg)
+	if s.warn == nil {
+		return
+	}
+	if _, ok := s.warned[msg]; ok {
+		return
+	}
+	if s.warned == nil {
+		s.warned = map[string]struct{}{}
+	}
+	s.warned[msg] = struct{}{}
+	s.warn(ctx, msg)
+}
+
 // Engine is the source policy engine.
 // It is responsible for evaluating a source policy against a source operation.
 // Create one with `NewEngine`
@@ -57,15 +94,22 @@ func (e *Engine) selectorCache(src *spb.Selector) *selectorCache {
 // Evaluate evaluates a source operation against the policy.
 //
 // Policies are re-evaluated for each convert rule.
+// An image source whose attestations were checked is pinned to the digest
+// they were resolved for.
 // Evaluate will error if there are too many converts for a single source op to prevent infinite loops.
 // This function may error out even if the op was mutated, in which case `true` will be returned along with the error.
 //
 // An error is returned when the source is denied by the policy.
-func (e *Engine) Evaluate(ctx context.Context, op *pb.SourceOp) (bool, error) {
+func (e *Engine) Evaluate(ctx context.Context, op *pb.SourceOp, opts ...EvaluateOpt) (bool, error) {
 	if len(e.pol) == 0 || op == nil {
 		return false, nil
 	}
 
+	st := &evaluateState{}
+	for _, opt := range opts {
+		opt(st)
+	}
+
 	var mutated bool
 	const maxIterr = 20
 
@@ -81,7 +125,7 @@ func (e *Engine) Evaluate(ctx context.Context, op *pb.SourceOp) (bool, error) {
 			ctx = bklog.WithLogger(ctx, bklog.G(ctx).WithField("updated", op))
 		}
 
-		mut, err := e.evaluatePolicies(ctx, op)
+		mut, err := e.evaluatePolicies(ctx, st, op)
 		if mut {
 			mutated = true
 		}
@@ -93,12 +137,16 @@ func (e *Engine) Evaluate(ctx context.Context, op *pb.SourceOp) (bool, error) {
 		}
 	}
 
-	return mutated, nil
+	mut, err := st.pin(op)
+	if mut {
+		mutated = true
+	}
+	return mutated, err
 }
 
-func (e *Engine) evaluatePolicies(ctx context.Context, srcOp *pb.SourceOp) (bool, error) {
+func (e *Engine) evaluatePolicies(ctx context.Context, st *evaluateState, srcOp *pb.SourceOp) (bool, error) {
 	for _, pol := range e.pol {
-		mut, err := e.evaluatePolicy(ctx, pol, srcOp)
+		mut, err := e.evaluatePolicy(ctx, st, pol, srcOp)
 		if mut || err != nil {
 			return mut, err
 		}
@@ -112,7 +160,12 @@ func (e *Engine) evaluatePolicies(ctx context.Context, srcOp *pb.SourceOp) (bool
 //
 // For Allow/Deny rules, the last matching rule wins.
 // E.g. `ALLOW foo; DENY foo` will deny `foo`, `DENY foo; ALLOW foo` will allow `foo`.
-func (e *Engine) evaluatePolicy(ctx context.Context, pol *spb.Policy, srcOp *pb.SourceOp) (retMut bool, retErr error) {
+// Warn rules report every match and leave the decision unchanged.
+// In an audit policy a denied source is reported as a warning instead.
+//
+// Attestation constraints are only checked once the identifier and attrs match,
+// so image attestations are resolved only for the rules that need them.
+func (e *Engine) evaluatePolicy(ctx context.Context, st *evaluateState, pol *spb.Policy, srcOp *pb.SourceOp) (retMut bool, retErr error) {
 	ident := srcOp.GetIdentifier()
 
 	ctx = bklog.WithLogger(ctx, bklog.G(ctx).WithField("ref", ident))
@@ -137,12 +190,28 @@ func (e *Engine) evaluatePolicy(ctx context.Context, pol *spb.Policy, srcOp *pb.
 		if !matched {
 			continue
 		}
+		matched, err = e.matchNetwork(rule.Selector.Network, srcOp)
+		if err != nil {
+			return false, errors.Wrap(err, "error matching source policy network")
+		}
+		if !matched {
+			continue
+		}
+		matched, err = e.matchAttestations(ctx, st, rule.Selector.Attestations, srcOp)
+		if err != nil {
+			return false, errors.Wrap(err, "error matching source policy attestations")
+		}
+		if !matched {
+			continue
+		}
 
 		switch rule.Action {
 		case spb.PolicyAction_ALLOW:
 			deny = false
 		case spb.PolicyAction_DENY:
 			deny = true
+		case spb.PolicyAction_WARN:
+			st.warnf(ctx, "source %q matched source policy warn rule %q", ident, rule.Selector.Identifier)
 		case spb.PolicyAction_CONVERT:
 			mut, err := mutate(ctx, srcOp, rule, selector, ident)
 			if err != nil || mut {
@@ -154,6 +223,10 @@ func (e *Engine) evaluatePolicy(ctx context.Context, pol *spb.Policy, srcOp *pb.
 	}
 
 	if deny {
+		if pol.Audit {
+			st.warnf(ctx, "source %q would be denied by source policy (audit mode)", ident)
+			return false, nil
+		}
 		return false, errors.Wrapf(ErrSourceDenied, "source %q denied by policy", ident)
 	}
 	return false, nil
@@ -1,6 +1,7 @@
 package sourcepolicy
 
 import (
+	"context"
 	"testing"
 
 	"github.com/moby/buildkit/solver/pb"
@@ -24,6 +25,83 @@ func TestEngineEvaluate(t *testing.T) {
 	t.Run("Test convert multiple", testConvertMultiple)
 	t.Run("test multiple policies", testMultiplePolicies)
 	t.Run("Last rule wins", testLastRuleWins)
+	t.Run("Warn", testWarn)
+	t.Run("Audit", testAudit)
+}
+
+func testWarn(t *testing.T) {
+	pol := []*spb.Policy{
+		{
+			Rules: []*spb.Rule{
+				{
+					Action: spb.PolicyAction_WARN,
+					Selector: &spb.Selector{
+						Identifier: "docker-image://docker.io/library/busybox:*",
+					},
+				},
+				{
+					Action: spb.PolicyAction_CONVERT,
+					Selector: &spb.Selector{
+						Identifier: "docker-image://docker.io/library/busybox:latest",
+					},
+					Updates: &spb.Update{
+						Identifier: "docker-image://docker.io/library/busybox:1.36",
+					},
+				},
+			},
+		},
+	}
+
+	var warnings []string
+	e := NewEngine(pol)
+	op := &pb.SourceOp{
+		Identifier: "docker-image://docker.io/library/busybox:latest",
+	}
+	mut, err := e.Evaluate(t.Context(), op, WithWarnFunc(func(_ context.Context, msg string) {
+		warnings = append(warnings, msg)
+	}))
+	require.NoError(t, err)
+	require.True(t, mut)
+	require.Equal(t, "docker-image://docker.io/library/busybox:1.36", op.Identifier)
+	require.Equal(t, []string{
+		`source "docker-image://docker.io/library/busybox:latest" matched source policy warn rule "docker-image://docker.io/library/busybox:*"`,
+		`source "docker-image://docker.io/library/busybox:1.36" matched source policy warn rule "docker-image://docker.io/library/busybox:*"`,
+	}, warnings)
+}
+
+func testAudit(t *testing.T) {
+	pol := []*spb.Policy{
+		{
+			Audit: true,
+			Rules: []*spb.Rule{
+				{
+					Action: spb.PolicyAction_DENY,
+					Selector: &spb.Selector{
+						Identifier: "docker-image://docker.io/library/busybox:latest",
+					},
+				},
+			},
+		},
+	}
+
+	var warnings []string
+	warn := WithWarnFunc(func(_ context.Context, msg string) {
+		warnings = append(warnings, msg)
+	})
+	e := NewEngine(pol)
+	mut, err := e.Evaluate(t.Context(), &pb.SourceOp{
+		Identifier: "docker-image://docker.io/library/busybox:latest",
+	}, warn)
+	require.NoError(t, err)
+	require.False(t, mut)
+	require.Equal(t, []string{`source "docker-image://docker.io/library/busybox:latest" would be denied by source policy (audit mode)`}, warnings)
+
+	pol[0].Audit = false
+	_, err = NewEngine(pol).Evaluate(t.Context(), &pb.SourceOp{
+		Identifier: "docker-image://docker.io/library/busybox:latest",
+	}, warn)
+	require.ErrorIs(t, err, ErrSourceDenied)
+	require.Len(t, warnings, 1)
 }
 
 func testLastRuleWins(t *testing.T) {
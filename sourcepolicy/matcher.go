@@ -38,6 +38,9 @@ func match(src *selectorCache, ref string, constraints []*spb.AttrConstraint, at
 	if src.Identifier == ref {
 		return true, nil
 	}
+	if src.Identifier == "" && src.Network != nil {
+		return true, nil
+	}
 
 	switch src.MatchType {
 	case spb.MatchType_EXACT:
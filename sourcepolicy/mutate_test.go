@@ -122,6 +122,35 @@ func TestMutate(t *testing.T) {
 				},
 			},
 		},
+		{
+			op: &pb.Op{
+				Op: &pb.Op_Source{
+					Source: &pb.SourceOp{
+						Identifier: "docker-image://docker.io/library/alpine:latest",
+					},
+				},
+			},
+			rule: &spb.Rule{
+				Selector: &spb.Selector{
+					Identifier: "docker-image://docker.io/library/alpine:latest",
+				},
+				Updates: &spb.Update{
+					Identifier: "docker-image://docker.io/library/alpine:latest@sha256:4edbd2beb5f78b1014028f4fbb99f3237d9561100b6881aabbf5acce2c4f9454",
+					Attrs:      map[string]string{pb.AttrImagePlatformDigests: "linux/amd64=sha256:6e4b94fc270e708e1068be28bd3551dc6917a4fc5a61293d51bb36e6b75c4b53"},
+				},
+			},
+			expected: true,
+			expectedOp: &pb.Op{
+				Op: &pb.Op_Source{
+					Source: &pb.SourceOp{
+						Identifier: "docker-image://docker.io/library/alpine:latest@sha256:4edbd2beb5f78b1014028f4fbb99f3237d9561100b6881aabbf5acce2c4f9454",
+						Attrs: map[string]string{
+							pb.AttrImagePlatformDigests: "linux/amd64=sha256:6e4b94fc270e708e1068be28bd3551dc6917a4fc5a61293d51bb36e6b75c4b53",
+						},
+					},
+				},
+			},
+		},
 	}
 
 	ctx := t.Context()
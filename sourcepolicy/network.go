@@ -0,0 +1,54 @@
+package sourcepolicy
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/moby/buildkit/solver/pb"
+	spb "github.com/moby/buildkit/sourcepolicy/pb"
+	"github.com/pkg/errors"
+)
+
+// matchNetwork matches the source ops that the proxy network synthesizes for
+// exec requests. Other sources never match a network selector.
+func (e *Engine) matchNetwork(sel *spb.NetworkSelector, op *pb.SourceOp) (bool, error) {
+	if sel == nil {
+		return true, nil
+	}
+	method := op.Attrs[pb.AttrProxyMethod]
+	if method == "" {
+		return false, nil
+	}
+	if len(sel.Methods) > 0 && !slices.ContainsFunc(sel.Methods, func(m string) bool {
+		return strings.EqualFold(m, method)
+	}) {
+		return false, nil
+	}
+	u, err := url.Parse(op.Identifier)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid request URL %q", op.Identifier)
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	for _, m := range []struct {
+		pattern, value string
+	}{
+		{sel.Host, strings.ToLower(u.Hostname())},
+		{sel.Path, path},
+	} {
+		if m.pattern == "" {
+			continue
+		}
+		ok, err := match(e.selectorCache(&spb.Selector{Identifier: m.pattern, MatchType: sel.MatchType}), m.value, nil, nil)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid network selector %q", m.pattern)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,90 @@
+package sourcepolicy
+
+import (
+	"testing"
+
+	"github.com/moby/buildkit/solver/pb"
+	spb "github.com/moby/buildkit/sourcepolicy/pb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchNetwork(t *testing.T) {
+	t.Parallel()
+
+	e := NewEngine(nil)
+	proxyOp := func(method, u string) *pb.SourceOp {
+		return &pb.SourceOp{
+			Identifier: u,
+			Attrs:      map[string]string{pb.AttrProxyMethod: method},
+		}
+	}
+
+	cases := []struct {
+		name     string
+		sel      *spb.NetworkSelector
+		op       *pb.SourceOp
+		expected bool
+	}{
+		{
+			name:     "nil selector",
+			op:       &pb.SourceOp{Identifier: "https://example.com/file"},
+			expected: true,
+		},
+		{
+			name:     "not a proxy request",
+			sel:      &spb.NetworkSelector{},
+			op:       &pb.SourceOp{Identifier: "https://example.com/file"},
+			expected: false,
+		},
+		{
+			name:     "method case insensitive",
+			sel:      &spb.NetworkSelector{Methods: []string{"get"}},
+			op:       proxyOp("GET", "https://example.com/file"),
+			expected: true,
+		},
+		{
+			name:     "method mismatch",
+			sel:      &spb.NetworkSelector{Methods: []string{"GET", "HEAD"}},
+			op:       proxyOp("PUT", "https://example.com/file"),
+			expected: false,
+		},
+		{
+			name:     "host ignores port",
+			sel:      &spb.NetworkSelector{Host: "example.com", MatchType: spb.MatchType_EXACT},
+			op:       proxyOp("GET", "https://EXAMPLE.com:8443/file"),
+			expected: true,
+		},
+		{
+			name:     "path wildcard",
+			sel:      &spb.NetworkSelector{Path: "/v2/*/blobs/*"},
+			op:       proxyOp("GET", "https://registry.example.com/v2/library/alpine/blobs/sha256:abc"),
+			expected: true,
+		},
+		{
+			name:     "empty path",
+			sel:      &spb.NetworkSelector{Path: "/", MatchType: spb.MatchType_EXACT},
+			op:       proxyOp("GET", "https://example.com"),
+			expected: true,
+		},
+		{
+			name:     "path regex",
+			sel:      &spb.NetworkSelector{Path: `^/simple/[a-z]+/$`, MatchType: spb.MatchType_REGEX},
+			op:       proxyOp("GET", "https://pypi.org/simple/requests/"),
+			expected: true,
+		},
+		{
+			name:     "path regex mismatch",
+			sel:      &spb.NetworkSelector{Path: `^/simple/[a-z]+/$`, MatchType: spb.MatchType_REGEX},
+			op:       proxyOp("GET", "https://pypi.org/packages/requests.tar.gz"),
+			expected: false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ok, err := e.matchNetwork(tc.sel, tc.op)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, ok)
+		})
+	}
+}
@@ -60,3 +60,21 @@ func (a *MatchType) UnmarshalJSON(data []byte) error {
 	*a = MatchType(val)
 	return nil
 }
+
+func (a AttestationType) MarshalJSON() ([]byte, error) {
+	return proto.MarshalJSONEnum(AttestationType_name, int32(a))
+}
+
+func (a *AttestationType) UnmarshalJSON(data []byte) error {
+	val, err := proto.UnmarshalJSONEnum(AttestationType_value, data, a.String())
+	if err != nil {
+		return err
+	}
+
+	_, ok := AttestationType_name[val]
+	if !ok {
+		return errors.Errorf("invalid AttestationType value: %d", val)
+	}
+	*a = AttestationType(val)
+	return nil
+}
@@ -78,3 +78,27 @@ func TestMatchTypeJSON(t *testing.T) {
 		require.Equal(t, a, a2)
 	}
 }
+
+func TestAttestationTypeJSON(t *testing.T) {
+	for i, s := range AttestationType_name {
+		// marshals to string form
+		data, err := json.Marshal(AttestationType(i))
+		require.NoError(t, err)
+		require.Equal(t, string(data), `"`+s+`"`)
+
+		// unmarshals froms string form
+		var a AttestationType
+		err = json.Unmarshal(data, &a)
+		require.NoError(t, err)
+		require.Equal(t, a, AttestationType(i))
+
+		// unmarshals froms number form
+		data, err = json.Marshal(i)
+		require.NoError(t, err)
+
+		var a2 AttestationType
+		err = json.Unmarshal(data, &a2)
+		require.NoError(t, err)
+		require.Equal(t, a, a2)
+	}
+}
@@ -28,6 +28,8 @@ const (
 	PolicyAction_ALLOW   PolicyAction = 0
 	PolicyAction_DENY    PolicyAction = 1
 	PolicyAction_CONVERT PolicyAction = 2
+	// WARN reports the match as a build warning without affecting whether the source is allowed
+	PolicyAction_WARN PolicyAction = 3
 )
 
 // Enum value maps for PolicyAction.
@@ -36,11 +38,13 @@ var (
 		0: "ALLOW",
 		1: "DENY",
 		2: "CONVERT",
+		3: "WARN",
 	}
 	PolicyAction_value = map[string]int32{
 		"ALLOW":   0,
 		"DENY":    1,
 		"CONVERT": 2,
+		"WARN":    3,
 	}
 )
 
@@ -121,6 +125,55 @@ func (AttrMatch) EnumDescriptor() ([]byte, []int) {
 	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP(), []int{1}
 }
 
+// AttestationType defines the kind of image attestation to check
+type AttestationType int32
+
+const (
+	// PROVENANCE is an SLSA provenance attestation bound to the image manifest
+	AttestationType_PROVENANCE AttestationType = 0
+	// SIGNATURE is a verified signature of the attestation manifest
+	AttestationType_SIGNATURE AttestationType = 1
+)
+
+// Enum value maps for AttestationType.
+var (
+	AttestationType_name = map[int32]string{
+		0: "PROVENANCE",
+		1: "SIGNATURE",
+	}
+	AttestationType_value = map[string]int32{
+		"PROVENANCE": 0,
+		"SIGNATURE":  1,
+	}
+)
+
+func (x AttestationType) Enum() *AttestationType {
+	p := new(AttestationType)
+	*p = x
+	return p
+}
+
+func (x AttestationType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AttestationType) Descriptor() protoreflect.EnumDescriptor {
+	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_enumTypes[2].Descriptor()
+}
+
+func (AttestationType) Type() protoreflect.EnumType {
+	return &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_enumTypes[2]
+}
+
+func (x AttestationType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AttestationType.Descriptor instead.
+func (AttestationType) EnumDescriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP(), []int{2}
+}
+
 // Match type is used to determine how a rule source is matched
 type MatchType int32
 
@@ -161,11 +214,11 @@ func (x MatchType) String() string {
 }
 
 func (MatchType) Descriptor() protoreflect.EnumDescriptor {
-	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_enumTypes[2].Descriptor()
+	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_enumTypes[3].Descriptor()
 }
 
 func (MatchType) Type() protoreflect.EnumType {
-	return &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_enumTypes[2]
+	return &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_enumTypes[3]
 }
 
 func (x MatchType) Number() protoreflect.EnumNumber {
@@ -174,7 +227,7 @@ func (x MatchType) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use MatchType.Descriptor instead.
 func (MatchType) EnumDescriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP(), []int{2}
+	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP(), []int{3}
 }
 
 // Rule defines the action(s) to take when a source is matched
@@ -296,8 +349,14 @@ type Selector struct {
 	state      protoimpl.MessageState `protogen:"open.v1"`
 	Identifier string                 `protobuf:"bytes,1,opt,name=identifier,proto3" json:"identifier,omitempty"`
 	// MatchType is the type of match to perform on the source identifier
-	MatchType     MatchType         `protobuf:"varint,2,opt,name=match_type,json=matchType,proto3,enum=moby.buildkit.v1.sourcepolicy.MatchType" json:"match_type,omitempty"`
-	Constraints   []*AttrConstraint `protobuf:"bytes,3,rep,name=constraints,proto3" json:"constraints,omitempty"`
+	MatchType   MatchType         `protobuf:"varint,2,opt,name=match_type,json=matchType,proto3,enum=moby.buildkit.v1.sourcepolicy.MatchType" json:"match_type,omitempty"`
+	Constraints []*AttrConstraint `protobuf:"bytes,3,rep,name=constraints,proto3" json:"constraints,omitempty"`
+	// Attestations require the matched image to carry verified attestations.
+	// All constraints must be satisfied for the selector to match.
+	Attestations []*AttestationConstraint `protobuf:"bytes,4,rep,name=attestations,proto3" json:"attestations,omitempty"`
+	// Network restricts the selector to requests made by exec steps through
+	// the proxy network. If set, an empty identifier matches any request URL.
+	Network       *NetworkSelector `protobuf:"bytes,5,opt,name=network,proto3" json:"network,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -353,6 +412,93 @@ func (x *Selector) GetConstraints() []*AttrConstraint {
 	return nil
 }
 
+func (x *Selector) GetAttestations() []*AttestationConstraint {
+	if x != nil {
+		return x.Attestations
+	}
+	return nil
+}
+
+func (x *Selector) GetNetwork() *NetworkSelector {
+	if x != nil {
+		return x.Network
+	}
+	return nil
+}
+
+// NetworkSelector matches requests made through the proxy network
+type NetworkSelector struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Host is matched against the host name of the request URL without port
+	Host string `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	// Path is matched against the path of the request URL
+	Path string `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	// Methods lists the allowed HTTP methods. Empty matches any method
+	Methods []string `protobuf:"bytes,3,rep,name=methods,proto3" json:"methods,omitempty"`
+	// MatchType is the type of match to perform on host and path
+	MatchType     MatchType `protobuf:"varint,4,opt,name=match_type,json=matchType,proto3,enum=moby.buildkit.v1.sourcepolicy.MatchType" json:"match_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NetworkSelector) Reset() {
+	*x = NetworkSelector{}
+	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NetworkSelector) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NetworkSelector) ProtoMessage() {}
+
+func (x *NetworkSelector) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NetworkSelector.ProtoReflect.Descriptor instead.
+func (*NetworkSelector) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *NetworkSelector) GetHost() string {
+	if x != nil {
+		return x.Host
+	}
+	return ""
+}
+
+func (x *NetworkSelector) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *NetworkSelector) GetMethods() []string {
+	if x != nil {
+		return x.Methods
+	}
+	return nil
+}
+
+func (x *NetworkSelector) GetMatchType() MatchType {
+	if x != nil {
+		return x.MatchType
+	}
+	return MatchType_WILDCARD
+}
+
 // AttrConstraint defines a constraint on a source attribute
 type AttrConstraint struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -365,7 +511,7 @@ type AttrConstraint struct {
 
 func (x *AttrConstraint) Reset() {
 	*x = AttrConstraint{}
-	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[3]
+	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -377,7 +523,7 @@ func (x *AttrConstraint) String() string {
 func (*AttrConstraint) ProtoMessage() {}
 
 func (x *AttrConstraint) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[3]
+	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -390,7 +536,7 @@ func (x *AttrConstraint) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AttrConstraint.ProtoReflect.Descriptor instead.
 func (*AttrConstraint) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP(), []int{3}
+	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *AttrConstraint) GetKey() string {
@@ -414,18 +560,84 @@ func (x *AttrConstraint) GetCondition() AttrMatch {
 	return AttrMatch_EQUAL
 }
 
+// AttestationConstraint requires an image attestation of the given type
+// whose identity matches.
+type AttestationConstraint struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  AttestationType        `protobuf:"varint,1,opt,name=type,proto3,enum=moby.buildkit.v1.sourcepolicy.AttestationType" json:"type,omitempty"`
+	// Identity is the builder ID for provenance or the signer identity for
+	// signatures. Empty identity matches any attestation of the type.
+	Identity      string    `protobuf:"bytes,2,opt,name=identity,proto3" json:"identity,omitempty"`
+	MatchType     MatchType `protobuf:"varint,3,opt,name=match_type,json=matchType,proto3,enum=moby.buildkit.v1.sourcepolicy.MatchType" json:"match_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AttestationConstraint) Reset() {
+	*x = AttestationConstraint{}
+	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AttestationConstraint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttestationConstraint) ProtoMessage() {}
+
+func (x *AttestationConstraint) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttestationConstraint.ProtoReflect.Descriptor instead.
+func (*AttestationConstraint) Descriptor() ([]byte, []int) {
+	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AttestationConstraint) GetType() AttestationType {
+	if x != nil {
+		return x.Type
+	}
+	return AttestationType_PROVENANCE
+}
+
+func (x *AttestationConstraint) GetIdentity() string {
+	if x != nil {
+		return x.Identity
+	}
+	return ""
+}
+
+func (x *AttestationConstraint) GetMatchType() MatchType {
+	if x != nil {
+		return x.MatchType
+	}
+	return MatchType_WILDCARD
+}
+
 // Policy is the list of rules the policy engine will perform
 type Policy struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Version       int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"` // Currently 1
-	Rules         []*Rule                `protobuf:"bytes,2,rep,name=rules,proto3" json:"rules,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Version int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"` // Currently 1
+	Rules   []*Rule                `protobuf:"bytes,2,rep,name=rules,proto3" json:"rules,omitempty"`
+	// Audit reports sources that would be denied as build warnings instead of failing the build
+	Audit         bool `protobuf:"varint,3,opt,name=audit,proto3" json:"audit,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Policy) Reset() {
 	*x = Policy{}
-	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[4]
+	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -437,7 +649,7 @@ func (x *Policy) String() string {
 func (*Policy) ProtoMessage() {}
 
 func (x *Policy) ProtoReflect() protoreflect.Message {
-	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[4]
+	mi := &file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -450,7 +662,7 @@ func (x *Policy) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Policy.ProtoReflect.Descriptor instead.
 func (*Policy) Descriptor() ([]byte, []int) {
-	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP(), []int{4}
+	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *Policy) GetVersion() int64 {
@@ -467,6 +679,13 @@ func (x *Policy) GetRules() []*Rule {
 	return nil
 }
 
+func (x *Policy) GetAudit() bool {
+	if x != nil {
+		return x.Audit
+	}
+	return false
+}
+
 var File_github_com_moby_buildkit_sourcepolicy_pb_policy_proto protoreflect.FileDescriptor
 
 const file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDesc = "" +
@@ -484,29 +703,48 @@ const file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDesc = "" +
 	"\n" +
 	"AttrsEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xc4\x01\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xe8\x02\n" +
 	"\bSelector\x12\x1e\n" +
 	"\n" +
 	"identifier\x18\x01 \x01(\tR\n" +
 	"identifier\x12G\n" +
 	"\n" +
 	"match_type\x18\x02 \x01(\x0e2(.moby.buildkit.v1.sourcepolicy.MatchTypeR\tmatchType\x12O\n" +
-	"\vconstraints\x18\x03 \x03(\v2-.moby.buildkit.v1.sourcepolicy.AttrConstraintR\vconstraints\"\x80\x01\n" +
+	"\vconstraints\x18\x03 \x03(\v2-.moby.buildkit.v1.sourcepolicy.AttrConstraintR\vconstraints\x12X\n" +
+	"\fattestations\x18\x04 \x03(\v24.moby.buildkit.v1.sourcepolicy.AttestationConstraintR\fattestations\x12H\n" +
+	"\anetwork\x18\x05 \x01(\v2..moby.buildkit.v1.sourcepolicy.NetworkSelectorR\anetwork\"\x9c\x01\n" +
+	"\x0fNetworkSelector\x12\x12\n" +
+	"\x04host\x18\x01 \x01(\tR\x04host\x12\x12\n" +
+	"\x04path\x18\x02 \x01(\tR\x04path\x12\x18\n" +
+	"\amethods\x18\x03 \x03(\tR\amethods\x12G\n" +
+	"\n" +
+	"match_type\x18\x04 \x01(\x0e2(.moby.buildkit.v1.sourcepolicy.MatchTypeR\tmatchType\"\x80\x01\n" +
 	"\x0eAttrConstraint\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value\x12F\n" +
-	"\tcondition\x18\x03 \x01(\x0e2(.moby.buildkit.v1.sourcepolicy.AttrMatchR\tcondition\"]\n" +
+	"\tcondition\x18\x03 \x01(\x0e2(.moby.buildkit.v1.sourcepolicy.AttrMatchR\tcondition\"\xc0\x01\n" +
+	"\x15AttestationConstraint\x12B\n" +
+	"\x04type\x18\x01 \x01(\x0e2..moby.buildkit.v1.sourcepolicy.AttestationTypeR\x04type\x12\x1a\n" +
+	"\bidentity\x18\x02 \x01(\tR\bidentity\x12G\n" +
+	"\n" +
+	"match_type\x18\x03 \x01(\x0e2(.moby.buildkit.v1.sourcepolicy.MatchTypeR\tmatchType\"s\n" +
 	"\x06Policy\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\x03R\aversion\x129\n" +
-	"\x05rules\x18\x02 \x03(\v2#.moby.buildkit.v1.sourcepolicy.RuleR\x05rules*0\n" +
+	"\x05rules\x18\x02 \x03(\v2#.moby.buildkit.v1.sourcepolicy.RuleR\x05rules\x12\x14\n" +
+	"\x05audit\x18\x03 \x01(\bR\x05audit*:\n" +
 	"\fPolicyAction\x12\t\n" +
 	"\x05ALLOW\x10\x00\x12\b\n" +
 	"\x04DENY\x10\x01\x12\v\n" +
-	"\aCONVERT\x10\x02*1\n" +
+	"\aCONVERT\x10\x02\x12\b\n" +
+	"\x04WARN\x10\x03*1\n" +
 	"\tAttrMatch\x12\t\n" +
 	"\x05EQUAL\x10\x00\x12\f\n" +
 	"\bNOTEQUAL\x10\x01\x12\v\n" +
-	"\aMATCHES\x10\x02*/\n" +
+	"\aMATCHES\x10\x02*0\n" +
+	"\x0fAttestationType\x12\x0e\n" +
+	"\n" +
+	"PROVENANCE\x10\x00\x12\r\n" +
+	"\tSIGNATURE\x10\x01*/\n" +
 	"\tMatchType\x12\f\n" +
 	"\bWILDCARD\x10\x00\x12\t\n" +
 	"\x05EXACT\x10\x01\x12\t\n" +
@@ -524,33 +762,41 @@ func file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescGZIP() []
 	return file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDescData
 }
 
-var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
-var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_goTypes = []any{
-	(PolicyAction)(0),      // 0: moby.buildkit.v1.sourcepolicy.PolicyAction
-	(AttrMatch)(0),         // 1: moby.buildkit.v1.sourcepolicy.AttrMatch
-	(MatchType)(0),         // 2: moby.buildkit.v1.sourcepolicy.MatchType
-	(*Rule)(nil),           // 3: moby.buildkit.v1.sourcepolicy.Rule
-	(*Update)(nil),         // 4: moby.buildkit.v1.sourcepolicy.Update
-	(*Selector)(nil),       // 5: moby.buildkit.v1.sourcepolicy.Selector
-	(*AttrConstraint)(nil), // 6: moby.buildkit.v1.sourcepolicy.AttrConstraint
-	(*Policy)(nil),         // 7: moby.buildkit.v1.sourcepolicy.Policy
-	nil,                    // 8: moby.buildkit.v1.sourcepolicy.Update.AttrsEntry
+	(PolicyAction)(0),             // 0: moby.buildkit.v1.sourcepolicy.PolicyAction
+	(AttrMatch)(0),                // 1: moby.buildkit.v1.sourcepolicy.AttrMatch
+	(AttestationType)(0),          // 2: moby.buildkit.v1.sourcepolicy.AttestationType
+	(MatchType)(0),                // 3: moby.buildkit.v1.sourcepolicy.MatchType
+	(*Rule)(nil),                  // 4: moby.buildkit.v1.sourcepolicy.Rule
+	(*Update)(nil),                // 5: moby.buildkit.v1.sourcepolicy.Update
+	(*Selector)(nil),              // 6: moby.buildkit.v1.sourcepolicy.Selector
+	(*NetworkSelector)(nil),       // 7: moby.buildkit.v1.sourcepolicy.NetworkSelector
+	(*AttrConstraint)(nil),        // 8: moby.buildkit.v1.sourcepolicy.AttrConstraint
+	(*AttestationConstraint)(nil), // 9: moby.buildkit.v1.sourcepolicy.AttestationConstraint
+	(*Policy)(nil),                // 10: moby.buildkit.v1.sourcepolicy.Policy
+	nil,                           // 11: moby.buildkit.v1.sourcepolicy.Update.AttrsEntry
 }
 var file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_depIdxs = []int32{
-	0, // 0: moby.buildkit.v1.sourcepolicy.Rule.action:type_name -> moby.buildkit.v1.sourcepolicy.PolicyAction
-	5, // 1: moby.buildkit.v1.sourcepolicy.Rule.selector:type_name -> moby.buildkit.v1.sourcepolicy.Selector
-	4, // 2: moby.buildkit.v1.sourcepolicy.Rule.updates:type_name -> moby.buildkit.v1.sourcepolicy.Update
-	8, // 3: moby.buildkit.v1.sourcepolicy.Update.attrs:type_name -> moby.buildkit.v1.sourcepolicy.Update.AttrsEntry
-	2, // 4: moby.buildkit.v1.sourcepolicy.Selector.match_type:type_name -> moby.buildkit.v1.sourcepolicy.MatchType
-	6, // 5: moby.buildkit.v1.sourcepolicy.Selector.constraints:type_name -> moby.buildkit.v1.sourcepolicy.AttrConstraint
-	1, // 6: moby.buildkit.v1.sourcepolicy.AttrConstraint.condition:type_name -> moby.buildkit.v1.sourcepolicy.AttrMatch
-	3, // 7: moby.buildkit.v1.sourcepolicy.Policy.rules:type_name -> moby.buildkit.v1.sourcepolicy.Rule
-	8, // [8:8] is the sub-list for method output_type
-	8, // [8:8] is the sub-list for method input_type
-	8, // [8:8] is the sub-list for extension type_name
-	8, // [8:8] is the sub-list for extension extendee
-	0, // [0:8] is the sub-list for field type_name
+	0,  // 0: moby.buildkit.v1.sourcepolicy.Rule.action:type_name -> moby.buildkit.v1.sourcepolicy.PolicyAction
+	6,  // 1: moby.buildkit.v1.sourcepolicy.Rule.selector:type_name -> moby.buildkit.v1.sourcepolicy.Selector
+	5,  // 2: moby.buildkit.v1.sourcepolicy.Rule.updates:type_name -> moby.buildkit.v1.sourcepolicy.Update
+	11, // 3: moby.buildkit.v1.sourcepolicy.Update.attrs:type_name -> moby.buildkit.v1.sourcepolicy.Update.AttrsEntry
+	3,  // 4: moby.buildkit.v1.sourcepolicy.Selector.match_type:type_name -> moby.buildkit.v1.sourcepolicy.MatchType
+	8,  // 5: moby.buildkit.v1.sourcepolicy.Selector.constraints:type_name -> moby.buildkit.v1.sourcepolicy.AttrConstraint
+	9,  // 6: moby.buildkit.v1.sourcepolicy.Selector.attestations:type_name -> moby.buildkit.v1.sourcepolicy.AttestationConstraint
+	7,  // 7: moby.buildkit.v1.sourcepolicy.Selector.network:type_name -> moby.buildkit.v1.sourcepolicy.NetworkSelector
+	3,  // 8: moby.buildkit.v1.sourcepolicy.NetworkSelector.match_type:type_name -> moby.buildkit.v1.sourcepolicy.MatchType
+	1,  // 9: moby.buildkit.v1.sourcepolicy.AttrConstraint.condition:type_name -> moby.buildkit.v1.sourcepolicy.AttrMatch
+	2,  // 10: moby.buildkit.v1.sourcepolicy.AttestationConstraint.type:type_name -> moby.buildkit.v1.sourcepolicy.AttestationType
+	3,  // 11: moby.buildkit.v1.sourcepolicy.AttestationConstraint.match_type:type_name -> moby.buildkit.v1.sourcepolicy.MatchType
+	4,  // 12: moby.buildkit.v1.sourcepolicy.Policy.rules:type_name -> moby.buildkit.v1.sourcepolicy.Rule
+	13, // [13:13] is the sub-list for method output_type
+	13, // [13:13] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
 }
 
 func init() { file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_init() }
@@ -563,8 +809,8 @@ func file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDesc), len(file_github_com_moby_buildkit_sourcepolicy_pb_policy_proto_rawDesc)),
-			NumEnums:      3,
-			NumMessages:   6,
+			NumEnums:      4,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -60,6 +60,23 @@ func NewWithRewriteTimestamp(ctx context.Context, cs content.Store, desc ocispec
 	return (&c).convert, nil
 }
 
+// NewWithFilter returns converter function that removes the tar entries
+// rejected by filter from the blob and compresses it according to the
+// specified compression type.
+func NewWithFilter(ctx context.Context, cs content.Store, desc ocispecs.Descriptor, comp compression.Config, filter tarconverter.EntryFilter) (converter.ConvertFunc, error) {
+	from, err := compression.FromMediaType(desc.MediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	c := conversion{target: comp}
+	c.compress, c.finalize = comp.Type.Compress(ctx, comp)
+	c.decompress = from.Decompress
+	c.filter = filter
+
+	return (&c).convert, nil
+}
+
 type conversion struct {
 	target           compression.Config
 	decompress       compression.Decompressor
@@ -67,6 +84,7 @@ type conversion struct {
 	finalize         compression.Finalizer
 	rewriteTimestamp *time.Time
 	immDiffIDs       map[digest.Digest]struct{} // diffIDs of immutable layers
+	filter           tarconverter.EntryFilter
 }
 
 var bufioPool = pools.New(func() *bufio.Writer {
@@ -125,8 +143,12 @@ func (c *conversion) convert(ctx context.Context, cs content.Store, desc ocispec
 	}
 	defer decR.Close()
 	rdr := decR
-	if c.rewriteTimestamp != nil {
-		tcR := tarconverter.NewReader(io.TeeReader(decR, origDiffID.Hash()), rewriteTimestampInTarHeader(*c.rewriteTimestamp))
+	if c.rewriteTimestamp != nil || c.filter != nil {
+		var hc tarconverter.HeaderConverter
+		if c.rewriteTimestamp != nil {
+			hc = rewriteTimestampInTarHeader(*c.rewriteTimestamp)
+		}
+		tcR := tarconverter.NewFilterReader(io.TeeReader(decR, origDiffID.Hash()), c.filter, hc)
 		defer tcR.Close()
 		rdr = tcR
 	}
@@ -8,10 +8,20 @@ import (
 
 type HeaderConverter func(*tar.Header)
 
+// EntryFilter returns false for the entries that are removed from the archive.
+type EntryFilter func(*tar.Header) bool
+
 // NewReader returns a reader that applies headerConverter.
 // srcContent is drained until hitting EOF.
 // Forked from https://github.com/moby/moby/blob/v24.0.6/pkg/archive/copy.go#L308-L373 .
 func NewReader(srcContent io.Reader, headerConverter HeaderConverter) io.ReadCloser {
+	return NewFilterReader(srcContent, nil, headerConverter)
+}
+
+// NewFilterReader returns a reader that removes the entries rejected by filter
+// and applies headerConverter to the remaining entries.
+// srcContent is drained until hitting EOF.
+func NewFilterReader(srcContent io.Reader, filter EntryFilter, headerConverter HeaderConverter) io.ReadCloser {
 	rebased, w := io.Pipe()
 
 	go func() {
@@ -37,6 +47,9 @@ func NewReader(srcContent io.Reader, headerConverter HeaderConverter) io.ReadClo
 				w.CloseWithError(err)
 				return
 			}
+			if filter != nil && !filter(hdr) {
+				continue
+			}
 			if headerConverter != nil {
 				headerConverter(hdr)
 			}
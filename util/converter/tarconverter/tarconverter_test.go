@@ -40,3 +40,39 @@ func TestPaddingForReader(t *testing.T) {
 	require.NoError(t, r.Close())
 	assert.Equal(t, len(inB), len(outB))
 }
+
+func TestFilterReader(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	tw := tar.NewWriter(buf)
+	for _, name := range []string{"foo", "bar", "baz"} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Size:     int64(len(name)),
+			Mode:     0o644,
+		}))
+		_, err := tw.Write([]byte(name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	r := NewFilterReader(buf, func(hdr *tar.Header) bool {
+		return hdr.Name != "bar"
+	}, nil)
+	defer r.Close()
+
+	tr := tar.NewReader(r)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		dt, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		require.Equal(t, hdr.Name, string(dt))
+		names = append(names, hdr.Name)
+	}
+	require.Equal(t, []string{"foo", "baz"}, names)
+}
@@ -0,0 +1,563 @@
+package estargz
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/stargz-snapshotter/estargz"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxPrefetchSize is the largest file that is fetched ahead of the tar
+// writer. Bigger files are streamed chunk by chunk when they are written.
+const maxPrefetchSize = 8 << 20
+
+const (
+	// maxBatchSize is the largest range of the blob that is fetched with a
+	// single request.
+	maxBatchSize = 4 << 20
+	// maxBatchGap is the largest gap between the payloads of two files that
+	// are still fetched with a single request.
+	maxBatchGap = 64 << 10
+	// maxCachedBatches is the number of fetched ranges kept in memory. Ranges
+	// are released once all their chunks were read, the limit only applies
+	// if files are read in a different order than they are stored.
+	maxCachedBatches = 16
+)
+
+// HasTOC returns true if the layer descriptor carries an eStargz TOC digest.
+func HasTOC(desc ocispecs.Descriptor) bool {
+	_, ok := desc.Annotations[estargz.TOCJSONDigestAnnotation]
+	return ok
+}
+
+// TOCReader reads an eStargz layer through its table of contents.
+type TOCReader struct {
+	r  *estargz.Reader
+	v  estargz.TOCEntryVerifier
+	br *batchReaderAt
+}
+
+// OpenTOC opens the eStargz layer described by desc. Only the footer and the
+// TOC are read from ra, file payloads are read by ranges when requested. The
+// TOC is verified against the digest in the layer annotations. ra must be
+// safe for concurrent use.
+func OpenTOC(ra io.ReaderAt, desc ocispecs.Descriptor) (*TOCReader, error) {
+	v, ok := desc.Annotations[estargz.TOCJSONDigestAnnotation]
+	if !ok {
+		return nil, errors.Errorf("layer %s does not have a TOC", desc.Digest)
+	}
+	tocDgst, err := digest.Parse(v)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid TOC digest for layer %s", desc.Digest)
+	}
+	br := &batchReaderAt{ra: ra}
+	r, err := estargz.Open(io.NewSectionReader(br, 0, desc.Size))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open TOC of layer %s", desc.Digest)
+	}
+	verifier, err := r.VerifyTOC(tocDgst)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to verify TOC of layer %s", desc.Digest)
+	}
+	return &TOCReader{r: r, v: verifier, br: br}, nil
+}
+
+// Tar returns an uncompressed tar stream with the contents of the layer.
+// Payloads of small files are fetched concurrently, up to parallel files
+// ahead of the writer. Payloads stored close to each other in the blob are
+// fetched with a single ranged read. Every chunk is verified against the TOC.
+func (tr *TOCReader) Tar(ctx context.Context, parallel int) io.ReadCloser {
+	return tr.tar(ctx, parallel, false)
+}
+
+// PrefetchTar is like Tar, but only includes the payloads of the files that
+// the layer lists for prefetching, the ones stored before its prefetch
+// landmark. The other regular files are written empty, LazyFiles returns them.
+func (tr *TOCReader) PrefetchTar(ctx context.Context, parallel int) io.ReadCloser {
+	return tr.tar(ctx, parallel, true)
+}
+
+func (tr *TOCReader) tar(ctx context.Context, parallel int, prefetch bool) io.ReadCloser {
+	if parallel < 1 {
+		parallel = 1
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(tr.writeTar(ctx, pw, parallel, prefetch))
+	}()
+	return pr
+}
+
+// LazyFile is a regular file whose payload is not included by PrefetchTar.
+type LazyFile struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	Xattrs  map[string][]byte
+}
+
+// LazyFiles returns the regular files whose payloads are not included by
+// PrefetchTar. Hardlinks are only returned once, by their first name.
+func (tr *TOCReader) LazyFiles() ([]LazyFile, error) {
+	files, err := tr.files(true)
+	if err != nil {
+		return nil, err
+	}
+	var lazy []LazyFile
+	for _, f := range files {
+		if !f.lazy {
+			continue
+		}
+		lazy = append(lazy, LazyFile{
+			Name:    f.name,
+			Size:    f.ent.Size,
+			ModTime: f.ent.ModTime(),
+			Xattrs:  f.ent.Xattrs,
+		})
+	}
+	return lazy, nil
+}
+
+// WriteFile writes the payload of the regular file name to w. Every chunk is
+// verified against the TOC.
+func (tr *TOCReader) WriteFile(w io.Writer, name string) error {
+	ent, ok := tr.r.Lookup(name)
+	if !ok || ent.Type != "reg" {
+		return errors.Errorf("regular file %s not found in TOC", name)
+	}
+	return tr.copyFile(w, ent)
+}
+
+type tocFile struct {
+	name string
+	ent  *estargz.TOCEntry
+	link string // set for hardlinks to an earlier entry
+	lazy bool   // the payload is not written by PrefetchTar
+	data chan []byte
+}
+
+func (tr *TOCReader) files(prefetch bool) ([]*tocFile, error) {
+	root, ok := tr.r.Lookup("")
+	if !ok {
+		return nil, errors.New("TOC has no root directory")
+	}
+	// without a prefetch landmark no files are prefetched
+	lazyOffset := int64(0)
+	if prefetch {
+		if landmark, ok := tr.r.Lookup(estargz.PrefetchLandmark); ok {
+			lazyOffset = landmark.Offset
+		}
+	}
+	var files []*tocFile
+	seen := map[*estargz.TOCEntry]string{}
+	var walk func(dir string, ent *estargz.TOCEntry)
+	walk = func(dir string, ent *estargz.TOCEntry) {
+		var names []string
+		children := map[string]*estargz.TOCEntry{}
+		ent.ForeachChild(func(base string, e *estargz.TOCEntry) bool {
+			names = append(names, base)
+			children[base] = e
+			return true
+		})
+		sort.Strings(names)
+		for _, base := range names {
+			if dir == "" && (base == estargz.PrefetchLandmark || base == estargz.NoPrefetchLandmark) {
+				continue
+			}
+			e := children[base]
+			p := path.Join(dir, base)
+			if first, ok := seen[e]; ok {
+				files = append(files, &tocFile{name: p, ent: e, link: first})
+				continue
+			}
+			if e.Type == "reg" && e.NumLink > 1 {
+				seen[e] = p
+			}
+			f := &tocFile{name: p, ent: e}
+			if prefetch && e.Type == "reg" && e.Size > 0 && e.Offset >= lazyOffset {
+				f.lazy = true
+			} else if e.Type == "reg" && e.Size > 0 && e.Size <= maxPrefetchSize {
+				f.data = make(chan []byte, 1)
+			}
+			files = append(files, f)
+			if e.Type == "dir" {
+				walk(p, e)
+			}
+		}
+	}
+	walk("", root)
+	return files, nil
+}
+
+func (tr *TOCReader) writeTar(ctx context.Context, w io.Writer, parallel int, prefetch bool) error {
+	files, err := tr.files(prefetch)
+	if err != nil {
+		return err
+	}
+	if err := tr.planBatches(files); err != nil {
+		return err
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, parallel)
+	eg.Go(func() error {
+		for _, f := range files {
+			if f.data == nil {
+				continue
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			}
+			eg.Go(func() error {
+				buf := make([]byte, 0, f.ent.Size)
+				bw := &byteWriter{buf: buf}
+				if err := tr.copyFile(bw, f.ent); err != nil {
+					return err
+				}
+				f.data <- bw.buf
+				return nil
+			})
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		tw := tar.NewWriter(w)
+		for _, f := range files {
+			if err := tw.WriteHeader(tarHeader(f)); err != nil {
+				return errors.WithStack(err)
+			}
+			if f.link != "" || f.lazy || f.ent.Type != "reg" || f.ent.Size == 0 {
+				continue
+			}
+			if f.data == nil {
+				if err := tr.copyFile(tw, f.ent); err != nil {
+					return err
+				}
+				continue
+			}
+			select {
+			case dt := <-f.data:
+				<-sem
+				if _, err := tw.Write(dt); err != nil {
+					return errors.WithStack(err)
+				}
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			}
+		}
+		return errors.WithStack(tw.Close())
+	})
+	return eg.Wait()
+}
+
+// copyFile writes the payload of a regular file to w one chunk at a time,
+// verifying each chunk against its digest in the TOC.
+func (tr *TOCReader) copyFile(w io.Writer, ent *estargz.TOCEntry) error {
+	sr, err := tr.r.OpenFile(ent.Name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	chunks, err := tr.chunks(ent)
+	if err != nil {
+		return err
+	}
+	for _, ce := range chunks {
+		v, err := tr.v.Verifier(ce)
+		if err != nil {
+			return errors.Wrapf(err, "no verifier for %s at offset %d", ent.Name, ce.ChunkOffset)
+		}
+		buf := make([]byte, ce.ChunkSize)
+		if _, err := sr.ReadAt(buf, ce.ChunkOffset); err != nil && !errors.Is(err, io.EOF) {
+			return errors.Wrapf(err, "failed to read %s at offset %d", ent.Name, ce.ChunkOffset)
+		}
+		tr.br.done(ce.Offset)
+		v.Write(buf)
+		if !v.Verified() {
+			return errors.Errorf("invalid chunk digest for %s at offset %d", ent.Name, ce.ChunkOffset)
+		}
+		if _, err := w.Write(buf); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// chunks returns the chunk entries of the payload of a regular file.
+func (tr *TOCReader) chunks(ent *estargz.TOCEntry) ([]*estargz.TOCEntry, error) {
+	var chunks []*estargz.TOCEntry
+	for off := int64(0); off < ent.Size; {
+		ce, ok := tr.r.ChunkEntryForOffset(ent.Name, off)
+		if !ok {
+			return nil, errors.Errorf("no chunk for %s at offset %d", ent.Name, off)
+		}
+		chunks = append(chunks, ce)
+		off = ce.ChunkOffset + ce.ChunkSize
+	}
+	return chunks, nil
+}
+
+// planBatches groups the compressed payloads of the files into the ranges
+// that are fetched from the blob with a single read.
+func (tr *TOCReader) planBatches(files []*tocFile) error {
+	var ranges []*batch
+	var starts []int64
+	for _, f := range files {
+		if f.link != "" || f.lazy || f.ent.Type != "reg" || f.ent.Size == 0 {
+			continue
+		}
+		chunks, err := tr.chunks(f.ent)
+		if err != nil {
+			return err
+		}
+		for _, ce := range chunks {
+			starts = append(starts, ce.Offset)
+		}
+		first, last := chunks[0], chunks[len(chunks)-1]
+		ranges = append(ranges, &batch{off: first.Offset, size: last.NextOffset() - first.Offset})
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].off < ranges[j].off
+	})
+
+	var batches []*batch
+	for _, r := range ranges {
+		if len(batches) > 0 {
+			b := batches[len(batches)-1]
+			end := b.off + b.size
+			if r.off < end {
+				// hardlinked payloads are only listed once, but stay safe
+				// against overlapping ranges
+				if r.off+r.size > end {
+					b.size = r.off + r.size - b.off
+				}
+				continue
+			}
+			if r.off-end <= maxBatchGap && r.off+r.size-b.off <= maxBatchSize {
+				b.size = r.off + r.size - b.off
+				continue
+			}
+		}
+		// payloads of big files are split, so every batch fits the limit
+		for r.size > maxBatchSize {
+			batches = append(batches, &batch{off: r.off, size: maxBatchSize})
+			r = &batch{off: r.off + maxBatchSize, size: r.size - maxBatchSize}
+		}
+		batches = append(batches, r)
+	}
+	tr.br.batches = batches
+	for _, off := range starts {
+		if b := tr.br.find(off); b != nil {
+			b.chunks++
+		}
+	}
+	return nil
+}
+
+func tarHeader(f *tocFile) *tar.Header {
+	ent := f.ent
+	h := &tar.Header{
+		Name:     f.name,
+		Mode:     ent.Mode,
+		Uid:      ent.UID,
+		Gid:      ent.GID,
+		Uname:    ent.Uname,
+		Gname:    ent.Gname,
+		ModTime:  ent.ModTime(),
+		Format:   tar.FormatPAX,
+		Devmajor: int64(ent.DevMajor),
+		Devminor: int64(ent.DevMinor),
+	}
+	if f.link != "" {
+		h.Typeflag = tar.TypeLink
+		h.Linkname = f.link
+		return h
+	}
+	switch ent.Type {
+	case "dir":
+		h.Typeflag = tar.TypeDir
+		h.Name += "/"
+	case "reg":
+		h.Typeflag = tar.TypeReg
+		if !f.lazy {
+			h.Size = ent.Size
+		}
+	case "symlink":
+		h.Typeflag = tar.TypeSymlink
+		h.Linkname = ent.LinkName
+	case "char":
+		h.Typeflag = tar.TypeChar
+	case "block":
+		h.Typeflag = tar.TypeBlock
+	case "fifo":
+		h.Typeflag = tar.TypeFifo
+	}
+	for k, v := range ent.Xattrs {
+		if h.PAXRecords == nil {
+			h.PAXRecords = map[string]string{}
+		}
+		h.PAXRecords["SCHILY.xattr."+k] = string(v)
+	}
+	return h
+}
+
+type byteWriter struct {
+	buf []byte
+}
+
+func (w *byteWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+// batchReaderAt coalesces the reads of file payloads into fewer, larger reads
+// of the underlying ReaderAt. A fetched range is kept in memory until all the
+// chunks that start in it have been read. Reads outside the planned ranges,
+// like the ones of the TOC, are passed through.
+type batchReaderAt struct {
+	ra      io.ReaderAt
+	batches []*batch // sorted by offset, not overlapping
+
+	mu     sync.Mutex
+	cached []*batch
+}
+
+type batch struct {
+	off, size int64
+	chunks    int // chunks starting in the range that were not read yet
+
+	mu sync.Mutex
+	dt []byte
+}
+
+func (r *batchReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	for len(p) > 0 {
+		i := sort.Search(len(r.batches), func(i int) bool {
+			return r.batches[i].off+r.batches[i].size > off
+		})
+		var m int
+		var err error
+		if i < len(r.batches) && r.batches[i].off <= off {
+			m, err = r.readBatch(r.batches[i], p, off)
+		} else {
+			end := off + int64(len(p))
+			if i < len(r.batches) && r.batches[i].off < end {
+				end = r.batches[i].off
+			}
+			m, err = r.ra.ReadAt(p[:end-off], off)
+		}
+		n += m
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+		off += int64(m)
+	}
+	return n, nil
+}
+
+func (r *batchReaderAt) readBatch(b *batch, p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	fetched := false
+	if b.dt == nil {
+		dt := make([]byte, b.size)
+		if _, err := r.ra.ReadAt(dt, b.off); err != nil {
+			b.mu.Unlock()
+			return 0, err
+		}
+		b.dt = dt
+		fetched = true
+	}
+	n := copy(p, b.dt[off-b.off:])
+	b.mu.Unlock()
+
+	if fetched {
+		r.cache(b)
+	}
+	return n, nil
+}
+
+// cache tracks the fetched batch and releases the oldest ones over the limit.
+func (r *batchReaderAt) cache(b *batch) {
+	r.mu.Lock()
+	r.cached = append(r.cached, b)
+	var evicted []*batch
+	for len(r.cached) > maxCachedBatches {
+		evicted = append(evicted, r.cached[0])
+		r.cached = r.cached[1:]
+	}
+	r.mu.Unlock()
+
+	for _, b := range evicted {
+		b.mu.Lock()
+		b.dt = nil
+		b.mu.Unlock()
+	}
+}
+
+// done marks the chunk starting at off as read.
+func (r *batchReaderAt) done(off int64) {
+	b := r.find(off)
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.chunks--
+	release := b.chunks <= 0
+	if release {
+		b.dt = nil
+	}
+	b.mu.Unlock()
+
+	if release {
+		r.mu.Lock()
+		if i := slices.Index(r.cached, b); i >= 0 {
+			r.cached = slices.Delete(r.cached, i, i+1)
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *batchReaderAt) find(off int64) *batch {
+	i := sort.Search(len(r.batches), func(i int) bool {
+		return r.batches[i].off+r.batches[i].size > off
+	})
+	if i < len(r.batches) && r.batches[i].off <= off {
+		return r.batches[i]
+	}
+	return nil
+}
+
+// ConcurrentReaderAt returns a ReaderAt that opens a new reader from the
+// provider for every read, so ranged reads can be issued concurrently.
+func ConcurrentReaderAt(ctx context.Context, p content.Provider, desc ocispecs.Descriptor) io.ReaderAt {
+	return &concurrentReaderAt{ctx: ctx, p: p, desc: desc}
+}
+
+type concurrentReaderAt struct {
+	ctx  context.Context
+	p    content.Provider
+	desc ocispecs.Descriptor
+}
+
+func (r *concurrentReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	ra, err := r.p.ReaderAt(r.ctx, r.desc)
+	if err != nil {
+		return 0, err
+	}
+	defer ra.Close()
+	return ra.ReadAt(b, off)
+}
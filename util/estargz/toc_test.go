@@ -0,0 +1,263 @@
+package estargz
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/containerd/stargz-snapshotter/estargz"
+	"github.com/moby/buildkit/util/testutil"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTOCReaderTar(t *testing.T) {
+	t.Parallel()
+
+	big := bytes.Repeat([]byte("0123456789abcdef"), 1<<16) // 1MiB, chunked
+	mtime := time.Unix(1700000000, 0)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, h := range []struct {
+		hdr  tar.Header
+		data []byte
+	}{
+		{hdr: tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}},
+		{hdr: tar.Header{Name: "dir/small", Typeflag: tar.TypeReg, Mode: 0644}, data: []byte("hello")},
+		{hdr: tar.Header{Name: "dir/big", Typeflag: tar.TypeReg, Mode: 0600, Uid: 1000, Gid: 1000}, data: big},
+		{hdr: tar.Header{Name: "dir/link", Typeflag: tar.TypeSymlink, Linkname: "small"}},
+		{hdr: tar.Header{Name: "hard", Typeflag: tar.TypeLink, Linkname: "dir/small"}},
+		{hdr: tar.Header{Name: ".wh.removed", Typeflag: tar.TypeReg, Mode: 0}},
+	} {
+		hdr := h.hdr
+		hdr.ModTime = mtime
+		hdr.Size = int64(len(h.data))
+		require.NoError(t, tw.WriteHeader(&hdr))
+		_, err := tw.Write(h.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	blob, err := estargz.Build(io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len())), estargz.WithChunkSize(64<<10), estargz.WithCompression(testutil.EStargzCompression()))
+	require.NoError(t, err)
+	defer blob.Close()
+	dt, err := io.ReadAll(blob)
+	require.NoError(t, err)
+
+	desc := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageLayerGzip,
+		Size:      int64(len(dt)),
+		Annotations: map[string]string{
+			estargz.TOCJSONDigestAnnotation: blob.TOCDigest().String(),
+		},
+	}
+	require.True(t, HasTOC(desc))
+
+	tr, err := OpenTOC(bytes.NewReader(dt), desc)
+	require.NoError(t, err)
+
+	rc := tr.Tar(t.Context(), 4)
+	defer rc.Close()
+
+	type entry struct {
+		typ      byte
+		mode     int64
+		uid      int
+		linkname string
+		data     digest.Digest
+	}
+	got := map[string]entry{}
+	r := tar.NewReader(rc)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.True(t, h.ModTime.Equal(mtime), h.Name)
+		got[h.Name] = entry{typ: h.Typeflag, mode: h.Mode, uid: h.Uid, linkname: h.Linkname, data: digest.FromBytes(data)}
+	}
+
+	require.Equal(t, map[string]entry{
+		"dir/":        {typ: tar.TypeDir, mode: 0755, data: digest.FromString("")},
+		"dir/big":     {typ: tar.TypeReg, mode: 0600, uid: 1000, data: digest.FromBytes(big)},
+		"dir/link":    {typ: tar.TypeSymlink, linkname: "small", data: digest.FromString("")},
+		"dir/small":   {typ: tar.TypeReg, mode: 0644, data: digest.FromString("hello")},
+		"hard":        {typ: tar.TypeLink, mode: 0644, linkname: "dir/small", data: digest.FromString("")},
+		".wh.removed": {typ: tar.TypeReg, data: digest.FromString("")},
+	}, got)
+}
+
+func TestTOCReaderBatchedReads(t *testing.T) {
+	t.Parallel()
+
+	big := bytes.Repeat([]byte("0123456789abcdef"), 1<<19) // 8MiB, chunked
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	files := map[string][]byte{}
+	for i := range 200 {
+		name := fmt.Sprintf("f%03d", i)
+		files[name] = []byte(name)
+	}
+	files["z-big"] = big
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(files[name]))}))
+		_, err := tw.Write(files[name])
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	blob, err := estargz.Build(io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len())), estargz.WithChunkSize(64<<10), estargz.WithCompression(testutil.EStargzCompression()))
+	require.NoError(t, err)
+	defer blob.Close()
+	dt, err := io.ReadAll(blob)
+	require.NoError(t, err)
+
+	desc := ocispecs.Descriptor{
+		Size: int64(len(dt)),
+		Annotations: map[string]string{
+			estargz.TOCJSONDigestAnnotation: blob.TOCDigest().String(),
+		},
+	}
+	ra := &countingReaderAt{ReaderAt: bytes.NewReader(dt)}
+	tr, err := OpenTOC(ra, desc)
+	require.NoError(t, err)
+	ra.reads.Store(0)
+
+	rc := tr.Tar(t.Context(), 4)
+	defer rc.Close()
+	r := tar.NewReader(rc)
+	n := 0
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, files[h.Name], data, h.Name)
+		n++
+	}
+	require.Equal(t, len(files), n)
+
+	// the small files share a single read, the chunks of the big file are
+	// read in ranges of maxBatchSize
+	require.LessOrEqual(t, ra.reads.Load(), int64(1+len(big)/maxBatchSize+1))
+}
+
+type countingReaderAt struct {
+	io.ReaderAt
+	reads atomic.Int64
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.reads.Add(1)
+	return r.ReaderAt.ReadAt(p, off)
+}
+
+func TestOpenTOCInvalidDigest(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "foo", Typeflag: tar.TypeReg, Size: 3}))
+	_, err := tw.Write([]byte("bar"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+
+	blob, err := estargz.Build(io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len())), estargz.WithCompression(testutil.EStargzCompression()))
+	require.NoError(t, err)
+	defer blob.Close()
+	dt, err := io.ReadAll(blob)
+	require.NoError(t, err)
+
+	desc := ocispecs.Descriptor{
+		Size: int64(len(dt)),
+		Annotations: map[string]string{
+			estargz.TOCJSONDigestAnnotation: "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		},
+	}
+	_, err = OpenTOC(bytes.NewReader(dt), desc)
+	require.ErrorContains(t, err, "failed to verify TOC")
+
+	_, err = OpenTOC(bytes.NewReader(dt), ocispecs.Descriptor{Size: int64(len(dt))})
+	require.ErrorContains(t, err, "does not have a TOC")
+}
+
+func TestTOCReaderPrefetchTar(t *testing.T) {
+	t.Parallel()
+
+	files := []struct {
+		name string
+		data string
+	}{
+		{"prio", "prio contents"},
+		{"lazy", "lazy contents"},
+		{"empty", ""},
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: f.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(f.data))}))
+		_, err := tw.Write([]byte(f.data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	blob, err := estargz.Build(io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len())), estargz.WithPrioritizedFiles([]string{"prio"}), estargz.WithCompression(testutil.EStargzCompression()))
+	require.NoError(t, err)
+	defer blob.Close()
+	dt, err := io.ReadAll(blob)
+	require.NoError(t, err)
+
+	tr, err := OpenTOC(bytes.NewReader(dt), ocispecs.Descriptor{
+		Size: int64(len(dt)),
+		Annotations: map[string]string{
+			estargz.TOCJSONDigestAnnotation: blob.TOCDigest().String(),
+		},
+	})
+	require.NoError(t, err)
+
+	lazy, err := tr.LazyFiles()
+	require.NoError(t, err)
+	require.Len(t, lazy, 1)
+	require.Equal(t, "lazy", lazy[0].Name)
+	require.Equal(t, int64(len("lazy contents")), lazy[0].Size)
+
+	// the files after the prefetch landmark are written empty
+	rc := tr.PrefetchTar(t.Context(), 4)
+	defer rc.Close()
+	contents := map[string]string{}
+	r := tar.NewReader(rc)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		dt, err := io.ReadAll(r)
+		require.NoError(t, err)
+		contents[hdr.Name] = string(dt)
+	}
+	require.Equal(t, map[string]string{
+		"prio":  "prio contents",
+		"lazy":  "",
+		"empty": "",
+	}, contents)
+
+	var w bytes.Buffer
+	require.NoError(t, tr.WriteFile(&w, "lazy"))
+	require.Equal(t, "lazy contents", w.String())
+	require.Error(t, tr.WriteFile(&w, "missing"))
+}
@@ -26,6 +26,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -515,7 +516,8 @@ func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		r.URL.Scheme = "http"
 		r.URL.Host = r.Host
 	}
-	if target, err := h.check(r.Context(), r.Method, r.URL.String()); err != nil {
+	target, checksum, err := h.check(r.Context(), r.Method, r.URL.String())
+	if err != nil {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	} else if target != nil {
@@ -529,6 +531,12 @@ func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadGateway)
 		return
 	}
+	if err := verifyResponse(resp, checksum); err != nil {
+		h.recordRequest(r, http.StatusBadGateway, "")
+		h.recordIncomplete(r, "checksum_mismatch")
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
 	defer resp.Body.Close()
 	h.recordRequest(r, resp.StatusCode, finalURL(r, resp))
 	copyHeader(w.Header(), resp.Header)
@@ -574,7 +582,8 @@ func (h *proxyHandler) handleConnect(w http.ResponseWriter, r *http.Request) {
 		req.URL.Scheme = "https"
 		req.URL.Host = r.Host
 		req.RequestURI = ""
-		if target, err := h.check(req.Context(), req.Method, req.URL.String()); err != nil {
+		target, checksum, err := h.check(req.Context(), req.Method, req.URL.String())
+		if err != nil {
 			_ = req.Body.Close()
 			_, _ = io.WriteString(tlsConn, "HTTP/1.1 403 Forbidden\r\nContent-Length: 10\r\nConnection: close\r\n\r\nForbidden\n")
 			return
@@ -590,6 +599,13 @@ func (h *proxyHandler) handleConnect(w http.ResponseWriter, r *http.Request) {
 			_, _ = fmt.Fprintf(tlsConn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", len(err.Error())+1, err.Error()+"\n")
 			return
 		}
+		if err := verifyResponse(resp, checksum); err != nil {
+			_ = req.Body.Close()
+			h.recordRequest(req, http.StatusBadGateway, "")
+			h.recordIncomplete(req, "checksum_mismatch")
+			_, _ = fmt.Fprintf(tlsConn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", len(err.Error())+1, err.Error()+"\n")
+			return
+		}
 		h.recordRequest(req, resp.StatusCode, finalURL(req, resp))
 		prepareMITMResponse(req, resp)
 		tracker := newProxyBodyTracker(resp.Body)
@@ -761,41 +777,98 @@ func (h *proxyHandler) roundTrip(r *http.Request) (*http.Response, error) {
 	return h.transport.RoundTrip(r.WithContext(context.WithoutCancel(r.Context())))
 }
 
-func (h *proxyHandler) check(ctx context.Context, method, rawURL string) (*neturl.URL, error) {
+// check evaluates the request against the policy. It returns the converted
+// URL, if any, and the digest that the response body is pinned to.
+func (h *proxyHandler) check(ctx context.Context, method, rawURL string) (*neturl.URL, digest.Digest, error) {
 	if h.policy == nil {
-		return nil, nil
+		return nil, "", nil
 	}
 	redactedURL := redactURL(rawURL)
 	op := &pb.Op{
 		Op: &pb.Op_Source{
 			Source: &pb.SourceOp{
 				Identifier: redactedURL,
+				Attrs: map[string]string{
+					pb.AttrProxyMethod: method,
+				},
 			},
 		},
 	}
 	if _, err := h.policy.Evaluate(ctx, op); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	source := op.GetSource()
 	target := source.Identifier
-	converted := target != redactedURL || len(source.Attrs) != 0
+	attrs := maps.Clone(source.Attrs)
+	delete(attrs, pb.AttrProxyMethod)
+	var checksum digest.Digest
+	if v, ok := attrs[pb.AttrHTTPChecksum]; ok {
+		delete(attrs, pb.AttrHTTPChecksum)
+		dgst, err := digest.Parse(v)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "invalid checksum pinned for proxy request %q", redactedURL)
+		}
+		checksum = dgst
+	}
+	converted := target != redactedURL || len(attrs) != 0 || checksum != ""
 	if !converted {
-		return nil, nil
+		return nil, "", nil
 	}
 	if method != http.MethodGet {
-		return nil, errors.Errorf("source policy converted proxy request %q, but conversion is only supported for GET", redactedURL)
+		return nil, "", errors.Errorf("source policy converted proxy request %q, but conversion is only supported for GET", redactedURL)
+	}
+	if len(attrs) != 0 {
+		return nil, "", errors.Errorf("source policy converted proxy request %q with attrs, but proxy conversion only supports URL updates and %s", redactedURL, pb.AttrHTTPChecksum)
 	}
-	if len(source.Attrs) != 0 {
-		return nil, errors.Errorf("source policy converted proxy request %q with attrs, but proxy conversion only supports URL updates", redactedURL)
+	if target == redactedURL {
+		return nil, checksum, nil
 	}
 	u, err := neturl.Parse(target)
 	if err != nil {
-		return nil, errors.Wrapf(err, "error parsing converted proxy request URL %q", redactURL(target))
+		return nil, "", errors.Wrapf(err, "error parsing converted proxy request URL %q", redactURL(target))
 	}
 	if !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
-		return nil, errors.Errorf("source policy converted proxy request to unsupported URL %q", redactURL(target))
+		return nil, "", errors.Errorf("source policy converted proxy request to unsupported URL %q", redactURL(target))
 	}
-	return u, nil
+	return u, checksum, nil
+}
+
+// verifyResponse buffers a successful response body pinned by the source
+// policy and checks its digest before anything is sent to the client.
+func verifyResponse(resp *http.Response, expected digest.Digest) error {
+	if expected == "" || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+	f, err := os.CreateTemp("", "buildkit-proxy-")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// the open file keeps the data until the body is closed
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	v := expected.Verifier()
+	n, err := io.Copy(io.MultiWriter(f, v), resp.Body)
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "error reading pinned response")
+	}
+	if !v.Verified() {
+		f.Close()
+		return errors.Errorf("response digest does not match pinned checksum %s", expected)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	resp.Body = f
+	resp.ContentLength = n
+	resp.TransferEncoding = nil
+	resp.Header.Del("Transfer-Encoding")
+	resp.Header.Set("Content-Length", strconv.FormatInt(n, 10))
+	return nil
 }
 
 func newCA() ([]byte, *x509.Certificate, *rsa.PrivateKey, error) {
@@ -32,14 +32,15 @@ type Puller struct {
 	Src          reference.Spec
 	Platform     ocispecs.Platform
 
-	g           flightcontrol.Group[struct{}]
-	resolveErr  error
-	resolveDone bool
-	desc        ocispecs.Descriptor
-	configDesc  ocispecs.Descriptor
-	ref         string
-	layers      []ocispecs.Descriptor
-	nonlayers   []ocispecs.Descriptor
+	g            flightcontrol.Group[struct{}]
+	resolveErr   error
+	resolveDone  bool
+	desc         ocispecs.Descriptor
+	manifestDesc ocispecs.Descriptor
+	configDesc   ocispecs.Descriptor
+	ref          string
+	layers       []ocispecs.Descriptor
+	nonlayers    []ocispecs.Descriptor
 }
 
 var _ content.Provider = &provider{}
@@ -47,10 +48,13 @@ var _ content.Provider = &provider{}
 type PulledManifests struct {
 	Ref              string
 	MainManifestDesc ocispecs.Descriptor
-	ConfigDesc       ocispecs.Descriptor
-	Nonlayers        []ocispecs.Descriptor
-	Descriptors      []ocispecs.Descriptor
-	Provider         func(session.Group) content.Provider
+	// ManifestDesc is the image manifest that was pulled for the platform.
+	// It is the same as MainManifestDesc unless the image is an index.
+	ManifestDesc ocispecs.Descriptor
+	ConfigDesc   ocispecs.Descriptor
+	Nonlayers    []ocispecs.Descriptor
+	Descriptors  []ocispecs.Descriptor
+	Provider     func(session.Group) content.Provider
 }
 
 func (p *Puller) resolve(ctx context.Context, resolver remotes.Resolver) error {
@@ -162,6 +166,8 @@ func (p *Puller) PullManifests(ctx context.Context, getResolver SessionResolver)
 	for _, desc := range metadata {
 		p.nonlayers = append(p.nonlayers, desc)
 		switch desc.MediaType {
+		case images.MediaTypeDockerSchema2Manifest, ocispecs.MediaTypeImageManifest:
+			p.manifestDesc = desc
 		case images.MediaTypeDockerSchema2Config, ocispecs.MediaTypeImageConfig:
 			p.configDesc = desc
 		}
@@ -176,6 +182,7 @@ func (p *Puller) PullManifests(ctx context.Context, getResolver SessionResolver)
 	return &PulledManifests{
 		Ref:              p.ref,
 		MainManifestDesc: p.desc,
+		ManifestDesc:     p.manifestDesc,
 		ConfigDesc:       p.configDesc,
 		Nonlayers:        p.nonlayers,
 		Descriptors:      p.layers,
@@ -46,7 +46,23 @@ func Pusher(ctx context.Context, resolver remotes.Resolver, ref string) (remotes
 	return &pusher{Pusher: p}, nil
 }
 
-func Push(ctx context.Context, sm *session.Manager, sid string, provider content.Provider, manager content.Manager, dgst digest.Digest, ref string, insecure bool, hosts docker.RegistryHosts, byDigest bool, annotations map[digest.Digest]map[string]string) error {
+// Opt configures the uploads of Push. If no option is set, blobs are pushed
+// with the containerd pusher.
+type Opt struct {
+	// Retries is the number of times a failed upload is retried. Zero
+	// disables retries. If unset, uploads are retried until the backoff
+	// reaches the maximum.
+	Retries *int
+	// ChunkSize enables chunked blob uploads. A failed chunked upload is
+	// resumed from the last chunk the registry received.
+	ChunkSize int64
+}
+
+func (opt Opt) isSet() bool {
+	return opt.Retries != nil || opt.ChunkSize > 0
+}
+
+func Push(ctx context.Context, sm *session.Manager, sid string, provider content.Provider, manager content.Manager, dgst digest.Digest, ref string, insecure bool, hosts docker.RegistryHosts, byDigest bool, annotations map[digest.Digest]map[string]string, opt Opt) error {
 	ctx = contentutil.RegisterContentPayloadTypes(ctx)
 	desc := ocispecs.Descriptor{
 		Digest: dgst,
@@ -70,19 +86,7 @@ func Push(ctx context.Context, sm *session.Manager, sid string, provider content
 		ref = r.String()
 	}
 
-	scope := resolver.ScopeType{Push: true}
-	if insecure {
-		insecureTrue := true
-		httpTrue := true
-		hosts = resolver.NewRegistryConfig(map[string]resolverconfig.RegistryConfig{
-			reference.Domain(parsed): {
-				Insecure:  &insecureTrue,
-				PlainHTTP: &httpTrue,
-			},
-		})
-		scope.Insecure = true
-	}
-
+	hosts, scope := pushHosts(parsed, insecure, hosts)
 	resolver := resolver.DefaultPool.GetResolver(hosts, ref, scope, sm, session.NewGroup(sid))
 
 	pusher, err := Pusher(ctx, resolver, ref)
@@ -90,6 +94,19 @@ func Push(ctx context.Context, sm *session.Manager, sid string, provider content
 		return err
 	}
 
+	blobHandler := limited.PushHandler(pusher, provider, ref)
+	if opt.isSet() {
+		registryHosts, err := resolver.HostsFunc(reference.Domain(parsed))
+		if err != nil {
+			return err
+		}
+		uploader, err := newBlobUploader(provider, registryHosts, ref, opt.ChunkSize)
+		if err != nil {
+			return err
+		}
+		blobHandler = limited.Handler(uploader.Push, ref)
+	}
+
 	var m sync.Mutex
 	manifestStack := []ocispecs.Descriptor{}
 
@@ -106,7 +123,13 @@ func Push(ctx context.Context, sm *session.Manager, sid string, provider content
 		}
 	})
 
-	pushHandler := retryhandler.New(limited.PushHandler(pusher, provider, ref), logs.LoggerFromContext(ctx))
+	manifestHandler := limited.PushHandler(pusher, provider, ref)
+	pushHandler := retryhandler.NewWithOpt(func(ctx context.Context, desc ocispecs.Descriptor) ([]ocispecs.Descriptor, error) {
+		if images.IsManifestType(desc.MediaType) || images.IsIndexType(desc.MediaType) {
+			return manifestHandler(ctx, desc)
+		}
+		return blobHandler(ctx, desc)
+	}, logs.LoggerFromContext(ctx), retryhandler.Opt{Retries: opt.Retries})
 	pushUpdateSourceHandler, err := updateDistributionSourceHandler(manager, pushHandler, ref)
 	if err != nil {
 		return err
@@ -147,6 +170,43 @@ func Push(ctx context.Context, sm *session.Manager, sid string, provider content
 	return mfstDone(nil)
 }
 
+// ResolveTag returns the descriptor the tag of ref currently points to in the
+// registry. Nil is returned if the tag does not exist.
+func ResolveTag(ctx context.Context, sm *session.Manager, sid string, ref string, insecure bool, hosts docker.RegistryHosts) (*ocispecs.Descriptor, error) {
+	parsed, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil, err
+	}
+	ref = reference.TagNameOnly(parsed).String()
+
+	hosts, scope := pushHosts(parsed, insecure, hosts)
+	resolver := resolver.DefaultPool.GetResolver(hosts, ref, scope, sm, session.NewGroup(sid))
+	_, desc, err := resolver.Resolve(ctx, ref)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to resolve %s", ref)
+	}
+	return &desc, nil
+}
+
+func pushHosts(parsed reference.Named, insecure bool, hosts docker.RegistryHosts) (docker.RegistryHosts, resolver.ScopeType) {
+	scope := resolver.ScopeType{Push: true}
+	if insecure {
+		insecureTrue := true
+		httpTrue := true
+		hosts = resolver.NewRegistryConfig(map[string]resolverconfig.RegistryConfig{
+			reference.Domain(parsed): {
+				Insecure:  &insecureTrue,
+				PlainHTTP: &httpTrue,
+			},
+		})
+		scope.Insecure = true
+	}
+	return hosts, scope
+}
+
 // TODO: the containerd function for this is filtering too much, that needs to be fixed.
 // For now we just carry this.
 func skipNonDistributableBlobs(f images.HandlerFunc) images.HandlerFunc {
@@ -0,0 +1,430 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/remotes/docker"
+	remoteserrors "github.com/containerd/containerd/v2/core/remotes/errors"
+	"github.com/containerd/containerd/v2/pkg/reference"
+	"github.com/moby/buildkit/util/bklog"
+	"github.com/moby/buildkit/util/flightcontrol"
+	"github.com/moby/buildkit/util/progress"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+const distributionSourceLabelPrefix = "containerd.io/distribution.source."
+
+// uploads deduplicates concurrent uploads of the same blob to a repository.
+var uploads flightcontrol.Group[struct{}]
+
+// blobUploader uploads blobs to a registry. Unlike the containerd pusher, it
+// tries every repository the blob is known to exist in on the same registry
+// for a cross-repository mount, and it can upload blobs in chunks. The upload
+// session of a failed chunked upload is kept, so that a retry of the handler
+// continues from the last offset the registry received instead of starting
+// from scratch.
+type blobUploader struct {
+	provider  content.Provider
+	host      docker.RegistryHost
+	refspec   reference.Spec
+	repo      string
+	chunkSize int64
+
+	mu       sync.Mutex
+	sessions map[digest.Digest]*uploadSession
+}
+
+type uploadSession struct {
+	host     docker.RegistryHost
+	location *url.URL
+}
+
+func newBlobUploader(provider content.Provider, hosts []docker.RegistryHost, ref string, chunkSize int64) (*blobUploader, error) {
+	refspec, err := reference.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	var host *docker.RegistryHost
+	for _, h := range hosts {
+		if h.Capabilities.Has(docker.HostCapabilityPush) {
+			host = &h
+			break
+		}
+	}
+	if host == nil {
+		return nil, errors.Errorf("no push capable host for %s", refspec.Hostname())
+	}
+	return &blobUploader{
+		provider:  provider,
+		host:      *host,
+		refspec:   refspec,
+		repo:      strings.TrimPrefix(refspec.Locator, refspec.Hostname()+"/"),
+		chunkSize: chunkSize,
+		sessions:  map[digest.Digest]*uploadSession{},
+	}, nil
+}
+
+// Push uploads the blob of desc if it does not exist in the repository yet.
+// Concurrent pushes of the same blob to the same repository share the upload.
+func (u *blobUploader) Push(ctx context.Context, desc ocispecs.Descriptor) ([]ocispecs.Descriptor, error) {
+	ctx, err := docker.ContextWithRepositoryScope(ctx, u.refspec, true)
+	if err != nil {
+		return nil, err
+	}
+	key := u.host.Host + "/" + u.repo + "@" + desc.Digest.String()
+	_, err = uploads.Do(ctx, key, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, u.push(ctx, desc)
+	})
+	return nil, err
+}
+
+func (u *blobUploader) push(ctx context.Context, desc ocispecs.Descriptor) error {
+	pw, _, _ := progress.NewFromContext(ctx)
+	defer pw.Close()
+	started := time.Now()
+	st := progress.Status{
+		Total:   int(desc.Size),
+		Started: &started,
+	}
+	done := func() {
+		now := time.Now()
+		st.Current = st.Total
+		st.Completed = &now
+		pw.Write(desc.Digest.String(), st)
+	}
+
+	var offset int64
+	var err error
+	u.mu.Lock()
+	s := u.sessions[desc.Digest]
+	u.mu.Unlock()
+	if s != nil {
+		offset, err = u.status(ctx, s)
+		if err != nil {
+			var statusErr remoteserrors.ErrUnexpectedStatus
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+				return err
+			}
+			// the upload session has expired
+			bklog.G(ctx).Debugf("restarting upload of %s: %v", desc.Digest, err)
+			u.setSession(desc.Digest, nil)
+			s, offset = nil, 0
+		}
+	}
+	if s == nil {
+		exists, err := u.exists(ctx, desc)
+		if err != nil {
+			return err
+		}
+		if exists {
+			done()
+			return nil
+		}
+		if s, err = u.mount(ctx, desc); err != nil {
+			return err
+		}
+		if s == nil {
+			done()
+			return nil
+		}
+		if u.chunkSize > 0 {
+			u.setSession(desc.Digest, s)
+		}
+	}
+
+	st.Current = int(offset)
+	pw.Write(desc.Digest.String(), st)
+	ra, err := u.provider.ReaderAt(ctx, desc)
+	if err != nil {
+		return err
+	}
+	defer ra.Close()
+	pra := &progressReaderAt{ReaderAt: ra, pw: pw, id: desc.Digest.String(), st: st}
+	if err := u.upload(ctx, s, pra, desc, offset); err != nil {
+		return err
+	}
+	u.setSession(desc.Digest, nil)
+	done()
+	return nil
+}
+
+func (u *blobUploader) setSession(dgst digest.Digest, s *uploadSession) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if s == nil {
+		delete(u.sessions, dgst)
+	} else {
+		u.sessions[dgst] = s
+	}
+}
+
+func (u *blobUploader) exists(ctx context.Context, desc ocispecs.Descriptor) (bool, error) {
+	resp, err := u.do(ctx, u.host, http.MethodHead, u.url(u.host, "blobs", desc.Digest.String()), nil, 0, nil)
+	if err != nil {
+		return false, err
+	}
+	if err := closeResponse(resp, http.StatusOK, http.StatusNotFound); err != nil {
+		return false, err
+	}
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// mount starts an upload session for desc. A cross-repository mount is tried
+// for each repository of the distribution source labels of the blob. Nil
+// session is returned if the blob was mounted.
+func (u *blobUploader) mount(ctx context.Context, desc ocispecs.Descriptor) (*uploadSession, error) {
+	candidates := u.mountCandidates(desc)
+	for i, from := range candidates {
+		mctx := docker.ContextWithAppendPullRepositoryScope(ctx, from)
+		q := url.Values{"mount": {desc.Digest.String()}, "from": {from}}
+		resp, err := u.do(mctx, u.host, http.MethodPost, u.url(u.host, "blobs", "uploads/")+"?"+q.Encode(), nil, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := closeResponse(resp, http.StatusCreated, http.StatusAccepted, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound); err != nil {
+			return nil, err
+		}
+		switch resp.StatusCode {
+		case http.StatusCreated:
+			bklog.G(ctx).Debugf("mounted %s from %s", desc.Digest, from)
+			return nil, nil
+		case http.StatusAccepted:
+			// the registry started a regular upload instead
+			s, err := u.session(u.host, resp)
+			if err != nil || i == len(candidates)-1 {
+				return s, err
+			}
+			u.cancel(ctx, s)
+		default:
+			bklog.G(ctx).Debugf("failed to mount %s from %s: %s", desc.Digest, from, resp.Status)
+		}
+	}
+
+	resp, err := u.do(ctx, u.host, http.MethodPost, u.url(u.host, "blobs", "uploads/"), nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := closeResponse(resp, http.StatusCreated, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusCreated {
+		return nil, nil
+	}
+	return u.session(u.host, resp)
+}
+
+// cancel deletes an upload session that is not used.
+func (u *blobUploader) cancel(ctx context.Context, s *uploadSession) {
+	resp, err := u.do(ctx, s.host, http.MethodDelete, s.location.String(), nil, 0, nil)
+	if err != nil {
+		bklog.G(ctx).Debugf("failed to cancel upload %s: %v", s.location, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+func (u *blobUploader) mountCandidates(desc ocispecs.Descriptor) []string {
+	v, ok := desc.Annotations[distributionSourceLabelPrefix+u.refspec.Hostname()]
+	if !ok {
+		return nil
+	}
+	var repos []string
+	for repo := range strings.SplitSeq(v, ",") {
+		if repo != "" && repo != u.repo {
+			repos = append(repos, repo)
+		}
+	}
+	return repos
+}
+
+// upload sends the blob starting at offset and completes the upload session.
+func (u *blobUploader) upload(ctx context.Context, s *uploadSession, ra io.ReaderAt, desc ocispecs.Descriptor, offset int64) error {
+	if u.chunkSize > 0 {
+		for offset < desc.Size {
+			n := min(u.chunkSize, desc.Size-offset)
+			h := http.Header{}
+			h.Set("Content-Type", "application/octet-stream")
+			h.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+n-1))
+			resp, err := u.do(ctx, s.host, http.MethodPatch, s.location.String(), io.NewSectionReader(ra, offset, n), n, h)
+			if err != nil {
+				return err
+			}
+			if err := closeResponse(resp, http.StatusAccepted); err != nil {
+				return err
+			}
+			if err := s.update(resp); err != nil {
+				return err
+			}
+			offset += n
+		}
+	}
+
+	loc := *s.location
+	q := loc.Query()
+	q.Set("digest", desc.Digest.String())
+	loc.RawQuery = q.Encode()
+	h := http.Header{}
+	h.Set("Content-Type", "application/octet-stream")
+	resp, err := u.do(ctx, s.host, http.MethodPut, loc.String(), io.NewSectionReader(ra, offset, desc.Size-offset), desc.Size-offset, h)
+	if err != nil {
+		return err
+	}
+	return closeResponse(resp, http.StatusCreated, http.StatusOK, http.StatusNoContent)
+}
+
+// status returns the number of bytes of the upload session that the registry
+// has received.
+func (u *blobUploader) status(ctx context.Context, s *uploadSession) (int64, error) {
+	resp, err := u.do(ctx, s.host, http.MethodGet, s.location.String(), nil, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := closeResponse(resp, http.StatusNoContent); err != nil {
+		return 0, err
+	}
+	if err := s.update(resp); err != nil {
+		return 0, err
+	}
+	rng := resp.Header.Get("Range")
+	if rng == "" {
+		return 0, nil
+	}
+	_, end, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, errors.Errorf("invalid upload range %q", rng)
+	}
+	n, err := strconv.ParseInt(end, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid upload range %q", rng)
+	}
+	return n + 1, nil
+}
+
+func (u *blobUploader) session(host docker.RegistryHost, resp *http.Response) (*uploadSession, error) {
+	s := &uploadSession{host: host}
+	if err := s.update(resp); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// update sets the location of the next request of the session from the
+// response of the registry.
+func (s *uploadSession) update(resp *http.Response) error {
+	location := resp.Header.Get("Location")
+	if location == "" {
+		if s.location == nil {
+			return errors.New("missing upload location")
+		}
+		return nil
+	}
+	lurl, err := resp.Request.URL.Parse(location)
+	if err != nil {
+		return errors.Wrapf(err, "unable to parse location %v", location)
+	}
+	if lurl.Host != s.host.Host || lurl.Scheme != s.host.Scheme {
+		// don't send the credentials of the registry to another host
+		s.host.Host = lurl.Host
+		s.host.Scheme = lurl.Scheme
+		s.host.Authorizer = nil
+	}
+	s.location = lurl
+	return nil
+}
+
+// progressReaderAt reports the progress of an upload from the offsets of the
+// blob that have been read.
+type progressReaderAt struct {
+	io.ReaderAt
+	pw progress.Writer
+	id string
+
+	mu      sync.Mutex
+	st      progress.Status
+	written time.Time
+}
+
+func (r *progressReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.ReaderAt.ReadAt(p, off)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if end := int(off) + n; end > r.st.Current {
+		r.st.Current = end
+		if time.Since(r.written) > 100*time.Millisecond {
+			r.written = time.Now()
+			r.pw.Write(r.id, r.st)
+		}
+	}
+	return n, err
+}
+
+// closeResponse closes the body of resp and returns an error if the status
+// code is not one of ok.
+func closeResponse(resp *http.Response, ok ...int) error {
+	defer resp.Body.Close()
+	if slices.Contains(ok, resp.StatusCode) {
+		return nil
+	}
+	return remoteserrors.NewUnexpectedStatusErr(resp)
+}
+
+func (u *blobUploader) url(host docker.RegistryHost, ps ...string) string {
+	return host.Scheme + "://" + host.Host + host.Path + "/" + u.repo + "/" + strings.Join(ps, "/")
+}
+
+// do sends a request to the registry. The request is repeated once if the
+// authorizer accepts new credentials from an unauthorized response.
+func (u *blobUploader) do(ctx context.Context, host docker.RegistryHost, method, target string, body *io.SectionReader, size int64, header http.Header) (*http.Response, error) {
+	for i := 0; ; i++ {
+		req, err := http.NewRequestWithContext(ctx, method, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range host.Header {
+			req.Header[k] = v
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if body != nil && size > 0 {
+			req.Body = io.NopCloser(io.NewSectionReader(body, 0, size))
+			req.ContentLength = size
+			req.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(io.NewSectionReader(body, 0, size)), nil
+			}
+		}
+		if host.Authorizer != nil {
+			if err := host.Authorizer.Authorize(ctx, req); err != nil {
+				return nil, err
+			}
+		}
+		client := host.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || host.Authorizer == nil || i > 0 {
+			return resp, nil
+		}
+		err = host.Authorizer.AddResponses(ctx, []*http.Response{resp})
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
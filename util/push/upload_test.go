@@ -0,0 +1,324 @@
+package push
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/remotes/docker"
+	"github.com/moby/buildkit/util/contentutil"
+	"github.com/moby/buildkit/util/progress"
+	"github.com/moby/buildkit/util/resolver/retryhandler"
+	digest "github.com/opencontainers/go-digest"
+	ocispecs "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestBlobUploaderResume(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	reg := newTestRegistry(t)
+	// the second chunk is cut off halfway
+	reg.failPatch = 2
+
+	provider, desc := testBlob(t, 4096)
+	u, err := newBlobUploader(provider, reg.hosts(), reg.host+"/library/foo:latest", 1024)
+	require.NoError(t, err)
+
+	h := retryhandler.NewWithOpt(u.Push, nil, retryhandler.Opt{Retries: new(2), Backoff: time.Millisecond})
+	_, err = h(ctx, desc)
+	require.NoError(t, err)
+
+	require.True(t, reg.hasBlob("library/foo", desc.Digest))
+	require.Equal(t, desc.Size, reg.received, "upload was not resumed")
+	require.Equal(t, 1, reg.uploads)
+}
+
+func TestBlobUploaderRestartsMonolithic(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	reg := newTestRegistry(t)
+	reg.failPut = 1
+
+	provider, desc := testBlob(t, 4096)
+	u, err := newBlobUploader(provider, reg.hosts(), reg.host+"/library/foo:latest", 0)
+	require.NoError(t, err)
+
+	h := retryhandler.NewWithOpt(u.Push, nil, retryhandler.Opt{Retries: new(2), Backoff: time.Millisecond})
+	_, err = h(ctx, desc)
+	require.NoError(t, err)
+
+	require.True(t, reg.hasBlob("library/foo", desc.Digest))
+	require.Equal(t, 2, reg.uploads)
+
+	// the retries are limited
+	reg.failPut = 3
+	provider, desc = testBlob(t, 1024)
+	u, err = newBlobUploader(provider, reg.hosts(), reg.host+"/library/bar:latest", 0)
+	require.NoError(t, err)
+	h = retryhandler.NewWithOpt(u.Push, nil, retryhandler.Opt{Retries: new(2), Backoff: time.Millisecond})
+	_, err = h(ctx, desc)
+	require.ErrorContains(t, err, "503")
+	// zero disables the retries
+	reg.failPut = 1
+	provider, desc = testBlob(t, 1024)
+	u, err = newBlobUploader(provider, reg.hosts(), reg.host+"/library/baz:latest", 0)
+	require.NoError(t, err)
+	h = retryhandler.NewWithOpt(u.Push, nil, retryhandler.Opt{Retries: new(0), Backoff: time.Millisecond})
+	_, err = h(ctx, desc)
+	require.ErrorContains(t, err, "503")
+	require.False(t, reg.hasBlob("library/baz", desc.Digest))
+}
+
+func TestBlobUploaderMount(t *testing.T) {
+	t.Parallel()
+	ctx := t.Context()
+
+	reg := newTestRegistry(t)
+	provider, desc := testBlob(t, 1024)
+	dt, err := content.ReadBlob(ctx, provider, desc)
+	require.NoError(t, err)
+	reg.addBlob("library/base", dt)
+
+	desc.Annotations = map[string]string{
+		distributionSourceLabelPrefix + reg.host: "library/missing,library/base",
+	}
+	u, err := newBlobUploader(provider, reg.hosts(), reg.host+"/library/foo:latest", 0)
+	require.NoError(t, err)
+	_, err = u.Push(ctx, desc)
+	require.NoError(t, err)
+
+	require.True(t, reg.hasBlob("library/foo", desc.Digest))
+	require.Equal(t, []string{"library/missing", "library/base"}, reg.mounts)
+	require.Equal(t, int64(0), reg.received)
+	require.Equal(t, 1, reg.cancels)
+
+	// existing blobs are not uploaded again
+	reg.mounts = nil
+	_, err = u.Push(ctx, desc)
+	require.NoError(t, err)
+	require.Empty(t, reg.mounts)
+	require.Equal(t, int64(0), reg.received)
+}
+
+func TestBlobUploaderConcurrent(t *testing.T) {
+	t.Parallel()
+
+	reg := newTestRegistry(t)
+	provider, desc := testBlob(t, 4096)
+
+	pr, ctx, done := progress.NewContext(t.Context())
+	eg, ctx := errgroup.WithContext(ctx)
+	for range 4 {
+		u, err := newBlobUploader(provider, reg.hosts(), reg.host+"/library/foo:latest", 256)
+		require.NoError(t, err)
+		eg.Go(func() error {
+			_, err := u.Push(ctx, desc)
+			return err
+		})
+	}
+	require.NoError(t, eg.Wait())
+	done(nil)
+
+	require.True(t, reg.hasBlob("library/foo", desc.Digest))
+	require.Equal(t, 1, reg.uploads)
+	require.Equal(t, desc.Size, reg.received)
+
+	var last *progress.Status
+	for {
+		ps, err := pr.Read(t.Context())
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		for _, p := range ps {
+			if st, ok := p.Sys.(progress.Status); ok && p.ID == desc.Digest.String() {
+				last = &st
+			}
+		}
+	}
+	require.NotNil(t, last)
+	require.Equal(t, int(desc.Size), last.Total)
+	require.Equal(t, last.Total, last.Current)
+	require.NotNil(t, last.Completed)
+}
+
+func testBlob(t *testing.T, size int) (content.Provider, ocispecs.Descriptor) {
+	dt := make([]byte, size)
+	_, err := rand.Read(dt)
+	require.NoError(t, err)
+	desc := ocispecs.Descriptor{
+		MediaType: ocispecs.MediaTypeImageLayer,
+		Digest:    digest.FromBytes(dt),
+		Size:      int64(size),
+	}
+	buf := contentutil.NewBuffer()
+	require.NoError(t, content.WriteBlob(t.Context(), buf, desc.Digest.String(), bytes.NewReader(dt), desc))
+	return buf, desc
+}
+
+// testRegistry implements the blob upload API of the distribution spec with
+// fault injection.
+type testRegistry struct {
+	mu        sync.Mutex
+	host      string
+	client    *http.Client
+	blobs     map[string]map[digest.Digest][]byte
+	sessions  map[string]*bytes.Buffer
+	failPatch int // number of the PATCH request that fails after half of the body
+	failPut   int // number of PUT requests that fail with 503
+	patches   int
+	received  int64
+	uploads   int
+	cancels   int
+	mounts    []string
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{
+		blobs:    map[string]map[digest.Digest][]byte{},
+		sessions: map[string]*bytes.Buffer{},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	r.host = u.Host
+	r.client = srv.Client()
+	return r
+}
+
+func (r *testRegistry) hosts() []docker.RegistryHost {
+	return []docker.RegistryHost{{
+		Client:       r.client,
+		Host:         r.host,
+		Scheme:       "http",
+		Path:         "/v2",
+		Capabilities: docker.HostCapabilityPull | docker.HostCapabilityResolve | docker.HostCapabilityPush,
+	}}
+}
+
+func (r *testRegistry) addBlob(repo string, dt []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.blobs[repo] == nil {
+		r.blobs[repo] = map[digest.Digest][]byte{}
+	}
+	r.blobs[repo][digest.FromBytes(dt)] = dt
+}
+
+func (r *testRegistry) hasBlob(repo string, dgst digest.Digest) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.blobs[repo][dgst]
+	return ok
+}
+
+func (r *testRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	repo, rest, ok := strings.Cut(strings.TrimPrefix(req.URL.Path, "/v2/"), "/blobs/")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case req.Method == http.MethodHead:
+		if _, ok := r.blobs[repo][digest.Digest(rest)]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case req.Method == http.MethodPost && rest == "uploads/":
+		if from := req.URL.Query().Get("from"); from != "" {
+			r.mounts = append(r.mounts, from)
+			dgst := digest.Digest(req.URL.Query().Get("mount"))
+			if dt, ok := r.blobs[from][dgst]; ok {
+				if r.blobs[repo] == nil {
+					r.blobs[repo] = map[digest.Digest][]byte{}
+				}
+				r.blobs[repo][dgst] = dt
+				w.WriteHeader(http.StatusCreated)
+				return
+			}
+		}
+		r.uploads++
+		id := strconv.Itoa(r.uploads)
+		r.sessions[id] = &bytes.Buffer{}
+		w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/"+id)
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasPrefix(rest, "uploads/"):
+		id := strings.TrimPrefix(rest, "uploads/")
+		buf, ok := r.sessions[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Location", req.URL.Path)
+		switch req.Method {
+		case http.MethodGet:
+			if buf.Len() > 0 {
+				w.Header().Set("Range", fmt.Sprintf("0-%d", buf.Len()-1))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			r.cancels++
+			delete(r.sessions, id)
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodPatch:
+			r.patches++
+			if !strings.HasPrefix(req.Header.Get("Content-Range"), strconv.Itoa(buf.Len())+"-") {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			dt, _ := io.ReadAll(req.Body)
+			if r.patches == r.failPatch {
+				dt = dt[:len(dt)/2]
+				buf.Write(dt)
+				r.received += int64(len(dt))
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			buf.Write(dt)
+			r.received += int64(len(dt))
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodPut:
+			dt, _ := io.ReadAll(req.Body)
+			if r.failPut > 0 {
+				r.failPut--
+				delete(r.sessions, id)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			buf.Write(dt)
+			r.received += int64(len(dt))
+			dgst := digest.Digest(req.URL.Query().Get("digest"))
+			if digest.FromBytes(buf.Bytes()) != dgst {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if r.blobs[repo] == nil {
+				r.blobs[repo] = map[digest.Digest][]byte{}
+			}
+			r.blobs[repo][dgst] = buf.Bytes()
+			delete(r.sessions, id)
+			w.WriteHeader(http.StatusCreated)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
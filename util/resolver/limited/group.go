@@ -107,7 +107,11 @@ func (g *Group) WrapFetcher(f remotes.Fetcher, ref string) remotes.Fetcher {
 }
 
 func (g *Group) PushHandler(pusher remotes.Pusher, provider content.Provider, ref string) images.HandlerFunc {
-	ph := remotes.PushHandler(pusher, provider)
+	return g.Handler(remotes.PushHandler(pusher, provider), ref)
+}
+
+// Handler limits the concurrency of the requests of h for the registry of ref.
+func (g *Group) Handler(h images.HandlerFunc, ref string) images.HandlerFunc {
 	req := g.req(ref)
 	return func(ctx context.Context, desc ocispecs.Descriptor) ([]ocispecs.Descriptor, error) {
 		ctx, release, err := req.acquire(ctx, desc)
@@ -115,7 +119,7 @@ func (g *Group) PushHandler(pusher remotes.Pusher, provider content.Provider, re
 			return nil, err
 		}
 		defer release()
-		return ph(ctx, desc)
+		return h(ctx, desc)
 	}
 }
 
@@ -184,6 +188,10 @@ func PushHandler(pusher remotes.Pusher, provider content.Provider, ref string) i
 	return Default.PushHandler(pusher, provider, ref)
 }
 
+func Handler(h images.HandlerFunc, ref string) images.HandlerFunc {
+	return Default.Handler(h, ref)
+}
+
 func domain(ref string) string {
 	if ref != "" {
 		if named, err := reference.ParseNormalizedNamed(ref); err == nil {
@@ -19,9 +19,29 @@ import (
 var MaxRetryBackoff = 8 * time.Second
 
 func New(f images.HandlerFunc, logger func([]byte)) images.HandlerFunc {
+	return NewWithOpt(f, logger, Opt{})
+}
+
+// Opt configures the retries of a handler.
+type Opt struct {
+	// Retries is the maximum number of retries. Zero disables retries. If
+	// unset, the handler is retried until the backoff reaches
+	// MaxRetryBackoff.
+	Retries *int
+	// Backoff is the wait time before the first retry. It is doubled for each
+	// following retry, up to MaxRetryBackoff. Defaults to one second.
+	Backoff time.Duration
+}
+
+// NewWithOpt returns a handler that retries f on temporary network errors and
+// server errors.
+func NewWithOpt(f images.HandlerFunc, logger func([]byte), opt Opt) images.HandlerFunc {
 	return func(ctx context.Context, desc ocispecs.Descriptor) ([]ocispecs.Descriptor, error) {
-		backoff := time.Second
-		for {
+		backoff := opt.Backoff
+		if backoff <= 0 {
+			backoff = time.Second
+		}
+		for retries := 0; ; retries++ {
 			descs, err := f(ctx, desc)
 			if err != nil {
 				select {
@@ -39,14 +59,22 @@ func New(f images.HandlerFunc, logger func([]byte)) images.HandlerFunc {
 				return descs, nil
 			}
 			// backoff logic
-			if backoff >= MaxRetryBackoff {
+			if opt.Retries != nil {
+				if retries >= *opt.Retries {
+					return nil, err
+				}
+			} else if backoff >= MaxRetryBackoff {
 				return nil, err
 			}
 			if logger != nil {
 				logger(fmt.Appendf(nil, "retrying in %v\n", backoff))
 			}
-			time.Sleep(backoff)
-			backoff *= 2
+			select {
+			case <-ctx.Done():
+				return nil, err
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, MaxRetryBackoff)
 		}
 	}
 }
@@ -0,0 +1,797 @@
+// Package squashfs writes SquashFS 4.0 filesystem images.
+//
+// Images are reproducible: directory entries are sorted by name, inodes are
+// numbered in the order they are written and modification times can be clamped
+// to an epoch. Fragments and the NFS export table are not written.
+package squashfs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"io"
+	gofs "io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tonistiigi/fsutil"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+type Compression string
+
+const (
+	CompressionGzip Compression = "gzip"
+	CompressionNone Compression = "uncompressed"
+)
+
+const (
+	DefaultBlockSize = 128 * 1024
+
+	magic          = 0x73717368
+	superSize      = 96
+	metadataSize   = 8192
+	compressorZlib = 1
+	invalidBlock   = math.MaxUint64
+	invalidXattr   = math.MaxUint32
+	invalidFrag    = math.MaxUint32
+	dirCount       = 256
+	maxNameLen     = 256
+
+	flagNoInodeComp = 1 << 0
+	flagNoDataComp  = 1 << 1
+	flagNoFragComp  = 1 << 3
+	flagNoFragments = 1 << 4
+	flagNoXattrComp = 1 << 8
+	flagNoXattrs    = 1 << 9
+	flagNoIDComp    = 1 << 11
+
+	uncompressedMetadata = 1 << 15
+	uncompressedData     = 1 << 24
+)
+
+const (
+	typeDir = iota + 1
+	typeFile
+	typeSymlink
+	typeBlockDev
+	typeCharDev
+	typeFifo
+	typeSocket
+	typeExtDir
+	typeExtFile
+	typeExtSymlink
+	typeExtBlockDev
+	typeExtCharDev
+	typeExtFifo
+	typeExtSocket
+)
+
+var xattrPrefixes = []string{"user.", "trusted.", "security."}
+
+type Opt struct {
+	// BlockSize is the size of the data blocks. It must be a power of two
+	// between 4KiB and 1MiB. Defaults to DefaultBlockSize.
+	BlockSize int
+	// Compression of data and metadata blocks. Defaults to CompressionGzip.
+	Compression Compression
+	// Epoch clamps the modification times of all inodes and sets the creation
+	// time of the image. The current time is used as creation time if unset.
+	// The root directory has the creation time as modification time.
+	Epoch *time.Time
+	// TempDir is used for buffering data blocks. Defaults to os.TempDir.
+	TempDir string
+}
+
+type node struct {
+	name     string
+	path     string
+	stat     *fstypes.Stat
+	parent   *node
+	children []*node
+	link     *node // target of a hardlink
+
+	nlink  uint32
+	ino    uint32
+	ref    uint64
+	xattr  uint32
+	start  uint64
+	blocks []uint32
+}
+
+func (n *node) mode() os.FileMode {
+	return os.FileMode(n.stat.Mode)
+}
+
+// basicType returns the inode type used for the node in directory entries.
+func (n *node) basicType() uint16 {
+	m := n.mode()
+	switch {
+	case m.IsDir():
+		return typeDir
+	case m&os.ModeSymlink != 0:
+		return typeSymlink
+	case m&os.ModeDevice != 0 && m&os.ModeCharDevice != 0:
+		return typeCharDev
+	case m&os.ModeDevice != 0:
+		return typeBlockDev
+	case m&os.ModeNamedPipe != 0:
+		return typeFifo
+	case m&os.ModeSocket != 0:
+		return typeSocket
+	default:
+		return typeFile
+	}
+}
+
+// ValidateBlockSize checks that bs is a valid block size for a SquashFS image.
+func ValidateBlockSize(bs int) error {
+	if bs < 4096 || bs > 1024*1024 || bs&(bs-1) != 0 {
+		return errors.Errorf("invalid block size %d, must be a power of two between 4KiB and 1MiB", bs)
+	}
+	return nil
+}
+
+// Write writes a SquashFS image of fsys to w.
+func Write(ctx context.Context, fsys fsutil.FS, w io.Writer, opt Opt) error {
+	if opt.BlockSize == 0 {
+		opt.BlockSize = DefaultBlockSize
+	}
+	if err := ValidateBlockSize(opt.BlockSize); err != nil {
+		return err
+	}
+	switch opt.Compression {
+	case "":
+		opt.Compression = CompressionGzip
+	case CompressionGzip, CompressionNone:
+	default:
+		return errors.Errorf("unsupported compression %q", opt.Compression)
+	}
+
+	created := time.Now()
+	if opt.Epoch != nil {
+		created = *opt.Epoch
+	}
+
+	root, err := readTree(ctx, fsys, created)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(opt.TempDir, "buildkit-squashfs-")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	iw := &imageWriter{
+		opt:     opt,
+		created: created,
+		fsys:    fsys,
+		data:    tmp,
+		ids:     map[uint32]uint16{},
+		xattrs:  map[string]uint32{},
+		buf:     make([]byte, opt.BlockSize),
+	}
+	iw.inodes = newMetadataWriter(iw.compress)
+	iw.dirs = newMetadataWriter(iw.compress)
+	iw.xattrKV = newMetadataWriter(iw.compress)
+	iw.xattrIDs = newMetadataWriter(iw.compress)
+
+	nodes := postOrder(root, nil)
+	for i, n := range nodes {
+		n.ino = uint32(i + 1)
+	}
+	for _, n := range nodes {
+		if err := ctx.Err(); err != nil {
+			return context.Cause(ctx)
+		}
+		if err := iw.writeData(n); err != nil {
+			return err
+		}
+	}
+	for _, n := range nodes {
+		if err := iw.writeInode(n); err != nil {
+			return err
+		}
+	}
+	return iw.finish(w, root, uint32(len(nodes)))
+}
+
+// readTree reads the filesystem tree. Hardlinked files share the node of the
+// first path.
+func readTree(ctx context.Context, fsys fsutil.FS, created time.Time) (*node, error) {
+	rootStat := &fstypes.Stat{
+		Mode:    uint32(os.ModeDir | 0755),
+		ModTime: created.UnixNano(),
+	}
+	root := &node{stat: rootStat}
+	nodes := map[string]*node{"": root}
+	err := fsys.Walk(ctx, "/", func(p string, entry gofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		stat, ok := fi.Sys().(*fstypes.Stat)
+		if !ok {
+			return errors.Errorf("fileinfo without stat info: %s", p)
+		}
+		p = strings.Trim(filepath.ToSlash(p), "/")
+		if p == "" {
+			root.stat = stat
+			return nil
+		}
+		parent, ok := nodes[path.Dir(p)]
+		if path.Dir(p) == "." {
+			parent, ok = root, true
+		}
+		if !ok || !parent.mode().IsDir() {
+			return errors.Errorf("parent directory of %s not found", p)
+		}
+		name := path.Base(p)
+		if len(name) > maxNameLen {
+			return errors.Errorf("name of %s is longer than %d bytes", p, maxNameLen)
+		}
+		n := &node{name: name, path: p, stat: stat, parent: parent, nlink: 1}
+		if stat.Linkname != "" && n.mode()&os.ModeSymlink == 0 && n.mode().IsRegular() {
+			target, ok := nodes[strings.Trim(filepath.ToSlash(stat.Linkname), "/")]
+			if !ok {
+				return errors.Errorf("hardlink target %s of %s not found", stat.Linkname, p)
+			}
+			if target.link != nil {
+				target = target.link
+			}
+			target.nlink++
+			n.link = target
+		}
+		parent.children = append(parent.children, n)
+		nodes[p] = n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortTree(root)
+	return root, nil
+}
+
+func sortTree(n *node) {
+	slices.SortFunc(n.children, func(a, b *node) int {
+		return strings.Compare(a.name, b.name)
+	})
+	for _, c := range n.children {
+		sortTree(c)
+	}
+}
+
+// postOrder returns the inodes of the tree with children before their
+// directories. Hardlinks are not included.
+func postOrder(n *node, out []*node) []*node {
+	for _, c := range n.children {
+		if c.link == nil {
+			out = postOrder(c, out)
+		}
+	}
+	return append(out, n)
+}
+
+type imageWriter struct {
+	opt     Opt
+	created time.Time
+	fsys    fsutil.FS
+
+	data     *os.File
+	dataSize uint64
+	buf      []byte
+
+	inodes   *metadataWriter
+	dirs     *metadataWriter
+	xattrKV  *metadataWriter
+	xattrIDs *metadataWriter
+
+	ids      map[uint32]uint16
+	idList   []uint32
+	xattrs   map[string]uint32
+	xattrCnt uint32
+}
+
+func (iw *imageWriter) compress(dt []byte) []byte {
+	if iw.opt.Compression == CompressionNone {
+		return nil
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(dt)
+	zw.Close()
+	if buf.Len() >= len(dt) {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// writeData writes the data blocks of a regular file.
+func (iw *imageWriter) writeData(n *node) error {
+	n.start = superSize + iw.dataSize
+	if n.basicType() != typeFile || n.stat.Size == 0 {
+		return nil
+	}
+	rc, err := iw.fsys.Open(n.path)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var size int64
+	for {
+		l, err := io.ReadFull(rc, iw.buf)
+		if l > 0 {
+			size += int64(l)
+			block := iw.buf[:l]
+			if isZero(block) {
+				// sparse block
+				n.blocks = append(n.blocks, 0)
+				continue
+			}
+			out := iw.compress(block)
+			bsize := uint32(len(out))
+			if out == nil {
+				out = block
+				bsize = uint32(l) | uncompressedData
+			}
+			if _, err := iw.data.Write(out); err != nil {
+				return errors.WithStack(err)
+			}
+			iw.dataSize += uint64(len(out))
+			n.blocks = append(n.blocks, bsize)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", n.path)
+		}
+	}
+	if size != n.stat.Size {
+		return errors.Errorf("size of %s changed during export", n.path)
+	}
+	return nil
+}
+
+func isZero(dt []byte) bool {
+	for _, b := range dt {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (iw *imageWriter) id(v uint32) (uint16, error) {
+	if i, ok := iw.ids[v]; ok {
+		return i, nil
+	}
+	if len(iw.idList) == math.MaxUint16+1 {
+		return 0, errors.New("too many unique uids and gids")
+	}
+	i := uint16(len(iw.idList))
+	iw.ids[v] = i
+	iw.idList = append(iw.idList, v)
+	return i, nil
+}
+
+// writeXattrs writes the extended attributes of the node and returns their
+// id. Attributes with namespaces that SquashFS doesn't support are skipped.
+func (iw *imageWriter) writeXattrs(st *fstypes.Stat) uint32 {
+	keys := make([]string, 0, len(st.Xattrs))
+	for k := range st.Xattrs {
+		for _, p := range xattrPrefixes {
+			if strings.HasPrefix(k, p) && len(k) > len(p) {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return invalidXattr
+	}
+	slices.Sort(keys)
+
+	var kv bytes.Buffer
+	var size uint32
+	for _, k := range keys {
+		for i, p := range xattrPrefixes {
+			if name, ok := strings.CutPrefix(k, p); ok {
+				binary.Write(&kv, binary.LittleEndian, uint16(i))
+				binary.Write(&kv, binary.LittleEndian, uint16(len(name)))
+				kv.WriteString(name)
+				break
+			}
+		}
+		v := st.Xattrs[k]
+		binary.Write(&kv, binary.LittleEndian, uint32(len(v)))
+		kv.Write(v)
+		size += uint32(len(k) + 1)
+	}
+	if id, ok := iw.xattrs[kv.String()]; ok {
+		return id
+	}
+
+	ref := iw.xattrKV.ref()
+	iw.xattrKV.Write(kv.Bytes())
+	var entry [16]byte
+	binary.LittleEndian.PutUint64(entry[0:], ref)
+	binary.LittleEndian.PutUint32(entry[8:], uint32(len(keys)))
+	binary.LittleEndian.PutUint32(entry[12:], size)
+	iw.xattrIDs.Write(entry[:])
+
+	id := iw.xattrCnt
+	iw.xattrCnt++
+	iw.xattrs[kv.String()] = id
+	return id
+}
+
+func (iw *imageWriter) mtime(st *fstypes.Stat) uint32 {
+	t := st.ModTime / int64(time.Second)
+	if iw.opt.Epoch != nil && t > iw.opt.Epoch.Unix() {
+		t = iw.opt.Epoch.Unix()
+	}
+	return uint32(min(max(t, 0), math.MaxUint32))
+}
+
+func (iw *imageWriter) writeInode(n *node) error {
+	var listing *dirListing
+	if n.mode().IsDir() {
+		listing = iw.writeDirListing(n)
+	}
+
+	uid, err := iw.id(n.stat.Uid)
+	if err != nil {
+		return err
+	}
+	gid, err := iw.id(n.stat.Gid)
+	if err != nil {
+		return err
+	}
+	n.xattr = iw.writeXattrs(n.stat)
+	hasXattr := n.xattr != invalidXattr
+
+	m := n.mode()
+	perm := uint16(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		perm |= 0o4000
+	}
+	if m&os.ModeSetgid != 0 {
+		perm |= 0o2000
+	}
+	if m&os.ModeSticky != 0 {
+		perm |= 0o1000
+	}
+
+	typ := n.basicType()
+	var body bytes.Buffer
+	le := func(v any) {
+		binary.Write(&body, binary.LittleEndian, v)
+	}
+	switch typ {
+	case typeDir:
+		var nlink uint32 = 2
+		for _, c := range n.children {
+			if c.link == nil && c.mode().IsDir() {
+				nlink++
+			}
+		}
+		size := listing.size + 3
+		if size > math.MaxUint16 || hasXattr {
+			typ = typeExtDir
+			le(nlink)
+			le(size)
+			le(listing.block)
+			le(listing.parent)
+			le(uint16(0)) // index count
+			le(listing.offset)
+			le(n.xattr)
+		} else {
+			le(listing.block)
+			le(nlink)
+			le(uint16(size))
+			le(listing.offset)
+			le(listing.parent)
+		}
+	case typeFile:
+		size := uint64(n.stat.Size)
+		if n.nlink > 1 || hasXattr || size > math.MaxUint32 || n.start > math.MaxUint32 {
+			typ = typeExtFile
+			var sparse uint64
+			for i, b := range n.blocks {
+				if b == 0 {
+					sparse += uint64(min(int64(iw.opt.BlockSize), n.stat.Size-int64(i*iw.opt.BlockSize)))
+				}
+			}
+			le(n.start)
+			le(size)
+			le(sparse)
+			le(n.nlink)
+			le(uint32(invalidFrag))
+			le(uint32(0))
+			le(n.xattr)
+		} else {
+			le(uint32(n.start))
+			le(uint32(invalidFrag))
+			le(uint32(0))
+			le(uint32(size))
+		}
+		le(n.blocks)
+	case typeSymlink:
+		le(n.nlink)
+		le(uint32(len(n.stat.Linkname)))
+		body.WriteString(n.stat.Linkname)
+		if hasXattr {
+			typ = typeExtSymlink
+			le(n.xattr)
+		}
+	case typeBlockDev, typeCharDev:
+		major, minor := uint32(n.stat.Devmajor), uint32(n.stat.Devminor)
+		le(n.nlink)
+		le(minor&0xff | major<<8 | (minor&^0xff)<<12)
+		if hasXattr {
+			typ += typeExtDir - typeDir
+			le(n.xattr)
+		}
+	case typeFifo, typeSocket:
+		le(n.nlink)
+		if hasXattr {
+			typ += typeExtDir - typeDir
+			le(n.xattr)
+		}
+	}
+
+	var hdr [16]byte
+	binary.LittleEndian.PutUint16(hdr[0:], typ)
+	binary.LittleEndian.PutUint16(hdr[2:], perm)
+	binary.LittleEndian.PutUint16(hdr[4:], uid)
+	binary.LittleEndian.PutUint16(hdr[6:], gid)
+	binary.LittleEndian.PutUint32(hdr[8:], iw.mtime(n.stat))
+	binary.LittleEndian.PutUint32(hdr[12:], n.ino)
+
+	n.ref = iw.inodes.ref()
+	iw.inodes.Write(hdr[:])
+	iw.inodes.Write(body.Bytes())
+	return nil
+}
+
+type dirListing struct {
+	block  uint32
+	offset uint16
+	size   uint32
+	parent uint32
+}
+
+// writeDirListing writes the directory entries of n. The inodes of all
+// children have been written before.
+func (iw *imageWriter) writeDirListing(n *node) *dirListing {
+	ref := iw.dirs.ref()
+	l := &dirListing{
+		block:  uint32(ref >> 16),
+		offset: uint16(ref),
+		// the root directory is the last inode and points past it
+		parent: n.ino + 1,
+	}
+	if n.parent != nil {
+		l.parent = n.parent.ino
+	}
+
+	var buf bytes.Buffer
+	children := n.children
+	for len(children) > 0 {
+		first := children[0]
+		if first.link != nil {
+			first = first.link
+		}
+		block := uint32(first.ref >> 16)
+		base := first.ino
+		count := 0
+		for count < len(children) && count < dirCount {
+			c := children[count]
+			if c.link != nil {
+				c = c.link
+			}
+			delta := int64(c.ino) - int64(base)
+			if uint32(c.ref>>16) != block || delta < math.MinInt16 || delta > math.MaxInt16 {
+				break
+			}
+			count++
+		}
+		binary.Write(&buf, binary.LittleEndian, uint32(count-1))
+		binary.Write(&buf, binary.LittleEndian, block)
+		binary.Write(&buf, binary.LittleEndian, base)
+		for _, c := range children[:count] {
+			name := c.name
+			if c.link != nil {
+				c = c.link
+			}
+			binary.Write(&buf, binary.LittleEndian, uint16(c.ref&0xffff))
+			binary.Write(&buf, binary.LittleEndian, int16(int64(c.ino)-int64(base)))
+			binary.Write(&buf, binary.LittleEndian, c.basicType())
+			binary.Write(&buf, binary.LittleEndian, uint16(len(name)-1))
+			buf.WriteString(name)
+		}
+		children = children[count:]
+	}
+	l.size = uint32(buf.Len())
+	iw.dirs.Write(buf.Bytes())
+	return l
+}
+
+// finish writes the superblock, the data blocks and the metadata tables.
+func (iw *imageWriter) finish(w io.Writer, root *node, inodes uint32) error {
+	inodeTable := iw.inodes.Bytes()
+	dirTable := iw.dirs.Bytes()
+
+	inodeStart := superSize + iw.dataSize
+	dirStart := inodeStart + uint64(len(inodeTable))
+	idStart := dirStart + uint64(len(dirTable))
+
+	var tables bytes.Buffer
+	ids := newMetadataWriter(iw.compress)
+	for _, id := range iw.idList {
+		binary.Write(ids, binary.LittleEndian, id)
+	}
+	idIndex, idBlocks := ids.index(idStart)
+	tables.Write(idBlocks)
+	idIndexStart := idStart + uint64(len(idBlocks))
+	binary.Write(&tables, binary.LittleEndian, idIndex)
+
+	xattrStart := uint64(invalidBlock)
+	if iw.xattrCnt > 0 {
+		kvStart := idIndexStart + uint64(len(idIndex)*8)
+		kv := iw.xattrKV.Bytes()
+		tables.Write(kv)
+		xidStart := kvStart + uint64(len(kv))
+		xidIndex, xidBlocks := iw.xattrIDs.index(xidStart)
+		tables.Write(xidBlocks)
+		xattrStart = xidStart + uint64(len(xidBlocks))
+		binary.Write(&tables, binary.LittleEndian, kvStart)
+		binary.Write(&tables, binary.LittleEndian, iw.xattrCnt)
+		binary.Write(&tables, binary.LittleEndian, uint32(0))
+		binary.Write(&tables, binary.LittleEndian, xidIndex)
+	}
+	bytesUsed := idStart + uint64(tables.Len())
+
+	var flags uint16 = flagNoFragments
+	if iw.xattrCnt == 0 {
+		flags |= flagNoXattrs
+	}
+	if iw.opt.Compression == CompressionNone {
+		flags |= flagNoInodeComp | flagNoDataComp | flagNoFragComp | flagNoXattrComp | flagNoIDComp
+	}
+	var sb [superSize]byte
+	le := binary.LittleEndian
+	le.PutUint32(sb[0:], magic)
+	le.PutUint32(sb[4:], inodes)
+	le.PutUint32(sb[8:], uint32(min(max(iw.created.Unix(), 0), math.MaxUint32)))
+	le.PutUint32(sb[12:], uint32(iw.opt.BlockSize))
+	le.PutUint32(sb[16:], 0) // fragments
+	le.PutUint16(sb[20:], compressorZlib)
+	le.PutUint16(sb[22:], uint16(bitsLen(iw.opt.BlockSize)))
+	le.PutUint16(sb[24:], flags)
+	le.PutUint16(sb[26:], uint16(len(iw.idList)))
+	le.PutUint16(sb[28:], 4)
+	le.PutUint16(sb[30:], 0)
+	le.PutUint64(sb[32:], root.ref)
+	le.PutUint64(sb[40:], bytesUsed)
+	le.PutUint64(sb[48:], idIndexStart)
+	le.PutUint64(sb[56:], xattrStart)
+	le.PutUint64(sb[64:], inodeStart)
+	le.PutUint64(sb[72:], dirStart)
+	le.PutUint64(sb[80:], idStart) // empty fragment table
+	le.PutUint64(sb[88:], invalidBlock)
+
+	if _, err := w.Write(sb[:]); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := iw.data.Seek(0, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := io.Copy(w, iw.data); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, dt := range [][]byte{inodeTable, dirTable, tables.Bytes()} {
+		if _, err := w.Write(dt); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	// pad the image to 4KiB so that it can be used with loop devices
+	if pad := (4096 - bytesUsed%4096) % 4096; pad > 0 {
+		if _, err := w.Write(make([]byte, pad)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func bitsLen(v int) int {
+	n := 0
+	for v > 1 {
+		v >>= 1
+		n++
+	}
+	return n
+}
+
+// metadataWriter splits data into metadata blocks of 8KiB.
+type metadataWriter struct {
+	compress func([]byte) []byte
+	out      bytes.Buffer
+	cur      []byte
+	starts   []uint64 // offsets of the written blocks
+}
+
+func newMetadataWriter(compress func([]byte) []byte) *metadataWriter {
+	return &metadataWriter{compress: compress}
+}
+
+// ref returns the reference of the current position: the offset of the
+// metadata block relative to the table and the offset inside the block.
+func (m *metadataWriter) ref() uint64 {
+	return uint64(m.out.Len())<<16 | uint64(len(m.cur))
+}
+
+func (m *metadataWriter) Write(dt []byte) (int, error) {
+	n := len(dt)
+	for len(dt) > 0 {
+		l := min(metadataSize-len(m.cur), len(dt))
+		m.cur = append(m.cur, dt[:l]...)
+		dt = dt[l:]
+		if len(m.cur) == metadataSize {
+			m.flush()
+		}
+	}
+	return n, nil
+}
+
+func (m *metadataWriter) flush() {
+	m.starts = append(m.starts, uint64(m.out.Len()))
+	var hdr [2]byte
+	if out := m.compress(m.cur); out != nil {
+		binary.LittleEndian.PutUint16(hdr[:], uint16(len(out)))
+		m.out.Write(hdr[:])
+		m.out.Write(out)
+	} else {
+		binary.LittleEndian.PutUint16(hdr[:], uint16(len(m.cur))|uncompressedMetadata)
+		m.out.Write(hdr[:])
+		m.out.Write(m.cur)
+	}
+	m.cur = m.cur[:0]
+}
+
+// Bytes flushes the last block and returns the table.
+func (m *metadataWriter) Bytes() []byte {
+	if len(m.cur) > 0 {
+		m.flush()
+	}
+	return m.out.Bytes()
+}
+
+// index returns the table and the absolute positions of its blocks if the
+// table is written at start.
+func (m *metadataWriter) index(start uint64) ([]uint64, []byte) {
+	dt := m.Bytes()
+	idx := make([]uint64, len(m.starts))
+	for i, s := range m.starts {
+		idx[i] = start + s
+	}
+	return idx, dt
+}
@@ -0,0 +1,114 @@
+package squashfs
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// TestMount checks that the images are accepted by the squashfs driver of the
+// kernel.
+func TestMount(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("mounting requires root")
+	}
+	if dt, err := os.ReadFile("/proc/filesystems"); err != nil || !bytes.Contains(dt, []byte("\tsquashfs\n")) {
+		t.Skip("squashfs is not supported by the kernel")
+	}
+	t.Parallel()
+
+	fsys, mtime, large, sparse := testTree()
+
+	for _, comp := range []Compression{CompressionGzip, CompressionNone} {
+		t.Run(string(comp), func(t *testing.T) {
+			t.Parallel()
+			tmpdir := t.TempDir()
+			img := filepath.Join(tmpdir, "image.sqfs")
+			f, err := os.Create(img)
+			require.NoError(t, err)
+			err = Write(context.TODO(), fsys, f, Opt{Compression: comp, BlockSize: 4096, TempDir: tmpdir})
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			dir := filepath.Join(tmpdir, "mnt")
+			require.NoError(t, os.Mkdir(dir, 0755))
+			out, err := exec.Command("mount", "-t", "squashfs", "-o", "loop,ro", img, dir).CombinedOutput()
+			if err != nil {
+				t.Skipf("failed to mount image: %v: %s", err, out)
+			}
+			t.Cleanup(func() {
+				require.NoError(t, unix.Unmount(dir, 0))
+			})
+
+			var names []string
+			err = filepath.WalkDir(dir, func(p string, _ os.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				rel, err := filepath.Rel(dir, p)
+				names = append(names, rel)
+				return err
+			})
+			require.NoError(t, err)
+			require.Equal(t, []string{".", "bin", "bin/sh", "bin/sh-link", "bin/shell", "dev", "dev/loop300", "dev/null", "empty", "fifo", "large", "sparse", "zero"}, names)
+
+			fi, err := os.Lstat(filepath.Join(dir, "bin/sh"))
+			require.NoError(t, err)
+			require.Equal(t, os.FileMode(0755)|os.ModeSetuid, fi.Mode())
+			require.Equal(t, mtime.Unix(), fi.ModTime().Unix())
+			st := fi.Sys().(*syscall.Stat_t)
+			require.Equal(t, uint32(1000), st.Uid)
+			require.Equal(t, uint32(1000), st.Gid)
+			require.Equal(t, uint64(2), uint64(st.Nlink))
+			dt, err := os.ReadFile(filepath.Join(dir, "bin/sh"))
+			require.NoError(t, err)
+			require.Equal(t, "#!/bin/sh\n", string(dt))
+
+			fi, err = os.Lstat(filepath.Join(dir, "bin/sh-link"))
+			require.NoError(t, err)
+			require.Equal(t, st.Ino, fi.Sys().(*syscall.Stat_t).Ino)
+
+			link, err := os.Readlink(filepath.Join(dir, "bin/shell"))
+			require.NoError(t, err)
+			require.Equal(t, "sh", link)
+
+			fi, err = os.Lstat(filepath.Join(dir, "dev/null"))
+			require.NoError(t, err)
+			require.Equal(t, os.ModeDevice|os.ModeCharDevice, fi.Mode().Type())
+			require.Equal(t, unix.Mkdev(1, 3), uint64(fi.Sys().(*syscall.Stat_t).Rdev))
+			fi, err = os.Lstat(filepath.Join(dir, "dev/loop300"))
+			require.NoError(t, err)
+			require.Equal(t, os.ModeDevice, fi.Mode().Type())
+			require.Equal(t, unix.Mkdev(7, 300), uint64(fi.Sys().(*syscall.Stat_t).Rdev))
+
+			fi, err = os.Lstat(filepath.Join(dir, "fifo"))
+			require.NoError(t, err)
+			require.Equal(t, os.ModeNamedPipe, fi.Mode().Type())
+			fi, err = os.Lstat(filepath.Join(dir, "empty"))
+			require.NoError(t, err)
+			require.Equal(t, os.ModeDir|0700, fi.Mode())
+
+			dt, err = os.ReadFile(filepath.Join(dir, "large"))
+			require.NoError(t, err)
+			require.Equal(t, large, dt)
+			xattr := make([]byte, 16)
+			n, err := unix.Lgetxattr(filepath.Join(dir, "large"), "user.foo", xattr)
+			require.NoError(t, err)
+			require.Equal(t, "bar", string(xattr[:n]))
+
+			dt, err = os.ReadFile(filepath.Join(dir, "sparse"))
+			require.NoError(t, err)
+			require.Equal(t, sparse, dt)
+			dt, err = os.ReadFile(filepath.Join(dir, "zero"))
+			require.NoError(t, err)
+			require.Empty(t, dt)
+		})
+	}
+}
@@ -0,0 +1,676 @@
+package squashfs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"io"
+	gofs "io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/tonistiigi/fsutil"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	fsys, mtime, large, sparse := testTree()
+
+	for _, comp := range []Compression{CompressionGzip, CompressionNone} {
+		t.Run(string(comp), func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			err := Write(context.TODO(), fsys, &buf, Opt{Compression: comp, BlockSize: 4096, TempDir: t.TempDir()})
+			require.NoError(t, err)
+			require.Zero(t, buf.Len()%4096)
+
+			img, err := readImage(buf.Bytes())
+			require.NoError(t, err)
+
+			require.Equal(t, []string{"/", "/bin", "/bin/sh", "/bin/sh-link", "/bin/shell", "/dev", "/dev/loop300", "/dev/null", "/empty", "/fifo", "/large", "/sparse", "/zero"}, img.paths())
+
+			sh := img.files["/bin/sh"]
+			require.Equal(t, "#!/bin/sh\n", string(sh.data))
+			require.Equal(t, os.FileMode(0755)|os.ModeSetuid, sh.mode)
+			require.Equal(t, uint32(1000), sh.uid)
+			require.Equal(t, uint32(1000), sh.gid)
+			require.Equal(t, uint32(2), sh.nlink)
+			require.Equal(t, uint32(mtime.Unix()), sh.mtime)
+			require.Equal(t, sh.ino, img.files["/bin/sh-link"].ino)
+
+			require.Equal(t, os.ModeSymlink, img.files["/bin/shell"].mode.Type())
+			require.Equal(t, "sh", string(img.files["/bin/shell"].data))
+
+			null := img.files["/dev/null"]
+			require.Equal(t, os.ModeDevice|os.ModeCharDevice, null.mode.Type())
+			require.Equal(t, uint32(1<<8|3), null.rdev)
+			loop := img.files["/dev/loop300"]
+			require.Equal(t, os.ModeDevice, loop.mode.Type())
+			require.Equal(t, uint32(44|7<<8|256<<12), loop.rdev)
+
+			require.Equal(t, os.ModeNamedPipe, img.files["/fifo"].mode.Type())
+			require.Equal(t, os.ModeDir|0700, img.files["/empty"].mode)
+			require.Equal(t, uint32(5), img.files["/"].nlink)
+
+			require.Equal(t, large, img.files["/large"].data)
+			require.Equal(t, map[string]string{"user.foo": "bar", "security.ima": "abc"}, img.files["/large"].xattrs)
+			require.Equal(t, sparse, img.files["/sparse"].data)
+			require.Empty(t, img.files["/zero"].data)
+		})
+	}
+}
+
+// testTree returns a tree with every file type the writer supports, and the
+// mtime and the contents of its large and sparse files.
+func testTree() (fsys *testFS, mtime time.Time, large, sparse []byte) {
+	mtime = time.Unix(1700000000, 0)
+	large = bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	sparse = make([]byte, 3*4096+10)
+	sparse[len(sparse)-1] = 1
+
+	fsys = &testFS{}
+	fsys.add(&fstypes.Stat{Path: "bin", Mode: uint32(os.ModeDir | 0755)}, nil)
+	fsys.add(&fstypes.Stat{Path: "bin/sh", Mode: uint32(0755 | os.ModeSetuid), Uid: 1000, Gid: 1000}, []byte("#!/bin/sh\n"))
+	fsys.add(&fstypes.Stat{Path: "bin/sh-link", Mode: 0755, Linkname: "bin/sh"}, nil)
+	fsys.add(&fstypes.Stat{Path: "bin/shell", Mode: uint32(os.ModeSymlink | 0777), Linkname: "sh"}, nil)
+	fsys.add(&fstypes.Stat{Path: "dev", Mode: uint32(os.ModeDir | 0755)}, nil)
+	fsys.add(&fstypes.Stat{Path: "dev/null", Mode: uint32(os.ModeDevice | os.ModeCharDevice | 0666), Devmajor: 1, Devminor: 3}, nil)
+	fsys.add(&fstypes.Stat{Path: "dev/loop300", Mode: uint32(os.ModeDevice | 0660), Devmajor: 7, Devminor: 300}, nil)
+	fsys.add(&fstypes.Stat{Path: "empty", Mode: uint32(os.ModeDir | 0700)}, nil)
+	fsys.add(&fstypes.Stat{Path: "fifo", Mode: uint32(os.ModeNamedPipe | 0644)}, nil)
+	fsys.add(&fstypes.Stat{Path: "large", Mode: 0644, Xattrs: map[string][]byte{
+		"user.foo":         []byte("bar"),
+		"security.ima":     []byte("abc"),
+		"system.posix_acl": []byte("skipped"),
+	}}, large)
+	fsys.add(&fstypes.Stat{Path: "sparse", Mode: 0644}, sparse)
+	fsys.add(&fstypes.Stat{Path: "zero", Mode: 0644}, nil)
+	for _, e := range fsys.entries {
+		e.ModTime = mtime.UnixNano()
+	}
+
+	return fsys, mtime, large, sparse
+}
+
+// TestUnsquashfs checks that the images are accepted by unsquashfs from
+// squashfs-tools.
+func TestUnsquashfs(t *testing.T) {
+	unsquashfs, err := exec.LookPath("unsquashfs")
+	if err != nil {
+		t.Skip("unsquashfs not found")
+	}
+	t.Parallel()
+
+	fsys, _, large, sparse := testTree()
+
+	for _, comp := range []Compression{CompressionGzip, CompressionNone} {
+		t.Run(string(comp), func(t *testing.T) {
+			t.Parallel()
+			tmpdir := t.TempDir()
+			img := filepath.Join(tmpdir, "image.sqfs")
+			f, err := os.Create(img)
+			require.NoError(t, err)
+			err = Write(context.TODO(), fsys, f, Opt{Compression: comp, BlockSize: 4096, TempDir: tmpdir})
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			out, err := exec.Command(unsquashfs, "-lls", img).CombinedOutput()
+			require.NoError(t, err, string(out))
+			modes := map[string]string{}
+			for line := range strings.Lines(string(out)) {
+				fields := strings.Fields(line)
+				for _, f := range fields[min(1, len(fields)):] {
+					if p, ok := strings.CutPrefix(f, "squashfs-root"); ok {
+						modes["/"+strings.TrimPrefix(p, "/")] = fields[0]
+						break
+					}
+				}
+			}
+			require.Equal(t, map[string]string{
+				"/":            "drwxr-xr-x",
+				"/bin":         "drwxr-xr-x",
+				"/bin/sh":      "-rwsr-xr-x",
+				"/bin/sh-link": "-rwsr-xr-x",
+				"/bin/shell":   "lrwxrwxrwx",
+				"/dev":         "drwxr-xr-x",
+				"/dev/loop300": "brw-rw----",
+				"/dev/null":    "crw-rw-rw-",
+				"/empty":       "drwx------",
+				"/fifo":        "prw-r--r--",
+				"/large":       "-rw-r--r--",
+				"/sparse":      "-rw-r--r--",
+				"/zero":        "-rw-r--r--",
+			}, modes)
+
+			dir := filepath.Join(tmpdir, "out")
+			out, err = exec.Command(unsquashfs, "-no-xattrs", "-d", dir, img, "large", "sparse", "bin/sh").CombinedOutput()
+			require.NoError(t, err, string(out))
+			dt, err := os.ReadFile(filepath.Join(dir, "large"))
+			require.NoError(t, err)
+			require.Equal(t, large, dt)
+			dt, err = os.ReadFile(filepath.Join(dir, "sparse"))
+			require.NoError(t, err)
+			require.Equal(t, sparse, dt)
+			dt, err = os.ReadFile(filepath.Join(dir, "bin/sh"))
+			require.NoError(t, err)
+			require.Equal(t, "#!/bin/sh\n", string(dt))
+		})
+	}
+}
+
+func TestWriteReproducible(t *testing.T) {
+	t.Parallel()
+
+	epoch := time.Unix(1600000000, 0)
+	write := func(mtime time.Time) []byte {
+		fsys := &testFS{}
+		fsys.add(&fstypes.Stat{Path: "a", Mode: uint32(os.ModeDir | 0755), ModTime: mtime.UnixNano()}, nil)
+		fsys.add(&fstypes.Stat{Path: "a/new", Mode: 0644, ModTime: mtime.UnixNano()}, []byte("new"))
+		fsys.add(&fstypes.Stat{Path: "a/old", Mode: 0644, ModTime: time.Unix(1000, 0).UnixNano()}, []byte("old"))
+		var buf bytes.Buffer
+		err := Write(context.TODO(), fsys, &buf, Opt{Epoch: &epoch})
+		require.NoError(t, err)
+		return buf.Bytes()
+	}
+
+	dt := write(time.Now())
+	require.Equal(t, dt, write(time.Now().Add(time.Hour)))
+
+	img, err := readImage(dt)
+	require.NoError(t, err)
+	require.Equal(t, uint32(epoch.Unix()), img.mkfsTime)
+	require.Equal(t, uint32(epoch.Unix()), img.files["/a"].mtime)
+	require.Equal(t, uint32(epoch.Unix()), img.files["/a/new"].mtime)
+	require.Equal(t, uint32(1000), img.files["/a/old"].mtime)
+}
+
+func TestWriteSuperblock(t *testing.T) {
+	t.Parallel()
+
+	fsys := &testFS{}
+	fsys.add(&fstypes.Stat{Path: "a", Mode: 0644, Uid: 1000}, []byte("a"))
+	fsys.add(&fstypes.Stat{Path: "b", Mode: 0644, Gid: 2000}, []byte("b"))
+
+	for _, tc := range []struct {
+		comp  Compression
+		flags uint16
+	}{
+		{CompressionGzip, flagNoFragments | flagNoXattrs},
+		{CompressionNone, flagNoFragments | flagNoXattrs | flagNoInodeComp | flagNoDataComp | flagNoFragComp | flagNoXattrComp | flagNoIDComp},
+	} {
+		t.Run(string(tc.comp), func(t *testing.T) {
+			t.Parallel()
+			start := time.Now().Truncate(time.Second)
+			var buf bytes.Buffer
+			err := Write(context.TODO(), fsys, &buf, Opt{Compression: tc.comp, BlockSize: 8192})
+			require.NoError(t, err)
+			dt := buf.Bytes()
+			le := binary.LittleEndian
+
+			require.Equal(t, uint32(magic), le.Uint32(dt[0:]))
+			require.Equal(t, uint32(3), le.Uint32(dt[4:]), "inode count")
+			require.Equal(t, uint32(8192), le.Uint32(dt[12:]), "block size")
+			require.Equal(t, uint32(0), le.Uint32(dt[16:]), "fragment count")
+			require.Equal(t, uint16(compressorZlib), le.Uint16(dt[20:]))
+			require.Equal(t, uint16(13), le.Uint16(dt[22:]), "block log")
+			require.Equal(t, tc.flags, le.Uint16(dt[24:]))
+			require.Equal(t, uint16(3), le.Uint16(dt[26:]), "id count")
+			require.Equal(t, uint16(4), le.Uint16(dt[28:]), "major version")
+			require.Equal(t, uint16(0), le.Uint16(dt[30:]), "minor version")
+			require.Equal(t, uint64(invalidBlock), le.Uint64(dt[56:]), "xattr table")
+			require.Equal(t, uint64(invalidBlock), le.Uint64(dt[88:]), "export table")
+			bytesUsed := le.Uint64(dt[40:])
+			require.LessOrEqual(t, bytesUsed, uint64(len(dt)))
+			require.Less(t, uint64(len(dt))-bytesUsed, uint64(4096))
+			require.Zero(t, len(dt)%4096)
+
+			img, err := readImage(dt)
+			require.NoError(t, err)
+			require.ElementsMatch(t, []uint32{0, 1000, 2000}, img.ids)
+			require.GreaterOrEqual(t, int64(img.mkfsTime), start.Unix())
+			require.LessOrEqual(t, int64(img.mkfsTime), time.Now().Unix())
+			// the root directory is created with the image
+			require.Equal(t, img.mkfsTime, img.files["/"].mtime)
+			require.Equal(t, os.ModeDir|0755, img.files["/"].mode)
+			require.Equal(t, uint32(2), img.files["/"].nlink)
+			require.Equal(t, uint32(1000), img.files["/a"].uid)
+			require.Equal(t, uint32(2000), img.files["/b"].gid)
+		})
+	}
+}
+
+func TestWriteXattrs(t *testing.T) {
+	t.Parallel()
+
+	xattrs := map[string][]byte{
+		"user.foo":               []byte("bar"),
+		"trusted.overlay.opaque": []byte("y"),
+	}
+	fsys := &testFS{}
+	fsys.add(&fstypes.Stat{Path: "a", Mode: 0644, Xattrs: xattrs}, nil)
+	fsys.add(&fstypes.Stat{Path: "b", Mode: 0644, Xattrs: xattrs}, nil)
+	fsys.add(&fstypes.Stat{Path: "c", Mode: 0644, Xattrs: map[string][]byte{"security.capability": {0, 1, 2}}}, nil)
+	fsys.add(&fstypes.Stat{Path: "d", Mode: 0644, Xattrs: map[string][]byte{"system.posix_acl_access": []byte("skipped")}}, nil)
+	fsys.add(&fstypes.Stat{Path: "e", Mode: 0644}, nil)
+
+	var buf bytes.Buffer
+	err := Write(context.TODO(), fsys, &buf, Opt{})
+	require.NoError(t, err)
+	dt := buf.Bytes()
+	require.Zero(t, binary.LittleEndian.Uint16(dt[24:])&flagNoXattrs)
+
+	img, err := readImage(dt)
+	require.NoError(t, err)
+	// identical sets of xattrs share an entry of the lookup table
+	require.Len(t, img.xattrIDs, 2)
+	require.Equal(t, map[string]string{"user.foo": "bar", "trusted.overlay.opaque": "y"}, img.files["/a"].xattrs)
+	require.Equal(t, img.files["/a"].xattrs, img.files["/b"].xattrs)
+	require.Equal(t, map[string]string{"security.capability": "\x00\x01\x02"}, img.files["/c"].xattrs)
+	require.Empty(t, img.files["/d"].xattrs)
+	require.Empty(t, img.files["/e"].xattrs)
+}
+
+func TestWriteManyEntries(t *testing.T) {
+	t.Parallel()
+
+	fsys := &testFS{}
+	fsys.add(&fstypes.Stat{Path: "d", Mode: uint32(os.ModeDir | 0755)}, nil)
+	for i := range 2000 {
+		name := strings.Repeat("x", 100) + string(rune('a'+i%26)) + strings.Repeat("y", i/26)
+		fsys.add(&fstypes.Stat{Path: "d/" + name, Mode: 0644}, nil)
+	}
+	var buf bytes.Buffer
+	err := Write(context.TODO(), fsys, &buf, Opt{})
+	require.NoError(t, err)
+
+	img, err := readImage(buf.Bytes())
+	require.NoError(t, err)
+	require.Len(t, img.files, 2002)
+}
+
+type testFS struct {
+	entries []*fstypes.Stat
+	data    map[string][]byte
+}
+
+func (fs *testFS) add(st *fstypes.Stat, dt []byte) {
+	if fs.data == nil {
+		fs.data = map[string][]byte{}
+	}
+	st.Size = int64(len(dt))
+	fs.entries = append(fs.entries, st)
+	fs.data[st.Path] = dt
+}
+
+func (fs *testFS) Walk(ctx context.Context, target string, fn gofs.WalkDirFunc) error {
+	for _, st := range fs.entries {
+		if err := fn(st.Path, gofs.FileInfoToDirEntry(&fsutil.StatInfo{Stat: st}), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *testFS) Open(p string) (io.ReadCloser, error) {
+	dt, ok := fs.data[p]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(dt)), nil
+}
+
+// testImage is a minimal SquashFS reader for validating the written images.
+type testImage struct {
+	dt       []byte
+	le       binary.ByteOrder
+	mkfsTime uint32
+	block    uint32
+	inodes   uint64
+	dirs     uint64
+	ids      []uint32
+	xattrKV  uint64
+	xattrIDs [][16]byte
+	files    map[string]*testFile
+}
+
+type testFile struct {
+	mode   os.FileMode
+	uid    uint32
+	gid    uint32
+	mtime  uint32
+	ino    uint32
+	nlink  uint32
+	rdev   uint32
+	data   []byte
+	xattrs map[string]string
+}
+
+func (img *testImage) paths() []string {
+	var out []string
+	for p := range img.files {
+		out = append(out, p)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func readImage(dt []byte) (*testImage, error) {
+	le := binary.LittleEndian
+	if le.Uint32(dt) != magic {
+		return nil, errors.New("invalid magic")
+	}
+	img := &testImage{
+		dt:       dt,
+		le:       le,
+		mkfsTime: le.Uint32(dt[8:]),
+		block:    le.Uint32(dt[12:]),
+		inodes:   le.Uint64(dt[64:]),
+		dirs:     le.Uint64(dt[72:]),
+		files:    map[string]*testFile{},
+	}
+	if le.Uint64(dt[40:]) > uint64(len(dt)) {
+		return nil, errors.New("invalid bytes used")
+	}
+
+	idIndex := le.Uint64(dt[48:])
+	for i := range int(le.Uint16(dt[26:])) {
+		blk := le.Uint64(dt[idIndex+uint64(i/2048*8):])
+		r := img.metadata(blk, uint64(i%2048*4))
+		var id uint32
+		if err := binary.Read(r, le, &id); err != nil {
+			return nil, err
+		}
+		img.ids = append(img.ids, id)
+	}
+
+	if xattrStart := le.Uint64(dt[56:]); xattrStart != invalidBlock {
+		img.xattrKV = le.Uint64(dt[xattrStart:])
+		count := le.Uint32(dt[xattrStart+8:])
+		for i := range int(count) {
+			blk := le.Uint64(dt[xattrStart+16+uint64(i/512*8):])
+			r := img.metadata(blk, uint64(i%512*16))
+			var entry [16]byte
+			if _, err := io.ReadFull(r, entry[:]); err != nil {
+				return nil, err
+			}
+			img.xattrIDs = append(img.xattrIDs, entry)
+		}
+	}
+
+	root := le.Uint64(dt[32:])
+	if err := img.readInode("/", root); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// metadata returns a reader for the metadata blocks starting at the absolute
+// position start.
+func (img *testImage) metadata(start, offset uint64) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		pos := start
+		for pos < uint64(len(img.dt)) {
+			hdr := img.le.Uint16(img.dt[pos:])
+			size := uint64(hdr &^ uncompressedMetadata)
+			block := img.dt[pos+2 : pos+2+size]
+			pos += 2 + size
+			if hdr&uncompressedMetadata == 0 {
+				zr, err := zlib.NewReader(bytes.NewReader(block))
+				if err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+				block, err = io.ReadAll(zr)
+				if err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+			}
+			if offset >= uint64(len(block)) {
+				offset -= uint64(len(block))
+				continue
+			}
+			if _, err := pw.Write(block[offset:]); err != nil {
+				return
+			}
+			offset = 0
+		}
+		pw.Close()
+	}()
+	return pr
+}
+
+func (img *testImage) readInode(p string, ref uint64) error {
+	le := img.le
+	r := img.metadata(img.inodes+ref>>16, ref&0xffff)
+	var hdr struct {
+		Type, Mode, UID, GID uint16
+		Mtime, Ino           uint32
+	}
+	if err := binary.Read(r, le, &hdr); err != nil {
+		return err
+	}
+	f := &testFile{
+		mode:  os.FileMode(hdr.Mode & 0777),
+		uid:   img.ids[hdr.UID],
+		gid:   img.ids[hdr.GID],
+		mtime: hdr.Mtime,
+		ino:   hdr.Ino,
+	}
+	if hdr.Mode&0o4000 != 0 {
+		f.mode |= os.ModeSetuid
+	}
+	if hdr.Mode&0o2000 != 0 {
+		f.mode |= os.ModeSetgid
+	}
+	if hdr.Mode&0o1000 != 0 {
+		f.mode |= os.ModeSticky
+	}
+	img.files[p] = f
+
+	xattr := uint32(invalidXattr)
+	read := func(v ...any) error {
+		for _, v := range v {
+			if err := binary.Read(r, le, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var u16 uint16
+	var u32 uint32
+
+	switch hdr.Type {
+	case typeDir, typeExtDir:
+		f.mode |= os.ModeDir
+		var block, size, parent uint32
+		var offset uint16
+		if hdr.Type == typeDir {
+			if err := read(&block, &f.nlink, &u16, &offset, &parent); err != nil {
+				return err
+			}
+			size = uint32(u16)
+		} else {
+			if err := read(&f.nlink, &size, &block, &parent, &u16, &offset, &xattr); err != nil {
+				return err
+			}
+		}
+		if err := img.readDir(p, uint64(block), offset, size-3); err != nil {
+			return err
+		}
+	case typeFile, typeExtFile:
+		var start, size uint64
+		f.nlink = 1
+		if hdr.Type == typeFile {
+			var start32, size32 uint32
+			if err := read(&start32, &u32, &u32, &size32); err != nil {
+				return err
+			}
+			start, size = uint64(start32), uint64(size32)
+		} else {
+			var sparse uint64
+			if err := read(&start, &size, &sparse, &f.nlink, &u32, &u32, &xattr); err != nil {
+				return err
+			}
+		}
+		nblocks := (size + uint64(img.block) - 1) / uint64(img.block)
+		blocks := make([]uint32, nblocks)
+		if err := read(blocks); err != nil {
+			return err
+		}
+		pos := start
+		for i, b := range blocks {
+			l := min(uint64(img.block), size-uint64(i)*uint64(img.block))
+			if b == 0 {
+				f.data = append(f.data, make([]byte, l)...)
+				continue
+			}
+			bsize := uint64(b &^ uncompressedData)
+			block := img.dt[pos : pos+bsize]
+			pos += bsize
+			if b&uncompressedData == 0 {
+				zr, err := zlib.NewReader(bytes.NewReader(block))
+				if err != nil {
+					return err
+				}
+				if block, err = io.ReadAll(zr); err != nil {
+					return err
+				}
+			}
+			if uint64(len(block)) != l {
+				return errors.Errorf("invalid block size %d for %s", len(block), p)
+			}
+			f.data = append(f.data, block...)
+		}
+	case typeSymlink, typeExtSymlink:
+		f.mode |= os.ModeSymlink
+		if err := read(&f.nlink, &u32); err != nil {
+			return err
+		}
+		f.data = make([]byte, u32)
+		if _, err := io.ReadFull(r, f.data); err != nil {
+			return err
+		}
+		if hdr.Type == typeExtSymlink {
+			if err := read(&xattr); err != nil {
+				return err
+			}
+		}
+	case typeBlockDev, typeCharDev, typeExtBlockDev, typeExtCharDev:
+		f.mode |= os.ModeDevice
+		if hdr.Type == typeCharDev || hdr.Type == typeExtCharDev {
+			f.mode |= os.ModeCharDevice
+		}
+		if err := read(&f.nlink, &f.rdev); err != nil {
+			return err
+		}
+		if hdr.Type >= typeExtDir {
+			if err := read(&xattr); err != nil {
+				return err
+			}
+		}
+	case typeFifo, typeSocket, typeExtFifo, typeExtSocket:
+		f.mode |= os.ModeNamedPipe
+		if hdr.Type == typeSocket || hdr.Type == typeExtSocket {
+			f.mode = f.mode&^os.ModeNamedPipe | os.ModeSocket
+		}
+		if err := read(&f.nlink); err != nil {
+			return err
+		}
+		if hdr.Type >= typeExtDir {
+			if err := read(&xattr); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.Errorf("invalid inode type %d", hdr.Type)
+	}
+
+	if xattr != invalidXattr {
+		entry := img.xattrIDs[xattr]
+		ref := le.Uint64(entry[:])
+		count := le.Uint32(entry[8:])
+		xr := img.metadata(img.xattrKV+ref>>16, ref&0xffff)
+		f.xattrs = map[string]string{}
+		for range count {
+			var typ, nameSize uint16
+			if err := binary.Read(xr, le, &typ); err != nil {
+				return err
+			}
+			if err := binary.Read(xr, le, &nameSize); err != nil {
+				return err
+			}
+			name := make([]byte, nameSize)
+			if _, err := io.ReadFull(xr, name); err != nil {
+				return err
+			}
+			var vsize uint32
+			if err := binary.Read(xr, le, &vsize); err != nil {
+				return err
+			}
+			value := make([]byte, vsize)
+			if _, err := io.ReadFull(xr, value); err != nil {
+				return err
+			}
+			f.xattrs[xattrPrefixes[typ]+string(name)] = string(value)
+		}
+	}
+	return nil
+}
+
+func (img *testImage) readDir(p string, block uint64, offset uint16, size uint32) error {
+	if size == 0 {
+		return nil
+	}
+	r := io.LimitReader(img.metadata(img.dirs+block, uint64(offset)), int64(size))
+	var prev string
+	for {
+		var hdr struct {
+			Count, Start, Ino uint32
+		}
+		if err := binary.Read(r, img.le, &hdr); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		for range hdr.Count + 1 {
+			var entry struct {
+				Offset  uint16
+				Delta   int16
+				Type    uint16
+				NameLen uint16
+			}
+			if err := binary.Read(r, img.le, &entry); err != nil {
+				return err
+			}
+			name := make([]byte, int(entry.NameLen)+1)
+			if _, err := io.ReadFull(r, name); err != nil {
+				return err
+			}
+			if string(name) <= prev {
+				return errors.Errorf("unsorted directory entry %s in %s", name, p)
+			}
+			prev = string(name)
+			ref := uint64(hdr.Start)<<16 | uint64(entry.Offset)
+			if err := img.readInode(path.Join(p, string(name)), ref); err != nil {
+				return err
+			}
+			if ino := img.files[path.Join(p, string(name))].ino; ino != uint32(int64(hdr.Ino)+int64(entry.Delta)) {
+				return errors.Errorf("invalid inode number %d for %s", ino, name)
+			}
+		}
+	}
+}
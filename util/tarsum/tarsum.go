@@ -0,0 +1,137 @@
+// Package tarsum computes the checksums of files in the tarsum format used by
+// the build cache.
+package tarsum
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+// WriteV1Headers writes a tar header to a writer in V1 tarsum format.
+func WriteV1Headers(h *tar.Header, w io.Writer) {
+	for _, elem := range v1TarHeaderSelect(h) {
+		w.Write([]byte(elem[0] + elem[1]))
+	}
+}
+
+// FileHeader returns the tar header of a file that is written with
+// WriteV1Headers before the contents of the file to compute its checksum.
+func FileHeader(stat *fstypes.Stat) (*tar.Header, error) {
+	// Clear the socket and irregular bits since archive/tar.FileInfoHeader does not handle them
+	stat.Mode &^= uint32(os.ModeSocket | os.ModeIrregular)
+
+	fi := &statInfo{stat}
+	hdr, err := tar.FileInfoHeader(fi, stat.Linkname)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to checksum file %s", stat.Path)
+	}
+	hdr.Name = "" // note: empty name is different from current has in docker build. Name is added on recursive directory scan instead
+	hdr.Devmajor = stat.Devmajor
+	hdr.Devminor = stat.Devminor
+	hdr.Uid = int(stat.Uid)
+	hdr.Gid = int(stat.Gid)
+
+	if len(stat.Xattrs) > 0 {
+		hdr.PAXRecords = make(map[string]string, len(stat.Xattrs))
+		for k, v := range stat.Xattrs {
+			hdr.PAXRecords["SCHILY.xattr."+k] = string(v)
+		}
+	}
+	return hdr, nil
+}
+
+type statInfo struct {
+	*fstypes.Stat
+}
+
+func (s *statInfo) Name() string {
+	return filepath.Base(s.Path)
+}
+
+func (s *statInfo) Size() int64 {
+	return s.Stat.Size
+}
+
+func (s *statInfo) Mode() os.FileMode {
+	return os.FileMode(s.Stat.Mode)
+}
+
+func (s *statInfo) ModTime() time.Time {
+	return time.Unix(s.Stat.ModTime/1e9, s.Stat.ModTime%1e9)
+}
+
+func (s *statInfo) IsDir() bool {
+	return s.Mode().IsDir()
+}
+
+func (s *statInfo) Sys() any {
+	return s.Stat
+}
+
+// Functions below are from docker legacy tarsum implementation.
+// There is no valid technical reason to continue using them.
+
+func v0TarHeaderSelect(h *tar.Header) (orderedHeaders [][2]string) {
+	return [][2]string{
+		{"name", h.Name},
+		{"mode", strconv.FormatInt(h.Mode, 10)},
+		{"uid", strconv.Itoa(h.Uid)},
+		{"gid", strconv.Itoa(h.Gid)},
+		{"size", strconv.FormatInt(h.Size, 10)},
+		{"mtime", strconv.FormatInt(h.ModTime.UTC().Unix(), 10)},
+		{"typeflag", string([]byte{h.Typeflag})},
+		{"linkname", h.Linkname},
+		{"uname", h.Uname},
+		{"gname", h.Gname},
+		{"devmajor", strconv.FormatInt(h.Devmajor, 10)},
+		{"devminor", strconv.FormatInt(h.Devminor, 10)},
+	}
+}
+
+func v1TarHeaderSelect(h *tar.Header) (orderedHeaders [][2]string) {
+	pax := h.PAXRecords
+	if len(h.Xattrs) > 0 { //nolint:staticcheck // field deprecated in stdlib
+		if pax == nil {
+			pax = map[string]string{}
+			for k, v := range h.Xattrs { //nolint:staticcheck // field deprecated in stdlib
+				pax["SCHILY.xattr."+k] = v
+			}
+		}
+	}
+
+	// Get extended attributes.
+	xAttrKeys := make([]string, 0, len(h.PAXRecords))
+	for k := range pax {
+		if k, ok := strings.CutPrefix(k, "SCHILY.xattr."); ok {
+			if k == "security.capability" || !strings.HasPrefix(k, "security.") && !strings.HasPrefix(k, "system.") {
+				xAttrKeys = append(xAttrKeys, k)
+			}
+		}
+	}
+	slices.Sort(xAttrKeys)
+
+	// Make the slice with enough capacity to hold the 11 basic headers
+	// we want from the v0 selector plus however many xattrs we have.
+	orderedHeaders = make([][2]string, 0, 11+len(xAttrKeys))
+
+	// Copy all headers from v0 excluding the 'mtime' header (the 5th element).
+	v0headers := v0TarHeaderSelect(h)
+	orderedHeaders = append(orderedHeaders, v0headers[0:5]...)
+	orderedHeaders = append(orderedHeaders, v0headers[6:]...)
+
+	// Finally, append the sorted xattrs.
+	for _, k := range xAttrKeys {
+		orderedHeaders = append(orderedHeaders, [2]string{k, h.PAXRecords["SCHILY.xattr."+k]})
+	}
+
+	return
+}
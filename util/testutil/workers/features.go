@@ -16,6 +16,7 @@ const (
 	FeatureCacheBackendRegistry = "cache_backend_registry"
 	FeatureCacheBackendS3       = "cache_backend_s3"
 	FeatureDirectPush           = "direct_push"
+	FeatureFrontendGraph        = "frontend_graph"
 	FeatureFrontendOutline      = "frontend_outline"
 	FeatureFrontendTargets      = "frontend_targets"
 	FeatureImageExporter        = "image_exporter"
@@ -44,6 +45,7 @@ var features = map[string]struct{}{
 	FeatureCacheBackendRegistry: {},
 	FeatureCacheBackendS3:       {},
 	FeatureDirectPush:           {},
+	FeatureFrontendGraph:        {},
 	FeatureFrontendOutline:      {},
 	FeatureFrontendTargets:      {},
 	FeatureImageExporter:        {},
@@ -27,6 +27,7 @@ import (
 	imageexporter "github.com/moby/buildkit/exporter/containerimage"
 	localexporter "github.com/moby/buildkit/exporter/local"
 	ociexporter "github.com/moby/buildkit/exporter/oci"
+	squashfsexporter "github.com/moby/buildkit/exporter/squashfs"
 	tarexporter "github.com/moby/buildkit/exporter/tar"
 	"github.com/moby/buildkit/frontend"
 	"github.com/moby/buildkit/identity"
@@ -92,6 +93,7 @@ type WorkerOpt struct {
 	MountPoolRoot    string
 	ResourceMonitor  *resources.Monitor
 	CDIManager       *cdidevices.Manager
+	OCILayouts       map[string]string // named OCI layout directories for the oci exporter
 }
 
 // Worker is a local worker instance with dedicated snapshotter, cache, and so on.
@@ -210,10 +212,11 @@ func NewWorker(ctx context.Context, opt WorkerOpt) (*Worker, error) {
 	sm.Register(os)
 
 	iw, err := imageexporter.NewImageWriter(imageexporter.WriterOpt{
-		Snapshotter:  opt.Snapshotter,
-		ContentStore: opt.ContentStore,
-		Applier:      opt.Applier,
-		Differ:       opt.Differ,
+		Snapshotter:   opt.Snapshotter,
+		ContentStore:  opt.ContentStore,
+		Applier:       opt.Applier,
+		Differ:        opt.Differ,
+		CacheAccessor: cm,
 	})
 	if err != nil {
 		return nil, err
@@ -619,12 +622,17 @@ func (w *Worker) Exporter(name string, sm *session.Manager) (exporter.Exporter,
 		return tarexporter.New(tarexporter.Opt{
 			SessionManager: sm,
 		})
+	case client.ExporterSquashFS:
+		return squashfsexporter.New(squashfsexporter.Opt{
+			SessionManager: sm,
+		})
 	case client.ExporterOCI:
 		return ociexporter.New(ociexporter.Opt{
 			SessionManager: sm,
 			ImageWriter:    w.imageWriter,
 			Variant:        ociexporter.VariantOCI,
 			LeaseManager:   w.LeaseManager(),
+			Layouts:        w.OCILayouts,
 		})
 	case client.ExporterDocker:
 		return ociexporter.New(ociexporter.Opt{